@@ -0,0 +1,15 @@
+resource "azurerm_resource_group" "test" {
+  #ts:skip=AC_AZURE_0389 skip resource lock check
+  name     = "testdeploy-${var.random_hex}"
+  location = "northeurope"
+}
+
+module "budget_test" {
+  source               = "../../"
+  budget_name          = var.budget_name
+  budget_scope         = azurerm_resource_group.test.id
+  budget_amount        = var.budget_amount
+  budget_time_grain    = var.budget_time_grain
+  budget_time_period   = var.budget_time_period
+  budget_notifications = var.budget_notifications
+}
@@ -0,0 +1,26 @@
+variable "random_hex" {
+  type = string
+}
+
+variable "budget_name" {
+  type = string
+}
+
+variable "budget_amount" {
+  type = number
+}
+
+variable "budget_time_grain" {
+  type = string
+}
+
+variable "budget_time_period" {
+  type = object({
+    start_date = string
+    end_date   = string
+  })
+}
+
+variable "budget_notifications" {
+  type = any
+}
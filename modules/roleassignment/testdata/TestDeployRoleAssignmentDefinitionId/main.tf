@@ -12,3 +12,11 @@ module "roleassignment_test" {
   role_assignment_definition   = var.role_definition
   role_assignment_scope        = azurerm_resource_group.test.id
 }
+
+output "principal_id" {
+  value = data.azurerm_client_config.current.object_id
+}
+
+output "scope" {
+  value = azurerm_resource_group.test.id
+}
@@ -1,11 +0,0 @@
-variable "subscription_id" {
-  type = string
-}
-
-variable "virtual_networks" {
-  type = any
-}
-
-variable "enable_telemetry" {
-  type = bool
-}
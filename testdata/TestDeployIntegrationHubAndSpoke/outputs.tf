@@ -1,3 +0,0 @@
-output "subscription_id" {
-  value = module.lz_vending.subscription_id
-}
@@ -1,13 +0,0 @@
-terraform {
-  required_version = "~> 1.8"
-  required_providers {
-    azurerm = {
-      source  = "hashicorp/azurerm"
-      version = "~> 4.0"
-    }
-    azapi = {
-      source  = "Azure/azapi"
-      version = "~> 2.4"
-    }
-  }
-}
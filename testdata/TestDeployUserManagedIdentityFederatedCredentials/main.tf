@@ -0,0 +1,23 @@
+module "lz_vending" {
+  source                          = "../../"
+  subscription_id                 = var.subscription_id
+  location                        = "westeurope"
+  disable_telemetry               = true
+  resource_group_creation_enabled = true
+  resource_groups = {
+    rg1 = {
+      name     = "rg-${var.random_hex}"
+      location = "westeurope"
+    }
+  }
+  umi_enabled             = true
+  user_managed_identities = var.user_managed_identities
+}
+
+output "umi_client_ids" {
+  value = module.lz_vending.umi_client_ids
+}
+
+output "umi_principal_ids" {
+  value = module.lz_vending.umi_principal_ids
+}
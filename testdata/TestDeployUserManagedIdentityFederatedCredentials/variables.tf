@@ -0,0 +1,11 @@
+variable "random_hex" {
+  type = string
+}
+
+variable "subscription_id" {
+  type = string
+}
+
+variable "user_managed_identities" {
+  type = any
+}
@@ -0,0 +1,212 @@
+package azureutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/subscription/armsubscription"
+	"github.com/google/uuid"
+)
+
+// defaultAliasPollInterval is the time between reads of an alias while waiting for it to be provisioned.
+const defaultAliasPollInterval = 10 * time.Second
+
+// ErrAliasNotFound is returned, wrapped, by GetAlias when the alias does not exist.
+var ErrAliasNotFound = errors.New("alias not found")
+
+// Alias is a subscription alias.
+type Alias struct {
+	Name string
+	// ProvisioningState is Accepted, Succeeded or Failed.
+	ProvisioningState string
+	// SubscriptionID is the zero UUID until the alias has been provisioned.
+	SubscriptionID uuid.UUID
+}
+
+// GetAlias returns the subscription alias, using the DefaultClientFactory.
+func GetAlias(ctx context.Context, name string) (*Alias, error) {
+	f, err := DefaultClientFactory()
+	if err != nil {
+		return nil, err
+	}
+	return f.GetAlias(ctx, name)
+}
+
+// WaitForAlias waits for the subscription alias to be provisioned, using the DefaultClientFactory.
+func WaitForAlias(ctx context.Context, name string, interval time.Duration) (*Alias, error) {
+	f, err := DefaultClientFactory()
+	if err != nil {
+		return nil, err
+	}
+	return f.WaitForAlias(ctx, name, interval)
+}
+
+// DeleteAlias deletes the subscription alias, using the DefaultClientFactory.
+func DeleteAlias(ctx context.Context, name string) error {
+	f, err := DefaultClientFactory()
+	if err != nil {
+		return err
+	}
+	return f.DeleteAlias(ctx, name)
+}
+
+// NewSubscriptionAlias returns a SubscriptionAlias for the alias name, using the DefaultClientFactory.
+func NewSubscriptionAlias(name string) (*SubscriptionAlias, error) {
+	f, err := DefaultClientFactory()
+	if err != nil {
+		return nil, err
+	}
+	return f.NewSubscriptionAlias(name), nil
+}
+
+// GetAlias returns the subscription alias.
+// If the alias does not exist, the error wraps ErrAliasNotFound.
+func (f *ClientFactory) GetAlias(ctx context.Context, name string) (*Alias, error) {
+	client, err := f.NewAliasClient()
+	if err != nil {
+		return nil, fmt.Errorf("cannot create alias client, %s", err)
+	}
+	resp, err := client.Get(ctx, name, nil)
+	if isNotFound(err) {
+		return nil, fmt.Errorf("cannot get alias %s, %w", name, ErrAliasNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot get alias %s, %v", name, err)
+	}
+	return newAlias(name, &resp.AliasResponse)
+}
+
+// WaitForAlias reads the subscription alias until its provisioning state is Succeeded, and returns it.
+// The alias not being found is retried, as the alias API is eventually consistent.
+// It returns an error if the provisioning state is Failed, or when the context is done.
+func (f *ClientFactory) WaitForAlias(ctx context.Context, name string, interval time.Duration) (*Alias, error) {
+	if interval == 0 {
+		interval = defaultAliasPollInterval
+	}
+	var last error
+	for {
+		a, err := f.GetAlias(ctx, name)
+		if ctx.Err() != nil {
+			if last == nil {
+				last = err
+			}
+			return nil, fmt.Errorf("cannot wait for alias %s, %w: %w", name, ctx.Err(), last)
+		}
+		switch {
+		case err != nil && !errors.Is(err, ErrAliasNotFound):
+			return nil, err
+		case err != nil:
+			last = err
+		case strings.EqualFold(a.ProvisioningState, string(armsubscription.ProvisioningStateSucceeded)):
+			return a, nil
+		case strings.EqualFold(a.ProvisioningState, string(armsubscription.ProvisioningStateFailed)):
+			return a, fmt.Errorf("alias %s provisioning state is %s", name, a.ProvisioningState)
+		default:
+			last = fmt.Errorf("provisioning state is %s", a.ProvisioningState)
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(interval):
+		}
+	}
+}
+
+// DeleteAlias deletes the subscription alias. The subscription is not cancelled.
+// It is not an error if the alias does not exist.
+func (f *ClientFactory) DeleteAlias(ctx context.Context, name string) error {
+	client, err := f.NewAliasClient()
+	if err != nil {
+		return fmt.Errorf("cannot create alias client, %s", err)
+	}
+	if _, err := client.Delete(ctx, name, nil); err != nil && !isNotFound(err) {
+		return fmt.Errorf("cannot delete alias %s, %v", name, err)
+	}
+	return nil
+}
+
+// newAlias converts an alias response.
+func newAlias(name string, resp *armsubscription.AliasResponse) (*Alias, error) {
+	a := &Alias{Name: name}
+	if resp.Properties == nil {
+		return a, nil
+	}
+	if resp.Properties.ProvisioningState != nil {
+		a.ProvisioningState = string(*resp.Properties.ProvisioningState)
+	}
+	if id := stringValue(resp.Properties.SubscriptionID); id != "" {
+		u, err := uuid.Parse(id)
+		if err != nil {
+			return nil, fmt.Errorf("alias %s subscription id %s is not a valid uuid, %v", name, id, err)
+		}
+		a.SubscriptionID = u
+	}
+	return a, nil
+}
+
+// SubscriptionAlias lets a test register the cleanup of a subscription by its alias name before the alias exists,
+// e.g. before the Terraform apply that creates it.
+// The subscription ID is resolved from the alias when Wait or Cleanup is called.
+type SubscriptionAlias struct {
+	Name string
+
+	f  *ClientFactory
+	mu sync.Mutex
+	id uuid.UUID
+}
+
+// NewSubscriptionAlias returns a SubscriptionAlias for the alias name.
+func (f *ClientFactory) NewSubscriptionAlias(name string) *SubscriptionAlias {
+	return &SubscriptionAlias{Name: name, f: f}
+}
+
+// SubscriptionID returns the subscription ID of the alias, or the zero UUID if it has not been resolved.
+func (a *SubscriptionAlias) SubscriptionID() uuid.UUID {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.id
+}
+
+// Wait waits for the alias to be provisioned, and returns it.
+// The subscription ID is remembered for Cleanup, in case the alias is deleted before then, e.g. by a Terraform destroy.
+func (a *SubscriptionAlias) Wait(ctx context.Context, interval time.Duration) (*Alias, error) {
+	alias, err := a.f.WaitForAlias(ctx, a.Name, interval)
+	if alias != nil {
+		a.resolve(alias.SubscriptionID)
+	}
+	return alias, err
+}
+
+// Cleanup cancels the subscription of the alias, deleting its resource groups, and then deletes the alias.
+// The subscription is resolved from the alias if it still exists, otherwise the ID remembered by Wait is used.
+// It does nothing if the alias was never created.
+func (a *SubscriptionAlias) Cleanup(ctx context.Context, t TestingT) error {
+	alias, err := a.f.GetAlias(ctx, a.Name)
+	switch {
+	case err == nil:
+		a.resolve(alias.SubscriptionID)
+	case !errors.Is(err, ErrAliasNotFound):
+		return err
+	}
+	id := a.SubscriptionID()
+	if id == uuid.Nil {
+		t.Logf("alias %s does not exist, nothing to clean up", a.Name)
+		return nil
+	}
+	if err := a.f.CancelSubscription(ctx, t, &id); err != nil {
+		return err
+	}
+	return a.f.DeleteAlias(ctx, a.Name)
+}
+
+func (a *SubscriptionAlias) resolve(id uuid.UUID) {
+	if id == uuid.Nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.id = id
+}
@@ -0,0 +1,114 @@
+package azureutils
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Azure/terraform-azurerm-lz-vending/tests/fakearm"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestGetAlias tests that the provisioning state and subscription ID are returned,
+// and that a missing alias wraps ErrAliasNotFound.
+func TestGetAlias(t *testing.T) {
+	f, srv := newFakeClientFactory(t, nil)
+	id := uuid.New()
+	srv.AddAlias(fakearm.Alias{Name: "testdeploy-alias", SubscriptionID: id})
+
+	a, err := f.GetAlias(context.Background(), "testdeploy-alias")
+	require.NoError(t, err)
+	assert.Equal(t, &Alias{Name: "testdeploy-alias", ProvisioningState: "Succeeded", SubscriptionID: id}, a)
+
+	_, err = f.GetAlias(context.Background(), "testdeploy-missing")
+	assert.ErrorIs(t, err, ErrAliasNotFound)
+}
+
+// TestWaitForAlias tests that the wait polls until the alias has succeeded.
+func TestWaitForAlias(t *testing.T) {
+	f, srv := newFakeClientFactory(t, nil)
+	id := uuid.New()
+	srv.AddAlias(fakearm.Alias{Name: "testdeploy-alias", SubscriptionID: id, ProvisioningState: "Accepted"})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for srv.RequestCount(http.MethodGet, "/aliases/testdeploy-alias") < 2 {
+			time.Sleep(time.Millisecond)
+		}
+		srv.AddAlias(fakearm.Alias{Name: "testdeploy-alias", SubscriptionID: id})
+	}()
+
+	a, err := f.WaitForAlias(context.Background(), "testdeploy-alias", time.Millisecond)
+	<-done
+	require.NoError(t, err)
+	assert.Equal(t, id, a.SubscriptionID)
+	assert.GreaterOrEqual(t, srv.RequestCount(http.MethodGet, "/aliases/testdeploy-alias"), 3)
+}
+
+// TestWaitForAliasFailed tests that a failed alias is reported without waiting for the context.
+func TestWaitForAliasFailed(t *testing.T) {
+	f, srv := newFakeClientFactory(t, nil)
+	srv.AddAlias(fakearm.Alias{Name: "testdeploy-alias", ProvisioningState: "Failed"})
+
+	_, err := f.WaitForAlias(context.Background(), "testdeploy-alias", time.Millisecond)
+	assert.ErrorContains(t, err, "provisioning state is Failed")
+}
+
+// TestWaitForAliasNotFound tests that a missing alias is retried until the context is done.
+func TestWaitForAliasNotFound(t *testing.T) {
+	f, _ := newFakeClientFactory(t, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err := f.WaitForAlias(ctx, "testdeploy-alias", time.Millisecond)
+	assert.ErrorIs(t, err, ErrAliasNotFound)
+	assert.ErrorContains(t, err, context.DeadlineExceeded.Error())
+}
+
+// TestSubscriptionAliasCleanup tests that the subscription is cancelled and the alias deleted,
+// including when the alias was deleted after Wait resolved the subscription.
+func TestSubscriptionAliasCleanup(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("alias exists", func(t *testing.T) {
+		f, srv := newFakeClientFactory(t, nil)
+		id := uuid.New()
+		srv.AddSubscription(fakearm.Subscription{ID: id})
+		srv.AddAlias(fakearm.Alias{Name: "testdeploy-alias", SubscriptionID: id})
+
+		a := f.NewSubscriptionAlias("testdeploy-alias")
+		require.NoError(t, a.Cleanup(ctx, t))
+		assert.Equal(t, id, a.SubscriptionID())
+		sub, _ := srv.Subscription(id)
+		assert.Equal(t, fakearm.SubscriptionStateWarned, sub.State)
+		_, ok := srv.Alias("testdeploy-alias")
+		assert.False(t, ok)
+	})
+
+	t.Run("alias deleted by destroy", func(t *testing.T) {
+		f, srv := newFakeClientFactory(t, nil)
+		id := uuid.New()
+		srv.AddSubscription(fakearm.Subscription{ID: id})
+		srv.AddAlias(fakearm.Alias{Name: "testdeploy-alias", SubscriptionID: id})
+
+		a := f.NewSubscriptionAlias("testdeploy-alias")
+		_, err := a.Wait(ctx, time.Millisecond)
+		require.NoError(t, err)
+		require.NoError(t, f.DeleteAlias(ctx, "testdeploy-alias"))
+
+		require.NoError(t, a.Cleanup(ctx, t))
+		sub, _ := srv.Subscription(id)
+		assert.Equal(t, fakearm.SubscriptionStateWarned, sub.State)
+	})
+
+	t.Run("alias never created", func(t *testing.T) {
+		f, srv := newFakeClientFactory(t, nil)
+		a := f.NewSubscriptionAlias("testdeploy-alias")
+		require.NoError(t, a.Cleanup(ctx, t))
+		assert.Zero(t, srv.RequestCount(http.MethodPost, "/cancel"))
+	})
+}
@@ -0,0 +1,93 @@
+package azureutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+)
+
+// armList is the body of an ARM list response.
+type armList[T any] struct {
+	Value    []T    `json:"value"`
+	NextLink string `json:"nextLink"`
+}
+
+// armResource contains the common properties of an ARM resource.
+type armResource struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// doARM sends a request to Azure Resource Manager using the factory's credential and pipeline.
+// It is used for APIs that do not have an SDK client in this module.
+// The path is relative to the ARM endpoint, or a full URL such as a nextLink.
+// If body is not nil it is sent as JSON, and if out is not nil the response is unmarshalled into it.
+// Responses other than 200, 201, 202 and 204 are returned as an *azcore.ResponseError.
+func (f *ClientFactory) doARM(ctx context.Context, method, path, apiVersion string, body, out any) error {
+	client, err := arm.NewClient("azureutils", "v0.0.0", f.cred, f.options)
+	if err != nil {
+		return fmt.Errorf("failed to create ARM client: %v", err)
+	}
+	u, err := url.Parse(path)
+	if err != nil {
+		return fmt.Errorf("cannot parse ARM path %q, %v", path, err)
+	}
+	endpoint := path
+	if !u.IsAbs() {
+		endpoint = runtime.JoinPaths(client.Endpoint(), path)
+	}
+	req, err := runtime.NewRequest(ctx, method, endpoint)
+	if err != nil {
+		return err
+	}
+	if apiVersion != "" {
+		q := req.Raw().URL.Query()
+		q.Set("api-version", apiVersion)
+		req.Raw().URL.RawQuery = q.Encode()
+	}
+	req.Raw().Header.Set("Accept", "application/json")
+	if body != nil {
+		if err := runtime.MarshalAsJSON(req, body); err != nil {
+			return err
+		}
+	}
+	resp, err := client.Pipeline().Do(req)
+	if err != nil {
+		return err
+	}
+	if !runtime.HasStatusCode(resp, http.StatusOK, http.StatusCreated, http.StatusAccepted, http.StatusNoContent) {
+		return runtime.NewResponseError(resp)
+	}
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	return runtime.UnmarshalAsJSON(resp, out)
+}
+
+// listARM returns every item of a paged ARM list, following nextLink.
+func listARM[T any](ctx context.Context, f *ClientFactory, path, apiVersion string) ([]T, error) {
+	var items []T
+	for path != "" {
+		var page armList[T]
+		if err := f.doARM(ctx, http.MethodGet, path, apiVersion, nil, &page); err != nil {
+			return nil, err
+		}
+		items = append(items, page.Value...)
+		// The nextLink already contains the api-version.
+		path, apiVersion = page.NextLink, ""
+	}
+	return items, nil
+}
+
+// isNotFound reports whether the error is ARM returning not found for the resource, or its parent.
+func isNotFound(err error) bool {
+	var respErr *azcore.ResponseError
+	return errors.As(err, &respErr) && respErr.StatusCode == http.StatusNotFound
+}
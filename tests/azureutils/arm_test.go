@@ -0,0 +1,37 @@
+package azureutils
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestListARMNextLink tests that an absolute nextLink is followed as it is, whatever its scheme,
+// rather than being joined to the ARM endpoint.
+func TestListARMNextLink(t *testing.T) {
+	var paged *httptest.Server
+	paged = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/page1":
+			fmt.Fprintf(w, `{"value": [{"name": "a"}], "nextLink": "%s/page2?api-version=2022-04-01"}`, paged.URL)
+		case "/page2":
+			fmt.Fprint(w, `{"value": [{"name": "b"}]}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(paged.Close)
+
+	f, srv := newFakeClientFactory(t, nil)
+	f.options.InsecureAllowCredentialWithHTTP = true
+	items, err := listARM[armResource](context.Background(), f, paged.URL+"/page1", "2022-04-01")
+	require.NoError(t, err)
+	assert.Equal(t, []armResource{{Name: "a"}, {Name: "b"}}, items)
+	assert.Empty(t, srv.Requests(), "no request should be sent to the ARM endpoint")
+}
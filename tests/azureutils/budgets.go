@@ -0,0 +1,244 @@
+package azureutils
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// budgetsAPIVersion is the Cost Management budgets API version, the same as used by the budget module.
+	budgetsAPIVersion = "2021-10-01"
+	// budgetsPath is the path of the budgets below a scope.
+	budgetsPath = "/providers/Microsoft.Consumption/budgets"
+	// budgetDateFormat is the format of the budget time period dates.
+	budgetDateFormat = "2006-01-02T15:04:05Z"
+)
+
+// Budget is a budget that a verifier expects to find in Azure.
+type Budget struct {
+	// Key identifies the entry of the input variable, e.g. the budgets map key.
+	Key       string
+	Scope     string
+	Name      string
+	Amount    float64
+	TimeGrain string
+	// StartDate and EndDate are in the yyyy-MM-ddTHH:mm:ssZ format of the module input.
+	StartDate     string
+	EndDate       string
+	Notifications map[string]BudgetNotification
+}
+
+// BudgetNotification is a notification of a budget, keyed by its name in Budget.
+type BudgetNotification struct {
+	Enabled       bool
+	Operator      string
+	Threshold     float64
+	ThresholdType string
+	ContactEmails []string
+	ContactRoles  []string
+	ContactGroups []string
+	Locale        string
+}
+
+type budget struct {
+	armResource
+	Properties struct {
+		Amount     float64 `json:"amount"`
+		TimeGrain  string  `json:"timeGrain"`
+		TimePeriod struct {
+			StartDate string `json:"startDate"`
+			EndDate   string `json:"endDate"`
+		} `json:"timePeriod"`
+		Notifications map[string]budgetNotification `json:"notifications"`
+	} `json:"properties"`
+}
+
+type budgetNotification struct {
+	Enabled       bool     `json:"enabled"`
+	Operator      string   `json:"operator"`
+	Threshold     float64  `json:"threshold"`
+	ThresholdType string   `json:"thresholdType"`
+	ContactEmails []string `json:"contactEmails"`
+	ContactRoles  []string `json:"contactRoles"`
+	ContactGroups []string `json:"contactGroups"`
+	Locale        string   `json:"locale"`
+}
+
+// BudgetStartDate returns the first day of the month of t, in the format of the budget time_period_start input.
+// Azure does not create a budget that starts before the current month,
+// so deployment tests should use BudgetStartDate(time.Now()) rather than a fixed date.
+func BudgetStartDate(t time.Time) string {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC).Format(budgetDateFormat)
+}
+
+// ExpectedBudgets returns the budgets configured by the root module's budgets input variable.
+// The resourceGroups are the root module's resource_groups input variable,
+// and are used to find the scope of entries with a resource_group_key.
+func ExpectedBudgets(subID uuid.UUID, budgets, resourceGroups map[string]map[string]any) ([]Budget, error) {
+	keys := sortedKeys(budgets)
+	out := make([]Budget, 0, len(keys))
+	for _, k := range keys {
+		in := budgets[k]
+		scope := "/subscriptions/" + subID.String()
+		if rgKey, _ := in["resource_group_key"].(string); rgKey != "" {
+			rg, ok := resourceGroups[rgKey]
+			if !ok {
+				return nil, fmt.Errorf("budget %s has resource_group_key %s, which is not in resource_groups", k, rgKey)
+			}
+			scope += fmt.Sprintf("/resourceGroups/%s", rg["name"])
+		} else if rel, _ := in["relative_scope"].(string); rel != "" {
+			scope += rel
+		}
+		b := Budget{
+			Key:           k,
+			Scope:         scope,
+			Notifications: make(map[string]BudgetNotification),
+		}
+		b.Name, _ = in["name"].(string)
+		b.Amount, _ = floatValue(in["amount"])
+		b.TimeGrain, _ = in["time_grain"].(string)
+		b.StartDate, _ = in["time_period_start"].(string)
+		b.EndDate, _ = in["time_period_end"].(string)
+		notifications := mapOfMaps(in["notifications"])
+		for _, nk := range sortedKeys(notifications) {
+			b.Notifications[nk] = expectedBudgetNotification(notifications[nk])
+		}
+		out = append(out, b)
+	}
+	return out, nil
+}
+
+// expectedBudgetNotification returns the notification for an entry of a budget's notifications,
+// with the defaults of the input variable.
+func expectedBudgetNotification(in map[string]any) BudgetNotification {
+	n := BudgetNotification{
+		Enabled:       boolValue(in["enabled"], false),
+		ThresholdType: "Actual",
+		ContactEmails: stringSlice(in["contact_emails"]),
+		ContactRoles:  stringSlice(in["contact_roles"]),
+		ContactGroups: stringSlice(in["contact_groups"]),
+		Locale:        "en-us",
+	}
+	n.Operator, _ = in["operator"].(string)
+	n.Threshold, _ = floatValue(in["threshold"])
+	if tt, _ := in["threshold_type"].(string); tt != "" {
+		n.ThresholdType = tt
+	}
+	if l, _ := in["locale"].(string); l != "" {
+		n.Locale = l
+	}
+	return n
+}
+
+// VerifyBudgets compares the budgets in Azure with the expected budgets,
+// using the DefaultClientFactory.
+func VerifyBudgets(ctx context.Context, expected []Budget) (Diff, error) {
+	f, err := DefaultClientFactory()
+	if err != nil {
+		return nil, err
+	}
+	return f.VerifyBudgets(ctx, expected)
+}
+
+// VerifyBudgets reads the expected budgets back from Cost Management and compares their amount, time grain,
+// time period and scope, and the operator, threshold, threshold type, contacts and locale of each notification.
+// Dates are compared as instants, and contacts as sets.
+// Budgets that are not found are reported with a nil Actual,
+// as are expected notifications, and unexpected notifications are reported with a nil Expected.
+func (f *ClientFactory) VerifyBudgets(ctx context.Context, expected []Budget) (Diff, error) {
+	diff := Diff{}
+	for _, want := range expected {
+		id := want.Scope + budgetsPath + "/" + want.Name
+		var got budget
+		if err := f.doARM(ctx, http.MethodGet, id, budgetsAPIVersion, nil, &got); err != nil {
+			if isNotFound(err) {
+				diff.compare(id, "budget", want.Key, nil)
+				continue
+			}
+			return nil, fmt.Errorf("cannot get budget %s, %v", id, err)
+		}
+		diff = append(diff, compareBudget(id, want, got)...)
+	}
+	return diff, nil
+}
+
+// compareBudget compares a budget returned by Azure with the expected budget.
+func compareBudget(id string, want Budget, got budget) Diff {
+	diff := Diff{}
+	gotScope := got.ID
+	if i := strings.Index(strings.ToLower(gotScope), strings.ToLower(budgetsPath)); i >= 0 {
+		gotScope = gotScope[:i]
+	}
+	diff.compare(id, "scope", strings.ToLower(want.Scope), strings.ToLower(gotScope))
+	diff.compare(id, "amount", want.Amount, got.Properties.Amount)
+	diff.compare(id, "time_grain", want.TimeGrain, got.Properties.TimeGrain)
+	diff.compare(id, "time_period_start", normalizeBudgetDate(want.StartDate), normalizeBudgetDate(got.Properties.TimePeriod.StartDate))
+	diff.compare(id, "time_period_end", normalizeBudgetDate(want.EndDate), normalizeBudgetDate(got.Properties.TimePeriod.EndDate))
+
+	// Azure does not preserve the case of the notification names.
+	actual := make(map[string]budgetNotification, len(got.Properties.Notifications))
+	names := make(map[string]string, len(got.Properties.Notifications))
+	for k, n := range got.Properties.Notifications {
+		actual[strings.ToLower(k)] = n
+		names[strings.ToLower(k)] = k
+	}
+	for _, k := range sortedKeys(want.Notifications) {
+		wn := want.Notifications[k]
+		gn, ok := actual[strings.ToLower(k)]
+		if !ok {
+			diff.compare(id, "notifications", k, nil)
+			continue
+		}
+		delete(actual, strings.ToLower(k))
+		prefix := "notifications." + k + "."
+		diff.compare(id, prefix+"enabled", wn.Enabled, gn.Enabled)
+		diff.compare(id, prefix+"operator", wn.Operator, gn.Operator)
+		diff.compare(id, prefix+"threshold", wn.Threshold, gn.Threshold)
+		diff.compare(id, prefix+"threshold_type", wn.ThresholdType, gn.ThresholdType)
+		diff.compareSet(id, prefix+"contact_emails", nilIfEmpty(wn.ContactEmails), nilIfEmpty(gn.ContactEmails))
+		diff.compareSet(id, prefix+"contact_roles", nilIfEmpty(wn.ContactRoles), nilIfEmpty(gn.ContactRoles))
+		diff.compareSet(id, prefix+"contact_groups", lowerAll(wn.ContactGroups), lowerAll(gn.ContactGroups))
+		diff.compare(id, prefix+"locale", strings.ToLower(wn.Locale), strings.ToLower(gn.Locale))
+	}
+	for _, k := range sortedKeys(actual) {
+		diff.compare(id, "notifications", nil, names[k])
+	}
+	return diff
+}
+
+// normalizeBudgetDate returns the date in budgetDateFormat, in UTC,
+// as Azure may return the dates with a different precision or offset.
+// Dates that cannot be parsed are returned unchanged.
+func normalizeBudgetDate(s string) string {
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UTC().Format(budgetDateFormat)
+		}
+	}
+	return s
+}
+
+// lowerAll returns the strings in lower case, or nil if there are none.
+func lowerAll(s []string) []string {
+	var out []string
+	for _, v := range s {
+		out = append(out, strings.ToLower(v))
+	}
+	return out
+}
+
+func floatValue(v any) (float64, bool) {
+	if f, ok := v.(float64); ok {
+		return f, true
+	}
+	if i, ok := intValue(v); ok {
+		return float64(i), true
+	}
+	return 0, false
+}
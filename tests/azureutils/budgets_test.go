@@ -0,0 +1,113 @@
+package azureutils
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Azure/terraform-azurerm-lz-vending/tests/fakearm"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestExpectedBudgets tests the scopes and notification defaults of the budget inputs.
+func TestExpectedBudgets(t *testing.T) {
+	id := uuid.New()
+	sub := "/subscriptions/" + id.String()
+	rgs := map[string]map[string]any{"rg1": {"name": "rg-one"}}
+
+	got, err := ExpectedBudgets(id, map[string]map[string]any{
+		"sub": {
+			"name":              "budget-sub",
+			"amount":            1000,
+			"time_grain":        "Monthly",
+			"time_period_start": "2024-01-01T00:00:00Z",
+			"time_period_end":   "2025-01-01T00:00:00Z",
+			"notifications": map[string]any{
+				"half": map[string]any{"enabled": true, "operator": "GreaterThan", "threshold": 50, "contact_roles": []string{"Owner"}},
+			},
+		},
+		"rg": {"name": "budget-rg", "amount": 12.5, "time_grain": "Quarterly", "resource_group_key": "rg1"},
+	}, rgs)
+	require.NoError(t, err)
+	assert.Equal(t, []Budget{
+		{Key: "rg", Scope: sub + "/resourceGroups/rg-one", Name: "budget-rg", Amount: 12.5, TimeGrain: "Quarterly", Notifications: map[string]BudgetNotification{}},
+		{Key: "sub", Scope: sub, Name: "budget-sub", Amount: 1000, TimeGrain: "Monthly", StartDate: "2024-01-01T00:00:00Z", EndDate: "2025-01-01T00:00:00Z",
+			Notifications: map[string]BudgetNotification{
+				"half": {Enabled: true, Operator: "GreaterThan", Threshold: 50, ThresholdType: "Actual", ContactRoles: []string{"Owner"}, Locale: "en-us"},
+			}},
+	}, got)
+
+	_, err = ExpectedBudgets(id, map[string]map[string]any{"rg": {"name": "b", "resource_group_key": "missing"}}, rgs)
+	assert.ErrorContains(t, err, "resource_group_key missing, which is not in resource_groups")
+
+	assert.Equal(t, "2024-02-01T00:00:00Z", BudgetStartDate(time.Date(2024, 2, 29, 23, 0, 0, 0, time.UTC)))
+}
+
+// TestVerifyBudgets tests that matching budgets have no differences,
+// and that mismatched properties, notifications and missing budgets are reported.
+func TestVerifyBudgets(t *testing.T) {
+	f, srv := newFakeClientFactory(t, nil)
+	id := uuid.New()
+	srv.AddSubscription(fakearm.Subscription{ID: id, DisplayName: "sub", State: "Enabled"})
+	srv.AddResourceGroup(id, "rg1", "westeurope")
+	sub := "/subscriptions/" + id.String()
+	rg := sub + "/resourceGroups/rg1"
+
+	srv.PutResource(sub+budgetsPath+"/budget-sub", map[string]any{"properties": map[string]any{
+		"amount":     1000.0,
+		"category":   "Cost",
+		"timeGrain":  "Monthly",
+		"timePeriod": map[string]any{"startDate": "2024-01-01T00:00:00Z", "endDate": "2025-01-01T00:00:00Z"},
+		"notifications": map[string]any{
+			"Half": map[string]any{
+				"enabled": true, "operator": "GreaterThan", "threshold": 50, "thresholdType": "Actual",
+				"contactEmails": []string{"b@example.com", "a@example.com"}, "contactRoles": []string{}, "locale": "en-US",
+			},
+		},
+	}})
+	srv.PutResource(rg+budgetsPath+"/budget-rg", map[string]any{"properties": map[string]any{
+		"amount":     10,
+		"timeGrain":  "Monthly",
+		"timePeriod": map[string]any{"startDate": "2023-12-01T00:00:00Z", "endDate": "2025-01-01T00:00:00Z"},
+		"notifications": map[string]any{
+			"full": map[string]any{
+				"enabled": true, "operator": "GreaterThan", "threshold": 100, "thresholdType": "Actual",
+				"contactRoles": []string{"Owner"}, "locale": "en-us",
+			},
+			"extra": map[string]any{
+				"enabled": true, "operator": "GreaterThan", "threshold": 80, "thresholdType": "Actual",
+				"contactRoles": []string{"Owner"}, "locale": "en-us",
+			},
+		},
+	}})
+
+	diff, err := f.VerifyBudgets(context.Background(), []Budget{
+		{Key: "sub", Scope: sub, Name: "budget-sub", Amount: 1000, TimeGrain: "Monthly", StartDate: "2024-01-01T00:00:00Z", EndDate: "2025-01-01T00:00:00Z",
+			Notifications: map[string]BudgetNotification{
+				"half": {Enabled: true, Operator: "GreaterThan", Threshold: 50, ThresholdType: "Actual", ContactEmails: []string{"a@example.com", "b@example.com"}, Locale: "en-us"},
+			}},
+	})
+	require.NoError(t, err)
+	assert.Empty(t, diff)
+
+	diff, err = f.VerifyBudgets(context.Background(), []Budget{
+		{Key: "rg", Scope: rg, Name: "budget-rg", Amount: 10, TimeGrain: "Monthly", StartDate: "2024-01-01T00:00:00Z", EndDate: "2025-01-01T00:00:00Z",
+			Notifications: map[string]BudgetNotification{
+				"full":   {Enabled: true, Operator: "GreaterThanOrEqualTo", Threshold: 100, ThresholdType: "Forecasted", ContactRoles: []string{"Owner"}, Locale: "en-us"},
+				"absent": {Enabled: true, Operator: "GreaterThan", Threshold: 10, ThresholdType: "Actual", ContactRoles: []string{"Owner"}, Locale: "en-us"},
+			}},
+		{Key: "missing", Scope: rg, Name: "budget-missing"},
+	})
+	require.NoError(t, err)
+	budgetID := rg + budgetsPath + "/budget-rg"
+	assert.Equal(t, Diff{
+		{ResourceID: budgetID, Property: "time_period_start", Expected: "2024-01-01T00:00:00Z", Actual: "2023-12-01T00:00:00Z"},
+		{ResourceID: budgetID, Property: "notifications", Expected: "absent"},
+		{ResourceID: budgetID, Property: "notifications.full.operator", Expected: "GreaterThanOrEqualTo", Actual: "GreaterThan"},
+		{ResourceID: budgetID, Property: "notifications.full.threshold_type", Expected: "Forecasted", Actual: "Actual"},
+		{ResourceID: budgetID, Property: "notifications", Actual: "extra"},
+		{ResourceID: rg + budgetsPath + "/budget-missing", Property: "budget", Expected: "missing"},
+	}, diff)
+}
@@ -1,122 +1,384 @@
 package azureutils
 
 import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
+	"net/http"
 	"os"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
 	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/authorization/armauthorization/v2"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/managementgroups/armmanagementgroups"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/network/armnetwork"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/subscription/armsubscription"
 	"github.com/google/uuid"
 )
 
-// NewSubnetClient creates a new subnet client using
-// armnetwork.NewSubnetsClient
-func NewSubnetClient(id uuid.UUID) (*armnetwork.SubnetsClient, error) {
-	cred, err := newDefaultAzureCredential()
+var (
+	defaultClientFactory     *ClientFactory
+	defaultClientFactoryErr  error
+	defaultClientFactoryOnce sync.Once
+)
+
+// ClientFactory creates Azure Resource Manager clients that share a single credential,
+// HTTP transport, retry policy and cloud configuration.
+// Use DefaultClientFactory to get the process-wide instance,
+// or NewClientFactory to supply your own credential and options.
+type ClientFactory struct {
+	cred    azcore.TokenCredential
+	options *arm.ClientOptions
+	// retry is used by operations that wait for eventually consistent APIs, see RetryPolicy.
+	retry RetryPolicy
+}
+
+// NewClientFactory creates a new ClientFactory using the supplied credential and options.
+// If options is nil, the defaults for the public cloud are used.
+// A shared HTTP transport is added to the options if one is not supplied.
+func NewClientFactory(cred azcore.TokenCredential, options *arm.ClientOptions) *ClientFactory {
+	var opts arm.ClientOptions
+	if options != nil {
+		opts = *options
+	} else {
+		opts = *defaultClientOptions(cloud.AzurePublic)
+	}
+	if opts.Transport == nil {
+		opts.Transport = &http.Client{}
+	}
+	return &ClientFactory{
+		cred:    cred,
+		options: &opts,
+		retry:   DefaultRetryPolicy(),
+	}
+}
+
+// DefaultClientFactory returns the process-wide ClientFactory.
+// The credential is created once, on first use, using newDefaultAzureCredential
+// and the cloud selected by cloudConfigFromEnv.
+// Its retry policy is DefaultRetryPolicy with the overrides from RetryPolicyFromEnv.
+func DefaultClientFactory() (*ClientFactory, error) {
+	defaultClientFactoryOnce.Do(func() {
+		cloudConfig, err := cloudConfigFromEnv()
+		if err != nil {
+			defaultClientFactoryErr = err
+			return
+		}
+		cred, err := newDefaultAzureCredential(cloudConfig)
+		if err != nil {
+			defaultClientFactoryErr = fmt.Errorf("failed to create Azure credential: %v", err)
+			return
+		}
+		retry, err := RetryPolicyFromEnv(DefaultRetryPolicy())
+		if err != nil {
+			defaultClientFactoryErr = err
+			return
+		}
+		defaultClientFactory = NewClientFactory(cred, defaultClientOptions(cloudConfig)).WithRetryPolicy(retry)
+	})
+	return defaultClientFactory, defaultClientFactoryErr
+}
+
+// Credential returns the credential shared by all clients created by the factory.
+func (f *ClientFactory) Credential() azcore.TokenCredential {
+	return f.cred
+}
+
+// CallerObjectID returns the object ID of the principal that the DefaultClientFactory authenticates as.
+func CallerObjectID(ctx context.Context) (string, error) {
+	f, err := DefaultClientFactory()
 	if err != nil {
-		return nil, fmt.Errorf("failed to create Azure credential: %v", err)
+		return "", err
 	}
-	clientOpts := arm.ClientOptions{
-		DisableRPRegistration: true,
+	return f.CallerObjectID(ctx)
+}
+
+// CallerObjectID returns the object ID of the principal that the credential authenticates as,
+// read from the oid claim of an Azure Resource Manager access token.
+func (f *ClientFactory) CallerObjectID(ctx context.Context) (string, error) {
+	audience := strings.TrimSuffix(f.options.Cloud.Services[cloud.ResourceManager].Audience, "/")
+	tok, err := f.cred.GetToken(ctx, policy.TokenRequestOptions{Scopes: []string{audience + "/.default"}})
+	if err != nil {
+		return "", fmt.Errorf("cannot get access token, %v", err)
+	}
+	parts := strings.Split(tok.Token, ".")
+	if len(parts) != 3 {
+		return "", fmt.Errorf("access token is not a JWT")
+	}
+	b, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return "", fmt.Errorf("cannot decode access token claims, %v", err)
+	}
+	var claims struct {
+		Oid string `json:"oid"`
 	}
+	if err := json.Unmarshal(b, &claims); err != nil {
+		return "", fmt.Errorf("cannot parse access token claims, %v", err)
+	}
+	if claims.Oid == "" {
+		return "", fmt.Errorf("access token has no oid claim")
+	}
+	return claims.Oid, nil
+}
 
-	client, err := armnetwork.NewSubnetsClient(id.String(), cred, &clientOpts)
+// NewSubnetsClient creates a new subnets client for the supplied subscription.
+func (f *ClientFactory) NewSubnetsClient(subID uuid.UUID) (*armnetwork.SubnetsClient, error) {
+	client, err := armnetwork.NewSubnetsClient(subID.String(), f.cred, f.options)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create subnet client: %v", err)
 	}
 	return client, nil
 }
 
-// NewSubscriptionsClient creates a new subscriptions client using
-// azidentity.NewDefaultAzureCredential.
-func NewSubscriptionsClient() (*armsubscription.SubscriptionsClient, error) {
-	cred, err := newDefaultAzureCredential()
+// NewVirtualNetworksClient creates a new virtual networks client for the supplied subscription.
+func (f *ClientFactory) NewVirtualNetworksClient(subID uuid.UUID) (*armnetwork.VirtualNetworksClient, error) {
+	client, err := armnetwork.NewVirtualNetworksClient(subID.String(), f.cred, f.options)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create Azure credential: %v", err)
+		return nil, fmt.Errorf("failed to create virtual network client: %v", err)
 	}
-	clientOpts := &arm.ClientOptions{
-		DisableRPRegistration: true,
+	return client, nil
+}
+
+// NewVirtualWansClient creates a new virtual WANs client for the supplied subscription.
+func (f *ClientFactory) NewVirtualWansClient(subID uuid.UUID) (*armnetwork.VirtualWansClient, error) {
+	client, err := armnetwork.NewVirtualWansClient(subID.String(), f.cred, f.options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create virtual WAN client: %v", err)
 	}
+	return client, nil
+}
 
-	client, err := armsubscription.NewSubscriptionsClient(cred, clientOpts)
+// NewVirtualHubsClient creates a new virtual hubs client for the supplied subscription.
+func (f *ClientFactory) NewVirtualHubsClient(subID uuid.UUID) (*armnetwork.VirtualHubsClient, error) {
+	client, err := armnetwork.NewVirtualHubsClient(subID.String(), f.cred, f.options)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create subscriptions client: %v", err)
+		return nil, fmt.Errorf("failed to create virtual hub client: %v", err)
 	}
 	return client, nil
 }
 
-// NewSubscriptionClient creates a new subscription client using
-// azidentity.NewDefaultAzureCredential.
-func NewSubscriptionClient() (*armsubscription.Client, error) {
-	cred, err := newDefaultAzureCredential()
+// NewAzureFirewallsClient creates a new Azure firewalls client for the supplied subscription.
+func (f *ClientFactory) NewAzureFirewallsClient(subID uuid.UUID) (*armnetwork.AzureFirewallsClient, error) {
+	client, err := armnetwork.NewAzureFirewallsClient(subID.String(), f.cred, f.options)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create Azure credential: %v", err)
+		return nil, fmt.Errorf("failed to create Azure firewall client: %v", err)
 	}
-	clientOpts := &arm.ClientOptions{
-		DisableRPRegistration: true,
+	return client, nil
+}
+
+// NewFirewallPoliciesClient creates a new firewall policies client for the supplied subscription.
+func (f *ClientFactory) NewFirewallPoliciesClient(subID uuid.UUID) (*armnetwork.FirewallPoliciesClient, error) {
+	client, err := armnetwork.NewFirewallPoliciesClient(subID.String(), f.cred, f.options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create firewall policy client: %v", err)
+	}
+	return client, nil
+}
+
+// NewRoutingIntentClient creates a new routing intent client for the supplied subscription.
+func (f *ClientFactory) NewRoutingIntentClient(subID uuid.UUID) (*armnetwork.RoutingIntentClient, error) {
+	client, err := armnetwork.NewRoutingIntentClient(subID.String(), f.cred, f.options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create routing intent client: %v", err)
 	}
+	return client, nil
+}
 
-	client, err := armsubscription.NewClient(cred, clientOpts)
+// NewResourceGroupsClient creates a new resource groups client for the supplied subscription.
+func (f *ClientFactory) NewResourceGroupsClient(subID uuid.UUID) (*armresources.ResourceGroupsClient, error) {
+	client, err := armresources.NewResourceGroupsClient(subID.String(), f.cred, f.options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create resource group client: %v", err)
+	}
+	return client, nil
+}
+
+// NewSubscriptionsClient creates a new subscriptions client.
+func (f *ClientFactory) NewSubscriptionsClient() (*armsubscription.SubscriptionsClient, error) {
+	client, err := armsubscription.NewSubscriptionsClient(f.cred, f.options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create subscriptions client: %v", err)
+	}
+	return client, nil
+}
+
+// NewSubscriptionClient creates a new subscription client.
+func (f *ClientFactory) NewSubscriptionClient() (*armsubscription.Client, error) {
+	client, err := armsubscription.NewClient(f.cred, f.options)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create subscription client: %v", err)
 	}
 	return client, nil
 }
 
-// NewManagementGroupSubscriptionsClient creates a new management group subscriptions client using
-// azidentity.NewDefaultAzureCredential.
-func NewManagementGroupSubscriptionsClient() (*armmanagementgroups.ManagementGroupSubscriptionsClient, error) {
-	cred, err := newDefaultAzureCredential()
+// NewAliasClient creates a new subscription alias client.
+func (f *ClientFactory) NewAliasClient() (*armsubscription.AliasClient, error) {
+	client, err := armsubscription.NewAliasClient(f.cred, f.options)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create Azure credential: %v", err)
+		return nil, fmt.Errorf("failed to create alias client: %v", err)
 	}
-	clientOpts := &arm.ClientOptions{
-		DisableRPRegistration: true,
+	return client, nil
+}
+
+// NewResourcesClient creates a new generic resources client for the supplied subscription.
+func (f *ClientFactory) NewResourcesClient(subID uuid.UUID) (*armresources.Client, error) {
+	client, err := armresources.NewClient(subID.String(), f.cred, f.options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create resources client: %v", err)
+	}
+	return client, nil
+}
+
+// NewTagsClient creates a new tags client for the supplied subscription.
+func (f *ClientFactory) NewTagsClient(subID uuid.UUID) (*armresources.TagsClient, error) {
+	client, err := armresources.NewTagsClient(subID.String(), f.cred, f.options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create tags client: %v", err)
+	}
+	return client, nil
+}
+
+// NewProvidersClient creates a new resource providers client for the supplied subscription.
+func (f *ClientFactory) NewProvidersClient(subID uuid.UUID) (*armresources.ProvidersClient, error) {
+	client, err := armresources.NewProvidersClient(subID.String(), f.cred, f.options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create providers client: %v", err)
 	}
+	return client, nil
+}
 
-	client, err := armmanagementgroups.NewManagementGroupSubscriptionsClient(cred, clientOpts)
+// NewRoleAssignmentsClient creates a new role assignments client for the supplied subscription.
+// The subscription is only used by the subscription scoped operations, the others take the scope or ID.
+func (f *ClientFactory) NewRoleAssignmentsClient(subID uuid.UUID) (*armauthorization.RoleAssignmentsClient, error) {
+	client, err := armauthorization.NewRoleAssignmentsClient(subID.String(), f.cred, f.options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create role assignments client: %v", err)
+	}
+	return client, nil
+}
+
+// NewRoleDefinitionsClient creates a new role definitions client.
+func (f *ClientFactory) NewRoleDefinitionsClient() (*armauthorization.RoleDefinitionsClient, error) {
+	client, err := armauthorization.NewRoleDefinitionsClient(f.cred, f.options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create role definitions client: %v", err)
+	}
+	return client, nil
+}
+
+// NewManagementGroupsClient creates a new management groups client.
+func (f *ClientFactory) NewManagementGroupsClient() (*armmanagementgroups.Client, error) {
+	client, err := armmanagementgroups.NewClient(f.cred, f.options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create management groups client: %v", err)
+	}
+	return client, nil
+}
+
+// NewManagementGroupSubscriptionsClient creates a new management group subscriptions client.
+func (f *ClientFactory) NewManagementGroupSubscriptionsClient() (*armmanagementgroups.ManagementGroupSubscriptionsClient, error) {
+	client, err := armmanagementgroups.NewManagementGroupSubscriptionsClient(f.cred, f.options)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create management group subscription client: %v", err)
 	}
 	return client, nil
 }
 
-// newDefaultAzureCredential creates a new default AzureCredential using
-// OIDC or azidentity.NewDefaultAzureCredential.
-// OIDC is used if the environment variable USE_OIDC or ARM_USE_OIDC is set to non-empty.
-func newDefaultAzureCredential() (azcore.TokenCredential, error) {
-	// Select the Azure cloud from the AZURE_ENVIRONMENT env var
-	var cloudConfig cloud.Configuration
-	env := os.Getenv("AZURE_ENVIRONMENT")
-	switch strings.ToLower(env) {
-	case "public":
-		cloudConfig = cloud.AzurePublic
-	case "usgovernment":
-		cloudConfig = cloud.AzureGovernment
-	case "china":
-		cloudConfig = cloud.AzureChina
-	default:
-		cloudConfig = cloud.AzurePublic
+// NewSubnetClient creates a new subnet client using
+// the DefaultClientFactory.
+func NewSubnetClient(id uuid.UUID) (*armnetwork.SubnetsClient, error) {
+	f, err := DefaultClientFactory()
+	if err != nil {
+		return nil, err
 	}
+	return f.NewSubnetsClient(id)
+}
+
+// NewSubscriptionsClient creates a new subscriptions client using
+// the DefaultClientFactory.
+func NewSubscriptionsClient() (*armsubscription.SubscriptionsClient, error) {
+	f, err := DefaultClientFactory()
+	if err != nil {
+		return nil, err
+	}
+	return f.NewSubscriptionsClient()
+}
+
+// NewSubscriptionClient creates a new subscription client using
+// the DefaultClientFactory.
+func NewSubscriptionClient() (*armsubscription.Client, error) {
+	f, err := DefaultClientFactory()
+	if err != nil {
+		return nil, err
+	}
+	return f.NewSubscriptionClient()
+}
+
+// NewManagementGroupSubscriptionsClient creates a new management group subscriptions client using
+// the DefaultClientFactory.
+func NewManagementGroupSubscriptionsClient() (*armmanagementgroups.ManagementGroupSubscriptionsClient, error) {
+	f, err := DefaultClientFactory()
+	if err != nil {
+		return nil, err
+	}
+	return f.NewManagementGroupSubscriptionsClient()
+}
 
+// DefaultClientOptions returns a copy of the client options used by the DefaultClientFactory,
+// for the cloud selected by the AZURE_ENVIRONMENT, ARM_METADATA_HOSTNAME and AZURE_CLOUD_CONFIG_FILE env vars.
+// Use it with NewClientFactory to change a single option, such as the transport.
+func DefaultClientOptions() (*arm.ClientOptions, error) {
+	cloudConfig, err := cloudConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	return defaultClientOptions(cloudConfig), nil
+}
+
+// defaultClientOptions returns the client options used by the DefaultClientFactory.
+// The retry policy is more patient than the SDK default as the deployment tests
+// run many clients in parallel and are prone to ARM throttling.
+func defaultClientOptions(cloudConfig cloud.Configuration) *arm.ClientOptions {
+	return &arm.ClientOptions{
+		ClientOptions: azcore.ClientOptions{
+			Cloud: cloudConfig,
+			Retry: policy.RetryOptions{
+				MaxRetries:    6,
+				RetryDelay:    2 * time.Second,
+				MaxRetryDelay: 60 * time.Second,
+			},
+		},
+		DisableRPRegistration: true,
+	}
+}
+
+// newDefaultAzureCredential creates a new default AzureCredential using
+// OIDC or azidentity.NewDefaultAzureCredential.
+// OIDC is used if the environment variable USE_OIDC or ARM_USE_OIDC is set to non-empty,
+// with the ID token source selected by assertionProviderFromEnv.
+func newDefaultAzureCredential(cloudConfig cloud.Configuration) (azcore.TokenCredential, error) {
 	useoidc := multiEnvDefault("", "USE_OIDC", "ARM_USE_OIDC")
 	if useoidc != "" {
+		assertion, err := assertionProviderFromEnv()
+		if err != nil {
+			return nil, err
+		}
 		return NewOidcCredential(&OidcCredentialOptions{
 			ClientOptions: azcore.ClientOptions{
 				Cloud: cloudConfig,
 			},
-			TenantID:      multiEnvDefault("", "ARM_TENANT_ID", "AZURE_TENANT_ID"),
-			ClientID:      multiEnvDefault("", "ARM_CLIENT_ID", "AZURE_CLIENT_ID"),
-			RequestToken:  multiEnvDefault("", "ARM_OIDC_REQUEST_TOKEN", "ACTIONS_ID_TOKEN_REQUEST_TOKEN"),
-			RequestURL:    multiEnvDefault("", "ARM_OIDC_REQUEST_URL", "ACTIONS_ID_TOKEN_REQUEST_URL"),
-			Token:         multiEnvDefault("", "ARM_OIDC_TOKEN"),
-			TokenFilePath: multiEnvDefault("", "ARM_OIDC_TOKEN_FILE_PATH"),
+			TenantID:                 multiEnvDefault("", "ARM_TENANT_ID", "AZURE_TENANT_ID"),
+			ClientID:                 multiEnvDefault("", "ARM_CLIENT_ID", "AZURE_CLIENT_ID"),
+			Assertion:                assertion,
+			DisableInstanceDiscovery: !isKnownCloud(cloudConfig),
 		})
 	}
 
@@ -126,6 +388,7 @@ func newDefaultAzureCredential() (azcore.TokenCredential, error) {
 		ClientOptions: azcore.ClientOptions{
 			Cloud: cloudConfig,
 		},
+		DisableInstanceDiscovery: !isKnownCloud(cloudConfig),
 	})
 }
 
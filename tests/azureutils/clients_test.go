@@ -0,0 +1,24 @@
+package azureutils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Azure/terraform-azurerm-lz-vending/tests/fakearm"
+)
+
+// newFakeClientFactory returns a ClientFactory that targets a new fake ARM server.
+// The eventual consistency retries are shortened so that the tests run quickly.
+func newFakeClientFactory(t *testing.T, opts *fakearm.Options) (*ClientFactory, *fakearm.Server) {
+	t.Helper()
+	srv := fakearm.NewServer(t, opts)
+	f := NewClientFactory(srv.Credential(), srv.ClientOptions())
+	f.retry = RetryPolicy{
+		MaxAttempts:  4,
+		InitialDelay: time.Millisecond,
+		MaxDelay:     5 * time.Millisecond,
+		Multiplier:   2,
+		RetryOn:      DefaultRetryPolicy().RetryOn,
+	}
+	return f, srv
+}
@@ -0,0 +1,224 @@
+package azureutils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
+)
+
+const (
+	// metadataAPIVersion is the API version of the ARM metadata endpoint that lists the known clouds.
+	metadataAPIVersion = "2022-09-01"
+
+	// azureStackMetadataAPIVersion is the API version supported by the Azure Stack Hub metadata endpoint,
+	// which describes a single cloud.
+	azureStackMetadataAPIVersion = "2015-01-01"
+
+	// metadataTimeout is the budget for loading the cloud configuration from the metadata endpoint.
+	metadataTimeout = 30 * time.Second
+)
+
+// CloudConfigFile is the format of the custom cloud file named by the AZURE_CLOUD_CONFIG_FILE env var, e.g.
+//
+//	{
+//	  "authorityHost": "https://login.contoso.local/",
+//	  "resourceManager": {
+//	    "endpoint": "https://management.contoso.local/",
+//	    "audience": "https://management.contoso.local/"
+//	  }
+//	}
+type CloudConfigFile struct {
+	AuthorityHost   string `json:"authorityHost"`
+	ResourceManager struct {
+		Endpoint string `json:"endpoint"`
+		Audience string `json:"audience"`
+	} `json:"resourceManager"`
+}
+
+// cloudConfigFromEnv selects the Azure cloud, in order of precedence, from:
+//
+//   - the JSON file named by AZURE_CLOUD_CONFIG_FILE, see CloudConfigFile
+//   - the ARM metadata endpoint on the host in ARM_METADATA_HOSTNAME,
+//     using AZURE_ENVIRONMENT to select the cloud if the endpoint lists more than one
+//   - the AZURE_ENVIRONMENT env var, one of public (the default), usgovernment or china
+//
+// An unknown AZURE_ENVIRONMENT value is an error.
+func cloudConfigFromEnv() (cloud.Configuration, error) {
+	env := os.Getenv("AZURE_ENVIRONMENT")
+	if path := os.Getenv("AZURE_CLOUD_CONFIG_FILE"); path != "" {
+		return loadCloudConfigFile(path)
+	}
+	if host := os.Getenv("ARM_METADATA_HOSTNAME"); host != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), metadataTimeout)
+		defer cancel()
+		return loadCloudConfigFromMetadata(ctx, http.DefaultClient, host, env)
+	}
+	switch strings.ToLower(env) {
+	case "", "public", "azurecloud":
+		return cloud.AzurePublic, nil
+	case "usgovernment", "azureusgovernment":
+		return cloud.AzureGovernment, nil
+	case "china", "azurechinacloud":
+		return cloud.AzureChina, nil
+	default:
+		return cloud.Configuration{}, fmt.Errorf("unknown AZURE_ENVIRONMENT value %q, "+
+			"use public, usgovernment or china, or set ARM_METADATA_HOSTNAME or AZURE_CLOUD_CONFIG_FILE for a custom cloud", env)
+	}
+}
+
+// loadCloudConfigFile reads a custom cloud configuration from a CloudConfigFile.
+// If the resource manager audience is omitted, the endpoint is used.
+func loadCloudConfigFile(path string) (cloud.Configuration, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return cloud.Configuration{}, fmt.Errorf("cannot read cloud config file: %v", err)
+	}
+	var f CloudConfigFile
+	if err := json.Unmarshal(b, &f); err != nil {
+		return cloud.Configuration{}, fmt.Errorf("cannot parse cloud config file %s: %v", path, err)
+	}
+	if f.AuthorityHost == "" || f.ResourceManager.Endpoint == "" {
+		return cloud.Configuration{}, fmt.Errorf("cloud config file %s must set authorityHost and resourceManager.endpoint", path)
+	}
+	return newCloudConfig(f.AuthorityHost, f.ResourceManager.Endpoint, f.ResourceManager.Audience), nil
+}
+
+// metadataCloud is a cloud described by the ARM metadata endpoint.
+// Azure Stack Hub does not report the resource manager endpoint, as it is the metadata host.
+type metadataCloud struct {
+	Name            string `json:"name"`
+	ResourceManager string `json:"resourceManager"`
+	Authentication  struct {
+		LoginEndpoint string   `json:"loginEndpoint"`
+		Audiences     []string `json:"audiences"`
+	} `json:"authentication"`
+}
+
+// metadataCloudNames maps the AZURE_ENVIRONMENT values to the cloud names used by the metadata endpoint.
+var metadataCloudNames = map[string]string{
+	"public":       "AzureCloud",
+	"usgovernment": "AzureUSGovernment",
+	"china":        "AzureChinaCloud",
+}
+
+// loadCloudConfigFromMetadata loads the cloud configuration from the ARM metadata endpoint on host.
+// The endpoint lists the known clouds, and name selects one of them if there is more than one,
+// defaulting to the public cloud.
+// If the endpoint is not available, the Azure Stack Hub endpoint, which describes a single cloud, is used.
+func loadCloudConfigFromMetadata(ctx context.Context, client *http.Client, host, name string) (cloud.Configuration, error) {
+	base := "https://" + strings.TrimSuffix(strings.TrimPrefix(host, "https://"), "/")
+
+	var clouds []metadataCloud
+	body, status, err := getMetadata(ctx, client, base, metadataAPIVersion)
+	if err != nil {
+		return cloud.Configuration{}, err
+	}
+	if status == http.StatusOK {
+		if err := json.Unmarshal(body, &clouds); err != nil {
+			return cloud.Configuration{}, fmt.Errorf("cannot parse cloud metadata from %s: %v", host, err)
+		}
+	} else {
+		body, status, err = getMetadata(ctx, client, base, azureStackMetadataAPIVersion)
+		if err != nil {
+			return cloud.Configuration{}, err
+		}
+		if status != http.StatusOK {
+			return cloud.Configuration{}, fmt.Errorf("cannot load cloud metadata from %s, received HTTP status %d with response: %s", host, status, body)
+		}
+		var c metadataCloud
+		if err := json.Unmarshal(body, &c); err != nil {
+			return cloud.Configuration{}, fmt.Errorf("cannot parse cloud metadata from %s: %v", host, err)
+		}
+		c.ResourceManager = base
+		clouds = []metadataCloud{c}
+	}
+
+	if n, ok := metadataCloudNames[strings.ToLower(name)]; ok {
+		name = n
+	}
+	var selected *metadataCloud
+	switch {
+	case len(clouds) == 1 && name == "":
+		selected = &clouds[0]
+	default:
+		if name == "" {
+			name = metadataCloudNames["public"]
+		}
+		names := make([]string, 0, len(clouds))
+		for i, c := range clouds {
+			names = append(names, c.Name)
+			if strings.EqualFold(c.Name, name) {
+				selected = &clouds[i]
+			}
+		}
+		if selected == nil {
+			return cloud.Configuration{}, fmt.Errorf("cloud %q not found in metadata from %s, set AZURE_ENVIRONMENT to one of %s",
+				name, host, strings.Join(names, ", "))
+		}
+	}
+
+	if selected.Authentication.LoginEndpoint == "" || selected.ResourceManager == "" {
+		return cloud.Configuration{}, fmt.Errorf("cloud metadata from %s does not contain the login and resource manager endpoints", host)
+	}
+	audience := ""
+	if len(selected.Authentication.Audiences) > 0 {
+		audience = selected.Authentication.Audiences[0]
+	}
+	return newCloudConfig(selected.Authentication.LoginEndpoint, selected.ResourceManager, audience), nil
+}
+
+func getMetadata(ctx context.Context, client *http.Client, base, apiVersion string) ([]byte, int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, base+"/metadata/endpoints?api-version="+apiVersion, http.NoBody)
+	if err != nil {
+		return nil, 0, fmt.Errorf("cannot create cloud metadata request: %v", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("cannot load cloud metadata: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, 0, fmt.Errorf("cannot read cloud metadata: %v", err)
+	}
+	return body, resp.StatusCode, nil
+}
+
+// newCloudConfig returns a cloud configuration for the authority host and resource manager endpoint.
+// If audience is empty, the endpoint is used.
+func newCloudConfig(authorityHost, endpoint, audience string) cloud.Configuration {
+	if !strings.HasSuffix(authorityHost, "/") {
+		authorityHost += "/"
+	}
+	if audience == "" {
+		audience = endpoint
+	}
+	return cloud.Configuration{
+		ActiveDirectoryAuthorityHost: authorityHost,
+		Services: map[cloud.ServiceName]cloud.ServiceConfiguration{
+			cloud.ResourceManager: {
+				Audience: audience,
+				Endpoint: endpoint,
+			},
+		},
+	}
+}
+
+// isKnownCloud reports whether the cloud uses the authority host of one of the Azure public or sovereign clouds.
+// Entra ID instance discovery is not available for other authority hosts, such as Azure Stack Hub.
+func isKnownCloud(c cloud.Configuration) bool {
+	for _, known := range []cloud.Configuration{cloud.AzurePublic, cloud.AzureGovernment, cloud.AzureChina} {
+		if strings.EqualFold(strings.TrimSuffix(c.ActiveDirectoryAuthorityHost, "/"), strings.TrimSuffix(known.ActiveDirectoryAuthorityHost, "/")) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,135 @@
+package azureutils
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestCloudConfigFromEnv tests the named clouds, and that an unknown name is an error.
+func TestCloudConfigFromEnv(t *testing.T) {
+	t.Setenv("AZURE_CLOUD_CONFIG_FILE", "")
+	t.Setenv("ARM_METADATA_HOSTNAME", "")
+	cases := map[string]cloud.Configuration{
+		"":             cloud.AzurePublic,
+		"public":       cloud.AzurePublic,
+		"USGovernment": cloud.AzureGovernment,
+		"china":        cloud.AzureChina,
+	}
+	for env, want := range cases {
+		t.Setenv("AZURE_ENVIRONMENT", env)
+		got, err := cloudConfigFromEnv()
+		require.NoError(t, err, env)
+		assert.Equal(t, want, got, env)
+	}
+
+	t.Setenv("AZURE_ENVIRONMENT", "germany")
+	_, err := cloudConfigFromEnv()
+	assert.ErrorContains(t, err, `unknown AZURE_ENVIRONMENT value "germany"`)
+}
+
+// TestCloudConfigFile tests that a custom cloud is loaded from a file,
+// and that the audience defaults to the endpoint.
+func TestCloudConfigFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cloud.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{
+		"authorityHost": "https://login.contoso.local",
+		"resourceManager": {"endpoint": "https://management.contoso.local/"}
+	}`), 0600))
+	t.Setenv("AZURE_CLOUD_CONFIG_FILE", path)
+	t.Setenv("AZURE_ENVIRONMENT", "custom")
+
+	got, err := cloudConfigFromEnv()
+	require.NoError(t, err)
+	assert.Equal(t, "https://login.contoso.local/", got.ActiveDirectoryAuthorityHost)
+	assert.Equal(t, cloud.ServiceConfiguration{
+		Audience: "https://management.contoso.local/",
+		Endpoint: "https://management.contoso.local/",
+	}, got.Services[cloud.ResourceManager])
+	assert.False(t, isKnownCloud(got))
+	assert.True(t, isKnownCloud(cloud.AzureChina))
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"authorityHost": "https://login.contoso.local"}`), 0600))
+	_, err = cloudConfigFromEnv()
+	assert.ErrorContains(t, err, "must set authorityHost and resourceManager.endpoint")
+}
+
+// newMetadataServer starts a metadata endpoint that serves the supplied body for each API version.
+// Other API versions return bad request.
+func newMetadataServer(t *testing.T, bodies map[string]string) (*httptest.Server, string) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := bodies[r.URL.Query().Get("api-version")]
+		if r.URL.Path != "/metadata/endpoints" || !ok {
+			http.Error(w, `{"error":{"code":"InvalidApiVersionParameter"}}`, http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, strings.TrimPrefix(srv.URL, "https://")
+}
+
+// TestCloudConfigFromMetadata tests selecting a cloud from the list returned by the metadata endpoint.
+func TestCloudConfigFromMetadata(t *testing.T) {
+	srv, host := newMetadataServer(t, map[string]string{
+		metadataAPIVersion: `[
+			{
+				"name": "AzureCloud",
+				"resourceManager": "https://management.azure.com/",
+				"authentication": {"loginEndpoint": "https://login.microsoftonline.com", "audiences": ["https://management.core.windows.net/"]}
+			},
+			{
+				"name": "ContosoCloud",
+				"resourceManager": "https://management.contoso.local/",
+				"authentication": {"loginEndpoint": "https://login.contoso.local", "audiences": ["https://management.core.contoso.local/"]}
+			}
+		]`,
+	})
+	ctx := context.Background()
+
+	got, err := loadCloudConfigFromMetadata(ctx, srv.Client(), host, "")
+	require.NoError(t, err)
+	assert.Equal(t, "https://management.azure.com/", got.Services[cloud.ResourceManager].Endpoint)
+
+	got, err = loadCloudConfigFromMetadata(ctx, srv.Client(), host, "contosocloud")
+	require.NoError(t, err)
+	assert.Equal(t, "https://login.contoso.local/", got.ActiveDirectoryAuthorityHost)
+	assert.Equal(t, cloud.ServiceConfiguration{
+		Audience: "https://management.core.contoso.local/",
+		Endpoint: "https://management.contoso.local/",
+	}, got.Services[cloud.ResourceManager])
+
+	_, err = loadCloudConfigFromMetadata(ctx, srv.Client(), host, "other")
+	assert.ErrorContains(t, err, "set AZURE_ENVIRONMENT to one of AzureCloud, ContosoCloud")
+}
+
+// TestCloudConfigFromMetadataAzureStack tests the Azure Stack Hub metadata endpoint,
+// where the metadata host is the resource manager endpoint.
+func TestCloudConfigFromMetadataAzureStack(t *testing.T) {
+	srv, host := newMetadataServer(t, map[string]string{
+		azureStackMetadataAPIVersion: `{
+			"galleryEndpoint": "https://portal.local.azurestack.external:30015/",
+			"authentication": {
+				"loginEndpoint": "https://adfs.local.azurestack.external/adfs",
+				"audiences": ["https://management.adfs.azurestack.local/1234"]
+			}
+		}`,
+	})
+
+	got, err := loadCloudConfigFromMetadata(context.Background(), srv.Client(), host, "")
+	require.NoError(t, err)
+	assert.Equal(t, "https://adfs.local.azurestack.external/adfs/", got.ActiveDirectoryAuthorityHost)
+	assert.Equal(t, cloud.ServiceConfiguration{
+		Audience: "https://management.adfs.azurestack.local/1234",
+		Endpoint: srv.URL,
+	}, got.Services[cloud.ResourceManager])
+}
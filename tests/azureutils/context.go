@@ -0,0 +1,54 @@
+package azureutils
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+const (
+	// testDeadlineGrace is subtracted from the test deadline so that Azure operations
+	// are cancelled, and the cleanup can report why, before the Go test timeout panics.
+	testDeadlineGrace = 1 * time.Minute
+
+	// resourceGroupDeleteTimeout is the budget given to each resource group delete poller.
+	resourceGroupDeleteTimeout = 30 * time.Minute
+
+	// subscriptionCancelTimeout is the budget given to cancelling a subscription, including retries.
+	subscriptionCancelTimeout = 5 * time.Minute
+
+	// fixtureDeleteReserve is kept back from the test deadline when creating a fixture,
+	// so that the fixture can still be deleted if its creation overruns.
+	fixtureDeleteReserve = 15 * time.Minute
+)
+
+// NewTestContext returns a context derived from the test deadline (see go test -timeout).
+// The context is cancelled shortly before the deadline so that cleanup stops cleanly.
+// If the test has no deadline, the context is only cancelled by the returned cancel func.
+//
+// The context is not derived from t.Context() as that is cancelled before
+// functions registered with t.Cleanup are run.
+func NewTestContext(t *testing.T) (context.Context, context.CancelFunc) {
+	deadline, ok := t.Deadline()
+	if !ok {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithDeadline(context.Background(), deadline.Add(-testDeadlineGrace))
+}
+
+// newFixtureContext returns a context for creating or deleting a test fixture.
+// It is bounded by fixtureTimeout and by the test deadline, less the grace of NewTestContext and the reserve.
+// The reserve is at most half of the time left, so that a short -timeout still leaves time for the fixture.
+// Like NewTestContext, it is not derived from t.Context(), so that it can be used in t.Cleanup.
+func newFixtureContext(t *testing.T, reserve time.Duration) (context.Context, context.CancelFunc) {
+	now := time.Now()
+	deadline := now.Add(fixtureTimeout)
+	if d, ok := t.Deadline(); ok {
+		d = d.Add(-testDeadlineGrace)
+		d = d.Add(-min(reserve, d.Sub(now)/2))
+		if d.Before(deadline) {
+			deadline = d
+		}
+	}
+	return context.WithDeadline(context.Background(), deadline)
+}
@@ -0,0 +1,474 @@
+package azureutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/subscription/armsubscription"
+	"github.com/google/uuid"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	// userAssignedIdentitiesAPIVersion is the Microsoft.ManagedIdentity/userAssignedIdentities API version.
+	userAssignedIdentitiesAPIVersion = "2023-01-31"
+
+	// DefaultDecommissionNamePrefix is prepended to the subscription display name by DecommissionStepRename.
+	DefaultDecommissionNamePrefix = "decommissioned-"
+
+	// DefaultCancelDateTag is the subscription tag set to the cancel date by DecommissionStepTag.
+	DefaultCancelDateTag = "cancel_date"
+)
+
+// DecommissionStep is a step of the subscription decommission pipeline.
+type DecommissionStep string
+
+// The decommission steps, in the order that they are run.
+const (
+	// DecommissionStepQuarantine moves the subscription to the quarantine management group.
+	DecommissionStepQuarantine DecommissionStep = "quarantine"
+	// DecommissionStepRemoveRoleAssignments removes the role assignments made at, or below, the subscription scope.
+	DecommissionStepRemoveRoleAssignments DecommissionStep = "remove_role_assignments"
+	// DecommissionStepRemoveManagedIdentities removes the user assigned managed identities in the subscription.
+	DecommissionStepRemoveManagedIdentities DecommissionStep = "remove_managed_identities"
+	// DecommissionStepDeleteResourceGroups deletes the resource groups in the subscription.
+	DecommissionStepDeleteResourceGroups DecommissionStep = "delete_resource_groups"
+	// DecommissionStepRename prefixes the subscription display name.
+	DecommissionStepRename DecommissionStep = "rename"
+	// DecommissionStepTag tags the subscription with the cancel date.
+	DecommissionStepTag DecommissionStep = "tag"
+	// DecommissionStepCancel cancels the subscription.
+	DecommissionStepCancel DecommissionStep = "cancel"
+	// DecommissionStepDeleteAlias deletes the aliases of the subscription.
+	DecommissionStepDeleteAlias DecommissionStep = "delete_alias"
+)
+
+// DecommissionSteps are all of the decommission steps, in the order that they are run.
+var DecommissionSteps = []DecommissionStep{
+	DecommissionStepQuarantine,
+	DecommissionStepRemoveRoleAssignments,
+	DecommissionStepRemoveManagedIdentities,
+	DecommissionStepDeleteResourceGroups,
+	DecommissionStepRename,
+	DecommissionStepTag,
+	DecommissionStepCancel,
+	DecommissionStepDeleteAlias,
+}
+
+// DecommissionStepState is the state of a decommission step.
+type DecommissionStepState string
+
+// Decommission step states.
+const (
+	DecommissionStepPending   DecommissionStepState = "pending"
+	DecommissionStepSucceeded DecommissionStepState = "succeeded"
+	DecommissionStepSkipped   DecommissionStepState = "skipped"
+	DecommissionStepFailed    DecommissionStepState = "failed"
+)
+
+// DecommissionOptions configures Decommission.
+type DecommissionOptions struct {
+	// Steps are the steps to run. They are always run in the order of DecommissionSteps.
+	// If nil, all steps are run.
+	Steps []DecommissionStep
+	// QuarantineManagementGroup is the ID of the management group that the subscription is moved to.
+	// If empty, DecommissionStepQuarantine is skipped.
+	QuarantineManagementGroup string
+	// KeepRoleAssignmentPrincipals are the object IDs of principals whose role assignments are not removed,
+	// e.g. a platform identity that must keep access to the subscription.
+	// The role assignments of the identity running the decommission are never removed,
+	// as the later steps need them.
+	KeepRoleAssignmentPrincipals []string
+	// RemoveResourceGroupLocks removes the management locks on the resource groups and their resources
+	// before DecommissionStepDeleteResourceGroups deletes them.
+	// Without it, a locked resource group fails the step.
+	RemoveResourceGroupLocks bool
+	// NamePrefix is prepended to the subscription display name, defaults to DefaultDecommissionNamePrefix.
+	NamePrefix string
+	// CancelDateTag is the tag set to the cancel date, defaults to DefaultCancelDateTag.
+	CancelDateTag string
+	// Resume is the status returned by a previous, incomplete, run for the same subscription.
+	// Steps that succeeded or were skipped in that run are not run again.
+	Resume *DecommissionStatus
+	// Progress, if not nil, is called each time a step completes.
+	Progress func(DecommissionStepStatus)
+	// Now returns the current time, defaults to time.Now.
+	Now func() time.Time
+}
+
+// DecommissionStatus is the progress of a decommission.
+// It is JSON serialisable so that it can be saved and passed to a later run as DecommissionOptions.Resume.
+type DecommissionStatus struct {
+	SubscriptionID uuid.UUID                `json:"subscription_id"`
+	Steps          []DecommissionStepStatus `json:"steps"`
+}
+
+// DecommissionStepStatus is the status of a decommission step.
+type DecommissionStepStatus struct {
+	Step      DecommissionStep      `json:"step"`
+	State     DecommissionStepState `json:"state"`
+	Detail    string                `json:"detail,omitempty"`
+	Error     string                `json:"error,omitempty"`
+	StartTime *time.Time            `json:"start_time,omitempty"`
+	EndTime   *time.Time            `json:"end_time,omitempty"`
+}
+
+// Complete reports whether every step has succeeded or was skipped.
+func (s *DecommissionStatus) Complete() bool {
+	for _, st := range s.Steps {
+		if st.State != DecommissionStepSucceeded && st.State != DecommissionStepSkipped {
+			return false
+		}
+	}
+	return true
+}
+
+// Step returns the status of the supplied step.
+func (s *DecommissionStatus) Step(step DecommissionStep) (DecommissionStepStatus, bool) {
+	for _, st := range s.Steps {
+		if st.Step == step {
+			return st, true
+		}
+	}
+	return DecommissionStepStatus{}, false
+}
+
+// Decommission runs the subscription decommission pipeline using the DefaultClientFactory.
+func Decommission(ctx context.Context, t TestingT, id uuid.UUID, opts *DecommissionOptions) (*DecommissionStatus, error) {
+	f, err := DefaultClientFactory()
+	if err != nil {
+		return nil, err
+	}
+	return f.Decommission(ctx, t, id, opts)
+}
+
+// Decommission runs the subscription decommission pipeline.
+// The steps are run in order and the pipeline stops at the first step that fails.
+// The returned status records the outcome of every step, including when an error is returned,
+// and can be passed back in DecommissionOptions.Resume to continue from the failed step.
+// Each step is safe to repeat.
+func (f *ClientFactory) Decommission(ctx context.Context, t TestingT, id uuid.UUID, opts *DecommissionOptions) (*DecommissionStatus, error) {
+	if opts == nil {
+		opts = &DecommissionOptions{}
+	}
+	if opts.Resume != nil && opts.Resume.SubscriptionID != id {
+		return nil, fmt.Errorf("cannot resume decommission of subscription %s with the status of subscription %s", id, opts.Resume.SubscriptionID)
+	}
+	d := &decommission{
+		f:    f,
+		t:    t,
+		id:   id,
+		opts: *opts,
+	}
+	if d.opts.NamePrefix == "" {
+		d.opts.NamePrefix = DefaultDecommissionNamePrefix
+	}
+	if d.opts.CancelDateTag == "" {
+		d.opts.CancelDateTag = DefaultCancelDateTag
+	}
+	if d.opts.Now == nil {
+		d.opts.Now = time.Now
+	}
+
+	status := &DecommissionStatus{SubscriptionID: id}
+	for _, step := range DecommissionSteps {
+		if d.opts.Steps != nil && !slices.Contains(d.opts.Steps, step) {
+			continue
+		}
+		st := DecommissionStepStatus{Step: step, State: DecommissionStepPending}
+		if d.opts.Resume != nil {
+			if prev, ok := d.opts.Resume.Step(step); ok && prev.State != DecommissionStepFailed {
+				st = prev
+			}
+		}
+		status.Steps = append(status.Steps, st)
+	}
+
+	sub, err := f.GetSubscription(ctx, id)
+	if err != nil {
+		return status, fmt.Errorf("subscription %s does not exist or cannot successfully check, %s", id, err)
+	}
+	d.sub = sub.Subscription
+
+	for i := range status.Steps {
+		st := &status.Steps[i]
+		if st.State == DecommissionStepSucceeded || st.State == DecommissionStepSkipped {
+			continue
+		}
+		start := d.opts.Now()
+		st.StartTime = &start
+		t.Logf("decommission subscription %s: running step %s", id, st.Step)
+		detail, err := d.run(ctx, st.Step)
+		end := d.opts.Now()
+		st.EndTime = &end
+		st.Detail = detail
+		st.Error = ""
+		switch {
+		case errors.Is(err, errStepSkipped):
+			st.State = DecommissionStepSkipped
+		case err != nil:
+			st.State = DecommissionStepFailed
+			st.Error = err.Error()
+		default:
+			st.State = DecommissionStepSucceeded
+		}
+		t.Logf("decommission subscription %s: step %s %s %s", id, st.Step, st.State, detail)
+		if d.opts.Progress != nil {
+			d.opts.Progress(*st)
+		}
+		if st.State == DecommissionStepFailed {
+			return status, fmt.Errorf("cannot decommission subscription %s, step %s failed, %v", id, st.Step, err)
+		}
+	}
+	return status, nil
+}
+
+// errStepSkipped is returned by a step that had nothing to do.
+var errStepSkipped = errors.New("step skipped")
+
+// decommission holds the state of a single Decommission run.
+type decommission struct {
+	f    *ClientFactory
+	t    TestingT
+	id   uuid.UUID
+	sub  armsubscription.Subscription
+	opts DecommissionOptions
+}
+
+// run runs the step and returns a description of what it did.
+func (d *decommission) run(ctx context.Context, step DecommissionStep) (string, error) {
+	switch step {
+	case DecommissionStepQuarantine:
+		return d.quarantine(ctx)
+	case DecommissionStepRemoveRoleAssignments:
+		return d.removeRoleAssignments(ctx)
+	case DecommissionStepRemoveManagedIdentities:
+		return d.removeManagedIdentities(ctx)
+	case DecommissionStepDeleteResourceGroups:
+		return d.deleteResourceGroups(ctx)
+	case DecommissionStepRename:
+		return d.rename(ctx)
+	case DecommissionStepTag:
+		return d.tag(ctx)
+	case DecommissionStepCancel:
+		return d.cancel(ctx)
+	case DecommissionStepDeleteAlias:
+		return d.deleteAliases(ctx)
+	}
+	return "", fmt.Errorf("unknown decommission step %q", step)
+}
+
+// active reports whether the subscription can still be modified.
+func (d *decommission) active() bool {
+	return d.sub.State == nil || *d.sub.State == armsubscription.SubscriptionStateEnabled
+}
+
+func (d *decommission) quarantine(ctx context.Context) (string, error) {
+	mg := d.opts.QuarantineManagementGroup
+	if mg == "" {
+		return "no quarantine management group", errStepSkipped
+	}
+	if err := d.f.SetSubscriptionManagementGroup(ctx, d.id, mg); err != nil {
+		return "", err
+	}
+	if err := d.f.IsSubscriptionInManagementGroup(ctx, d.t, d.id, mg); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("moved to management group %s", mg), nil
+}
+
+// removeRoleAssignments removes the role assignments at the subscription scope and below.
+// Assignments inherited from management groups are not removed.
+func (d *decommission) removeRoleAssignments(ctx context.Context) (string, error) {
+	caller, err := d.f.CallerObjectID(ctx)
+	if err != nil {
+		return "", fmt.Errorf("cannot resolve the calling principal, %v", err)
+	}
+	keep := append([]string{caller}, d.opts.KeepRoleAssignmentPrincipals...)
+	subScope := "/subscriptions/" + d.id.String()
+	ras, err := d.f.listRoleAssignments(ctx, subScope)
+	if err != nil {
+		return "", fmt.Errorf("cannot list role assignments, %v", err)
+	}
+	client, err := d.f.NewRoleAssignmentsClient(d.id)
+	if err != nil {
+		return "", err
+	}
+	n := 0
+	for _, ra := range ras {
+		id := stringValue(ra.ID)
+		if !strings.HasPrefix(strings.ToLower(id), strings.ToLower(subScope)+"/") {
+			continue
+		}
+		if slices.ContainsFunc(keep, func(p string) bool {
+			return strings.EqualFold(p, stringValue(roleAssignmentProperties(ra).PrincipalID))
+		}) {
+			continue
+		}
+		if _, err := client.DeleteByID(ctx, id, nil); err != nil {
+			return "", fmt.Errorf("cannot remove role assignment %s, %v", id, err)
+		}
+		n++
+	}
+	return fmt.Sprintf("removed %d role assignments", n), nil
+}
+
+func (d *decommission) removeManagedIdentities(ctx context.Context) (string, error) {
+	path := "/subscriptions/" + d.id.String() + "/providers/Microsoft.ManagedIdentity/userAssignedIdentities"
+	umis, err := listARM[armResource](ctx, d.f, path, userAssignedIdentitiesAPIVersion)
+	if err != nil {
+		return "", fmt.Errorf("cannot list user assigned identities, %v", err)
+	}
+	for _, umi := range umis {
+		if err := d.f.doARM(ctx, http.MethodDelete, umi.ID, userAssignedIdentitiesAPIVersion, nil, nil); err != nil {
+			return "", fmt.Errorf("cannot remove user assigned identity %s, %v", umi.ID, err)
+		}
+	}
+	return fmt.Sprintf("removed %d user assigned identities", len(umis)), nil
+}
+
+func (d *decommission) deleteResourceGroups(ctx context.Context) (string, error) {
+	rgs, err := d.f.ListResourceGroups(ctx, d.id)
+	if err != nil {
+		return "", fmt.Errorf("cannot list resource groups for subscription %s, %v", d.id, err)
+	}
+
+	d.t.Logf("removing %d resource groups for subscription %s", len(rgs), d.id)
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(10)
+	for _, rg := range rgs {
+		g.Go(func() error {
+			d.t.Logf("removing resource group %s for subscription %s", *rg.Name, d.id)
+			return d.f.DeleteResourceGroupWithOptions(gctx, *rg.Name, d.id, &DeleteResourceGroupOptions{
+				RemoveLocks: d.opts.RemoveResourceGroupLocks,
+			})
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return "", fmt.Errorf("cannot delete resource groups for subscription %s, %v", d.id, err)
+	}
+	return fmt.Sprintf("removed %d resource groups", len(rgs)), nil
+}
+
+func (d *decommission) rename(ctx context.Context) (string, error) {
+	if !d.active() {
+		return fmt.Sprintf("subscription is %s", *d.sub.State), errStepSkipped
+	}
+	name := to.Ptr("")
+	if d.sub.DisplayName != nil {
+		name = d.sub.DisplayName
+	}
+	if strings.HasPrefix(*name, d.opts.NamePrefix) {
+		return fmt.Sprintf("already named %s", *name), nil
+	}
+	newName := d.opts.NamePrefix + *name
+	client, err := d.f.NewSubscriptionClient()
+	if err != nil {
+		return "", fmt.Errorf("cannot create subscription client, %s", err)
+	}
+	if _, err := client.Rename(ctx, d.id.String(), armsubscription.Name{SubscriptionName: &newName}, nil); err != nil {
+		return "", fmt.Errorf("cannot rename subscription %s, %v", d.id, err)
+	}
+	d.sub.DisplayName = &newName
+	return fmt.Sprintf("renamed to %s", newName), nil
+}
+
+func (d *decommission) tag(ctx context.Context) (string, error) {
+	if !d.active() {
+		return fmt.Sprintf("subscription is %s", *d.sub.State), errStepSkipped
+	}
+	client, err := d.f.NewTagsClient(d.id)
+	if err != nil {
+		return "", err
+	}
+	date := d.opts.Now().UTC().Format(time.DateOnly)
+	_, err = client.UpdateAtScope(ctx, "subscriptions/"+d.id.String(), armresources.TagsPatchResource{
+		Operation: to.Ptr(armresources.TagsPatchOperationMerge),
+		Properties: &armresources.Tags{
+			Tags: map[string]*string{d.opts.CancelDateTag: &date},
+		},
+	}, nil)
+	if err != nil {
+		return "", fmt.Errorf("cannot tag subscription %s, %v", d.id, err)
+	}
+	return fmt.Sprintf("tagged %s=%s", d.opts.CancelDateTag, date), nil
+}
+
+func (d *decommission) cancel(ctx context.Context) (string, error) {
+	// The subscription may have been cancelled since the pipeline started,
+	// so read its state again rather than relying on the error returned by cancel.
+	if err := d.refresh(ctx); err != nil {
+		return "", err
+	}
+	// If the sub is already in warned or disabled state then do not try and cancel again.
+	if !d.active() {
+		d.t.Logf("subscription %s is already cancelled", d.id)
+		return fmt.Sprintf("subscription is %s", *d.sub.State), errStepSkipped
+	}
+	client, err := d.f.NewSubscriptionClient()
+	if err != nil {
+		return "", fmt.Errorf("cannot create subscription client, %s", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, subscriptionCancelTimeout)
+	defer cancel()
+
+	// Not found is retried as a subscription that has just been created by an alias
+	// is not immediately visible to the cancel API.
+	err = d.f.retryPolicy(ctx).WithRetryOn(ErrorClassNotFound).Do(ctx, func(ctx context.Context) error {
+		_, err := client.Cancel(ctx, d.id.String(), nil)
+		if err == nil {
+			return nil
+		}
+		// A previous attempt may have cancelled the subscription and then failed to return the response.
+		if d.refresh(ctx) == nil && !d.active() {
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		return "", fmt.Errorf("cannot cancel subscription %s, %v", d.id, err)
+	}
+	d.t.Logf("cancelled subscription %s", d.id)
+	return "cancelled", nil
+}
+
+// refresh reads the subscription again.
+func (d *decommission) refresh(ctx context.Context) error {
+	sub, err := d.f.GetSubscription(ctx, d.id)
+	if err != nil {
+		return fmt.Errorf("cannot get subscription %s, %v", d.id, err)
+	}
+	d.sub = sub.Subscription
+	return nil
+}
+
+// deleteAliases deletes every alias that refers to the subscription.
+func (d *decommission) deleteAliases(ctx context.Context) (string, error) {
+	aliases, err := d.f.ListAliases(ctx)
+	if err != nil {
+		return "", err
+	}
+	var names []string
+	for _, a := range aliases {
+		if a.Name == nil || a.Properties == nil || a.Properties.SubscriptionID == nil ||
+			!strings.EqualFold(*a.Properties.SubscriptionID, d.id.String()) {
+			continue
+		}
+		if err := d.f.DeleteAlias(ctx, *a.Name); err != nil {
+			return "", err
+		}
+		names = append(names, *a.Name)
+	}
+	if len(names) == 0 {
+		return "no aliases", nil
+	}
+	return "deleted aliases " + strings.Join(names, ", "), nil
+}
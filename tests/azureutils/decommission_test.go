@@ -0,0 +1,190 @@
+package azureutils
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Azure/terraform-azurerm-lz-vending/tests/fakearm"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// addDecommissionFixture adds a landing zone subscription with an alias, a resource group,
+// a user assigned identity and role assignments at several scopes.
+func addDecommissionFixture(srv *fakearm.Server, id uuid.UUID) {
+	sub := "/subscriptions/" + id.String()
+	srv.AddSubscription(fakearm.Subscription{ID: id, DisplayName: "lz-app1", ManagementGroup: "landingzones"})
+	srv.AddAlias(fakearm.Alias{Name: "lz-app1", SubscriptionID: id})
+	srv.AddResourceGroup(id, "rg-identity", "westeurope")
+	srv.PutResource(sub+"/resourceGroups/rg-identity/providers/Microsoft.ManagedIdentity/userAssignedIdentities/umi1", nil)
+	roleAssignment := func(scope, name, principal string) {
+		srv.PutResource(scope+"/providers/Microsoft.Authorization/roleAssignments/"+name, map[string]any{
+			"properties": map[string]any{"principalId": principal},
+		})
+	}
+	roleAssignment(sub, "ra-sub", "principal-app")
+	roleAssignment(sub+"/resourceGroups/rg-identity", "ra-rg", "principal-app")
+	roleAssignment(sub, "ra-keep", "principal-pipeline")
+	roleAssignment("/providers/Microsoft.Management/managementGroups/landingzones", "ra-inherited", "principal-platform")
+}
+
+// TestDecommission tests that every step of the pipeline is run against the subscription.
+func TestDecommission(t *testing.T) {
+	f, srv := newFakeClientFactory(t, nil)
+	id := uuid.New()
+	addDecommissionFixture(srv, id)
+	now := time.Date(2024, 5, 17, 10, 0, 0, 0, time.UTC)
+
+	var progress []DecommissionStep
+	status, err := f.Decommission(context.Background(), t, id, &DecommissionOptions{
+		QuarantineManagementGroup:    "quarantine",
+		KeepRoleAssignmentPrincipals: []string{"PRINCIPAL-PIPELINE"},
+		Progress: func(st DecommissionStepStatus) {
+			progress = append(progress, st.Step)
+		},
+		Now: func() time.Time { return now },
+	})
+	require.NoError(t, err)
+	assert.True(t, status.Complete())
+	assert.Equal(t, DecommissionSteps, progress)
+	st, _ := status.Step(DecommissionStepRemoveRoleAssignments)
+	assert.Equal(t, "removed 2 role assignments", st.Detail)
+
+	sub, _ := srv.Subscription(id)
+	assert.Equal(t, "quarantine", sub.ManagementGroup)
+	assert.Equal(t, "decommissioned-lz-app1", sub.DisplayName)
+	assert.Equal(t, map[string]string{DefaultCancelDateTag: "2024-05-17"}, sub.Tags)
+	assert.Equal(t, fakearm.SubscriptionStateWarned, sub.State)
+	assert.Empty(t, srv.ResourceGroups(id))
+	_, ok := srv.Alias("lz-app1")
+	assert.False(t, ok)
+	assert.Equal(t, []string{"/subscriptions/" + id.String() + "/providers/Microsoft.Authorization/roleAssignments/ra-keep"},
+		srv.ResourceIDs("/subscriptions/"+id.String()))
+	assert.Len(t, srv.ResourceIDs("/providers/Microsoft.Management/managementGroups/landingzones"), 1, "expected the inherited role assignment to be kept")
+}
+
+// TestDecommissionKeepsCaller tests that the role assignments of the principal running the decommission are kept
+// when no principals are configured, so that the steps after the removal can still be run.
+func TestDecommissionKeepsCaller(t *testing.T) {
+	f, srv := newFakeClientFactory(t, nil)
+	id := uuid.New()
+	addDecommissionFixture(srv, id)
+	owner := "/subscriptions/" + id.String() + "/providers/Microsoft.Authorization/roleAssignments/ra-owner"
+	srv.PutResource(owner, map[string]any{
+		"properties": map[string]any{
+			"principalId":      fakearm.CallerObjectID,
+			"roleDefinitionId": "/subscriptions/" + id.String() + "/providers/Microsoft.Authorization/roleDefinitions/8e3af657-a8ff-443c-a75c-2fe8c4bcb635",
+		},
+	})
+
+	status, err := f.Decommission(context.Background(), t, id, nil)
+	require.NoError(t, err)
+	assert.True(t, status.Complete())
+	st, _ := status.Step(DecommissionStepRemoveRoleAssignments)
+	assert.Equal(t, "removed 3 role assignments", st.Detail)
+	assert.Equal(t, []string{owner}, srv.ResourceIDs("/subscriptions/"+id.String()))
+}
+
+// TestDecommissionResume tests that a failed run can be resumed from the failed step,
+// using a status that has been saved as JSON.
+func TestDecommissionResume(t *testing.T) {
+	f, srv := newFakeClientFactory(t, nil)
+	id := uuid.New()
+	addDecommissionFixture(srv, id)
+	srv.InjectFault(fakearm.Fault{
+		Method:       http.MethodPost,
+		PathContains: "/rename",
+		StatusCode:   http.StatusInternalServerError,
+		Code:         "InternalServerError",
+		Message:      "Something went wrong.",
+		Count:        1,
+	})
+	opts := &DecommissionOptions{QuarantineManagementGroup: "quarantine"}
+
+	status, err := f.Decommission(context.Background(), t, id, opts)
+	require.ErrorContains(t, err, "step rename failed")
+	assert.False(t, status.Complete())
+	wantStates := map[DecommissionStep]DecommissionStepState{
+		DecommissionStepQuarantine:              DecommissionStepSucceeded,
+		DecommissionStepRemoveRoleAssignments:   DecommissionStepSucceeded,
+		DecommissionStepRemoveManagedIdentities: DecommissionStepSucceeded,
+		DecommissionStepDeleteResourceGroups:    DecommissionStepSucceeded,
+		DecommissionStepRename:                  DecommissionStepFailed,
+		DecommissionStepTag:                     DecommissionStepPending,
+		DecommissionStepCancel:                  DecommissionStepPending,
+		DecommissionStepDeleteAlias:             DecommissionStepPending,
+	}
+	for step, want := range wantStates {
+		st, ok := status.Step(step)
+		require.True(t, ok)
+		assert.Equal(t, want, st.State, step)
+	}
+	sub, _ := srv.Subscription(id)
+	assert.Equal(t, fakearm.SubscriptionStateEnabled, sub.State)
+
+	b, err := json.Marshal(status)
+	require.NoError(t, err)
+	opts.Resume = &DecommissionStatus{}
+	require.NoError(t, json.Unmarshal(b, opts.Resume))
+
+	status, err = f.Decommission(context.Background(), t, id, opts)
+	require.NoError(t, err)
+	assert.True(t, status.Complete())
+	assert.Equal(t, 1, srv.RequestCount(http.MethodPut, "/managementGroups/quarantine/subscriptions/"), "expected the quarantine step not to be repeated")
+	sub, _ = srv.Subscription(id)
+	assert.Equal(t, "decommissioned-lz-app1", sub.DisplayName)
+	assert.Equal(t, fakearm.SubscriptionStateWarned, sub.State)
+}
+
+// TestDecommissionResumeOtherSubscription tests that the status of another subscription is refused.
+func TestDecommissionResumeOtherSubscription(t *testing.T) {
+	f, _ := newFakeClientFactory(t, nil)
+	_, err := f.Decommission(context.Background(), t, uuid.New(), &DecommissionOptions{
+		Resume: &DecommissionStatus{SubscriptionID: uuid.New()},
+	})
+	assert.ErrorContains(t, err, "cannot resume decommission")
+}
+
+// TestDecommissionCancelledDuringRun tests that the cancel step reads the subscription state again,
+// and is skipped if the subscription was cancelled after the pipeline started.
+func TestDecommissionCancelledDuringRun(t *testing.T) {
+	f, srv := newFakeClientFactory(t, nil)
+	id := uuid.New()
+	srv.AddSubscription(fakearm.Subscription{ID: id, DisplayName: "lz-app3"})
+
+	status, err := f.Decommission(context.Background(), t, id, &DecommissionOptions{
+		Steps: []DecommissionStep{DecommissionStepDeleteResourceGroups, DecommissionStepCancel},
+		Progress: func(st DecommissionStepStatus) {
+			if st.Step == DecommissionStepDeleteResourceGroups {
+				srv.AddSubscription(fakearm.Subscription{ID: id, DisplayName: "lz-app3", State: fakearm.SubscriptionStateWarned})
+			}
+		},
+	})
+	require.NoError(t, err)
+	st, _ := status.Step(DecommissionStepCancel)
+	assert.Equal(t, DecommissionStepSkipped, st.State)
+	assert.Zero(t, srv.RequestCount(http.MethodPost, "/cancel"))
+}
+
+// TestDecommissionCancelledSubscription tests that the steps that modify the subscription
+// are skipped once it has been cancelled, and that the quarantine step is skipped
+// when no management group is configured.
+func TestDecommissionCancelledSubscription(t *testing.T) {
+	f, srv := newFakeClientFactory(t, nil)
+	id := uuid.New()
+	srv.AddSubscription(fakearm.Subscription{ID: id, DisplayName: "lz-app2", State: fakearm.SubscriptionStateWarned})
+
+	status, err := f.Decommission(context.Background(), t, id, nil)
+	require.NoError(t, err)
+	for _, step := range []DecommissionStep{DecommissionStepQuarantine, DecommissionStepRename, DecommissionStepTag, DecommissionStepCancel} {
+		st, _ := status.Step(step)
+		assert.Equal(t, DecommissionStepSkipped, st.State, step)
+	}
+	sub, _ := srv.Subscription(id)
+	assert.Equal(t, "lz-app2", sub.DisplayName)
+	assert.Zero(t, srv.RequestCount(http.MethodPost, "/cancel"))
+}
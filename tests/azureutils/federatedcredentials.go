@@ -0,0 +1,257 @@
+package azureutils
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// federatedCredentialsPath is the path of the federated identity credentials below a user assigned identity.
+	federatedCredentialsPath = "/federatedIdentityCredentials"
+
+	githubActionsIssuer  = "https://token.actions.githubusercontent.com"
+	terraformCloudIssuer = "https://app.terraform.io"
+	// tokenExchangeAudience is the audience of the federated credentials created by the module for GitHub and Terraform Cloud.
+	tokenExchangeAudience = "api://AzureADTokenExchange"
+)
+
+// FederatedCredential is a federated identity credential of a user assigned identity.
+type FederatedCredential struct {
+	// Key identifies the entry of the input variable, e.g. github/gh1 for the gh1 key of federated_credentials_github.
+	// It is empty for the credentials returned by ListFederatedCredentials.
+	Key       string
+	Name      string
+	Issuer    string
+	Subject   string
+	Audiences []string
+}
+
+// String returns a description of the federated credential, used in a Difference.
+func (fc FederatedCredential) String() string {
+	return fmt.Sprintf("%s: %s for %s from %s", fc.Key, fc.Name, fc.Subject, fc.Issuer)
+}
+
+type federatedCredential struct {
+	armResource
+	Properties struct {
+		Issuer    string   `json:"issuer"`
+		Subject   string   `json:"subject"`
+		Audiences []string `json:"audiences"`
+	} `json:"properties"`
+}
+
+type userAssignedIdentity struct {
+	armResource
+	Properties struct {
+		ClientID    string `json:"clientId"`
+		PrincipalID string `json:"principalId"`
+	} `json:"properties"`
+}
+
+// ExpectedFederatedCredentials returns the federated credentials configured by an entry of the root module's
+// user_managed_identities input variable, with the names, issuers, subjects and audiences computed by the module.
+func ExpectedFederatedCredentials(umi map[string]any) ([]FederatedCredential, error) {
+	var out []FederatedCredential
+
+	github := mapOfMaps(umi["federated_credentials_github"])
+	for _, k := range sortedKeys(github) {
+		in := github[k]
+		org, _ := in["organization"].(string)
+		repo, _ := in["repository"].(string)
+		entity, _ := in["entity"].(string)
+		value, _ := in["value"].(string)
+		fc := FederatedCredential{
+			Key:       "github/" + k,
+			Issuer:    githubActionsIssuer,
+			Audiences: []string{tokenExchangeAudience},
+		}
+		if slug, _ := in["enterprise_slug"].(string); slug != "" {
+			fc.Issuer += "/" + slug
+		}
+		prefix := fmt.Sprintf("repo:%s/%s:", org, repo)
+		defaultName := fmt.Sprintf("github-%s-%s-%s-%s", org, repo, entity, value)
+		switch entity {
+		case "branch":
+			fc.Subject = prefix + "ref:refs/heads/" + value
+		case "tag":
+			fc.Subject = prefix + "ref:refs/tags/" + value
+		case "environment":
+			fc.Subject = prefix + "environment:" + value
+		case "pull_request":
+			fc.Subject = prefix + "pull_request"
+			defaultName = fmt.Sprintf("github-%s-%s-pull-request", org, repo)
+		default:
+			return nil, fmt.Errorf("federated credential %s has entity %s, which is not one of environment, pull_request, tag or branch", fc.Key, entity)
+		}
+		fc.Name = stringOrDefault(in["name"], defaultName)
+		out = append(out, fc)
+	}
+
+	tfc := mapOfMaps(umi["federated_credentials_terraform_cloud"])
+	for _, k := range sortedKeys(tfc) {
+		in := tfc[k]
+		org, _ := in["organization"].(string)
+		project, _ := in["project"].(string)
+		workspace, _ := in["workspace"].(string)
+		phase, _ := in["run_phase"].(string)
+		out = append(out, FederatedCredential{
+			Key:       "terraform_cloud/" + k,
+			Name:      stringOrDefault(in["name"], fmt.Sprintf("terraformcloud-%s-%s-%s-%s", org, project, workspace, phase)),
+			Issuer:    terraformCloudIssuer,
+			Subject:   fmt.Sprintf("organization:%s:project:%s:workspace:%s:run_phase:%s", org, project, workspace, phase),
+			Audiences: []string{tokenExchangeAudience},
+		})
+	}
+
+	advanced := mapOfMaps(umi["federated_credentials_advanced"])
+	for _, k := range sortedKeys(advanced) {
+		in := advanced[k]
+		fc := FederatedCredential{
+			Key:       "advanced/" + k,
+			Audiences: stringSlice(in["audiences"]),
+		}
+		fc.Name, _ = in["name"].(string)
+		fc.Issuer, _ = in["issuer_url"].(string)
+		fc.Subject, _ = in["subject_identifier"].(string)
+		if len(fc.Audiences) == 0 {
+			fc.Audiences = []string{tokenExchangeAudience}
+		}
+		out = append(out, fc)
+	}
+	return out, nil
+}
+
+// ListFederatedCredentials returns the federated identity credentials of the user assigned identity,
+// using the DefaultClientFactory.
+func ListFederatedCredentials(ctx context.Context, umiID string) ([]FederatedCredential, error) {
+	f, err := DefaultClientFactory()
+	if err != nil {
+		return nil, err
+	}
+	return f.ListFederatedCredentials(ctx, umiID)
+}
+
+// VerifyUserManagedIdentities compares the user assigned identities and their federated credentials
+// in Azure with the input, using the DefaultClientFactory.
+func VerifyUserManagedIdentities(ctx context.Context, subID uuid.UUID, umis, resourceGroups map[string]map[string]any, clientIDs, principalIDs map[string]string) (Diff, error) {
+	f, err := DefaultClientFactory()
+	if err != nil {
+		return nil, err
+	}
+	return f.VerifyUserManagedIdentities(ctx, subID, umis, resourceGroups, clientIDs, principalIDs)
+}
+
+// ListFederatedCredentials returns the federated identity credentials of the user assigned identity,
+// sorted by name.
+func (f *ClientFactory) ListFederatedCredentials(ctx context.Context, umiID string) ([]FederatedCredential, error) {
+	fcs, err := listARM[federatedCredential](ctx, f, umiID+federatedCredentialsPath, userAssignedIdentitiesAPIVersion)
+	if err != nil {
+		return nil, fmt.Errorf("cannot list federated credentials of %s, %v", umiID, err)
+	}
+	out := make([]FederatedCredential, 0, len(fcs))
+	for _, fc := range fcs {
+		out = append(out, FederatedCredential{
+			Name:      fc.Name,
+			Issuer:    fc.Properties.Issuer,
+			Subject:   fc.Properties.Subject,
+			Audiences: fc.Properties.Audiences,
+		})
+	}
+	slices.SortFunc(out, func(a, b FederatedCredential) int { return strings.Compare(a.Name, b.Name) })
+	return out, nil
+}
+
+// VerifyUserManagedIdentities compares the user assigned identities in Azure with the root module's
+// user_managed_identities input variable.
+// The resourceGroups are the root module's resource_groups input variable,
+// and are used to find the resource group of entries with a resource_group_key.
+// The clientIDs and principalIDs are the umi_client_ids and umi_principal_ids outputs,
+// and are compared with the identities in Azure.
+// The federated credentials of each identity are matched by name, and their issuer, subject and audiences are compared.
+// Identities and federated credentials that are not found are reported with a nil Actual,
+// and federated credentials that were not expected are reported with a nil Expected.
+func (f *ClientFactory) VerifyUserManagedIdentities(ctx context.Context, subID uuid.UUID, umis, resourceGroups map[string]map[string]any, clientIDs, principalIDs map[string]string) (Diff, error) {
+	diff := Diff{}
+	for _, k := range sortedKeys(umis) {
+		id, err := userAssignedIdentityID(subID, k, umis[k], resourceGroups)
+		if err != nil {
+			return nil, err
+		}
+		var umi userAssignedIdentity
+		if err := f.doARM(ctx, http.MethodGet, id, userAssignedIdentitiesAPIVersion, nil, &umi); err != nil {
+			if isNotFound(err) {
+				diff.compare(id, "user_managed_identity", k, nil)
+				continue
+			}
+			return nil, fmt.Errorf("cannot get user assigned identity %s, %v", id, err)
+		}
+		diff.compare(id, "client_id", strings.ToLower(clientIDs[k]), strings.ToLower(umi.Properties.ClientID))
+		diff.compare(id, "principal_id", strings.ToLower(principalIDs[k]), strings.ToLower(umi.Properties.PrincipalID))
+
+		expected, err := ExpectedFederatedCredentials(umis[k])
+		if err != nil {
+			return nil, err
+		}
+		actual, err := f.ListFederatedCredentials(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		diff = append(diff, compareFederatedCredentials(id, expected, actual)...)
+	}
+	return diff, nil
+}
+
+// compareFederatedCredentials compares the federated credentials of a user assigned identity, matched by name.
+func compareFederatedCredentials(umiID string, expected, actual []FederatedCredential) Diff {
+	diff := Diff{}
+	byName := make(map[string]FederatedCredential, len(actual))
+	for _, fc := range actual {
+		byName[strings.ToLower(fc.Name)] = fc
+	}
+	for _, want := range expected {
+		id := umiID + federatedCredentialsPath + "/" + want.Name
+		got, ok := byName[strings.ToLower(want.Name)]
+		if !ok {
+			diff.compare(id, "federated_credential", want.String(), nil)
+			continue
+		}
+		delete(byName, strings.ToLower(want.Name))
+		diff.compare(id, "issuer", want.Issuer, got.Issuer)
+		diff.compare(id, "subject", want.Subject, got.Subject)
+		diff.compareSet(id, "audiences", nilIfEmpty(want.Audiences), nilIfEmpty(got.Audiences))
+	}
+	for _, k := range sortedKeys(byName) {
+		got := byName[k]
+		diff.compare(umiID+federatedCredentialsPath+"/"+got.Name, "federated_credential", nil,
+			fmt.Sprintf("%s for %s from %s", got.Name, got.Subject, got.Issuer))
+	}
+	return diff
+}
+
+// userAssignedIdentityID returns the resource ID of an entry of the user_managed_identities input variable.
+func userAssignedIdentityID(subID uuid.UUID, key string, umi map[string]any, resourceGroups map[string]map[string]any) (string, error) {
+	rg, _ := umi["resource_group_name_existing"].(string)
+	if rgKey, _ := umi["resource_group_key"].(string); rgKey != "" {
+		in, ok := resourceGroups[rgKey]
+		if !ok {
+			return "", fmt.Errorf("user managed identity %s has resource_group_key %s, which is not in resource_groups", key, rgKey)
+		}
+		rg, _ = in["name"].(string)
+	}
+	if rg == "" {
+		return "", fmt.Errorf("user managed identity %s has neither resource_group_key nor resource_group_name_existing", key)
+	}
+	return fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/Microsoft.ManagedIdentity/userAssignedIdentities/%s", subID, rg, umi["name"]), nil
+}
+
+func stringOrDefault(v any, dv string) string {
+	if s, ok := v.(string); ok && s != "" {
+		return s
+	}
+	return dv
+}
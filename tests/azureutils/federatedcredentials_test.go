@@ -0,0 +1,101 @@
+package azureutils
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Azure/terraform-azurerm-lz-vending/tests/fakearm"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestExpectedFederatedCredentials tests the names, issuers and subjects computed from the federated credential inputs.
+func TestExpectedFederatedCredentials(t *testing.T) {
+	aud := []string{tokenExchangeAudience}
+	got, err := ExpectedFederatedCredentials(map[string]any{
+		"name": "umi",
+		"federated_credentials_github": map[string]any{
+			"branch": map[string]any{"organization": "org", "repository": "repo", "entity": "branch", "value": "main"},
+			"env":    map[string]any{"organization": "org", "repository": "repo", "entity": "environment", "value": "prod", "enterprise_slug": "ent", "name": "custom"},
+			"pr":     map[string]any{"organization": "org", "repository": "repo", "entity": "pull_request"},
+			"tag":    map[string]any{"organization": "org", "repository": "repo", "entity": "tag", "value": "v1"},
+		},
+		"federated_credentials_terraform_cloud": map[string]any{
+			"plan": map[string]any{"organization": "org", "project": "proj", "workspace": "ws", "run_phase": "plan"},
+		},
+		"federated_credentials_advanced": map[string]any{
+			"k8s": map[string]any{"name": "k8s", "issuer_url": "https://oidc.example.com", "subject_identifier": "system:serviceaccount:ns:sa"},
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []FederatedCredential{
+		{Key: "github/branch", Name: "github-org-repo-branch-main", Issuer: githubActionsIssuer, Subject: "repo:org/repo:ref:refs/heads/main", Audiences: aud},
+		{Key: "github/env", Name: "custom", Issuer: githubActionsIssuer + "/ent", Subject: "repo:org/repo:environment:prod", Audiences: aud},
+		{Key: "github/pr", Name: "github-org-repo-pull-request", Issuer: githubActionsIssuer, Subject: "repo:org/repo:pull_request", Audiences: aud},
+		{Key: "github/tag", Name: "github-org-repo-tag-v1", Issuer: githubActionsIssuer, Subject: "repo:org/repo:ref:refs/tags/v1", Audiences: aud},
+		{Key: "terraform_cloud/plan", Name: "terraformcloud-org-proj-ws-plan", Issuer: terraformCloudIssuer, Subject: "organization:org:project:proj:workspace:ws:run_phase:plan", Audiences: aud},
+		{Key: "advanced/k8s", Name: "k8s", Issuer: "https://oidc.example.com", Subject: "system:serviceaccount:ns:sa", Audiences: aud},
+	}, got)
+
+	_, err = ExpectedFederatedCredentials(map[string]any{
+		"federated_credentials_github": map[string]any{
+			"bad": map[string]any{"organization": "org", "repository": "repo", "entity": "commit"},
+		},
+	})
+	assert.ErrorContains(t, err, "federated credential github/bad has entity commit")
+}
+
+// TestVerifyUserManagedIdentities tests that the identity IDs and federated credentials are compared,
+// and that mismatched, missing and extra credentials and missing identities are reported.
+func TestVerifyUserManagedIdentities(t *testing.T) {
+	f, srv := newFakeClientFactory(t, nil)
+	id := uuid.New()
+	srv.AddSubscription(fakearm.Subscription{ID: id, DisplayName: "sub", State: "Enabled"})
+	srv.AddResourceGroup(id, "rg-identity", "westeurope")
+	umiID := "/subscriptions/" + id.String() + "/resourceGroups/rg-identity/providers/Microsoft.ManagedIdentity/userAssignedIdentities/umi1"
+	srv.PutResource(umiID, map[string]any{"properties": map[string]any{
+		"clientId":    "11111111-1111-1111-1111-111111111111",
+		"principalId": "22222222-2222-2222-2222-222222222222",
+	}})
+	srv.PutResource(umiID+"/federatedIdentityCredentials/github-org-repo-branch-main", map[string]any{"properties": map[string]any{
+		"issuer":    githubActionsIssuer,
+		"subject":   "repo:org/repo:ref:refs/heads/master",
+		"audiences": []string{tokenExchangeAudience},
+	}})
+	srv.PutResource(umiID+"/federatedIdentityCredentials/manual", map[string]any{"properties": map[string]any{
+		"issuer":    githubActionsIssuer,
+		"subject":   "repo:org/other:pull_request",
+		"audiences": []string{tokenExchangeAudience},
+	}})
+
+	umis := map[string]map[string]any{
+		"default": {
+			"name":               "umi1",
+			"resource_group_key": "rg1",
+			"federated_credentials_github": map[string]any{
+				"branch": map[string]any{"organization": "org", "repository": "repo", "entity": "branch", "value": "main"},
+			},
+			"federated_credentials_terraform_cloud": map[string]any{
+				"apply": map[string]any{"organization": "org", "project": "proj", "workspace": "ws", "run_phase": "apply"},
+			},
+		},
+		"missing": {"name": "umi2", "resource_group_name_existing": "rg-identity"},
+	}
+	diff, err := f.VerifyUserManagedIdentities(context.Background(), id, umis,
+		map[string]map[string]any{"rg1": {"name": "rg-identity"}},
+		map[string]string{"default": "11111111-1111-1111-1111-111111111111"},
+		map[string]string{"default": "33333333-3333-3333-3333-333333333333"},
+	)
+	require.NoError(t, err)
+	fcs := umiID + "/federatedIdentityCredentials/"
+	assert.Equal(t, Diff{
+		{ResourceID: umiID, Property: "principal_id", Expected: "33333333-3333-3333-3333-333333333333", Actual: "22222222-2222-2222-2222-222222222222"},
+		{ResourceID: fcs + "github-org-repo-branch-main", Property: "subject", Expected: "repo:org/repo:ref:refs/heads/main", Actual: "repo:org/repo:ref:refs/heads/master"},
+		{ResourceID: fcs + "terraformcloud-org-proj-ws-apply", Property: "federated_credential",
+			Expected: "terraform_cloud/apply: terraformcloud-org-proj-ws-apply for organization:org:project:proj:workspace:ws:run_phase:apply from " + terraformCloudIssuer},
+		{ResourceID: fcs + "manual", Property: "federated_credential", Actual: "manual for repo:org/other:pull_request from " + githubActionsIssuer},
+		{ResourceID: "/subscriptions/" + id.String() + "/resourceGroups/rg-identity/providers/Microsoft.ManagedIdentity/userAssignedIdentities/umi2",
+			Property: "user_managed_identity", Expected: "missing"},
+	}, diff)
+}
@@ -0,0 +1,558 @@
+package azureutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/network/armnetwork"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+	"github.com/google/uuid"
+)
+
+const (
+	// fixtureTimeout is the budget given to creating or deleting a fixture.
+	// A virtual hub takes around 20 minutes to finish provisioning its router.
+	fixtureTimeout = 45 * time.Minute
+
+	defaultFixtureLocation         = "westeurope"
+	defaultHubAddressSpace         = "192.168.10.0/23"
+	defaultHubSubnetPrefix         = "192.168.10.0/24"
+	defaultHubGatewaySubnetPrefix  = "192.168.11.0/24"
+	defaultVirtualHubAddressPrefix = "192.168.100.0/23"
+	defaultVirtualHubPollInterval  = 30 * time.Second
+)
+
+// HubNetworkOptions are the options for a hub virtual network fixture.
+type HubNetworkOptions struct {
+	// Name is the name of the resource group and of the virtual network.
+	Name string
+	// Location defaults to westeurope.
+	Location string
+	// AddressSpace defaults to 192.168.10.0/23.
+	AddressSpace []string
+	// SubnetPrefix is the address prefix of the default subnet, default 192.168.10.0/24.
+	SubnetPrefix string
+	// GatewaySubnet adds a GatewaySubnet with the GatewaySubnetPrefix, default 192.168.11.0/24.
+	GatewaySubnet       bool
+	GatewaySubnetPrefix string
+}
+
+// HubNetwork is a hub virtual network created by a fixture.
+type HubNetwork struct {
+	SubscriptionID    uuid.UUID
+	ResourceGroupName string
+	// ID is the resource ID of the virtual network, for the hub_network_resource_id input.
+	ID string
+}
+
+// VirtualHubOptions are the options for a virtual WAN and virtual hub fixture.
+type VirtualHubOptions struct {
+	// Name is the name of the resource group and of the virtual hub.
+	// The virtual WAN is named with a -vwan suffix.
+	Name string
+	// Location defaults to westeurope.
+	Location string
+	// AddressPrefix defaults to 192.168.100.0/23.
+	AddressPrefix string
+	// PollInterval is the time between polls of the hub routing state, default 30 seconds.
+	PollInterval time.Duration
+	// RoutingIntent adds a Standard Azure Firewall, with a firewall policy, to the hub,
+	// and a routing intent that sends Internet and private traffic to it.
+	RoutingIntent bool
+}
+
+// VirtualHub is a virtual WAN and virtual hub created by a fixture.
+type VirtualHub struct {
+	SubscriptionID    uuid.UUID
+	ResourceGroupName string
+	VirtualWanID      string
+	// ID is the resource ID of the virtual hub, for the vwan_hub_resource_id input.
+	ID string
+	// FirewallID and RoutingIntentID are set if the hub was created with VirtualHubOptions.RoutingIntent.
+	FirewallID      string
+	RoutingIntentID string
+}
+
+// NewHubNetwork creates a hub virtual network that is deleted when the test completes,
+// using the DefaultClientFactory.
+func NewHubNetwork(t *testing.T, subID uuid.UUID, opts *HubNetworkOptions) *HubNetwork {
+	f, err := DefaultClientFactory()
+	if err != nil {
+		t.Fatalf("cannot create client factory, %v", err)
+	}
+	return f.NewHubNetwork(t, subID, opts)
+}
+
+// NewVirtualHub creates a virtual WAN and virtual hub that are deleted when the test completes,
+// using the DefaultClientFactory.
+func NewVirtualHub(t *testing.T, subID uuid.UUID, opts *VirtualHubOptions) *VirtualHub {
+	f, err := DefaultClientFactory()
+	if err != nil {
+		t.Fatalf("cannot create client factory, %v", err)
+	}
+	return f.NewVirtualHub(t, subID, opts)
+}
+
+// CreateHubNetwork creates a hub virtual network in a new resource group,
+// using the DefaultClientFactory.
+func CreateHubNetwork(ctx context.Context, subID uuid.UUID, opts *HubNetworkOptions) (*HubNetwork, error) {
+	f, err := DefaultClientFactory()
+	if err != nil {
+		return nil, err
+	}
+	return f.CreateHubNetwork(ctx, subID, opts)
+}
+
+// CreateVirtualHub creates a virtual WAN and virtual hub in a new resource group,
+// using the DefaultClientFactory.
+func CreateVirtualHub(ctx context.Context, subID uuid.UUID, opts *VirtualHubOptions) (*VirtualHub, error) {
+	f, err := DefaultClientFactory()
+	if err != nil {
+		return nil, err
+	}
+	return f.CreateVirtualHub(ctx, subID, opts)
+}
+
+// NewHubNetwork creates a hub virtual network, and registers its deletion with t.Cleanup.
+// The creation is bounded by the test deadline, less the time kept back for the deletion.
+// The test fails immediately if the hub cannot be created.
+func (f *ClientFactory) NewHubNetwork(t *testing.T, subID uuid.UUID, opts *HubNetworkOptions) *HubNetwork {
+	ctx, cancel := newFixtureContext(t, fixtureDeleteReserve)
+	defer cancel()
+	hub, err := f.CreateHubNetwork(ctx, subID, opts)
+	if hub != nil {
+		t.Cleanup(func() { f.cleanupFixture(t, hub.Delete) })
+	}
+	if err != nil {
+		t.Fatalf("cannot create hub network, %v", err)
+	}
+	return hub
+}
+
+// NewVirtualHub creates a virtual WAN and virtual hub, and registers their deletion with t.Cleanup.
+// The creation is bounded by the test deadline, less the time kept back for the deletion.
+// The test fails immediately if the hub cannot be created.
+func (f *ClientFactory) NewVirtualHub(t *testing.T, subID uuid.UUID, opts *VirtualHubOptions) *VirtualHub {
+	ctx, cancel := newFixtureContext(t, fixtureDeleteReserve)
+	defer cancel()
+	hub, err := f.CreateVirtualHub(ctx, subID, opts)
+	if hub != nil {
+		t.Cleanup(func() { f.cleanupFixture(t, hub.Delete) })
+	}
+	if err != nil {
+		t.Fatalf("cannot create virtual hub, %v", err)
+	}
+	return hub
+}
+
+// cleanupFixture deletes a fixture, failing the test if it cannot.
+// The delete is bounded by the time left before the test deadline.
+func (f *ClientFactory) cleanupFixture(t *testing.T, del func(context.Context, *ClientFactory) error) {
+	ctx, cancel := newFixtureContext(t, 0)
+	defer cancel()
+	if err := del(ctx, f); err != nil {
+		t.Errorf("cannot delete test fixture, %v", err)
+	}
+}
+
+// CreateHubNetwork creates a hub virtual network in a new resource group.
+// If the resource group is created but the virtual network is not, the hub is returned with the error
+// so that the caller can delete it.
+func (f *ClientFactory) CreateHubNetwork(ctx context.Context, subID uuid.UUID, opts *HubNetworkOptions) (*HubNetwork, error) {
+	if opts == nil || opts.Name == "" {
+		return nil, errors.New("cannot create hub network, the name is required")
+	}
+	o := *opts
+	o.Location = stringOrDefault(o.Location, defaultFixtureLocation)
+	if len(o.AddressSpace) == 0 {
+		o.AddressSpace = []string{defaultHubAddressSpace}
+	}
+	o.SubnetPrefix = stringOrDefault(o.SubnetPrefix, defaultHubSubnetPrefix)
+	o.GatewaySubnetPrefix = stringOrDefault(o.GatewaySubnetPrefix, defaultHubGatewaySubnetPrefix)
+
+	if err := f.createFixtureResourceGroup(ctx, subID, o.Name, o.Location); err != nil {
+		return nil, err
+	}
+	hub := &HubNetwork{SubscriptionID: subID, ResourceGroupName: o.Name}
+
+	subnets := []*armnetwork.Subnet{{
+		Name:       to.Ptr("default"),
+		Properties: &armnetwork.SubnetPropertiesFormat{AddressPrefix: to.Ptr(o.SubnetPrefix)},
+	}}
+	if o.GatewaySubnet {
+		subnets = append(subnets, &armnetwork.Subnet{
+			Name:       to.Ptr("GatewaySubnet"),
+			Properties: &armnetwork.SubnetPropertiesFormat{AddressPrefix: to.Ptr(o.GatewaySubnetPrefix)},
+		})
+	}
+	client, err := f.NewVirtualNetworksClient(subID)
+	if err != nil {
+		return hub, err
+	}
+	poller, err := client.BeginCreateOrUpdate(ctx, o.Name, o.Name, armnetwork.VirtualNetwork{
+		Location: to.Ptr(o.Location),
+		Properties: &armnetwork.VirtualNetworkPropertiesFormat{
+			AddressSpace: &armnetwork.AddressSpace{AddressPrefixes: to.SliceOfPtrs(o.AddressSpace...)},
+			Subnets:      subnets,
+		},
+	}, nil)
+	if err != nil {
+		return hub, fmt.Errorf("cannot create hub virtual network %s, %v", o.Name, err)
+	}
+	resp, err := poller.PollUntilDone(ctx, nil)
+	if err != nil {
+		return hub, fmt.Errorf("cannot create hub virtual network %s, %v", o.Name, err)
+	}
+	hub.ID = stringValue(resp.ID)
+	return hub, nil
+}
+
+// CreateVirtualHub creates a Standard virtual WAN and virtual hub in a new resource group,
+// and waits for the hub router to be provisioned, as connections cannot be made until it is.
+// If the resource group is created but the hub is not, the hub is returned with the error
+// so that the caller can delete it.
+func (f *ClientFactory) CreateVirtualHub(ctx context.Context, subID uuid.UUID, opts *VirtualHubOptions) (*VirtualHub, error) {
+	if opts == nil || opts.Name == "" {
+		return nil, errors.New("cannot create virtual hub, the name is required")
+	}
+	o := *opts
+	o.Location = stringOrDefault(o.Location, defaultFixtureLocation)
+	o.AddressPrefix = stringOrDefault(o.AddressPrefix, defaultVirtualHubAddressPrefix)
+	if o.PollInterval == 0 {
+		o.PollInterval = defaultVirtualHubPollInterval
+	}
+
+	if err := f.createFixtureResourceGroup(ctx, subID, o.Name, o.Location); err != nil {
+		return nil, err
+	}
+	hub := &VirtualHub{SubscriptionID: subID, ResourceGroupName: o.Name}
+
+	wans, err := f.NewVirtualWansClient(subID)
+	if err != nil {
+		return hub, err
+	}
+	wanPoller, err := wans.BeginCreateOrUpdate(ctx, o.Name, o.Name+"-vwan", armnetwork.VirtualWAN{
+		Location: to.Ptr(o.Location),
+		Properties: &armnetwork.VirtualWanProperties{
+			Type:                       to.Ptr("Standard"),
+			AllowBranchToBranchTraffic: to.Ptr(true),
+			DisableVPNEncryption:       to.Ptr(false),
+		},
+	}, nil)
+	if err != nil {
+		return hub, fmt.Errorf("cannot create virtual WAN %s-vwan, %v", o.Name, err)
+	}
+	wan, err := wanPoller.PollUntilDone(ctx, nil)
+	if err != nil {
+		return hub, fmt.Errorf("cannot create virtual WAN %s-vwan, %v", o.Name, err)
+	}
+	hub.VirtualWanID = stringValue(wan.ID)
+
+	hubs, err := f.NewVirtualHubsClient(subID)
+	if err != nil {
+		return hub, err
+	}
+	hubPoller, err := hubs.BeginCreateOrUpdate(ctx, o.Name, o.Name, armnetwork.VirtualHub{
+		Location: to.Ptr(o.Location),
+		Properties: &armnetwork.VirtualHubProperties{
+			AddressPrefix: to.Ptr(o.AddressPrefix),
+			SKU:           to.Ptr("Standard"),
+			VirtualWan:    &armnetwork.SubResource{ID: wan.ID},
+		},
+	}, nil)
+	if err != nil {
+		return hub, fmt.Errorf("cannot create virtual hub %s, %v", o.Name, err)
+	}
+	vhub, err := hubPoller.PollUntilDone(ctx, nil)
+	if err != nil {
+		return hub, fmt.Errorf("cannot create virtual hub %s, %v", o.Name, err)
+	}
+	hub.ID = stringValue(vhub.ID)
+
+	for {
+		resp, err := hubs.Get(ctx, o.Name, o.Name, nil)
+		if err != nil {
+			return hub, fmt.Errorf("cannot get virtual hub %s, %v", o.Name, err)
+		}
+		var state armnetwork.RoutingState
+		if resp.Properties != nil && resp.Properties.RoutingState != nil {
+			state = *resp.Properties.RoutingState
+		}
+		switch state {
+		case armnetwork.RoutingStateProvisioned:
+			if o.RoutingIntent {
+				return hub, f.createRoutingIntent(ctx, hub, o)
+			}
+			return hub, nil
+		case armnetwork.RoutingStateFailed:
+			return hub, fmt.Errorf("virtual hub %s routing state is %s", o.Name, state)
+		}
+		select {
+		case <-ctx.Done():
+			return hub, fmt.Errorf("cannot wait for virtual hub %s routing state, %v", o.Name, ctx.Err())
+		case <-time.After(o.PollInterval):
+		}
+	}
+}
+
+// createRoutingIntent creates the firewall policy and hub firewall of the virtual hub,
+// and the routing intent that uses the firewall as the next hop.
+func (f *ClientFactory) createRoutingIntent(ctx context.Context, hub *VirtualHub, o VirtualHubOptions) error {
+	policies, err := f.NewFirewallPoliciesClient(hub.SubscriptionID)
+	if err != nil {
+		return err
+	}
+	policyPoller, err := policies.BeginCreateOrUpdate(ctx, o.Name, o.Name+"-fwpol", armnetwork.FirewallPolicy{
+		Location: to.Ptr(o.Location),
+		Properties: &armnetwork.FirewallPolicyPropertiesFormat{
+			SKU:             &armnetwork.FirewallPolicySKU{Tier: to.Ptr(armnetwork.FirewallPolicySKUTierStandard)},
+			ThreatIntelMode: to.Ptr(armnetwork.AzureFirewallThreatIntelModeAlert),
+		},
+	}, nil)
+	if err != nil {
+		return fmt.Errorf("cannot create firewall policy %s-fwpol, %v", o.Name, err)
+	}
+	policy, err := policyPoller.PollUntilDone(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("cannot create firewall policy %s-fwpol, %v", o.Name, err)
+	}
+
+	firewalls, err := f.NewAzureFirewallsClient(hub.SubscriptionID)
+	if err != nil {
+		return err
+	}
+	fwPoller, err := firewalls.BeginCreateOrUpdate(ctx, o.Name, o.Name+"-fw", armnetwork.AzureFirewall{
+		Location: to.Ptr(o.Location),
+		Properties: &armnetwork.AzureFirewallPropertiesFormat{
+			SKU: &armnetwork.AzureFirewallSKU{
+				Name: to.Ptr(armnetwork.AzureFirewallSKUNameAZFWHub),
+				Tier: to.Ptr(armnetwork.AzureFirewallSKUTierStandard),
+			},
+			VirtualHub:     &armnetwork.SubResource{ID: to.Ptr(hub.ID)},
+			HubIPAddresses: &armnetwork.HubIPAddresses{PublicIPs: &armnetwork.HubPublicIPAddresses{Count: to.Ptr[int32](1)}},
+			FirewallPolicy: &armnetwork.SubResource{ID: policy.ID},
+		},
+	}, nil)
+	if err != nil {
+		return fmt.Errorf("cannot create hub firewall %s-fw, %v", o.Name, err)
+	}
+	fw, err := fwPoller.PollUntilDone(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("cannot create hub firewall %s-fw, %v", o.Name, err)
+	}
+	hub.FirewallID = stringValue(fw.ID)
+
+	intents, err := f.NewRoutingIntentClient(hub.SubscriptionID)
+	if err != nil {
+		return err
+	}
+	intentPoller, err := intents.BeginCreateOrUpdate(ctx, o.Name, lastSegment(hub.ID), o.Name+"-routingintent", armnetwork.RoutingIntent{
+		Properties: &armnetwork.RoutingIntentProperties{
+			RoutingPolicies: []*armnetwork.RoutingPolicy{
+				{Name: to.Ptr("PublicTraffic"), Destinations: to.SliceOfPtrs("Internet"), NextHop: fw.ID},
+				{Name: to.Ptr("PrivateTraffic"), Destinations: to.SliceOfPtrs("PrivateTraffic"), NextHop: fw.ID},
+			},
+		},
+	}, nil)
+	if err != nil {
+		return fmt.Errorf("cannot create routing intent %s-routingintent, %v", o.Name, err)
+	}
+	intent, err := intentPoller.PollUntilDone(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("cannot create routing intent %s-routingintent, %v", o.Name, err)
+	}
+	hub.RoutingIntentID = stringValue(intent.ID)
+	return nil
+}
+
+// createFixtureResourceGroup creates the resource group of a fixture.
+func (f *ClientFactory) createFixtureResourceGroup(ctx context.Context, subID uuid.UUID, name, location string) error {
+	client, err := f.NewResourceGroupsClient(subID)
+	if err != nil {
+		return err
+	}
+	if _, err := client.CreateOrUpdate(ctx, name, armresources.ResourceGroup{Location: to.Ptr(location)}, nil); err != nil {
+		return fmt.Errorf("cannot create resource group %s, %v", name, err)
+	}
+	return nil
+}
+
+// Delete deletes the resource group of the hub network.
+func (h *HubNetwork) Delete(ctx context.Context, f *ClientFactory) error {
+	return f.DeleteResourceGroup(ctx, h.ResourceGroupName, h.SubscriptionID)
+}
+
+// Delete deletes the routing intent and firewall of the virtual hub, if any, then the hub,
+// then the resource group containing it and the virtual WAN.
+// The hub is deleted first as a resource group delete does not order the hub before the WAN.
+func (h *VirtualHub) Delete(ctx context.Context, f *ClientFactory) error {
+	if h.RoutingIntentID != "" {
+		client, err := f.NewRoutingIntentClient(h.SubscriptionID)
+		if err != nil {
+			return err
+		}
+		poller, err := client.BeginDelete(ctx, h.ResourceGroupName, lastSegment(h.ID), lastSegment(h.RoutingIntentID), nil)
+		if err == nil {
+			_, err = poller.PollUntilDone(ctx, nil)
+		}
+		if err != nil && !isNotFound(err) {
+			return fmt.Errorf("cannot delete routing intent %s, %v", h.RoutingIntentID, err)
+		}
+	}
+	if h.FirewallID != "" {
+		client, err := f.NewAzureFirewallsClient(h.SubscriptionID)
+		if err != nil {
+			return err
+		}
+		poller, err := client.BeginDelete(ctx, h.ResourceGroupName, lastSegment(h.FirewallID), nil)
+		if err == nil {
+			_, err = poller.PollUntilDone(ctx, nil)
+		}
+		if err != nil && !isNotFound(err) {
+			return fmt.Errorf("cannot delete hub firewall %s, %v", h.FirewallID, err)
+		}
+	}
+	if h.ID != "" {
+		client, err := f.NewVirtualHubsClient(h.SubscriptionID)
+		if err != nil {
+			return err
+		}
+		poller, err := client.BeginDelete(ctx, h.ResourceGroupName, lastSegment(h.ID), nil)
+		if err == nil {
+			_, err = poller.PollUntilDone(ctx, nil)
+		}
+		if err != nil && !isNotFound(err) {
+			return fmt.Errorf("cannot delete virtual hub %s, %v", h.ID, err)
+		}
+	}
+	return f.DeleteResourceGroup(ctx, h.ResourceGroupName, h.SubscriptionID)
+}
+
+// FixturePool shares fixtures between the tests of a package.
+// Create it in a package variable, and call Close from TestMain after m.Run:
+//
+//	var fixtures = azureutils.NewFixturePool(nil)
+//
+//	func TestMain(m *testing.M) {
+//		code := m.Run()
+//		ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+//		defer cancel()
+//		if err := fixtures.Close(ctx); err != nil {
+//			fmt.Fprintln(os.Stderr, err)
+//		}
+//		os.Exit(code)
+//	}
+//
+// A fixture is created the first time it is requested, so a package whose deploy tests are skipped creates nothing.
+// Its creation is bounded by the deadline of the test that requests it, less the time kept back for the deletion.
+// The go test -timeout no longer applies once m.Run returns, so Close is only bounded by its context.
+// Tests sharing a hub must not use overlapping address spaces at the same time.
+type FixturePool struct {
+	f *ClientFactory
+
+	mu      sync.Mutex
+	entries map[string]*poolEntry
+	order   []string
+}
+
+type poolEntry struct {
+	once  sync.Once
+	value any
+	err   error
+	del   func(context.Context, *ClientFactory) error
+}
+
+// NewFixturePool creates a new FixturePool using the supplied ClientFactory,
+// or the DefaultClientFactory if it is nil.
+func NewFixturePool(f *ClientFactory) *FixturePool {
+	return &FixturePool{f: f, entries: make(map[string]*poolEntry)}
+}
+
+// HubNetwork returns the pool's hub network with the name in opts, creating it on first use.
+func (p *FixturePool) HubNetwork(t *testing.T, subID uuid.UUID, opts *HubNetworkOptions) *HubNetwork {
+	return poolFixture(t, p, "hubnetwork", subID, opts.Name, func(ctx context.Context, f *ClientFactory) (*HubNetwork, func(context.Context, *ClientFactory) error, error) {
+		hub, err := f.CreateHubNetwork(ctx, subID, opts)
+		if hub == nil {
+			return nil, nil, err
+		}
+		return hub, hub.Delete, err
+	})
+}
+
+// VirtualHub returns the pool's virtual hub with the name in opts, creating it on first use.
+func (p *FixturePool) VirtualHub(t *testing.T, subID uuid.UUID, opts *VirtualHubOptions) *VirtualHub {
+	return poolFixture(t, p, "virtualhub", subID, opts.Name, func(ctx context.Context, f *ClientFactory) (*VirtualHub, func(context.Context, *ClientFactory) error, error) {
+		hub, err := f.CreateVirtualHub(ctx, subID, opts)
+		if hub == nil {
+			return nil, nil, err
+		}
+		return hub, hub.Delete, err
+	})
+}
+
+// Close deletes the fixtures in the pool, in the reverse order of their creation.
+func (p *FixturePool) Close(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var errs []error
+	for _, k := range slices.Backward(p.order) {
+		e := p.entries[k]
+		if e.del == nil {
+			continue
+		}
+		f, err := p.factory()
+		if err != nil {
+			return err
+		}
+		if err := e.del(ctx, f); err != nil {
+			errs = append(errs, fmt.Errorf("cannot delete test fixture %s, %v", k, err))
+		}
+	}
+	p.entries = make(map[string]*poolEntry)
+	p.order = nil
+	return errors.Join(errs...)
+}
+
+func (p *FixturePool) factory() (*ClientFactory, error) {
+	if p.f != nil {
+		return p.f, nil
+	}
+	return DefaultClientFactory()
+}
+
+// poolFixture returns the fixture of the kind and name, creating it once with create.
+// Every test that requests a fixture that could not be created fails with the same error.
+func poolFixture[T any](t *testing.T, p *FixturePool, kind string, subID uuid.UUID, name string, create func(context.Context, *ClientFactory) (*T, func(context.Context, *ClientFactory) error, error)) *T {
+	k := fmt.Sprintf("%s/%s/%s", kind, subID, name)
+	p.mu.Lock()
+	e, ok := p.entries[k]
+	if !ok {
+		e = &poolEntry{}
+		p.entries[k] = e
+		p.order = append(p.order, k)
+	}
+	p.mu.Unlock()
+
+	e.once.Do(func() {
+		f, err := p.factory()
+		if err != nil {
+			e.err = err
+			return
+		}
+		ctx, cancel := newFixtureContext(t, fixtureDeleteReserve)
+		defer cancel()
+		var v *T
+		v, e.del, e.err = create(ctx, f)
+		e.value = v
+	})
+	if e.err != nil {
+		t.Fatalf("cannot create test fixture %s, %v", k, e.err)
+	}
+	return e.value.(*T)
+}
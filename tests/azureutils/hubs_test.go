@@ -0,0 +1,161 @@
+package azureutils
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/terraform-azurerm-lz-vending/tests/fakearm"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestNewHubNetwork tests that the hub network is created with a gateway subnet,
+// and that it is deleted when the test completes.
+func TestNewHubNetwork(t *testing.T) {
+	f, srv := newFakeClientFactory(t, nil)
+	id := uuid.New()
+	srv.AddSubscription(fakearm.Subscription{ID: id, DisplayName: "sub", State: "Enabled"})
+
+	var hub *HubNetwork
+	t.Run("fixture", func(t *testing.T) {
+		hub = f.NewHubNetwork(t, id, &HubNetworkOptions{Name: "testdeploy-hub", GatewaySubnet: true})
+		assert.Equal(t, "/subscriptions/"+id.String()+"/resourceGroups/testdeploy-hub/providers/Microsoft.Network/virtualNetworks/testdeploy-hub", hub.ID)
+
+		vnet, ok := srv.Resource(hub.ID)
+		require.True(t, ok)
+		props := vnet["properties"].(map[string]any)
+		assert.Equal(t, []any{"192.168.10.0/23"}, props["addressSpace"].(map[string]any)["addressPrefixes"])
+		var subnets []string
+		for _, s := range props["subnets"].([]any) {
+			subnets = append(subnets, s.(map[string]any)["name"].(string))
+		}
+		assert.Equal(t, []string{"default", "GatewaySubnet"}, subnets)
+	})
+	assert.Empty(t, srv.ResourceGroups(id), "the fixture should be deleted by the test cleanup")
+	_, ok := srv.Resource(hub.ID)
+	assert.False(t, ok)
+}
+
+// TestNewVirtualHub tests that the virtual hub fixture waits for the hub router to be provisioned.
+func TestNewVirtualHub(t *testing.T) {
+	f, srv := newFakeClientFactory(t, nil)
+	id := uuid.New()
+	srv.AddSubscription(fakearm.Subscription{ID: id, DisplayName: "sub", State: "Enabled"})
+	hubID := "/subscriptions/" + id.String() + "/resourceGroups/testdeploy-vhub/providers/Microsoft.Network/virtualHubs/testdeploy-vhub"
+
+	done := provisionHubRouter(srv, hubID)
+
+	t.Run("fixture", func(t *testing.T) {
+		hub := f.NewVirtualHub(t, id, &VirtualHubOptions{Name: "testdeploy-vhub", PollInterval: time.Millisecond})
+		assert.Equal(t, hubID, hub.ID)
+		assert.Equal(t, "/subscriptions/"+id.String()+"/resourceGroups/testdeploy-vhub/providers/Microsoft.Network/virtualWans/testdeploy-vhub-vwan", hub.VirtualWanID)
+		vhub, _ := srv.Resource(hubID)
+		assert.Equal(t, hub.VirtualWanID, vhub["properties"].(map[string]any)["virtualWan"].(map[string]any)["id"])
+	})
+	<-done
+	assert.Empty(t, srv.ResourceGroups(id))
+}
+
+// TestNewVirtualHubRoutingIntent tests that the routing intent of the virtual hub fixture
+// uses its firewall as the next hop, and that they are deleted when the test completes.
+func TestNewVirtualHubRoutingIntent(t *testing.T) {
+	f, srv := newFakeClientFactory(t, nil)
+	id := uuid.New()
+	srv.AddSubscription(fakearm.Subscription{ID: id, DisplayName: "sub", State: "Enabled"})
+	rg := "/subscriptions/" + id.String() + "/resourceGroups/testdeploy-vhub/providers/Microsoft.Network"
+	done := provisionHubRouter(srv, rg+"/virtualHubs/testdeploy-vhub")
+
+	t.Run("fixture", func(t *testing.T) {
+		hub := f.NewVirtualHub(t, id, &VirtualHubOptions{Name: "testdeploy-vhub", PollInterval: time.Millisecond, RoutingIntent: true})
+		assert.Equal(t, rg+"/azureFirewalls/testdeploy-vhub-fw", hub.FirewallID)
+		assert.Equal(t, rg+"/virtualHubs/testdeploy-vhub/routingIntent/testdeploy-vhub-routingintent", hub.RoutingIntentID)
+
+		fw, ok := srv.Resource(hub.FirewallID)
+		require.True(t, ok)
+		fwProps := fw["properties"].(map[string]any)
+		assert.Equal(t, hub.ID, fwProps["virtualHub"].(map[string]any)["id"])
+		assert.Equal(t, rg+"/firewallPolicies/testdeploy-vhub-fwpol", fwProps["firewallPolicy"].(map[string]any)["id"])
+
+		intent, ok := srv.Resource(hub.RoutingIntentID)
+		require.True(t, ok)
+		policies := intent["properties"].(map[string]any)["routingPolicies"].([]any)
+		require.Len(t, policies, 2)
+		for _, p := range policies {
+			assert.Equal(t, hub.FirewallID, p.(map[string]any)["nextHop"])
+		}
+	})
+	<-done
+	assert.Empty(t, srv.ResourceGroups(id))
+	assert.Empty(t, srv.ResourceIDs("/subscriptions/"+id.String()))
+}
+
+// provisionHubRouter sets the routing state of the virtual hub to Provisioned once it exists,
+// as the fake does not provision the hub router.
+func provisionHubRouter(srv *fakearm.Server, hubID string) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			if hub, ok := srv.Resource(hubID); ok {
+				props := hub["properties"].(map[string]any)
+				props["routingState"] = "Provisioned"
+				srv.PutResource(hubID, hub)
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}()
+	return done
+}
+
+// TestFixturePool tests that a pooled fixture is created once for concurrent tests,
+// and deleted when the pool is closed.
+func TestFixturePool(t *testing.T) {
+	f, srv := newFakeClientFactory(t, nil)
+	id := uuid.New()
+	srv.AddSubscription(fakearm.Subscription{ID: id, DisplayName: "sub", State: "Enabled"})
+	pool := NewFixturePool(f)
+
+	var wg sync.WaitGroup
+	hubs := make([]*HubNetwork, 3)
+	for i := range hubs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			hubs[i] = pool.HubNetwork(t, id, &HubNetworkOptions{Name: "testdeploy-hub"})
+		}()
+	}
+	wg.Wait()
+	assert.Same(t, hubs[0], hubs[1])
+	assert.Same(t, hubs[0], hubs[2])
+	assert.Equal(t, []string{"testdeploy-hub"}, srv.ResourceGroups(id))
+
+	require.NoError(t, pool.Close(context.Background()))
+	assert.Empty(t, srv.ResourceGroups(id))
+}
+
+// TestNewFixtureContext tests that a fixture context ends before the test deadline,
+// keeping back the reserve, and never lasts longer than the fixture timeout.
+func TestNewFixtureContext(t *testing.T) {
+	testDeadline, ok := t.Deadline()
+	if !ok {
+		t.Skip("the test has no deadline, run it with -timeout")
+	}
+	ctx, cancel := newFixtureContext(t, fixtureDeleteReserve)
+	defer cancel()
+	deadline, ok := ctx.Deadline()
+	require.True(t, ok)
+	left := time.Until(testDeadline.Add(-testDeadlineGrace))
+	assert.False(t, deadline.After(testDeadline.Add(-testDeadlineGrace-min(fixtureDeleteReserve, left/2))))
+	assert.True(t, deadline.After(time.Now()), "a short timeout should still leave time to create the fixture")
+	assert.False(t, deadline.After(time.Now().Add(fixtureTimeout)))
+
+	cleanupCtx, cleanupCancel := newFixtureContext(t, 0)
+	defer cleanupCancel()
+	cleanupDeadline, _ := cleanupCtx.Deadline()
+	assert.False(t, cleanupDeadline.Before(deadline), "the cleanup should have at least as long as the creation")
+	assert.False(t, cleanupDeadline.After(testDeadline.Add(-testDeadlineGrace)))
+}
@@ -0,0 +1,59 @@
+package azureutils
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// locksAPIVersion is the Microsoft.Authorization/locks API version.
+const locksAPIVersion = "2020-05-01"
+
+// ListResourceGroupLocks returns the IDs of the management locks on the resource group
+// and on the resources in it using the DefaultClientFactory.
+// Locks inherited from the subscription are not included.
+func ListResourceGroupLocks(ctx context.Context, rgname string, subID uuid.UUID) ([]string, error) {
+	f, err := DefaultClientFactory()
+	if err != nil {
+		return nil, err
+	}
+	return f.ListResourceGroupLocks(ctx, rgname, subID)
+}
+
+// ListResourceGroupLocks returns the IDs of the management locks on the resource group
+// and on the resources in it.
+// ARM also lists the locks inherited from the subscription, which are not included.
+func (f *ClientFactory) ListResourceGroupLocks(ctx context.Context, rgname string, subID uuid.UUID) ([]string, error) {
+	path := fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/Microsoft.Authorization/locks", subID, rgname)
+	locks, err := listARM[armResource](ctx, f, path, locksAPIVersion)
+	if err != nil {
+		return nil, fmt.Errorf("cannot list locks for resource group %s, %v", rgname, err)
+	}
+	scope := strings.ToLower(fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/", subID, rgname))
+	ids := make([]string, 0, len(locks))
+	for _, l := range locks {
+		if strings.HasPrefix(strings.ToLower(l.ID), scope) {
+			ids = append(ids, l.ID)
+		}
+	}
+	return ids, nil
+}
+
+// RemoveResourceGroupLocks removes the management locks on the resource group
+// and on the resources in it, and returns the IDs of the removed locks.
+// Locks inherited from the subscription are left in place.
+func (f *ClientFactory) RemoveResourceGroupLocks(ctx context.Context, rgname string, subID uuid.UUID) ([]string, error) {
+	ids, err := f.ListResourceGroupLocks(ctx, rgname, subID)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		if err := f.doARM(ctx, http.MethodDelete, id, locksAPIVersion, nil, nil); err != nil {
+			return nil, fmt.Errorf("cannot remove lock %s, %v", id, err)
+		}
+	}
+	return ids, nil
+}
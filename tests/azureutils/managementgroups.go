@@ -0,0 +1,340 @@
+package azureutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/managementgroups/armmanagementgroups"
+	"github.com/google/uuid"
+)
+
+const (
+	// defaultManagementGroupPollInterval is the time between reads of an eventually consistent management group.
+	defaultManagementGroupPollInterval = 10 * time.Second
+
+	// managementGroupConfirmations is the number of consecutive reads that must agree before a change
+	// to a management group is considered visible, as reads may be served by replicas that have not caught up.
+	managementGroupConfirmations = 2
+)
+
+// ManagementGroupTreeOptions are the options for a management group tree fixture.
+type ManagementGroupTreeOptions struct {
+	// Name is the name of the root management group of the tree, which is created below the tenant root group.
+	Name string
+	// Children are the names of management groups to create below the root group.
+	Children []string
+	// PollInterval is the time between reads while waiting for a change to be visible, default 10 seconds.
+	PollInterval time.Duration
+}
+
+// ManagementGroupTree is a temporary management group hierarchy created by a fixture.
+type ManagementGroupTree struct {
+	// TenantID is the ID of the tenant, which is also the name of the tenant root group.
+	TenantID string
+	// Root is the name of the root management group of the tree.
+	Root string
+	// Children are the names of the management groups below the root group.
+	Children []string
+
+	f        *ClientFactory
+	interval time.Duration
+	// created are the groups that were created, in order.
+	created []string
+}
+
+// NewManagementGroupTree creates a management group tree that is deleted when the test completes,
+// using the DefaultClientFactory.
+func NewManagementGroupTree(t *testing.T, opts *ManagementGroupTreeOptions) *ManagementGroupTree {
+	f, err := DefaultClientFactory()
+	if err != nil {
+		t.Fatalf("cannot create client factory, %v", err)
+	}
+	return f.NewManagementGroupTree(t, opts)
+}
+
+// CreateManagementGroupTree creates a management group tree, using the DefaultClientFactory.
+func CreateManagementGroupTree(ctx context.Context, opts *ManagementGroupTreeOptions) (*ManagementGroupTree, error) {
+	f, err := DefaultClientFactory()
+	if err != nil {
+		return nil, err
+	}
+	return f.CreateManagementGroupTree(ctx, opts)
+}
+
+// WaitForSubscriptionInManagementGroup waits until the subscription is in the management group,
+// using the DefaultClientFactory.
+func WaitForSubscriptionInManagementGroup(ctx context.Context, id uuid.UUID, mg string, interval time.Duration) error {
+	f, err := DefaultClientFactory()
+	if err != nil {
+		return err
+	}
+	return f.WaitForSubscriptionInManagementGroup(ctx, id, mg, interval)
+}
+
+// NewManagementGroupTree creates a management group tree, and registers its deletion with t.Cleanup.
+// The cleanup moves any subscriptions in the tree back to the tenant root group before deleting it.
+// The test fails immediately if the tree cannot be created.
+func (f *ClientFactory) NewManagementGroupTree(t *testing.T, opts *ManagementGroupTreeOptions) *ManagementGroupTree {
+	ctx, cancel := newFixtureContext(t, fixtureDeleteReserve)
+	defer cancel()
+	tree, err := f.CreateManagementGroupTree(ctx, opts)
+	if tree != nil {
+		t.Cleanup(func() {
+			f.cleanupFixture(t, func(ctx context.Context, _ *ClientFactory) error { return tree.Delete(ctx) })
+		})
+	}
+	if err != nil {
+		t.Fatalf("cannot create management group tree, %v", err)
+	}
+	return tree
+}
+
+// CreateManagementGroupTree creates the root management group below the tenant root group,
+// and its children below it, waiting for each group to be readable with no-cache before creating the next.
+// If some groups are created but not all, the tree is returned with the error so that the caller can delete it.
+func (f *ClientFactory) CreateManagementGroupTree(ctx context.Context, opts *ManagementGroupTreeOptions) (*ManagementGroupTree, error) {
+	if opts == nil || opts.Name == "" {
+		return nil, errors.New("cannot create management group tree, the name is required")
+	}
+	tree := &ManagementGroupTree{
+		Root:     opts.Name,
+		Children: slices.Clone(opts.Children),
+		f:        f,
+		interval: opts.PollInterval,
+	}
+	if tree.interval == 0 {
+		tree.interval = defaultManagementGroupPollInterval
+	}
+
+	client, err := f.NewManagementGroupsClient()
+	if err != nil {
+		return nil, err
+	}
+	if err := tree.createGroup(ctx, client, tree.Root, ""); err != nil {
+		return treeOrNil(tree), err
+	}
+	for _, child := range tree.Children {
+		if err := tree.createGroup(ctx, client, child, tree.Root); err != nil {
+			return tree, err
+		}
+	}
+	return tree, nil
+}
+
+// treeOrNil returns nil if no groups of the tree were created, as there is nothing to delete.
+func treeOrNil(tree *ManagementGroupTree) *ManagementGroupTree {
+	if len(tree.created) == 0 {
+		return nil
+	}
+	return tree
+}
+
+// createGroup creates a management group below the parent, or below the tenant root group if parent is empty,
+// and waits for it to be readable with the expected parent.
+// The tenant ID is read from the first group that is created.
+func (tree *ManagementGroupTree) createGroup(ctx context.Context, client *armmanagementgroups.Client, name, parent string) error {
+	req := armmanagementgroups.CreateManagementGroupRequest{
+		Properties: &armmanagementgroups.CreateManagementGroupProperties{
+			DisplayName: to.Ptr(name),
+		},
+	}
+	if parent != "" {
+		req.Properties.Details = &armmanagementgroups.CreateManagementGroupDetails{
+			Parent: &armmanagementgroups.CreateParentGroupInfo{ID: to.Ptr(managementGroupID(parent))},
+		}
+	}
+	poller, err := client.BeginCreateOrUpdate(ctx, name, req, &armmanagementgroups.ClientBeginCreateOrUpdateOptions{
+		CacheControl: to.Ptr("no-cache"),
+	})
+	if err != nil {
+		return fmt.Errorf("cannot create management group %s, %v", name, err)
+	}
+	if _, err := poller.PollUntilDone(ctx, nil); err != nil {
+		return fmt.Errorf("cannot create management group %s, %v", name, err)
+	}
+	tree.created = append(tree.created, name)
+
+	err = pollManagementGroup(ctx, tree.interval, func() (bool, error) {
+		resp, err := client.Get(ctx, name, &armmanagementgroups.ClientGetOptions{CacheControl: to.Ptr("no-cache")})
+		if err != nil {
+			return false, err
+		}
+		if tree.TenantID == "" && resp.Properties != nil {
+			tree.TenantID = stringValue(resp.Properties.TenantID)
+		}
+		want := parent
+		if want == "" {
+			want = tree.TenantID
+		}
+		var got string
+		if resp.Properties != nil && resp.Properties.Details != nil && resp.Properties.Details.Parent != nil {
+			got = lastSegment(stringValue(resp.Properties.Details.Parent.ID))
+		}
+		return strings.EqualFold(got, want), nil
+	})
+	if err != nil {
+		return fmt.Errorf("cannot read management group %s, %v", name, err)
+	}
+	return nil
+}
+
+// MoveSubscription moves the subscription to a management group of the tree,
+// and waits until the move is visible.
+func (tree *ManagementGroupTree) MoveSubscription(ctx context.Context, id uuid.UUID, mg string) error {
+	if !strings.EqualFold(mg, tree.Root) && !slices.ContainsFunc(tree.Children, func(c string) bool { return strings.EqualFold(c, mg) }) {
+		return fmt.Errorf("cannot move subscription %s, management group %s is not in the tree", id, mg)
+	}
+	if err := tree.f.SetSubscriptionManagementGroup(ctx, id, mg); err != nil {
+		return err
+	}
+	return tree.f.WaitForSubscriptionInManagementGroup(ctx, id, mg, tree.interval)
+}
+
+// RemoveSubscription moves the subscription back to the tenant root group,
+// and waits until the move is visible.
+func (tree *ManagementGroupTree) RemoveSubscription(ctx context.Context, id uuid.UUID) error {
+	if err := tree.f.SetSubscriptionManagementGroup(ctx, id, tree.TenantID); err != nil {
+		return err
+	}
+	return tree.f.WaitForSubscriptionInManagementGroup(ctx, id, tree.TenantID, tree.interval)
+}
+
+// WaitForSubscription waits until the subscription is in a management group of the tree,
+// e.g. after Terraform has moved it.
+func (tree *ManagementGroupTree) WaitForSubscription(ctx context.Context, id uuid.UUID, mg string) error {
+	return tree.f.WaitForSubscriptionInManagementGroup(ctx, id, mg, tree.interval)
+}
+
+// Delete moves the subscriptions in the tree, including any moved there by Terraform, back to the tenant root group,
+// then deletes the groups that were created, children first.
+func (tree *ManagementGroupTree) Delete(ctx context.Context) error {
+	client, err := tree.f.NewManagementGroupsClient()
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, name := range slices.Backward(tree.created) {
+		if err := tree.deleteGroup(ctx, client, name); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// deleteGroup moves the subscriptions in the group to the tenant root group and deletes it.
+// The delete is retried while it fails with a bad request or conflict,
+// as the group's children are eventually consistent.
+func (tree *ManagementGroupTree) deleteGroup(ctx context.Context, client *armmanagementgroups.Client, name string) error {
+	resp, err := client.Get(ctx, name, &armmanagementgroups.ClientGetOptions{
+		CacheControl: to.Ptr("no-cache"),
+		Expand:       to.Ptr(armmanagementgroups.ManagementGroupExpandTypeChildren),
+	})
+	if isNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("cannot get management group %s, %v", name, err)
+	}
+	if resp.Properties != nil {
+		for _, c := range resp.Properties.Children {
+			if c.Type == nil || *c.Type != armmanagementgroups.ManagementGroupChildTypeSubscriptions {
+				continue
+			}
+			id, err := uuid.Parse(stringValue(c.Name))
+			if err != nil {
+				return fmt.Errorf("cannot parse subscription %s in management group %s, %v", stringValue(c.Name), name, err)
+			}
+			if err := tree.RemoveSubscription(ctx, id); err != nil {
+				return fmt.Errorf("cannot move subscription %s from management group %s to the tenant root group, %v", id, name, err)
+			}
+		}
+	}
+
+	for {
+		poller, err := client.BeginDelete(ctx, name, &armmanagementgroups.ClientBeginDeleteOptions{CacheControl: to.Ptr("no-cache")})
+		if err == nil {
+			_, err = poller.PollUntilDone(ctx, nil)
+		}
+		var respErr *azcore.ResponseError
+		switch {
+		case err == nil || isNotFound(err):
+			return nil
+		case !errors.As(err, &respErr) || (respErr.StatusCode != http.StatusBadRequest && respErr.StatusCode != http.StatusConflict):
+			return fmt.Errorf("cannot delete management group %s, %v", name, err)
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("cannot delete management group %s, %v", name, err)
+		case <-time.After(tree.interval):
+		}
+	}
+}
+
+// WaitForSubscriptionInManagementGroup waits until the subscription is in the management group,
+// reading the membership with no-cache until it has been reported by consecutive reads.
+// Not found errors are retried, as the management group API is eventually consistent.
+// It returns an error when the context is done.
+func (f *ClientFactory) WaitForSubscriptionInManagementGroup(ctx context.Context, id uuid.UUID, mg string, interval time.Duration) error {
+	client, err := f.NewManagementGroupSubscriptionsClient()
+	if err != nil {
+		return err
+	}
+	if interval == 0 {
+		interval = defaultManagementGroupPollInterval
+	}
+	err = pollManagementGroup(ctx, interval, func() (bool, error) {
+		resp, err := client.GetSubscription(ctx, mg, id.String(), &armmanagementgroups.ManagementGroupSubscriptionsClientGetSubscriptionOptions{
+			CacheControl: to.Ptr("no-cache"),
+		})
+		if err != nil {
+			return false, err
+		}
+		var parent string
+		if resp.Properties != nil && resp.Properties.Parent != nil {
+			parent = lastSegment(stringValue(resp.Properties.Parent.ID))
+		}
+		return strings.EqualFold(parent, mg), nil
+	})
+	if err != nil {
+		return fmt.Errorf("subscription %s is not in management group %s, %v", id, mg, err)
+	}
+	return nil
+}
+
+// pollManagementGroup calls check until it has returned true managementGroupConfirmations times in a row.
+// A not found error counts as false, other errors stop the polling.
+func pollManagementGroup(ctx context.Context, interval time.Duration, check func() (bool, error)) error {
+	confirmed := 0
+	for {
+		ok, err := check()
+		switch {
+		case err != nil && !isNotFound(err):
+			return err
+		case ok:
+			confirmed++
+			if confirmed >= managementGroupConfirmations {
+				return nil
+			}
+		default:
+			confirmed = 0
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
+
+// managementGroupID returns the resource ID of a management group.
+func managementGroupID(name string) string {
+	return "/providers/Microsoft.Management/managementGroups/" + name
+}
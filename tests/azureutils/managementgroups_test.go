@@ -0,0 +1,84 @@
+package azureutils
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Azure/terraform-azurerm-lz-vending/tests/fakearm"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestNewManagementGroupTree tests that the tree is created and readable, that subscriptions can be moved into it,
+// and that the cleanup moves all subscriptions in the tree to the tenant root group before deleting it.
+func TestNewManagementGroupTree(t *testing.T) {
+	f, srv := newFakeClientFactory(t, &fakearm.Options{ManagementGroupReadDelay: 2})
+	moved, byTerraform := uuid.New(), uuid.New()
+	srv.AddSubscription(fakearm.Subscription{ID: moved})
+	srv.AddSubscription(fakearm.Subscription{ID: byTerraform})
+
+	t.Run("fixture", func(t *testing.T) {
+		tree := f.NewManagementGroupTree(t, &ManagementGroupTreeOptions{
+			Name:         "testdeploy-mg",
+			Children:     []string{"testdeploy-mg-child"},
+			PollInterval: time.Millisecond,
+		})
+		assert.Equal(t, fakearm.TenantID, tree.TenantID)
+		assert.Equal(t, []string{"testdeploy-mg", "testdeploy-mg-child"}, srv.ManagementGroups())
+
+		ctx := context.Background()
+		require.NoError(t, tree.MoveSubscription(ctx, moved, "testdeploy-mg-child"))
+		sub, _ := srv.Subscription(moved)
+		assert.Equal(t, "testdeploy-mg-child", sub.ManagementGroup)
+		assert.ErrorContains(t, tree.MoveSubscription(ctx, moved, "other"), "is not in the tree")
+
+		srv.SetSubscriptionManagementGroup(byTerraform, "testdeploy-mg")
+		require.NoError(t, tree.WaitForSubscription(ctx, byTerraform, "testdeploy-mg"))
+	})
+
+	assert.Empty(t, srv.ManagementGroups(), "the tree should be deleted by the test cleanup")
+	for _, id := range []uuid.UUID{moved, byTerraform} {
+		sub, _ := srv.Subscription(id)
+		assert.Equal(t, fakearm.TenantID, sub.ManagementGroup)
+	}
+}
+
+// TestManagementGroupTreeDeleteRetry tests that a delete that fails with a bad request, as the group's children
+// are eventually consistent, is retried.
+func TestManagementGroupTreeDeleteRetry(t *testing.T) {
+	f, srv := newFakeClientFactory(t, nil)
+	ctx := context.Background()
+	tree, err := f.CreateManagementGroupTree(ctx, &ManagementGroupTreeOptions{Name: "testdeploy-mg", PollInterval: time.Millisecond})
+	require.NoError(t, err)
+
+	srv.InjectFault(fakearm.Fault{
+		Method:       http.MethodDelete,
+		PathContains: "/managementGroups/testdeploy-mg",
+		StatusCode:   http.StatusBadRequest,
+		Code:         "BadRequest",
+		Message:      "Cannot delete a management group that has child management groups or subscriptions.",
+		Count:        2,
+	})
+	require.NoError(t, tree.Delete(ctx))
+	assert.Empty(t, srv.ManagementGroups())
+	assert.Equal(t, 3, srv.RequestCount(http.MethodDelete, "/managementGroups/testdeploy-mg"))
+}
+
+// TestWaitForSubscriptionInManagementGroup tests that a single read is not enough to confirm the membership,
+// and that the wait fails when the context is done.
+func TestWaitForSubscriptionInManagementGroup(t *testing.T) {
+	f, srv := newFakeClientFactory(t, nil)
+	id := uuid.New()
+	srv.AddSubscription(fakearm.Subscription{ID: id, ManagementGroup: "mg1"})
+
+	require.NoError(t, f.WaitForSubscriptionInManagementGroup(context.Background(), id, "mg1", time.Millisecond))
+	assert.Equal(t, 2, srv.RequestCount(http.MethodGet, "/managementGroups/mg1/subscriptions/"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := f.WaitForSubscriptionInManagementGroup(ctx, id, "mg2", time.Millisecond)
+	assert.ErrorContains(t, err, "is not in management group mg2")
+}
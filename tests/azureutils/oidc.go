@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"strings"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
 
@@ -15,64 +16,77 @@ import (
 	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
 )
 
-// OidcCredential contains the fields needed to authenticate to Azure using an OIDC token
-type OidcCredential struct {
-	requestToken  string
-	requestURL    string
-	token         string
-	tokenFilePath string
-	cred          *azidentity.ClientAssertionCredential
+const (
+	// defaultOidcAudience is the audience requested for ID tokens exchanged with Entra ID.
+	defaultOidcAudience = "api://AzureADTokenExchange"
+
+	// azureDevOpsOidcAPIVersion is the Azure DevOps API version of the OIDC token request.
+	azureDevOpsOidcAPIVersion = "7.1"
+)
+
+// AssertionProvider supplies the OIDC ID token that OidcCredential exchanges for an access token.
+// GetAssertion is called each time a new access token is required,
+// so implementations should return a fresh token rather than caching one.
+type AssertionProvider interface {
+	GetAssertion(ctx context.Context) (string, error)
 }
 
-// OidcCredentialOptions contains the fields needed to create an OidcCredential
-type OidcCredentialOptions struct {
-	azcore.ClientOptions
-	TenantID      string
-	ClientID      string
-	RequestToken  string
-	RequestURL    string
-	Token         string
-	TokenFilePath string
+// StaticAssertion is an AssertionProvider that always returns the same token.
+type StaticAssertion string
+
+// GetAssertion implements AssertionProvider.
+func (s StaticAssertion) GetAssertion(_ context.Context) (string, error) {
+	return string(s), nil
 }
 
-// NewOidcCredential creates a new OidcCredential
-func NewOidcCredential(options *OidcCredentialOptions) (*OidcCredential, error) {
-	w := &OidcCredential{
-		requestToken:  options.RequestToken,
-		requestURL:    options.RequestURL,
-		token:         options.Token,
-		tokenFilePath: options.TokenFilePath,
-	}
+// FileAssertion is an AssertionProvider that reads the token from a file,
+// such as a Kubernetes projected service account token.
+// The file is read on every call so that rotated tokens are used.
+type FileAssertion struct {
+	Path string
+}
 
-	cred, err := azidentity.NewClientAssertionCredential(options.TenantID, options.ClientID, w.getAssertion, &azidentity.ClientAssertionCredentialOptions{ClientOptions: options.ClientOptions})
+// GetAssertion implements AssertionProvider.
+func (f FileAssertion) GetAssertion(_ context.Context) (string, error) {
+	b, err := os.ReadFile(f.Path)
 	if err != nil {
-		return nil, err
+		return "", fmt.Errorf("reading token file: %v", err)
 	}
-
-	w.cred = cred
-	return w, nil
+	tok := strings.TrimSpace(string(b))
+	if tok == "" {
+		return "", fmt.Errorf("token file %s is empty", f.Path)
+	}
+	return tok, nil
 }
 
-// GetToken gets a new token from the credential
-func (w *OidcCredential) GetToken(ctx context.Context, opts policy.TokenRequestOptions) (azcore.AccessToken, error) {
-	return w.cred.GetToken(ctx, opts)
+// EnvAssertion is an AssertionProvider that reads the token from an environment variable,
+// such as GitLab CI_JOB_JWT_V2.
+// The variable is read on every call.
+type EnvAssertion struct {
+	Name string
 }
 
-func (w *OidcCredential) getAssertion(ctx context.Context) (string, error) {
-	if w.token != "" {
-		return w.token, nil
+// GetAssertion implements AssertionProvider.
+func (e EnvAssertion) GetAssertion(_ context.Context) (string, error) {
+	tok := os.Getenv(e.Name)
+	if tok == "" {
+		return "", fmt.Errorf("environment variable %s is not set", e.Name)
 	}
+	return tok, nil
+}
 
-	if w.tokenFilePath != "" {
-		idTokenData, err := os.ReadFile(w.tokenFilePath)
-		if err != nil {
-			return "", fmt.Errorf("reading token file: %v", err)
-		}
-
-		return string(idTokenData), nil
-	}
+// GitHubActionsAssertion is an AssertionProvider that requests an ID token from the GitHub Actions
+// token endpoint, ACTIONS_ID_TOKEN_REQUEST_URL, using the request token ACTIONS_ID_TOKEN_REQUEST_TOKEN.
+type GitHubActionsAssertion struct {
+	RequestURL   string
+	RequestToken string
+	// Transport is used to send the request, defaults to http.DefaultClient.
+	Transport policy.Transporter
+}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, w.requestURL, http.NoBody)
+// GetAssertion implements AssertionProvider.
+func (g GitHubActionsAssertion) GetAssertion(ctx context.Context) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, g.RequestURL, http.NoBody)
 	if err != nil {
 		return "", fmt.Errorf("getAssertion: failed to build request")
 	}
@@ -83,40 +97,202 @@ func (w *OidcCredential) getAssertion(ctx context.Context) (string, error) {
 	}
 
 	if query.Get("audience") == "" {
-		query.Set("audience", "api://AzureADTokenExchange")
+		query.Set("audience", defaultOidcAudience)
 		req.URL.RawQuery = query.Encode()
 	}
 
 	req.Header.Set("Accept", "application/json")
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", w.requestToken))
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", g.RequestToken))
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
-	resp, err := http.DefaultClient.Do(req)
+	var tokenRes struct {
+		Count *int    `json:"count"`
+		Value *string `json:"value"`
+	}
+	if err := doAssertionRequest(g.Transport, req, &tokenRes); err != nil {
+		return "", err
+	}
+
+	if tokenRes.Value == nil {
+		return "", fmt.Errorf("getAssertion: nil JWT assertion received from OIDC provider")
+	}
+
+	return *tokenRes.Value, nil
+}
+
+// AzureDevOpsAssertion is an AssertionProvider that requests an ID token for a service connection
+// from the Azure DevOps pipeline OIDC endpoint, SYSTEM_OIDCREQUEST_URI,
+// using the pipeline access token SYSTEM_ACCESSTOKEN.
+type AzureDevOpsAssertion struct {
+	RequestURI          string
+	AccessToken         string
+	ServiceConnectionID string
+	// Transport is used to send the request, defaults to http.DefaultClient.
+	Transport policy.Transporter
+}
+
+// GetAssertion implements AssertionProvider.
+func (a AzureDevOpsAssertion) GetAssertion(ctx context.Context) (string, error) {
+	u, err := url.Parse(a.RequestURI)
+	if err != nil {
+		return "", fmt.Errorf("getAssertion: cannot parse request URI: %v", err)
+	}
+	query := u.Query()
+	query.Set("api-version", azureDevOpsOidcAPIVersion)
+	query.Set("serviceConnectionId", a.ServiceConnectionID)
+	u.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), http.NoBody)
+	if err != nil {
+		return "", fmt.Errorf("getAssertion: failed to build request")
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", a.AccessToken))
+	req.Header.Set("Content-Type", "application/json")
+
+	var tokenRes struct {
+		OidcToken *string `json:"oidcToken"`
+	}
+	if err := doAssertionRequest(a.Transport, req, &tokenRes); err != nil {
+		return "", err
+	}
+
+	if tokenRes.OidcToken == nil || *tokenRes.OidcToken == "" {
+		return "", fmt.Errorf("getAssertion: no OIDC token received from Azure DevOps")
+	}
+
+	return *tokenRes.OidcToken, nil
+}
+
+// doAssertionRequest sends the token request and unmarshals the JSON response into out.
+func doAssertionRequest(transport policy.Transporter, req *http.Request, out any) error {
+	if transport == nil {
+		transport = http.DefaultClient
+	}
+	resp, err := transport.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("getAssertion: cannot request token: %v", err)
+		return fmt.Errorf("getAssertion: cannot request token: %v", err)
 	}
 
 	defer resp.Body.Close()
 	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
 	if err != nil {
-		return "", fmt.Errorf("getAssertion: cannot parse response: %v", err)
+		return fmt.Errorf("getAssertion: cannot parse response: %v", err)
 	}
 
 	if c := resp.StatusCode; c < 200 || c > 299 {
-		return "", fmt.Errorf("getAssertion: received HTTP status %d with response: %s", resp.StatusCode, body)
+		return fmt.Errorf("getAssertion: received HTTP status %d with response: %s", resp.StatusCode, body)
 	}
 
-	var tokenRes struct {
-		Count *int    `json:"count"`
-		Value *string `json:"value"`
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("getAssertion: cannot unmarshal response: %v", err)
 	}
-	if err := json.Unmarshal(body, &tokenRes); err != nil {
-		return "", fmt.Errorf("getAssertion: cannot unmarshal response: %v", err)
+	return nil
+}
+
+// assertionProviderFromEnv selects the AssertionProvider for the CI environment, in order of precedence:
+//
+//   - ARM_OIDC_TOKEN, a static token
+//   - ARM_OIDC_TOKEN_FILE_PATH, a token file
+//   - ARM_OIDC_REQUEST_URL or ACTIONS_ID_TOKEN_REQUEST_URL, GitHub Actions
+//   - SYSTEM_OIDCREQUEST_URI, Azure DevOps, which also requires a service connection ID
+//   - CI_JOB_JWT_V2, GitLab
+//   - AZURE_FEDERATED_TOKEN_FILE, a Kubernetes projected service account token
+func assertionProviderFromEnv() (AssertionProvider, error) {
+	if tok := multiEnvDefault("", "ARM_OIDC_TOKEN"); tok != "" {
+		return StaticAssertion(tok), nil
 	}
+	if path := multiEnvDefault("", "ARM_OIDC_TOKEN_FILE_PATH"); path != "" {
+		return FileAssertion{Path: path}, nil
+	}
+	if u := multiEnvDefault("", "ARM_OIDC_REQUEST_URL", "ACTIONS_ID_TOKEN_REQUEST_URL"); u != "" {
+		return GitHubActionsAssertion{
+			RequestURL:   u,
+			RequestToken: multiEnvDefault("", "ARM_OIDC_REQUEST_TOKEN", "ACTIONS_ID_TOKEN_REQUEST_TOKEN"),
+		}, nil
+	}
+	if u := os.Getenv("SYSTEM_OIDCREQUEST_URI"); u != "" {
+		id := multiEnvDefault("", "ARM_ADO_PIPELINE_SERVICE_CONNECTION_ID", "ARM_OIDC_AZURE_SERVICE_CONNECTION_ID", "AZURESUBSCRIPTION_SERVICE_CONNECTION_ID")
+		if id == "" {
+			return nil, fmt.Errorf("SYSTEM_OIDCREQUEST_URI is set but the service connection ID is not, set ARM_ADO_PIPELINE_SERVICE_CONNECTION_ID")
+		}
+		tok := multiEnvDefault("", "ARM_OIDC_REQUEST_TOKEN", "SYSTEM_ACCESSTOKEN")
+		if tok == "" {
+			return nil, fmt.Errorf("SYSTEM_OIDCREQUEST_URI is set but SYSTEM_ACCESSTOKEN is not, map it into the pipeline step environment")
+		}
+		return AzureDevOpsAssertion{
+			RequestURI:          u,
+			AccessToken:         tok,
+			ServiceConnectionID: id,
+		}, nil
+	}
+	if os.Getenv("CI_JOB_JWT_V2") != "" {
+		return EnvAssertion{Name: "CI_JOB_JWT_V2"}, nil
+	}
+	if path := os.Getenv("AZURE_FEDERATED_TOKEN_FILE"); path != "" {
+		return FileAssertion{Path: path}, nil
+	}
+	return nil, fmt.Errorf("OIDC is enabled but no token source was found, " +
+		"set ARM_OIDC_TOKEN, ARM_OIDC_TOKEN_FILE_PATH, ARM_OIDC_REQUEST_URL or AZURE_FEDERATED_TOKEN_FILE, " +
+		"or run in GitHub Actions, Azure DevOps or GitLab CI")
+}
 
-	if tokenRes.Value == nil {
-		return "", fmt.Errorf("getAssertion: nil JWT assertion received from OIDC provider")
+// OidcCredential contains the fields needed to authenticate to Azure using an OIDC token
+type OidcCredential struct {
+	assertion AssertionProvider
+	cred      *azidentity.ClientAssertionCredential
+}
+
+// OidcCredentialOptions contains the fields needed to create an OidcCredential
+type OidcCredentialOptions struct {
+	azcore.ClientOptions
+	TenantID string
+	ClientID string
+	// Assertion supplies the ID token.
+	// If nil, it is created from Token, TokenFilePath or RequestURL and RequestToken, in that order.
+	Assertion     AssertionProvider
+	RequestToken  string
+	RequestURL    string
+	Token         string
+	TokenFilePath string
+	// DisableInstanceDiscovery should be set for private clouds and test endpoints,
+	// see azidentity.ClientAssertionCredentialOptions.
+	DisableInstanceDiscovery bool
+}
+
+// NewOidcCredential creates a new OidcCredential
+func NewOidcCredential(options *OidcCredentialOptions) (*OidcCredential, error) {
+	w := &OidcCredential{
+		assertion: options.Assertion,
+	}
+	if w.assertion == nil {
+		switch {
+		case options.Token != "":
+			w.assertion = StaticAssertion(options.Token)
+		case options.TokenFilePath != "":
+			w.assertion = FileAssertion{Path: options.TokenFilePath}
+		default:
+			w.assertion = GitHubActionsAssertion{
+				RequestURL:   options.RequestURL,
+				RequestToken: options.RequestToken,
+				Transport:    options.Transport,
+			}
+		}
 	}
 
-	return *tokenRes.Value, nil
+	cred, err := azidentity.NewClientAssertionCredential(options.TenantID, options.ClientID, w.assertion.GetAssertion, &azidentity.ClientAssertionCredentialOptions{
+		ClientOptions:            options.ClientOptions,
+		DisableInstanceDiscovery: options.DisableInstanceDiscovery,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	w.cred = cred
+	return w, nil
+}
+
+// GetToken gets a new token from the credential
+func (w *OidcCredential) GetToken(ctx context.Context, opts policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	return w.cred.GetToken(ctx, opts)
 }
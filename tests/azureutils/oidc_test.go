@@ -0,0 +1,177 @@
+package azureutils
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/terraform-azurerm-lz-vending/tests/fakearm"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestOidcCredentialRequestURL tests the GitHub Actions flow, where the ID token is requested from a URL
+// and exchanged for an access token.
+func TestOidcCredentialRequestURL(t *testing.T) {
+	srv := fakearm.NewServer(t, nil)
+	cred, err := NewOidcCredential(&OidcCredentialOptions{
+		ClientOptions:            azcore.ClientOptions{Cloud: srv.Cloud(), Transport: srv.Client()},
+		TenantID:                 uuid.NewString(),
+		ClientID:                 uuid.NewString(),
+		RequestToken:             fakearm.OidcRequestToken,
+		RequestURL:               srv.OidcRequestURL(),
+		DisableInstanceDiscovery: true,
+	})
+	require.NoError(t, err)
+
+	tok, err := cred.GetToken(context.Background(), policy.TokenRequestOptions{Scopes: []string{srv.URL() + "/.default"}})
+	require.NoError(t, err)
+	assert.Equal(t, fakearm.AccessToken, tok.Token)
+	assert.Equal(t, []string{fakearm.OidcIDToken}, srv.ClientAssertions())
+}
+
+// TestOidcCredentialTokenFile tests that the ID token is read from a file.
+func TestOidcCredentialTokenFile(t *testing.T) {
+	srv := fakearm.NewServer(t, nil)
+	path := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(path, []byte("file-id-token"), 0600))
+	cred, err := NewOidcCredential(&OidcCredentialOptions{
+		ClientOptions:            azcore.ClientOptions{Cloud: srv.Cloud(), Transport: srv.Client()},
+		TenantID:                 uuid.NewString(),
+		ClientID:                 uuid.NewString(),
+		TokenFilePath:            path,
+		DisableInstanceDiscovery: true,
+	})
+	require.NoError(t, err)
+
+	_, err = cred.GetToken(context.Background(), policy.TokenRequestOptions{Scopes: []string{srv.URL() + "/.default"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"file-id-token"}, srv.ClientAssertions())
+}
+
+// TestOidcCredentialAzureDevOps tests the Azure DevOps flow, where the ID token for a service connection
+// is requested with a POST to SYSTEM_OIDCREQUEST_URI.
+func TestOidcCredentialAzureDevOps(t *testing.T) {
+	srv := fakearm.NewServer(t, nil)
+	cred, err := NewOidcCredential(&OidcCredentialOptions{
+		ClientOptions: azcore.ClientOptions{Cloud: srv.Cloud(), Transport: srv.Client()},
+		TenantID:      uuid.NewString(),
+		ClientID:      uuid.NewString(),
+		Assertion: AzureDevOpsAssertion{
+			RequestURI:          srv.AzureDevOpsOidcRequestURL(),
+			AccessToken:         fakearm.OidcRequestToken,
+			ServiceConnectionID: fakearm.AzureDevOpsServiceConnectionID,
+			Transport:           srv.Client(),
+		},
+		DisableInstanceDiscovery: true,
+	})
+	require.NoError(t, err)
+
+	_, err = cred.GetToken(context.Background(), policy.TokenRequestOptions{Scopes: []string{srv.URL() + "/.default"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{fakearm.OidcIDToken}, srv.ClientAssertions())
+	assert.Equal(t, 1, srv.RequestCount(http.MethodPost, "/oidc/azuredevops"))
+}
+
+// TestAzureDevOpsAssertionUnknownServiceConnection tests that the endpoint error is reported.
+func TestAzureDevOpsAssertionUnknownServiceConnection(t *testing.T) {
+	srv := fakearm.NewServer(t, nil)
+	a := AzureDevOpsAssertion{
+		RequestURI:          srv.AzureDevOpsOidcRequestURL(),
+		AccessToken:         fakearm.OidcRequestToken,
+		ServiceConnectionID: "other",
+		Transport:           srv.Client(),
+	}
+	_, err := a.GetAssertion(context.Background())
+	assert.ErrorContains(t, err, "received HTTP status 404")
+}
+
+// TestFileAssertionRotation tests that a rotated token file is re-read.
+func TestFileAssertionRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(path, []byte("token-1\n"), 0600))
+	a := FileAssertion{Path: path}
+
+	tok, err := a.GetAssertion(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "token-1", tok)
+
+	require.NoError(t, os.WriteFile(path, []byte("token-2"), 0600))
+	tok, err = a.GetAssertion(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "token-2", tok)
+}
+
+// TestAssertionProviderFromEnv tests the selection of the ID token source for each CI environment.
+func TestAssertionProviderFromEnv(t *testing.T) {
+	vars := []string{
+		"ARM_OIDC_TOKEN", "ARM_OIDC_TOKEN_FILE_PATH", "ARM_OIDC_REQUEST_URL", "ARM_OIDC_REQUEST_TOKEN",
+		"ACTIONS_ID_TOKEN_REQUEST_URL", "ACTIONS_ID_TOKEN_REQUEST_TOKEN", "SYSTEM_OIDCREQUEST_URI", "SYSTEM_ACCESSTOKEN",
+		"ARM_ADO_PIPELINE_SERVICE_CONNECTION_ID", "ARM_OIDC_AZURE_SERVICE_CONNECTION_ID", "AZURESUBSCRIPTION_SERVICE_CONNECTION_ID",
+		"CI_JOB_JWT_V2", "AZURE_FEDERATED_TOKEN_FILE",
+	}
+	cases := []struct {
+		name    string
+		env     map[string]string
+		want    AssertionProvider
+		wantErr string
+	}{
+		{
+			name: "static token takes precedence",
+			env:  map[string]string{"ARM_OIDC_TOKEN": "tok", "ACTIONS_ID_TOKEN_REQUEST_URL": "https://github"},
+			want: StaticAssertion("tok"),
+		},
+		{
+			name: "github actions",
+			env:  map[string]string{"ACTIONS_ID_TOKEN_REQUEST_URL": "https://github", "ACTIONS_ID_TOKEN_REQUEST_TOKEN": "req"},
+			want: GitHubActionsAssertion{RequestURL: "https://github", RequestToken: "req"},
+		},
+		{
+			name: "azure devops",
+			env: map[string]string{
+				"SYSTEM_OIDCREQUEST_URI":                 "https://dev.azure.com/oidc",
+				"SYSTEM_ACCESSTOKEN":                     "sys",
+				"ARM_ADO_PIPELINE_SERVICE_CONNECTION_ID": "sc1",
+			},
+			want: AzureDevOpsAssertion{RequestURI: "https://dev.azure.com/oidc", AccessToken: "sys", ServiceConnectionID: "sc1"},
+		},
+		{
+			name:    "azure devops without service connection",
+			env:     map[string]string{"SYSTEM_OIDCREQUEST_URI": "https://dev.azure.com/oidc", "SYSTEM_ACCESSTOKEN": "sys"},
+			wantErr: "service connection ID is not",
+		},
+		{
+			name: "gitlab",
+			env:  map[string]string{"CI_JOB_JWT_V2": "jwt"},
+			want: EnvAssertion{Name: "CI_JOB_JWT_V2"},
+		},
+		{
+			name: "kubernetes projected token",
+			env:  map[string]string{"AZURE_FEDERATED_TOKEN_FILE": "/var/run/secrets/azure/tokens/azure-identity-token"},
+			want: FileAssertion{Path: "/var/run/secrets/azure/tokens/azure-identity-token"},
+		},
+		{
+			name:    "no source",
+			wantErr: "no token source was found",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, v := range vars {
+				t.Setenv(v, tc.env[v])
+			}
+			got, err := assertionProviderFromEnv()
+			if tc.wantErr != "" {
+				assert.ErrorContains(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
@@ -0,0 +1,458 @@
+package azureutils
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// The states reported by Azure for healthy peerings and hub connections,
+// and the values of hub_peering_direction.
+const (
+	peeringStateConnected   = "Connected"
+	peeringStateInitiated   = "Initiated"
+	peeringSyncFullyInSync  = "FullyInSync"
+	hubRoutingProvisioned   = "Provisioned"
+	provisioningSucceeded   = "Succeeded"
+	peeringDirectionBoth    = "both"
+	peeringDirectionFromHub = "fromhub"
+	peeringDirectionToHub   = "tohub"
+)
+
+type addressSpace struct {
+	AddressPrefixes []string `json:"addressPrefixes"`
+}
+
+type virtualNetworkPeering struct {
+	armResource
+	Properties struct {
+		PeeringState                     string        `json:"peeringState"`
+		PeeringSyncLevel                 string        `json:"peeringSyncLevel"`
+		RemoteVirtualNetwork             *armID        `json:"remoteVirtualNetwork"`
+		AllowForwardedTraffic            bool          `json:"allowForwardedTraffic"`
+		AllowGatewayTransit              bool          `json:"allowGatewayTransit"`
+		AllowVirtualNetworkAccess        bool          `json:"allowVirtualNetworkAccess"`
+		UseRemoteGateways                bool          `json:"useRemoteGateways"`
+		EnableOnlyIPv6Peering            bool          `json:"enableOnlyIPv6Peering"`
+		PeerCompleteVnets                *bool         `json:"peerCompleteVnets"`
+		LocalSubnetNames                 []string      `json:"localSubnetNames"`
+		RemoteSubnetNames                []string      `json:"remoteSubnetNames"`
+		LocalVirtualNetworkAddressSpace  *addressSpace `json:"localVirtualNetworkAddressSpace"`
+		RemoteVirtualNetworkAddressSpace *addressSpace `json:"remoteVirtualNetworkAddressSpace"`
+	} `json:"properties"`
+}
+
+type virtualHub struct {
+	armResource
+	Properties struct {
+		RoutingState string `json:"routingState"`
+	} `json:"properties"`
+}
+
+type hubVirtualNetworkConnection struct {
+	armResource
+	Properties struct {
+		ProvisioningState      string `json:"provisioningState"`
+		RemoteVirtualNetwork   *armID `json:"remoteVirtualNetwork"`
+		EnableInternetSecurity bool   `json:"enableInternetSecurity"`
+		RoutingConfiguration   *struct {
+			AssociatedRouteTable  *armID `json:"associatedRouteTable"`
+			PropagatedRouteTables *struct {
+				IDs    []armID  `json:"ids"`
+				Labels []string `json:"labels"`
+			} `json:"propagatedRouteTables"`
+		} `json:"routingConfiguration"`
+	} `json:"properties"`
+}
+
+type routingIntent struct {
+	armResource
+	Properties struct {
+		RoutingPolicies []struct {
+			Name         string   `json:"name"`
+			Destinations []string `json:"destinations"`
+			NextHop      string   `json:"nextHop"`
+		} `json:"routingPolicies"`
+	} `json:"properties"`
+}
+
+// peeringSettings are the expected settings of one side of a peering,
+// with the variable defaults applied.
+type peeringSettings struct {
+	localID                   string
+	remoteID                  string
+	name                      string
+	allowForwardedTraffic     bool
+	allowGatewayTransit       bool
+	allowVirtualNetworkAccess bool
+	useRemoteGateways         bool
+	enableOnlyIPv6Peering     bool
+	peerCompleteVnets         bool
+	localPeeredSubnets        []string
+	remotePeeredSubnets       []string
+	localPeeredAddressSpaces  []string
+	remotePeeredAddressSpaces []string
+}
+
+// withOptions returns the settings with the values of a hub_peering_options_tohub or
+// hub_peering_options_fromhub object applied.
+func (s peeringSettings) withOptions(v any) peeringSettings {
+	opts, _ := v.(map[string]any)
+	s.allowForwardedTraffic = boolValue(opts["allow_forwarded_traffic"], s.allowForwardedTraffic)
+	s.allowGatewayTransit = boolValue(opts["allow_gateway_transit"], s.allowGatewayTransit)
+	s.allowVirtualNetworkAccess = boolValue(opts["allow_virtual_network_access"], s.allowVirtualNetworkAccess)
+	s.useRemoteGateways = boolValue(opts["use_remote_gateways"], s.useRemoteGateways)
+	s.enableOnlyIPv6Peering = boolValue(opts["enable_only_ipv6_peering"], s.enableOnlyIPv6Peering)
+	s.peerCompleteVnets = boolValue(opts["peer_complete_vnets"], s.peerCompleteVnets)
+	s.localPeeredSubnets = stringSlice(opts["local_peered_subnets"])
+	s.remotePeeredSubnets = stringSlice(opts["remote_peered_subnets"])
+	s.localPeeredAddressSpaces = stringSlice(opts["local_peered_address_spaces"])
+	s.remotePeeredAddressSpaces = stringSlice(opts["remote_peered_address_spaces"])
+	return s
+}
+
+// VerifyHubPeering checks the peerings between a virtual network and its hub, using the DefaultClientFactory.
+func VerifyHubPeering(ctx context.Context, subID uuid.UUID, vnet map[string]any) (Diff, error) {
+	f, err := DefaultClientFactory()
+	if err != nil {
+		return nil, err
+	}
+	return f.VerifyHubPeering(ctx, subID, vnet)
+}
+
+// VerifyMeshPeering checks the peerings between the virtual networks with mesh peering enabled,
+// using the DefaultClientFactory.
+func VerifyMeshPeering(ctx context.Context, subID uuid.UUID, vnets map[string]map[string]any) (Diff, error) {
+	f, err := DefaultClientFactory()
+	if err != nil {
+		return nil, err
+	}
+	return f.VerifyMeshPeering(ctx, subID, vnets)
+}
+
+// VerifyVirtualHubConnection checks the virtual WAN hub connection of a virtual network,
+// using the DefaultClientFactory.
+func VerifyVirtualHubConnection(ctx context.Context, subID uuid.UUID, vnet map[string]any) (Diff, error) {
+	f, err := DefaultClientFactory()
+	if err != nil {
+		return nil, err
+	}
+	return f.VerifyVirtualHubConnection(ctx, subID, vnet)
+}
+
+// VerifyHubPeering checks the peerings between a virtual network and its hub, as configured by an entry of
+// the virtualnetwork module's virtual_networks input variable.
+// The entry must include the hub_network_resource_id.
+// Each peering created by the module, according to hub_peering_direction, must exist with the
+// hub_peering_options_tohub or hub_peering_options_fromhub settings.
+// A peering must be Connected and fully in sync if the remote network has a peering back,
+// otherwise, for a unidirectional peering whose other side is not managed by the module, it must be Initiated.
+// An empty Diff is returned if hub peering is not enabled.
+func (f *ClientFactory) VerifyHubPeering(ctx context.Context, subID uuid.UUID, vnet map[string]any) (Diff, error) {
+	diff := Diff{}
+	if !boolValue(vnet["hub_peering_enabled"], false) {
+		return diff, nil
+	}
+	hubID, _ := vnet["hub_network_resource_id"].(string)
+	if hubID == "" {
+		return nil, fmt.Errorf("cannot verify hub peering of virtual network %s, hub_network_resource_id is not set", vnet["name"])
+	}
+	vnetID := virtualNetworkID(subID, vnet)
+	direction, _ := vnet["hub_peering_direction"].(string)
+	direction = strings.ToLower(direction)
+	if direction != peeringDirectionFromHub && direction != peeringDirectionToHub {
+		direction = peeringDirectionBoth
+	}
+
+	var peerings []peeringSettings
+	if direction != peeringDirectionFromHub {
+		name, _ := vnet["hub_peering_name_tohub"].(string)
+		if name == "" {
+			name = peeringName(hubID)
+		}
+		peerings = append(peerings, peeringSettings{
+			localID:                   vnetID,
+			remoteID:                  hubID,
+			name:                      name,
+			allowForwardedTraffic:     true,
+			allowVirtualNetworkAccess: true,
+			useRemoteGateways:         true,
+			peerCompleteVnets:         true,
+		}.withOptions(vnet["hub_peering_options_tohub"]))
+	}
+	if direction != peeringDirectionToHub {
+		name, _ := vnet["hub_peering_name_fromhub"].(string)
+		if name == "" {
+			name = peeringName(vnetID)
+		}
+		peerings = append(peerings, peeringSettings{
+			localID:                   hubID,
+			remoteID:                  vnetID,
+			name:                      name,
+			allowForwardedTraffic:     true,
+			allowGatewayTransit:       true,
+			allowVirtualNetworkAccess: true,
+			peerCompleteVnets:         true,
+		}.withOptions(vnet["hub_peering_options_fromhub"]))
+	}
+
+	for _, p := range peerings {
+		d, err := f.verifyPeering(ctx, p)
+		if err != nil {
+			return nil, err
+		}
+		diff = append(diff, d...)
+	}
+	return diff, nil
+}
+
+// VerifyMeshPeering checks the peerings between the virtual networks with mesh peering enabled,
+// as configured by the virtualnetwork module's virtual_networks input variable.
+// Each network must be peered to every other mesh network, and both sides of each peering must be Connected.
+func (f *ClientFactory) VerifyMeshPeering(ctx context.Context, subID uuid.UUID, vnets map[string]map[string]any) (Diff, error) {
+	keys := make([]string, 0, len(vnets))
+	for k, v := range vnets {
+		if boolValue(v["mesh_peering_enabled"], false) {
+			keys = append(keys, k)
+		}
+	}
+	slices.Sort(keys)
+
+	diff := Diff{}
+	for _, src := range keys {
+		for _, dst := range keys {
+			if src == dst {
+				continue
+			}
+			remoteID := virtualNetworkID(subID, vnets[dst])
+			d, err := f.verifyPeering(ctx, peeringSettings{
+				localID:                   virtualNetworkID(subID, vnets[src]),
+				remoteID:                  remoteID,
+				name:                      peeringName(remoteID),
+				allowForwardedTraffic:     boolValue(vnets[src]["mesh_peering_allow_forwarded_traffic"], false),
+				allowVirtualNetworkAccess: true,
+				peerCompleteVnets:         true,
+			})
+			if err != nil {
+				return nil, err
+			}
+			diff = append(diff, d...)
+		}
+	}
+	return diff, nil
+}
+
+// verifyPeering compares one side of a peering with the expected settings,
+// and checks its state against the other side.
+func (f *ClientFactory) verifyPeering(ctx context.Context, want peeringSettings) (Diff, error) {
+	id := want.localID + "/virtualNetworkPeerings/" + want.name
+	diff := Diff{}
+	var got virtualNetworkPeering
+	if err := f.doARM(ctx, http.MethodGet, id, networkAPIVersion, nil, &got); err != nil {
+		if isNotFound(err) {
+			diff.compare(id, "peering", want.name, nil)
+			return diff, nil
+		}
+		return nil, fmt.Errorf("cannot get virtual network peering %s, %v", id, err)
+	}
+	props := got.Properties
+
+	remoteID := ""
+	if props.RemoteVirtualNetwork != nil {
+		remoteID = props.RemoteVirtualNetwork.ID
+	}
+	diff.compare(id, "remote_virtual_network_id", strings.ToLower(want.remoteID), strings.ToLower(remoteID))
+	diff.compare(id, "allow_forwarded_traffic", want.allowForwardedTraffic, props.AllowForwardedTraffic)
+	diff.compare(id, "allow_gateway_transit", want.allowGatewayTransit, props.AllowGatewayTransit)
+	diff.compare(id, "allow_virtual_network_access", want.allowVirtualNetworkAccess, props.AllowVirtualNetworkAccess)
+	diff.compare(id, "use_remote_gateways", want.useRemoteGateways, props.UseRemoteGateways)
+	diff.compare(id, "enable_only_ipv6_peering", want.enableOnlyIPv6Peering, props.EnableOnlyIPv6Peering)
+	diff.compare(id, "peer_complete_vnets", want.peerCompleteVnets, props.PeerCompleteVnets == nil || *props.PeerCompleteVnets)
+	if !want.peerCompleteVnets {
+		diff.compareSet(id, "local_peered_subnets", want.localPeeredSubnets, nilIfEmpty(props.LocalSubnetNames))
+		diff.compareSet(id, "remote_peered_subnets", want.remotePeeredSubnets, nilIfEmpty(props.RemoteSubnetNames))
+		diff.compareSet(id, "local_peered_address_spaces", want.localPeeredAddressSpaces, addressPrefixes(props.LocalVirtualNetworkAddressSpace))
+		diff.compareSet(id, "remote_peered_address_spaces", want.remotePeeredAddressSpaces, addressPrefixes(props.RemoteVirtualNetworkAddressSpace))
+	}
+
+	// A peering is only Connected once the remote network has a peering back to this network.
+	reverse, err := f.hasPeeringTo(ctx, want.remoteID, want.localID)
+	if err != nil {
+		return nil, err
+	}
+	state := peeringStateInitiated
+	if reverse {
+		state = peeringStateConnected
+	}
+	diff.compare(id, "peering_state", state, props.PeeringState)
+	if reverse {
+		diff.compare(id, "peering_sync_level", peeringSyncFullyInSync, props.PeeringSyncLevel)
+	}
+	return diff, nil
+}
+
+// hasPeeringTo reports whether the virtual network has a peering to the remote virtual network.
+// It is false if the virtual network does not exist.
+func (f *ClientFactory) hasPeeringTo(ctx context.Context, vnetID, remoteID string) (bool, error) {
+	peerings, err := listARM[virtualNetworkPeering](ctx, f, vnetID+"/virtualNetworkPeerings", networkAPIVersion)
+	if err != nil {
+		if isNotFound(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("cannot list peerings of virtual network %s, %v", vnetID, err)
+	}
+	for _, p := range peerings {
+		if p.Properties.RemoteVirtualNetwork != nil && strings.EqualFold(p.Properties.RemoteVirtualNetwork.ID, remoteID) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// VerifyVirtualHubConnection checks the virtual WAN hub connection of a virtual network, as configured by an entry of
+// the virtualnetwork module's virtual_networks input variable.
+// The hub routing must be provisioned and the connection must have succeeded.
+// Without routing intent, the connection's associated and propagated route tables must match the input.
+// With routing intent, the hub must have a routing policy for the traffic secured by vwan_security_configuration,
+// and the connection must be associated with the hub's default route table.
+// An empty Diff is returned if the connection is not enabled.
+func (f *ClientFactory) VerifyVirtualHubConnection(ctx context.Context, subID uuid.UUID, vnet map[string]any) (Diff, error) {
+	diff := Diff{}
+	if !boolValue(vnet["vwan_connection_enabled"], false) {
+		return diff, nil
+	}
+	hubID, _ := vnet["vwan_hub_resource_id"].(string)
+	if hubID == "" {
+		return nil, fmt.Errorf("cannot verify virtual hub connection of virtual network %s, vwan_hub_resource_id is not set", vnet["name"])
+	}
+	vnetID := virtualNetworkID(subID, vnet)
+	name, _ := vnet["vwan_connection_name"].(string)
+	if name == "" {
+		name = "vhc-" + uuid.NewSHA1(uuid.NameSpaceURL, []byte(vnetID)).String()
+	}
+	security, _ := vnet["vwan_security_configuration"].(map[string]any)
+	secureInternet := boolValue(security["secure_internet_traffic"], false)
+	securePrivate := boolValue(security["secure_private_traffic"], false)
+
+	var hub virtualHub
+	if err := f.doARM(ctx, http.MethodGet, hubID, networkAPIVersion, nil, &hub); err != nil {
+		return nil, fmt.Errorf("cannot get virtual hub %s, %v", hubID, err)
+	}
+	diff.compare(hubID, "routing_state", hubRoutingProvisioned, hub.Properties.RoutingState)
+
+	id := hubID + "/hubVirtualNetworkConnections/" + name
+	var conn hubVirtualNetworkConnection
+	if err := f.doARM(ctx, http.MethodGet, id, networkAPIVersion, nil, &conn); err != nil {
+		if isNotFound(err) {
+			diff.compare(id, "hub_connection", name, nil)
+			return diff, nil
+		}
+		return nil, fmt.Errorf("cannot get virtual hub connection %s, %v", id, err)
+	}
+	props := conn.Properties
+	remoteID := ""
+	if props.RemoteVirtualNetwork != nil {
+		remoteID = props.RemoteVirtualNetwork.ID
+	}
+	diff.compare(id, "provisioning_state", provisioningSucceeded, props.ProvisioningState)
+	diff.compare(id, "remote_virtual_network_id", strings.ToLower(vnetID), strings.ToLower(remoteID))
+	diff.compare(id, "enable_internet_security", secureInternet, props.EnableInternetSecurity)
+
+	var associated string
+	var propagatedIDs, propagatedLabels []string
+	if rc := props.RoutingConfiguration; rc != nil {
+		if rc.AssociatedRouteTable != nil {
+			associated = rc.AssociatedRouteTable.ID
+		}
+		if rc.PropagatedRouteTables != nil {
+			for _, i := range rc.PropagatedRouteTables.IDs {
+				propagatedIDs = append(propagatedIDs, strings.ToLower(i.ID))
+			}
+			propagatedLabels = rc.PropagatedRouteTables.Labels
+		}
+	}
+	defaultRouteTable := hubID + "/hubRouteTables/defaultRouteTable"
+
+	if boolValue(security["routing_intent_enabled"], false) {
+		// Azure manages the connection's routing configuration when the hub has routing intent.
+		diff.compare(id, "associated_route_table.id", strings.ToLower(defaultRouteTable), strings.ToLower(associated))
+		d, err := f.verifyRoutingIntent(ctx, hubID, secureInternet, securePrivate)
+		if err != nil {
+			return nil, err
+		}
+		return append(diff, d...), nil
+	}
+
+	wantAssociated, _ := vnet["vwan_associated_routetable_resource_id"].(string)
+	if wantAssociated == "" {
+		wantAssociated = defaultRouteTable
+	}
+	wantIDs := stringSlice(vnet["vwan_propagated_routetables_resource_ids"])
+	wantLabels := stringSlice(vnet["vwan_propagated_routetables_labels"])
+	switch {
+	case securePrivate:
+		if len(wantIDs) == 0 {
+			wantIDs = []string{hubID + "/hubRouteTables/noneRouteTable"}
+		}
+		wantLabels = []string{"none"}
+	case len(wantLabels) == 0:
+		wantLabels = []string{"default"}
+	}
+	if len(wantIDs) == 0 {
+		wantIDs = []string{defaultRouteTable}
+	}
+	for i := range wantIDs {
+		wantIDs[i] = strings.ToLower(wantIDs[i])
+	}
+	diff.compare(id, "associated_route_table.id", strings.ToLower(wantAssociated), strings.ToLower(associated))
+	diff.compareSet(id, "propagated_route_tables.ids", wantIDs, propagatedIDs)
+	diff.compareSet(id, "propagated_route_tables.labels", wantLabels, propagatedLabels)
+	return diff, nil
+}
+
+// verifyRoutingIntent checks that the hub has routing intent,
+// with a routing policy for each type of secured traffic.
+func (f *ClientFactory) verifyRoutingIntent(ctx context.Context, hubID string, secureInternet, securePrivate bool) (Diff, error) {
+	path := hubID + "/routingIntent"
+	intents, err := listARM[routingIntent](ctx, f, path, networkAPIVersion)
+	if err != nil {
+		return nil, fmt.Errorf("cannot list routing intent of virtual hub %s, %v", hubID, err)
+	}
+	diff := Diff{}
+	if len(intents) == 0 {
+		diff.compare(path, "routing_intent", true, false)
+		return diff, nil
+	}
+	id := intents[0].ID
+	destinations := map[string]bool{}
+	for _, p := range intents[0].Properties.RoutingPolicies {
+		for _, d := range p.Destinations {
+			destinations[d] = true
+		}
+	}
+	diff.compare(id, "routing_policies", true, len(intents[0].Properties.RoutingPolicies) > 0)
+	if secureInternet {
+		diff.compare(id, "routing_policy.Internet", true, destinations["Internet"])
+	}
+	if securePrivate {
+		diff.compare(id, "routing_policy.PrivateTraffic", true, destinations["PrivateTraffic"])
+	}
+	return diff, nil
+}
+
+// peeringName returns the module's default name for a peering to the remote virtual network,
+// which is the Terraform uuidv5("url", id) of the remote network.
+func peeringName(remoteID string) string {
+	return "peer-" + uuid.NewSHA1(uuid.NameSpaceURL, []byte(remoteID)).String()
+}
+
+func addressPrefixes(a *addressSpace) []string {
+	if a == nil {
+		return nil
+	}
+	return nilIfEmpty(a.AddressPrefixes)
+}
@@ -0,0 +1,195 @@
+package azureutils
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Azure/terraform-azurerm-lz-vending/tests/fakearm"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// putPeering adds a peering from one virtual network to another to the fake server.
+func putPeering(srv *fakearm.Server, localID, remoteID, name, state string, props map[string]any) {
+	p := map[string]any{
+		"remoteVirtualNetwork":      map[string]any{"id": remoteID},
+		"peeringState":              state,
+		"peeringSyncLevel":          "FullyInSync",
+		"allowVirtualNetworkAccess": true,
+	}
+	for k, v := range props {
+		p[k] = v
+	}
+	srv.PutResource(localID+"/virtualNetworkPeerings/"+name, map[string]any{"properties": p})
+}
+
+// properties returns the properties of each difference, in order.
+func properties(diff Diff) []string {
+	props := make([]string, 0, len(diff))
+	for _, d := range diff {
+		props = append(props, d.Property)
+	}
+	return props
+}
+
+// TestVerifyHubPeering tests that a connected bidirectional hub peering has no differences,
+// and that mismatched options and states are reported.
+func TestVerifyHubPeering(t *testing.T) {
+	f, srv := newFakeClientFactory(t, nil)
+	id := uuid.New()
+	ctx := context.Background()
+	srv.AddResourceGroup(id, "rg1", "westeurope")
+	srv.AddResourceGroup(id, "rg-hub", "westeurope")
+	vnetID := "/subscriptions/" + id.String() + "/resourceGroups/rg1/providers/Microsoft.Network/virtualNetworks/vnet1"
+	hubID := "/subscriptions/" + id.String() + "/resourceGroups/rg-hub/providers/Microsoft.Network/virtualNetworks/hub"
+	putPeering(srv, vnetID, hubID, peeringName(hubID), "Connected", map[string]any{"allowForwardedTraffic": true})
+	putPeering(srv, hubID, vnetID, peeringName(vnetID), "Connected", map[string]any{"allowForwardedTraffic": true, "allowGatewayTransit": true})
+	vnet := map[string]any{
+		"name":                      "vnet1",
+		"resource_group_name":       "rg1",
+		"hub_network_resource_id":   hubID,
+		"hub_peering_enabled":       true,
+		"hub_peering_options_tohub": map[string]any{"use_remote_gateways": false},
+	}
+
+	diff, err := f.VerifyHubPeering(ctx, id, vnet)
+	require.NoError(t, err)
+	assert.Empty(t, diff, diff.String())
+
+	// Both sides exist, but the spoke side never reached Connected.
+	putPeering(srv, vnetID, hubID, peeringName(hubID), "Initiated", map[string]any{"allowForwardedTraffic": true})
+	diff, err = f.VerifyHubPeering(ctx, id, vnet)
+	require.NoError(t, err)
+	assert.Equal(t, Diff{
+		{ResourceID: vnetID + "/virtualNetworkPeerings/" + peeringName(hubID), Property: "peering_state", Expected: "Connected", Actual: "Initiated"},
+	}, diff)
+
+	// The hub side is missing, so the spoke side is Initiated.
+	srv.DeleteResource(hubID + "/virtualNetworkPeerings/" + peeringName(vnetID))
+	diff, err = f.VerifyHubPeering(ctx, id, vnet)
+	require.NoError(t, err)
+	assert.Equal(t, Diff{
+		{ResourceID: hubID + "/virtualNetworkPeerings/" + peeringName(vnetID), Property: "peering", Expected: peeringName(vnetID)},
+	}, diff)
+
+	// With a unidirectional peering the hub side is not managed by the module.
+	vnet["hub_peering_direction"] = "tohub"
+	diff, err = f.VerifyHubPeering(ctx, id, vnet)
+	require.NoError(t, err)
+	assert.Empty(t, diff, diff.String())
+
+	vnet["hub_peering_options_tohub"] = map[string]any{
+		"allow_forwarded_traffic": false,
+		"peer_complete_vnets":     false,
+		"local_peered_subnets":    []any{"snet-default"},
+		"remote_peered_subnets":   []any{"default"},
+	}
+	diff, err = f.VerifyHubPeering(ctx, id, vnet)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"allow_forwarded_traffic",
+		"use_remote_gateways",
+		"peer_complete_vnets",
+		"local_peered_subnets",
+		"remote_peered_subnets",
+	}, properties(diff))
+}
+
+// TestVerifyMeshPeering tests that each mesh network must be peered to every other mesh network.
+func TestVerifyMeshPeering(t *testing.T) {
+	f, srv := newFakeClientFactory(t, nil)
+	id := uuid.New()
+	ctx := context.Background()
+	srv.AddResourceGroup(id, "rg1", "westeurope")
+	vnets := map[string]map[string]any{
+		"primary":   {"name": "vnet1", "resource_group_name": "rg1", "mesh_peering_enabled": true},
+		"secondary": {"name": "vnet2", "resource_group_name": "rg1", "mesh_peering_enabled": true},
+		"other":     {"name": "vnet3", "resource_group_name": "rg1"},
+	}
+	vnet1 := virtualNetworkID(id, vnets["primary"])
+	vnet2 := virtualNetworkID(id, vnets["secondary"])
+	putPeering(srv, vnet1, vnet2, peeringName(vnet2), "Connected", nil)
+	putPeering(srv, vnet2, vnet1, peeringName(vnet1), "Connected", map[string]any{"peeringSyncLevel": "LocalNotInSync"})
+
+	diff, err := f.VerifyMeshPeering(ctx, id, vnets)
+	require.NoError(t, err)
+	assert.Equal(t, Diff{
+		{ResourceID: vnet2 + "/virtualNetworkPeerings/" + peeringName(vnet1), Property: "peering_sync_level", Expected: "FullyInSync", Actual: "LocalNotInSync"},
+	}, diff)
+
+	vnets["other"]["mesh_peering_enabled"] = true
+	vnet3 := virtualNetworkID(id, vnets["other"])
+	diff, err = f.VerifyMeshPeering(ctx, id, vnets)
+	require.NoError(t, err)
+	missing := make([]string, 0, len(diff))
+	for _, d := range diff {
+		if d.Property == "peering" {
+			missing = append(missing, d.ResourceID)
+		}
+	}
+	assert.ElementsMatch(t, []string{
+		vnet1 + "/virtualNetworkPeerings/" + peeringName(vnet3),
+		vnet2 + "/virtualNetworkPeerings/" + peeringName(vnet3),
+		vnet3 + "/virtualNetworkPeerings/" + peeringName(vnet1),
+		vnet3 + "/virtualNetworkPeerings/" + peeringName(vnet2),
+	}, missing)
+}
+
+// TestVerifyVirtualHubConnection tests the routing configuration of a hub connection,
+// with and without routing intent.
+func TestVerifyVirtualHubConnection(t *testing.T) {
+	f, srv := newFakeClientFactory(t, nil)
+	id := uuid.New()
+	ctx := context.Background()
+	srv.AddResourceGroup(id, "rg1", "westeurope")
+	srv.AddResourceGroup(id, "rg-hub", "westeurope")
+	vnet := map[string]any{
+		"name":                    "vnet1",
+		"resource_group_name":     "rg1",
+		"vwan_connection_enabled": true,
+	}
+	vnetID := virtualNetworkID(id, vnet)
+	hubID := "/subscriptions/" + id.String() + "/resourceGroups/rg-hub/providers/Microsoft.Network/virtualHubs/vhub"
+	vnet["vwan_hub_resource_id"] = hubID
+	connID := hubID + "/hubVirtualNetworkConnections/vhc-" + uuid.NewSHA1(uuid.NameSpaceURL, []byte(vnetID)).String()
+	srv.PutResource(hubID, map[string]any{"properties": map[string]any{"routingState": "Provisioned"}})
+	srv.PutResource(connID, map[string]any{"properties": map[string]any{
+		"provisioningState":    "Succeeded",
+		"remoteVirtualNetwork": map[string]any{"id": vnetID},
+		"routingConfiguration": map[string]any{
+			"associatedRouteTable": map[string]any{"id": hubID + "/hubRouteTables/defaultRouteTable"},
+			"propagatedRouteTables": map[string]any{
+				"ids":    []any{map[string]any{"id": hubID + "/hubRouteTables/defaultRouteTable"}},
+				"labels": []any{"default"},
+			},
+		},
+	}})
+
+	diff, err := f.VerifyVirtualHubConnection(ctx, id, vnet)
+	require.NoError(t, err)
+	assert.Empty(t, diff, diff.String())
+
+	vnet["vwan_security_configuration"] = map[string]any{"secure_private_traffic": true}
+	diff, err = f.VerifyVirtualHubConnection(ctx, id, vnet)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"propagated_route_tables.ids", "propagated_route_tables.labels"}, properties(diff))
+
+	vnet["vwan_security_configuration"] = map[string]any{"routing_intent_enabled": true, "secure_private_traffic": true}
+	diff, err = f.VerifyVirtualHubConnection(ctx, id, vnet)
+	require.NoError(t, err)
+	assert.Equal(t, Diff{{ResourceID: hubID + "/routingIntent", Property: "routing_intent", Expected: true, Actual: false}}, diff)
+
+	srv.PutResource(hubID+"/routingIntent/ri", map[string]any{"properties": map[string]any{
+		"routingPolicies": []any{map[string]any{"name": "PublicTraffic", "destinations": []any{"Internet"}}},
+	}})
+	diff, err = f.VerifyVirtualHubConnection(ctx, id, vnet)
+	require.NoError(t, err)
+	assert.Equal(t, Diff{{ResourceID: hubID + "/routingIntent/ri", Property: "routing_policy.PrivateTraffic", Expected: true, Actual: false}}, diff)
+
+	srv.PutResource(hubID, map[string]any{"properties": map[string]any{"routingState": "Provisioning"}})
+	srv.DeleteResource(connID)
+	diff, err = f.VerifyVirtualHubConnection(ctx, id, vnet)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"routing_state", "hub_connection"}, properties(diff))
+}
@@ -2,21 +2,51 @@ package azureutils
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"time"
 
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
 	"github.com/google/uuid"
 )
 
 // ListResourceGroup returns all resource groups in the subscription
+// using the DefaultClientFactory.
 func ListResourceGroup(ctx context.Context, subID uuid.UUID) ([]*armresources.ResourceGroup, error) {
-	cred, err := newDefaultAzureCredential()
+	f, err := DefaultClientFactory()
 	if err != nil {
-		return nil, fmt.Errorf("failed to create Azure credential: %v", err)
+		return nil, err
 	}
-	resourceGroupClient, err := armresources.NewResourceGroupsClient(subID.String(), cred, nil)
+	return f.ListResourceGroups(ctx, subID)
+}
+
+// DeleteResourceGroup deletes a resource group by name and subscription id
+// using the DefaultClientFactory.
+func DeleteResourceGroup(ctx context.Context, rgname string, subID uuid.UUID) error {
+	f, err := DefaultClientFactory()
 	if err != nil {
-		return nil, fmt.Errorf("failed to create resource group client: %v", err)
+		return err
+	}
+	return f.DeleteResourceGroup(ctx, rgname, subID)
+}
+
+// DeleteResourceGroupWithOptions deletes a resource group by name and subscription id
+// using the DefaultClientFactory.
+func DeleteResourceGroupWithOptions(ctx context.Context, rgname string, subID uuid.UUID, opts *DeleteResourceGroupOptions) error {
+	f, err := DefaultClientFactory()
+	if err != nil {
+		return err
+	}
+	return f.DeleteResourceGroupWithOptions(ctx, rgname, subID, opts)
+}
+
+// ListResourceGroups returns all resource groups in the subscription.
+func (f *ClientFactory) ListResourceGroups(ctx context.Context, subID uuid.UUID) ([]*armresources.ResourceGroup, error) {
+	resourceGroupClient, err := f.NewResourceGroupsClient(subID)
+	if err != nil {
+		return nil, err
 	}
 
 	resultPager := resourceGroupClient.NewListPager(nil)
@@ -32,25 +62,107 @@ func ListResourceGroup(ctx context.Context, subID uuid.UUID) ([]*armresources.Re
 	return resourceGroups, nil
 }
 
-// DeleteResourceGroup deletes a resource group by name and subscription id
-func DeleteResourceGroup(ctx context.Context, rgname string, subID uuid.UUID) error {
-	cred, err := newDefaultAzureCredential()
+// ResourceGroupCreatedTime returns the earliest creation time of the resources in the resource group.
+// Resource groups do not report their own creation time.
+// It returns a zero time if the resource group is empty.
+func (f *ClientFactory) ResourceGroupCreatedTime(ctx context.Context, rgname string, subID uuid.UUID) (time.Time, error) {
+	client, err := f.NewResourcesClient(subID)
 	if err != nil {
-		return fmt.Errorf("failed to create Azure credential: %v", err)
+		return time.Time{}, err
 	}
-	resourceGroupClient, err := armresources.NewResourceGroupsClient(subID.String(), cred, nil)
-	if err != nil {
-		return fmt.Errorf("failed to create resource group client: %v", err)
+	expand := "createdTime"
+	pager := client.NewListByResourceGroupPager(rgname, &armresources.ClientListByResourceGroupOptions{
+		Expand: &expand,
+	})
+	var created time.Time
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return time.Time{}, err
+		}
+		for _, res := range page.Value {
+			if res.CreatedTime == nil {
+				continue
+			}
+			if created.IsZero() || res.CreatedTime.Before(created) {
+				created = *res.CreatedTime
+			}
+		}
 	}
+	return created, nil
+}
 
-	pollerResp, err := resourceGroupClient.BeginDelete(ctx, rgname, nil)
+// forceDeletionTypes are the resource types that support forced deletion.
+const forceDeletionTypes = "Microsoft.Compute/virtualMachines,Microsoft.Compute/virtualMachineScaleSets"
+
+// DeleteResourceGroupOptions contains the optional parameters for DeleteResourceGroupWithOptions.
+type DeleteResourceGroupOptions struct {
+	// RemoveLocks removes the management locks on the resource group and on the resources in it before deleting it.
+	// Locks inherited from the subscription are never removed, so they still fail the delete.
+	// Without it, the delete fails if the resource group, or a resource in it, is locked.
+	RemoveLocks bool
+
+	// ForceDeletion force deletes the virtual machines and virtual machine scale sets in the resource group.
+	ForceDeletion bool
+}
+
+// DeleteResourceGroup deletes a resource group by name and subscription id.
+// It fails if the resource group is locked, use DeleteResourceGroupWithOptions to remove the locks.
+// The delete poller is given its own budget, bounded by the supplied context.
+func (f *ClientFactory) DeleteResourceGroup(ctx context.Context, rgname string, subID uuid.UUID) error {
+	return f.DeleteResourceGroupWithOptions(ctx, rgname, subID, nil)
+}
+
+// DeleteResourceGroupWithOptions deletes a resource group by name and subscription id.
+// If opts.RemoveLocks is set, the management locks on the resource group and its resources are removed first,
+// and as lock removal is eventually consistent, the delete is retried while ARM reports the scope is locked.
+// If opts is nil, the default options are used.
+func (f *ClientFactory) DeleteResourceGroupWithOptions(ctx context.Context, rgname string, subID uuid.UUID, opts *DeleteResourceGroupOptions) error {
+	if opts == nil {
+		opts = &DeleteResourceGroupOptions{}
+	}
+	resourceGroupClient, err := f.NewResourceGroupsClient(subID)
 	if err != nil {
 		return err
 	}
 
-	_, err = pollerResp.PollUntilDone(ctx, nil)
+	ctx, cancel := context.WithTimeout(ctx, resourceGroupDeleteTimeout)
+	defer cancel()
+
+	var deleteOpts armresources.ResourceGroupsClientBeginDeleteOptions
+	if opts.ForceDeletion {
+		deleteOpts.ForceDeletionTypes = to.Ptr(forceDeletionTypes)
+	}
+
+	err = f.retryPolicy(ctx).Do(ctx, func(ctx context.Context) error {
+		if opts.RemoveLocks {
+			if _, err := f.RemoveResourceGroupLocks(ctx, rgname, subID); err != nil {
+				return Permanent(err)
+			}
+		}
+		err := deleteResourceGroup(ctx, resourceGroupClient, rgname, &deleteOpts)
+		if !opts.RemoveLocks || !isScopeLocked(err) {
+			return Permanent(err)
+		}
+		return err
+	})
+	if isScopeLocked(err) {
+		return fmt.Errorf("resource group %s is still locked: %w", rgname, err)
+	}
+	return err
+}
+
+func deleteResourceGroup(ctx context.Context, client *armresources.ResourceGroupsClient, rgname string, opts *armresources.ResourceGroupsClientBeginDeleteOptions) error {
+	pollerResp, err := client.BeginDelete(ctx, rgname, opts)
 	if err != nil {
 		return err
 	}
-	return nil
+	_, err = pollerResp.PollUntilDone(ctx, nil)
+	return err
+}
+
+// isScopeLocked reports whether the error is ARM refusing an operation because of a management lock.
+func isScopeLocked(err error) bool {
+	var respErr *azcore.ResponseError
+	return errors.As(err, &respErr) && respErr.ErrorCode == "ScopeLocked"
 }
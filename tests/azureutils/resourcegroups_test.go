@@ -0,0 +1,124 @@
+package azureutils
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/terraform-azurerm-lz-vending/tests/fakearm"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestListAndDeleteResourceGroup tests listing resource groups and deleting one using the long-running operation.
+func TestListAndDeleteResourceGroup(t *testing.T) {
+	f, srv := newFakeClientFactory(t, &fakearm.Options{DeletePollCount: 3})
+	id := uuid.New()
+	srv.AddResourceGroup(id, "rg1", "westeurope")
+	srv.AddResourceGroup(id, "rg2", "westeurope")
+
+	ctx := context.Background()
+	rgs, err := f.ListResourceGroups(ctx, id)
+	require.NoError(t, err)
+	require.Len(t, rgs, 2)
+	assert.Equal(t, "rg1", *rgs[0].Name)
+
+	require.NoError(t, f.DeleteResourceGroup(ctx, "rg1", id))
+	assert.Equal(t, []string{"rg2"}, srv.ResourceGroups(id))
+}
+
+// TestDeleteResourceGroupDeadline tests that the delete poller stops when the context deadline is reached.
+func TestDeleteResourceGroupDeadline(t *testing.T) {
+	f, srv := newFakeClientFactory(t, &fakearm.Options{DeletePollCount: 1 << 30})
+	id := uuid.New()
+	srv.AddResourceGroup(id, "rg1", "westeurope")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	err := f.DeleteResourceGroup(ctx, "rg1", id)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+// TestDeleteResourceGroupRemovesLocks tests that locks on the resource group and its resources are removed when asked,
+// and that the delete is retried while the lock removal propagates.
+func TestDeleteResourceGroupRemovesLocks(t *testing.T) {
+	f, srv := newFakeClientFactory(t, &fakearm.Options{LockReleaseDelay: 2})
+	id := uuid.New()
+	srv.AddResourceGroup(id, "rg1", "westeurope")
+	rgID := "/subscriptions/" + id.String() + "/resourceGroups/rg1"
+	vnetID := rgID + "/providers/Microsoft.Network/virtualNetworks/vnet1"
+	srv.PutResource(vnetID, nil)
+	srv.PutResource(rgID+"/providers/Microsoft.Authorization/locks/rg-lock", map[string]any{
+		"properties": map[string]any{"level": "CanNotDelete"},
+	})
+	srv.PutResource(vnetID+"/providers/Microsoft.Authorization/locks/vnet-lock", map[string]any{
+		"properties": map[string]any{"level": "ReadOnly"},
+	})
+
+	ctx := context.Background()
+	locks, err := f.ListResourceGroupLocks(ctx, "rg1", id)
+	require.NoError(t, err)
+	assert.Len(t, locks, 2)
+
+	require.NoError(t, f.DeleteResourceGroupWithOptions(ctx, "rg1", id, &DeleteResourceGroupOptions{RemoveLocks: true}))
+	assert.Empty(t, srv.ResourceGroups(id))
+	lockDeletes := srv.RequestCount("DELETE", "/locks/")
+	assert.Equal(t, 2, lockDeletes)
+	assert.Equal(t, 3, srv.RequestCount("DELETE", "/resourceGroups/rg1")-lockDeletes, "expected the delete to be retried while locked")
+}
+
+// TestDeleteResourceGroupLocked tests that a locked resource group is not deleted, and its locks are kept, by default.
+func TestDeleteResourceGroupLocked(t *testing.T) {
+	f, srv := newFakeClientFactory(t, nil)
+	id := uuid.New()
+	srv.AddResourceGroup(id, "rg1", "westeurope")
+	lockID := "/subscriptions/" + id.String() + "/resourceGroups/rg1/providers/Microsoft.Authorization/locks/rg-lock"
+	srv.PutResource(lockID, nil)
+
+	err := f.DeleteResourceGroup(context.Background(), "rg1", id)
+	assert.ErrorContains(t, err, "ScopeLocked")
+	assert.Equal(t, []string{"rg1"}, srv.ResourceGroups(id))
+	assert.Zero(t, srv.RequestCount(http.MethodDelete, "/locks/"))
+	assert.Equal(t, 1, srv.RequestCount(http.MethodDelete, "/resourceGroups/rg1"), "a locked delete should not be retried")
+}
+
+// TestDeleteResourceGroupKeepsSubscriptionLocks tests that the locks inherited from the subscription,
+// which ARM lists with those of the resource group, are never removed.
+func TestDeleteResourceGroupKeepsSubscriptionLocks(t *testing.T) {
+	f, srv := newFakeClientFactory(t, nil)
+	id := uuid.New()
+	srv.AddResourceGroup(id, "rg1", "westeurope")
+	subLockID := "/subscriptions/" + id.String() + "/providers/Microsoft.Authorization/locks/sub-lock"
+	rgLockID := "/subscriptions/" + id.String() + "/resourceGroups/rg1/providers/Microsoft.Authorization/locks/rg-lock"
+	srv.PutResource(subLockID, nil)
+	srv.PutResource(rgLockID, nil)
+
+	ctx := context.Background()
+	locks, err := f.ListResourceGroupLocks(ctx, "rg1", id)
+	require.NoError(t, err)
+	assert.Equal(t, []string{rgLockID}, locks)
+
+	err = f.DeleteResourceGroupWithOptions(ctx, "rg1", id, &DeleteResourceGroupOptions{RemoveLocks: true})
+	assert.ErrorContains(t, err, "still locked")
+	assert.Equal(t, []string{"rg1"}, srv.ResourceGroups(id))
+	assert.NotEmpty(t, srv.ResourceIDs(subLockID), "the subscription lock should be kept")
+}
+
+// TestDeleteResourceGroupForceDeletion tests that the forced deletion types are sent.
+func TestDeleteResourceGroupForceDeletion(t *testing.T) {
+	f, srv := newFakeClientFactory(t, nil)
+	id := uuid.New()
+	srv.AddResourceGroup(id, "rg1", "westeurope")
+
+	require.NoError(t, f.DeleteResourceGroupWithOptions(context.Background(), "rg1", id, &DeleteResourceGroupOptions{ForceDeletion: true}))
+	var found bool
+	for _, r := range srv.Requests() {
+		if strings.HasPrefix(r, "DELETE") && strings.Contains(r, "forceDeletionTypes=Microsoft.Compute%2FvirtualMachines%2CMicrosoft.Compute%2FvirtualMachineScaleSets") {
+			found = true
+		}
+	}
+	assert.True(t, found, "expected forceDeletionTypes in %v", srv.Requests())
+}
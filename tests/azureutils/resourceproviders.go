@@ -0,0 +1,298 @@
+package azureutils
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// featuresAPIVersion is the Microsoft.Features API version.
+	featuresAPIVersion = "2021-07-01"
+
+	// DefaultRegistrationTimeout is how long WaitForResourceProviders waits by default.
+	DefaultRegistrationTimeout = 30 * time.Minute
+	// DefaultRegistrationPollInterval is how often WaitForResourceProviders polls by default.
+	DefaultRegistrationPollInterval = 30 * time.Second
+
+	registrationStateRegistered = "Registered"
+	registrationStatePending    = "Pending"
+)
+
+// ResourceProviderWaitOptions are the options for WaitForResourceProviders.
+type ResourceProviderWaitOptions struct {
+	// Timeout is how long to wait for the registrations to complete, default DefaultRegistrationTimeout.
+	Timeout time.Duration
+	// PollInterval is the time between polls, default DefaultRegistrationPollInterval.
+	PollInterval time.Duration
+	// Progress, if set, is called with the status after each poll.
+	Progress func(*RegistrationStatus)
+}
+
+// RegistrationStatus is the registration state of a set of resource providers and their features in a subscription.
+type RegistrationStatus struct {
+	SubscriptionID string                         `json:"subscription_id"`
+	Providers      []ResourceProviderRegistration `json:"providers"`
+}
+
+// ResourceProviderRegistration is the registration state of a resource provider and the requested features.
+type ResourceProviderRegistration struct {
+	Namespace string                `json:"namespace"`
+	State     string                `json:"state"`
+	Features  []FeatureRegistration `json:"features,omitempty"`
+}
+
+// FeatureRegistration is the registration state of a resource provider feature.
+// Features that require approval stay Pending until they are approved,
+// and the approval details are taken from the subscription feature registration.
+type FeatureRegistration struct {
+	Name          string     `json:"name"`
+	State         string     `json:"state"`
+	ApprovalType  string     `json:"approval_type,omitempty"`
+	RequestedTime *time.Time `json:"requested_time,omitempty"`
+	Approver      string     `json:"approver,omitempty"`
+}
+
+// Complete reports whether every resource provider is Registered,
+// and every feature is Registered or Pending approval.
+func (s *RegistrationStatus) Complete() bool {
+	return len(s.Incomplete()) == 0
+}
+
+// Incomplete returns a description of each resource provider and feature that has not completed registration,
+// e.g. Microsoft.PowerBI is Registering.
+// Features are named as namespace/feature.
+func (s *RegistrationStatus) Incomplete() []string {
+	var out []string
+	for _, p := range s.Providers {
+		if p.State != registrationStateRegistered {
+			out = append(out, fmt.Sprintf("%s is %s", p.Namespace, stateOrUnknown(p.State)))
+		}
+		for _, f := range p.Features {
+			if f.State != registrationStateRegistered && f.State != registrationStatePending {
+				out = append(out, fmt.Sprintf("%s/%s is %s", p.Namespace, f.Name, stateOrUnknown(f.State)))
+			}
+		}
+	}
+	return out
+}
+
+// PendingApproval returns the features, as namespace/feature, that are registered but waiting for approval.
+func (s *RegistrationStatus) PendingApproval() []string {
+	var out []string
+	for _, p := range s.Providers {
+		for _, f := range p.Features {
+			if f.State == registrationStatePending {
+				out = append(out, p.Namespace+"/"+f.Name)
+			}
+		}
+	}
+	return out
+}
+
+func stateOrUnknown(s string) string {
+	if s == "" {
+		return "unknown"
+	}
+	return s
+}
+
+// WaitForResourceProviders waits for the registration of the resource providers and features,
+// using the DefaultClientFactory.
+func WaitForResourceProviders(ctx context.Context, subID uuid.UUID, providers map[string][]string, opts *ResourceProviderWaitOptions) (*RegistrationStatus, error) {
+	f, err := DefaultClientFactory()
+	if err != nil {
+		return nil, err
+	}
+	return f.WaitForResourceProviders(ctx, subID, providers, opts)
+}
+
+// ResourceProviderRegistrations returns the registration state of the resource providers and features,
+// using the DefaultClientFactory.
+func ResourceProviderRegistrations(ctx context.Context, subID uuid.UUID, providers map[string][]string) (*RegistrationStatus, error) {
+	f, err := DefaultClientFactory()
+	if err != nil {
+		return nil, err
+	}
+	return f.ResourceProviderRegistrations(ctx, subID, providers)
+}
+
+// DiffResourceProviders compares the registered resource providers with the expected set,
+// using the DefaultClientFactory.
+func DiffResourceProviders(ctx context.Context, subID uuid.UUID, providers map[string][]string) (Diff, error) {
+	f, err := DefaultClientFactory()
+	if err != nil {
+		return nil, err
+	}
+	return f.DiffResourceProviders(ctx, subID, providers)
+}
+
+// WaitForResourceProviders waits for the resource providers, the keys of the map, to be Registered,
+// and for their features, the map values, to be Registered or Pending approval.
+// The map is in the form of the subscription_register_resource_providers_and_features input variable.
+// If the registrations are not complete when the timeout expires, the last status is returned with an error
+// listing the stuck registrations.
+func (f *ClientFactory) WaitForResourceProviders(ctx context.Context, subID uuid.UUID, providers map[string][]string, opts *ResourceProviderWaitOptions) (*RegistrationStatus, error) {
+	if opts == nil {
+		opts = &ResourceProviderWaitOptions{}
+	}
+	timeout := opts.Timeout
+	if timeout == 0 {
+		timeout = DefaultRegistrationTimeout
+	}
+	interval := opts.PollInterval
+	if interval == 0 {
+		interval = DefaultRegistrationPollInterval
+	}
+	deadline := time.Now().Add(timeout)
+
+	for {
+		status, err := f.ResourceProviderRegistrations(ctx, subID, providers)
+		if err != nil {
+			return nil, err
+		}
+		if opts.Progress != nil {
+			opts.Progress(status)
+		}
+		if status.Complete() {
+			return status, nil
+		}
+		if time.Now().Add(interval).After(deadline) {
+			return status, fmt.Errorf("resource provider registration in subscription %s did not complete within %s: %s",
+				subID, timeout, strings.Join(status.Incomplete(), ", "))
+		}
+		select {
+		case <-ctx.Done():
+			return status, fmt.Errorf("cannot wait for resource provider registration, %v", ctx.Err())
+		case <-time.After(interval):
+		}
+	}
+}
+
+// ResourceProviderRegistrations returns the current registration state of the resource providers and features.
+// The providers are returned in sorted order.
+func (f *ClientFactory) ResourceProviderRegistrations(ctx context.Context, subID uuid.UUID, providers map[string][]string) (*RegistrationStatus, error) {
+	client, err := f.NewProvidersClient(subID)
+	if err != nil {
+		return nil, err
+	}
+	status := &RegistrationStatus{SubscriptionID: subID.String()}
+	for _, ns := range sortedKeys(providers) {
+		resp, err := client.Get(ctx, ns, nil)
+		if err != nil {
+			return nil, fmt.Errorf("cannot get resource provider %s, %v", ns, err)
+		}
+		p := ResourceProviderRegistration{
+			Namespace: ns,
+			State:     stringValue(resp.RegistrationState),
+		}
+		for _, name := range providers[ns] {
+			fr, err := f.featureRegistration(ctx, subID, ns, name)
+			if err != nil {
+				return nil, err
+			}
+			p.Features = append(p.Features, fr)
+		}
+		status.Providers = append(status.Providers, p)
+	}
+	return status, nil
+}
+
+// featureRegistration returns the state of a feature, with the approval details if it is pending.
+func (f *ClientFactory) featureRegistration(ctx context.Context, subID uuid.UUID, ns, name string) (FeatureRegistration, error) {
+	var feature struct {
+		Properties struct {
+			State string `json:"state"`
+		} `json:"properties"`
+	}
+	path := fmt.Sprintf("/subscriptions/%s/providers/Microsoft.Features/providers/%s/features/%s", subID, ns, name)
+	if err := f.doARM(ctx, http.MethodGet, path, featuresAPIVersion, nil, &feature); err != nil {
+		return FeatureRegistration{}, fmt.Errorf("cannot get feature %s/%s, %v", ns, name, err)
+	}
+	fr := FeatureRegistration{Name: name, State: feature.Properties.State}
+	if fr.State != registrationStatePending {
+		return fr, nil
+	}
+
+	var reg struct {
+		Properties struct {
+			ApprovalType         string `json:"approvalType"`
+			AuthorizationProfile *struct {
+				RequestedTime *time.Time `json:"requestedTime"`
+				Approver      string     `json:"approver"`
+			} `json:"authorizationProfile"`
+		} `json:"properties"`
+	}
+	path = fmt.Sprintf("/subscriptions/%s/providers/Microsoft.Features/featureProviders/%s/subscriptionFeatureRegistrations/%s", subID, ns, name)
+	if err := f.doARM(ctx, http.MethodGet, path, featuresAPIVersion, nil, &reg); err != nil {
+		// The approval details are informational, so a feature without them is still reported.
+		if isNotFound(err) {
+			return fr, nil
+		}
+		return FeatureRegistration{}, fmt.Errorf("cannot get subscription feature registration %s/%s, %v", ns, name, err)
+	}
+	fr.ApprovalType = reg.Properties.ApprovalType
+	if ap := reg.Properties.AuthorizationProfile; ap != nil {
+		fr.RequestedTime = ap.RequestedTime
+		fr.Approver = ap.Approver
+	}
+	return fr, nil
+}
+
+// DiffResourceProviders compares the registered resource providers in the subscription with the expected set,
+// e.g. the default of the subscription_register_resource_providers_and_features input variable.
+// Expected providers that are not Registered are reported with their state,
+// and Registered providers that are not expected are reported with a nil Expected.
+// Namespaces are compared case-insensitively, and features are not compared.
+func (f *ClientFactory) DiffResourceProviders(ctx context.Context, subID uuid.UUID, providers map[string][]string) (Diff, error) {
+	client, err := f.NewProvidersClient(subID)
+	if err != nil {
+		return nil, err
+	}
+	live := make(map[string]string)
+	var namespaces []string
+	pager := client.NewListPager(nil)
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("cannot list resource providers, %v", err)
+		}
+		for _, p := range page.Value {
+			ns := stringValue(p.Namespace)
+			live[strings.ToLower(ns)] = stringValue(p.RegistrationState)
+			namespaces = append(namespaces, ns)
+		}
+	}
+
+	diff := Diff{}
+	expected := make(map[string]bool, len(providers))
+	for _, ns := range sortedKeys(providers) {
+		expected[strings.ToLower(ns)] = true
+		diff.compare(providerID(subID, ns), "registration_state", registrationStateRegistered, stateOrUnknown(live[strings.ToLower(ns)]))
+	}
+	extra := make(map[string]bool)
+	for _, ns := range namespaces {
+		if !expected[strings.ToLower(ns)] && live[strings.ToLower(ns)] == registrationStateRegistered {
+			extra[ns] = true
+		}
+	}
+	for _, ns := range sortedKeys(extra) {
+		diff.compare(providerID(subID, ns), "registration_state", nil, registrationStateRegistered)
+	}
+	return diff, nil
+}
+
+func providerID(subID uuid.UUID, ns string) string {
+	return fmt.Sprintf("/subscriptions/%s/providers/%s", subID, ns)
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
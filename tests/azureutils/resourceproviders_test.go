@@ -0,0 +1,98 @@
+package azureutils
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Azure/terraform-azurerm-lz-vending/tests/fakearm"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestWaitForResourceProviders tests waiting until a provider is registered,
+// and that a feature pending approval completes the wait.
+func TestWaitForResourceProviders(t *testing.T) {
+	f, srv := newFakeClientFactory(t, nil)
+	id := uuid.New()
+	srv.AddSubscription(fakearm.Subscription{ID: id, DisplayName: "sub", State: "Enabled"})
+	srv.SetResourceProvider(id, "Microsoft.Network", "Registered")
+	srv.SetResourceProvider(id, "Microsoft.PowerBI", "Registering")
+	srv.SetFeature(id, "Microsoft.PowerBI", "DailyPrivateLinkServicesForPowerBI", "Pending")
+
+	polls := 0
+	status, err := f.WaitForResourceProviders(context.Background(), id, map[string][]string{
+		"Microsoft.Network": nil,
+		"Microsoft.PowerBI": {"DailyPrivateLinkServicesForPowerBI"},
+	}, &ResourceProviderWaitOptions{
+		PollInterval: time.Millisecond,
+		Timeout:      time.Minute,
+		Progress: func(s *RegistrationStatus) {
+			polls++
+			if polls == 1 {
+				assert.Equal(t, []string{"Microsoft.PowerBI is Registering"}, s.Incomplete())
+				srv.SetResourceProvider(id, "Microsoft.PowerBI", "Registered")
+			}
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, polls)
+	assert.True(t, status.Complete())
+	assert.Equal(t, []string{"Microsoft.PowerBI/DailyPrivateLinkServicesForPowerBI"}, status.PendingApproval())
+	requested := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	assert.Equal(t, ResourceProviderRegistration{
+		Namespace: "Microsoft.PowerBI",
+		State:     "Registered",
+		Features: []FeatureRegistration{{
+			Name:          "DailyPrivateLinkServicesForPowerBI",
+			State:         "Pending",
+			ApprovalType:  "ApprovalRequired",
+			RequestedTime: &requested,
+		}},
+	}, status.Providers[1])
+}
+
+// TestWaitForResourceProvidersStuck tests that registrations that do not complete are reported.
+func TestWaitForResourceProvidersStuck(t *testing.T) {
+	f, srv := newFakeClientFactory(t, nil)
+	id := uuid.New()
+	srv.AddSubscription(fakearm.Subscription{ID: id, DisplayName: "sub", State: "Enabled"})
+	srv.SetResourceProvider(id, "Microsoft.Compute", "Registering")
+	srv.SetResourceProvider(id, "Microsoft.Storage", "Registered")
+	srv.SetFeature(id, "Microsoft.Storage", "AllowNFSV3", "NotRegistered")
+
+	status, err := f.WaitForResourceProviders(context.Background(), id, map[string][]string{
+		"Microsoft.Compute": nil,
+		"Microsoft.Storage": {"AllowNFSV3"},
+	}, &ResourceProviderWaitOptions{PollInterval: time.Millisecond, Timeout: 10 * time.Millisecond})
+	require.ErrorContains(t, err, "did not complete within 10ms: Microsoft.Compute is Registering, Microsoft.Storage/AllowNFSV3 is NotRegistered")
+	assert.False(t, status.Complete())
+}
+
+// TestDiffResourceProviders tests that unregistered expected providers,
+// and registered providers that are not expected, are reported.
+func TestDiffResourceProviders(t *testing.T) {
+	f, srv := newFakeClientFactory(t, nil)
+	id := uuid.New()
+	srv.AddSubscription(fakearm.Subscription{ID: id, DisplayName: "sub", State: "Enabled"})
+	srv.SetResourceProvider(id, "Microsoft.Compute", "Registered")
+	srv.SetResourceProvider(id, "microsoft.insights", "Registered")
+	srv.SetResourceProvider(id, "Microsoft.Storage", "NotRegistered")
+	srv.SetResourceProvider(id, "Microsoft.Billing", "Registered")
+	srv.SetResourceProvider(id, "Microsoft.Batch", "NotRegistered")
+
+	diff, err := f.DiffResourceProviders(context.Background(), id, map[string][]string{
+		"Microsoft.Compute":  nil,
+		"Microsoft.Insights": nil,
+		"Microsoft.Storage":  nil,
+		"Microsoft.Web":      nil,
+	})
+	require.NoError(t, err)
+	sub := "/subscriptions/" + id.String()
+	assert.Equal(t, Diff{
+		{ResourceID: sub + "/providers/Microsoft.Storage", Property: "registration_state", Expected: "Registered", Actual: "NotRegistered"},
+		{ResourceID: sub + "/providers/Microsoft.Web", Property: "registration_state", Expected: "Registered", Actual: "unknown"},
+		{ResourceID: sub + "/providers/Microsoft.Billing", Property: "registration_state", Actual: "Registered"},
+	}, diff)
+}
@@ -0,0 +1,236 @@
+package azureutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+)
+
+// Environment variables that override the retry policy of the DefaultClientFactory, e.g. in CI.
+// Durations use the time.ParseDuration format, e.g. 30s or 2m.
+const (
+	RetryMaxAttemptsEnvVar  = "AZUREUTILS_RETRY_MAX_ATTEMPTS"
+	RetryInitialDelayEnvVar = "AZUREUTILS_RETRY_INITIAL_DELAY"
+	RetryMaxDelayEnvVar     = "AZUREUTILS_RETRY_MAX_DELAY"
+	RetryDeadlineEnvVar     = "AZUREUTILS_RETRY_DEADLINE"
+)
+
+// ErrorClass is the structural classification of an error returned by Azure Resource Manager,
+// made from the status code of an azcore.ResponseError.
+type ErrorClass string
+
+const (
+	// ErrorClassOther is any error that is not an ARM response error of a known class.
+	ErrorClassOther ErrorClass = "other"
+	// ErrorClassThrottled is a 429 Too Many Requests response.
+	ErrorClassThrottled ErrorClass = "throttled"
+	// ErrorClassConflict is a 409 Conflict response, e.g. another operation is in progress or the scope is locked.
+	ErrorClassConflict ErrorClass = "conflict"
+	// ErrorClassNotFound is a 404 Not Found response,
+	// which is transient when reading a resource that an eventually consistent API has just created.
+	ErrorClassNotFound ErrorClass = "not_found"
+)
+
+// ClassifyError returns the class of the error.
+func ClassifyError(err error) ErrorClass {
+	var respErr *azcore.ResponseError
+	if !errors.As(err, &respErr) {
+		return ErrorClassOther
+	}
+	switch respErr.StatusCode {
+	case http.StatusTooManyRequests:
+		return ErrorClassThrottled
+	case http.StatusConflict:
+		return ErrorClassConflict
+	case http.StatusNotFound:
+		return ErrorClassNotFound
+	}
+	return ErrorClassOther
+}
+
+// RetryPolicy controls how operations that fail with transient errors are retried.
+// The delay before each retry grows exponentially from InitialDelay up to MaxDelay,
+// and is randomised by Jitter so that parallel tests do not retry in step.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts, including the first.
+	MaxAttempts int
+	// InitialDelay is the delay before the first retry.
+	InitialDelay time.Duration
+	// MaxDelay caps the delay between attempts.
+	MaxDelay time.Duration
+	// Multiplier is the factor by which the delay grows after each attempt.
+	Multiplier float64
+	// Jitter is the fraction, between 0 and 1, by which each delay is randomly increased or decreased.
+	Jitter float64
+	// Deadline is the total time allowed for all attempts, zero for no limit other than the context.
+	Deadline time.Duration
+	// RetryOn are the classes of error that are retried.
+	RetryOn []ErrorClass
+}
+
+// DefaultRetryPolicy returns the retry policy used by a new ClientFactory.
+// Throttling and conflicts are retried for up to 15 attempts.
+// 429 responses are also retried by the SDK pipeline, see defaultClientOptions,
+// so these are the throttles that outlast the pipeline retries.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:  15,
+		InitialDelay: 5 * time.Second,
+		MaxDelay:     time.Minute,
+		Multiplier:   2,
+		Jitter:       0.2,
+		RetryOn:      []ErrorClass{ErrorClassThrottled, ErrorClassConflict},
+	}
+}
+
+// RetryPolicyFromEnv returns the policy with the values set by the AZUREUTILS_RETRY_* env vars applied.
+func RetryPolicyFromEnv(p RetryPolicy) (RetryPolicy, error) {
+	if v := os.Getenv(RetryMaxAttemptsEnvVar); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return p, fmt.Errorf("invalid %s %q, must be a positive integer", RetryMaxAttemptsEnvVar, v)
+		}
+		p.MaxAttempts = n
+	}
+	for _, d := range []struct {
+		name string
+		dst  *time.Duration
+	}{
+		{RetryInitialDelayEnvVar, &p.InitialDelay},
+		{RetryMaxDelayEnvVar, &p.MaxDelay},
+		{RetryDeadlineEnvVar, &p.Deadline},
+	} {
+		v := os.Getenv(d.name)
+		if v == "" {
+			continue
+		}
+		dur, err := time.ParseDuration(v)
+		if err != nil || dur < 0 {
+			return p, fmt.Errorf("invalid %s %q, must be a duration such as 30s", d.name, v)
+		}
+		*d.dst = dur
+	}
+	return p, nil
+}
+
+// WithRetryOn returns a copy of the policy that also retries the supplied classes of error,
+// e.g. ErrorClassNotFound when reading from an eventually consistent API.
+func (p RetryPolicy) WithRetryOn(classes ...ErrorClass) RetryPolicy {
+	p.RetryOn = append(slices.Clone(p.RetryOn), classes...)
+	return p
+}
+
+// Retryable reports whether the policy retries the error.
+func (p RetryPolicy) Retryable(err error) bool {
+	var perm *permanentError
+	if errors.As(err, &perm) {
+		return false
+	}
+	return slices.Contains(p.RetryOn, ClassifyError(err))
+}
+
+// Delay returns the delay before the retry that follows the supplied attempt, starting at 1, including jitter.
+func (p RetryPolicy) Delay(attempt int) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+	d := float64(p.InitialDelay) * math.Pow(multiplier, float64(attempt-1))
+	if p.MaxDelay > 0 && d > float64(p.MaxDelay) {
+		d = float64(p.MaxDelay)
+	}
+	if p.Jitter > 0 {
+		d += d * p.Jitter * (2*rand.Float64() - 1)
+	}
+	return time.Duration(d)
+}
+
+// Do calls fn until it succeeds, returns an error that the policy does not retry,
+// the attempts are exhausted, or the deadline or context is done.
+// The context passed to fn is bounded by the policy deadline.
+func (p RetryPolicy) Do(ctx context.Context, fn func(context.Context) error) error {
+	if p.Deadline > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.Deadline)
+		defer cancel()
+	}
+	for attempt := 1; ; attempt++ {
+		err := fn(ctx)
+		if err == nil {
+			return nil
+		}
+		if !p.Retryable(err) {
+			return unwrapPermanent(err)
+		}
+		if attempt >= p.MaxAttempts {
+			return fmt.Errorf("giving up after %d attempts, %w", attempt, err)
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("giving up after %d attempts, %w: %w", attempt, ctx.Err(), err)
+		case <-time.After(p.Delay(attempt)):
+		}
+	}
+}
+
+// Permanent wraps an error so that RetryPolicy.Do returns it without retrying,
+// whatever its class. Do returns the underlying error.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+func unwrapPermanent(err error) error {
+	var perm *permanentError
+	if errors.As(err, &perm) {
+		return perm.err
+	}
+	return err
+}
+
+type retryPolicyKey struct{}
+
+// WithRetryPolicy returns a context that makes the azureutils operations called with it use the policy,
+// instead of the policy of the ClientFactory.
+func WithRetryPolicy(ctx context.Context, p RetryPolicy) context.Context {
+	return context.WithValue(ctx, retryPolicyKey{}, p)
+}
+
+// RetryPolicy returns the retry policy of the factory.
+func (f *ClientFactory) RetryPolicy() RetryPolicy {
+	return f.retry
+}
+
+// WithRetryPolicy returns a copy of the factory that uses the policy.
+// The copy shares the credential and client options of the factory.
+func (f *ClientFactory) WithRetryPolicy(p RetryPolicy) *ClientFactory {
+	c := *f
+	c.retry = p
+	return &c
+}
+
+// retryPolicy returns the policy set on the context with WithRetryPolicy, or the policy of the factory.
+func (f *ClientFactory) retryPolicy(ctx context.Context) RetryPolicy {
+	if p, ok := ctx.Value(retryPolicyKey{}).(RetryPolicy); ok {
+		return p
+	}
+	return f.retry
+}
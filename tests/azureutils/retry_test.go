@@ -0,0 +1,141 @@
+package azureutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/terraform-azurerm-lz-vending/tests/fakearm"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestClassifyError tests that errors are classified by the status code of a wrapped response error.
+func TestClassifyError(t *testing.T) {
+	for status, class := range map[int]ErrorClass{
+		http.StatusTooManyRequests:     ErrorClassThrottled,
+		http.StatusConflict:            ErrorClassConflict,
+		http.StatusNotFound:            ErrorClassNotFound,
+		http.StatusInternalServerError: ErrorClassOther,
+	} {
+		err := fmt.Errorf("cannot do thing, %w", &azcore.ResponseError{StatusCode: status})
+		assert.Equal(t, class, ClassifyError(err), "status %d", status)
+	}
+	assert.Equal(t, ErrorClassOther, ClassifyError(errors.New("Subscription is not in active state")))
+}
+
+// TestRetryPolicyDo tests that retryable errors are retried until the attempts are exhausted,
+// and that other errors, and permanent errors, are returned at once.
+func TestRetryPolicyDo(t *testing.T) {
+	p := RetryPolicy{MaxAttempts: 3, InitialDelay: time.Millisecond, RetryOn: []ErrorClass{ErrorClassConflict}}
+	conflict := &azcore.ResponseError{StatusCode: http.StatusConflict}
+
+	cases := []struct {
+		name     string
+		err      error
+		attempts int
+	}{
+		{"retryable", conflict, 3},
+		{"not retryable", &azcore.ResponseError{StatusCode: http.StatusNotFound}, 1},
+		{"permanent", Permanent(conflict), 1},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			attempts := 0
+			err := p.Do(context.Background(), func(context.Context) error {
+				attempts++
+				return tc.err
+			})
+			assert.Equal(t, tc.attempts, attempts)
+			var respErr *azcore.ResponseError
+			assert.ErrorAs(t, err, &respErr, "the error should be the last response error")
+			var perm *permanentError
+			assert.False(t, errors.As(err, &perm), "a permanent error should be unwrapped")
+		})
+	}
+
+	t.Run("succeeds", func(t *testing.T) {
+		attempts := 0
+		err := p.Do(context.Background(), func(context.Context) error {
+			attempts++
+			if attempts < 2 {
+				return conflict
+			}
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 2, attempts)
+	})
+}
+
+// TestRetryPolicyDeadline tests that the deadline bounds the attempts.
+func TestRetryPolicyDeadline(t *testing.T) {
+	p := RetryPolicy{
+		MaxAttempts:  1000,
+		InitialDelay: 5 * time.Millisecond,
+		Deadline:     30 * time.Millisecond,
+		RetryOn:      []ErrorClass{ErrorClassThrottled},
+	}
+	err := p.Do(context.Background(), func(context.Context) error {
+		return &azcore.ResponseError{StatusCode: http.StatusTooManyRequests}
+	})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+// TestRetryPolicyDelay tests that the delay grows exponentially, is capped, and that jitter stays in bounds.
+func TestRetryPolicyDelay(t *testing.T) {
+	p := RetryPolicy{InitialDelay: time.Second, MaxDelay: 5 * time.Second, Multiplier: 2}
+	assert.Equal(t, time.Second, p.Delay(1))
+	assert.Equal(t, 4*time.Second, p.Delay(3))
+	assert.Equal(t, 5*time.Second, p.Delay(10))
+
+	p.Jitter = 0.5
+	for range 100 {
+		d := p.Delay(2)
+		assert.GreaterOrEqual(t, d, time.Second)
+		assert.LessOrEqual(t, d, 3*time.Second)
+	}
+}
+
+// TestRetryPolicyFromEnv tests that the environment variables override the policy, and that invalid values are errors.
+func TestRetryPolicyFromEnv(t *testing.T) {
+	t.Setenv(RetryMaxAttemptsEnvVar, "7")
+	t.Setenv(RetryInitialDelayEnvVar, "2s")
+	t.Setenv(RetryMaxDelayEnvVar, "")
+	t.Setenv(RetryDeadlineEnvVar, "10m")
+	p, err := RetryPolicyFromEnv(DefaultRetryPolicy())
+	require.NoError(t, err)
+	assert.Equal(t, 7, p.MaxAttempts)
+	assert.Equal(t, 2*time.Second, p.InitialDelay)
+	assert.Equal(t, DefaultRetryPolicy().MaxDelay, p.MaxDelay)
+	assert.Equal(t, 10*time.Minute, p.Deadline)
+
+	t.Setenv(RetryMaxAttemptsEnvVar, "0")
+	_, err = RetryPolicyFromEnv(DefaultRetryPolicy())
+	assert.ErrorContains(t, err, RetryMaxAttemptsEnvVar)
+
+	t.Setenv(RetryMaxAttemptsEnvVar, "")
+	t.Setenv(RetryDeadlineEnvVar, "ten minutes")
+	_, err = RetryPolicyFromEnv(DefaultRetryPolicy())
+	assert.ErrorContains(t, err, RetryDeadlineEnvVar)
+}
+
+// TestWithRetryPolicy tests that a policy on the context overrides the policy of the factory for a single call.
+func TestWithRetryPolicy(t *testing.T) {
+	f, srv := newFakeClientFactory(t, nil)
+	id := uuid.New()
+	srv.AddSubscription(fakearm.Subscription{ID: id, ManagementGroup: "mg1"})
+
+	ctx := WithRetryPolicy(context.Background(), RetryPolicy{MaxAttempts: 1})
+	assert.Error(t, f.IsSubscriptionInManagementGroup(ctx, t, id, "mg2"))
+	assert.Equal(t, 1, srv.RequestCount(http.MethodGet, "/managementGroups/mg2/subscriptions/"))
+
+	slow := f.WithRetryPolicy(RetryPolicy{MaxAttempts: 2})
+	assert.Equal(t, 2, slow.RetryPolicy().MaxAttempts)
+	assert.Equal(t, 4, f.RetryPolicy().MaxAttempts, "the original factory should be unchanged")
+}
@@ -0,0 +1,272 @@
+package azureutils
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/authorization/armauthorization/v2"
+	"github.com/google/uuid"
+)
+
+// roleAssignmentsPath is the path of the role assignments below a scope.
+const roleAssignmentsPath = "/providers/Microsoft.Authorization/roleAssignments"
+
+// RoleAssignment is a role assignment that a verifier expects to find in Azure.
+type RoleAssignment struct {
+	// Key identifies the entry of the input variable, e.g. the role_assignments map key.
+	Key   string
+	Scope string
+	// PrincipalID is the object ID of the principal.
+	PrincipalID string
+	// Definition is either the role definition name, e.g. Contributor, or its resource ID.
+	// A resource ID matches the role definition with the same GUID at any scope.
+	Definition       string
+	Condition        string
+	ConditionVersion string
+	// PrincipalType is only checked if it is set, as Azure sets it when it is omitted.
+	PrincipalType string
+}
+
+// String returns a description of the role assignment, used in a Difference.
+func (ra RoleAssignment) String() string {
+	return fmt.Sprintf("%s: %s for principal %s", ra.Key, ra.Definition, ra.PrincipalID)
+}
+
+// ExpectedRoleAssignments returns the role assignments configured by the root module's role_assignments input variable.
+// The resourceGroups are the root module's resource_groups input variable,
+// and are used to find the scope of entries with a resource_group_scope_key.
+func ExpectedRoleAssignments(subID uuid.UUID, roleAssignments, resourceGroups map[string]map[string]any) ([]RoleAssignment, error) {
+	keys := sortedKeys(roleAssignments)
+	out := make([]RoleAssignment, 0, len(keys))
+	for _, k := range keys {
+		principalID, _ := roleAssignments[k]["principal_id"].(string)
+		ra, err := expectedRoleAssignment(subID, k, principalID, roleAssignments[k], resourceGroups)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, ra)
+	}
+	return out, nil
+}
+
+// ExpectedUserManagedIdentityRoleAssignments returns the role assignments configured by the role_assignments of
+// each entry of the root module's user_managed_identities input variable.
+// The principalIDs are the umi_principal_ids output, as the identities do not exist until the module is applied.
+// The resourceGroups are the root module's resource_groups input variable.
+func ExpectedUserManagedIdentityRoleAssignments(subID uuid.UUID, umis map[string]map[string]any, principalIDs map[string]string, resourceGroups map[string]map[string]any) ([]RoleAssignment, error) {
+	var out []RoleAssignment
+	for _, umiKey := range sortedKeys(umis) {
+		roleAssignments := mapOfMaps(umis[umiKey]["role_assignments"])
+		if len(roleAssignments) == 0 {
+			continue
+		}
+		principalID, ok := principalIDs[umiKey]
+		if !ok {
+			return nil, fmt.Errorf("cannot find the principal id of user managed identity %s", umiKey)
+		}
+		for _, k := range sortedKeys(roleAssignments) {
+			ra, err := expectedRoleAssignment(subID, umiKey+"/"+k, principalID, roleAssignments[k], resourceGroups)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, ra)
+		}
+	}
+	return out, nil
+}
+
+// expectedRoleAssignment returns the role assignment for an entry of a role_assignments input variable.
+func expectedRoleAssignment(subID uuid.UUID, key, principalID string, in map[string]any, resourceGroups map[string]map[string]any) (RoleAssignment, error) {
+	scope := "/subscriptions/" + subID.String()
+	if rgKey, _ := in["resource_group_scope_key"].(string); rgKey != "" {
+		rg, ok := resourceGroups[rgKey]
+		if !ok {
+			return RoleAssignment{}, fmt.Errorf("role assignment %s has resource_group_scope_key %s, which is not in resource_groups", key, rgKey)
+		}
+		scope += fmt.Sprintf("/resourceGroups/%s", rg["name"])
+	} else if rel, _ := in["relative_scope"].(string); rel != "" {
+		scope += rel
+	}
+	ra := RoleAssignment{
+		Key:         key,
+		Scope:       scope,
+		PrincipalID: principalID,
+	}
+	ra.Definition, _ = in["definition"].(string)
+	ra.Condition, _ = in["condition"].(string)
+	ra.ConditionVersion, _ = in["condition_version"].(string)
+	ra.PrincipalType, _ = in["principal_type"].(string)
+	return ra, nil
+}
+
+// VerifyRoleAssignments compares the role assignments in Azure with the expected role assignments,
+// using the DefaultClientFactory.
+func VerifyRoleAssignments(ctx context.Context, expected []RoleAssignment) (Diff, error) {
+	f, err := DefaultClientFactory()
+	if err != nil {
+		return nil, err
+	}
+	return f.VerifyRoleAssignments(ctx, expected)
+}
+
+// VerifyRoleAssignments compares the role assignments in Azure with the expected role assignments.
+// An expected role assignment matches an assignment made directly at its scope, to the same principal,
+// with the same role definition, and its condition, condition version and principal type are then compared.
+// Expected role assignments that are not found are reported with a nil Actual.
+// Assignments at the expected scopes, to the expected principals, that were not expected are reported with a nil Expected.
+// Assignments to other principals, and assignments inherited from a parent scope, are ignored.
+func (f *ClientFactory) VerifyRoleAssignments(ctx context.Context, expected []RoleAssignment) (Diff, error) {
+	byScope := make(map[string][]RoleAssignment)
+	var scopes []string
+	for _, ra := range expected {
+		s := strings.ToLower(ra.Scope)
+		if _, ok := byScope[s]; !ok {
+			scopes = append(scopes, ra.Scope)
+		}
+		byScope[s] = append(byScope[s], ra)
+	}
+
+	diff := Diff{}
+	definitions := make(map[string]string)
+	for _, scope := range scopes {
+		actual, err := f.listRoleAssignmentsAtScope(ctx, scope)
+		if err != nil {
+			return nil, err
+		}
+		matched := make(map[string]bool)
+		principals := make(map[string]bool)
+		for _, want := range byScope[strings.ToLower(scope)] {
+			principals[strings.ToLower(want.PrincipalID)] = true
+			got, err := f.findRoleAssignment(ctx, want, actual, matched, definitions)
+			if err != nil {
+				return nil, err
+			}
+			if got == nil {
+				diff.compare(scope+roleAssignmentsPath, "role_assignment", want.String(), nil)
+				continue
+			}
+			diff = append(diff, compareRoleAssignment(want, got)...)
+		}
+		for _, ra := range actual {
+			props := roleAssignmentProperties(ra)
+			if matched[stringValue(ra.ID)] || !principals[strings.ToLower(stringValue(props.PrincipalID))] {
+				continue
+			}
+			diff.compare(stringValue(ra.ID), "role_assignment", nil,
+				fmt.Sprintf("%s for principal %s", stringValue(props.RoleDefinitionID), stringValue(props.PrincipalID)))
+		}
+	}
+	return diff, nil
+}
+
+// listRoleAssignments returns the role assignments that apply at the scope, i.e. those at, above and below it.
+func (f *ClientFactory) listRoleAssignments(ctx context.Context, scope string) ([]*armauthorization.RoleAssignment, error) {
+	client, err := f.NewRoleAssignmentsClient(uuid.Nil)
+	if err != nil {
+		return nil, err
+	}
+	var ras []*armauthorization.RoleAssignment
+	pager := client.NewListForScopePager(scope, nil)
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		ras = append(ras, page.Value...)
+	}
+	return ras, nil
+}
+
+// listRoleAssignmentsAtScope returns the role assignments made directly at the scope.
+func (f *ClientFactory) listRoleAssignmentsAtScope(ctx context.Context, scope string) ([]*armauthorization.RoleAssignment, error) {
+	ras, err := f.listRoleAssignments(ctx, scope)
+	if err != nil {
+		return nil, fmt.Errorf("cannot list role assignments at scope %s, %v", scope, err)
+	}
+	prefix := strings.ToLower(scope + roleAssignmentsPath + "/")
+	return slices.DeleteFunc(ras, func(ra *armauthorization.RoleAssignment) bool {
+		id := strings.ToLower(stringValue(ra.ID))
+		return !strings.HasPrefix(id, prefix) || strings.Contains(strings.TrimPrefix(id, prefix), "/")
+	}), nil
+}
+
+// roleAssignmentProperties returns the properties of the role assignment, which may be empty.
+func roleAssignmentProperties(ra *armauthorization.RoleAssignment) armauthorization.RoleAssignmentProperties {
+	if ra.Properties == nil {
+		return armauthorization.RoleAssignmentProperties{}
+	}
+	return *ra.Properties
+}
+
+// findRoleAssignment returns the first unmatched assignment to the principal with the expected role definition,
+// or nil if there is none.
+// The names of role definitions are cached in definitions, keyed by the role definition ID.
+func (f *ClientFactory) findRoleAssignment(ctx context.Context, want RoleAssignment, actual []*armauthorization.RoleAssignment, matched map[string]bool, definitions map[string]string) (*armauthorization.RoleAssignment, error) {
+	isID := strings.Contains(strings.ToLower(want.Definition), "/providers/microsoft.authorization/roledefinitions/")
+	for _, ra := range actual {
+		props := roleAssignmentProperties(ra)
+		definitionID := stringValue(props.RoleDefinitionID)
+		if matched[stringValue(ra.ID)] || !strings.EqualFold(stringValue(props.PrincipalID), want.PrincipalID) {
+			continue
+		}
+		if isID {
+			if !strings.EqualFold(lastSegment(want.Definition), lastSegment(definitionID)) {
+				continue
+			}
+		} else {
+			name, ok := definitions[definitionID]
+			if !ok {
+				client, err := f.NewRoleDefinitionsClient()
+				if err != nil {
+					return nil, err
+				}
+				rd, err := client.GetByID(ctx, definitionID, nil)
+				if err != nil {
+					return nil, fmt.Errorf("cannot get role definition %s, %v", definitionID, err)
+				}
+				if rd.Properties != nil {
+					name = stringValue(rd.Properties.RoleName)
+				}
+				definitions[definitionID] = name
+			}
+			if !strings.EqualFold(name, want.Definition) {
+				continue
+			}
+		}
+		matched[stringValue(ra.ID)] = true
+		return ra, nil
+	}
+	return nil, nil
+}
+
+// compareRoleAssignment compares the condition and principal type of a matched role assignment.
+func compareRoleAssignment(want RoleAssignment, got *armauthorization.RoleAssignment) Diff {
+	diff := Diff{}
+	props := roleAssignmentProperties(got)
+	id := stringValue(got.ID)
+	diff.compare(id, "condition", want.Condition, stringValue(props.Condition))
+	diff.compare(id, "condition_version", want.ConditionVersion, stringValue(props.ConditionVersion))
+	if want.PrincipalType != "" {
+		var principalType string
+		if props.PrincipalType != nil {
+			principalType = string(*props.PrincipalType)
+		}
+		diff.compare(id, "principal_type", want.PrincipalType, principalType)
+	}
+	return diff
+}
+
+func lastSegment(id string) string {
+	return id[strings.LastIndex(id, "/")+1:]
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
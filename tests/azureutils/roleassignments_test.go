@@ -0,0 +1,128 @@
+package azureutils
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Azure/terraform-azurerm-lz-vending/tests/fakearm"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	readerDefinitionGUID      = "acdd72a7-3385-48ef-bd42-f606fba81ae7"
+	blobContributorDefinition = "ba92f5b4-2d11-453d-a403-e96b0029c9fe"
+)
+
+// putRoleAssignment adds a role assignment at the scope to the fake server, and returns its ID.
+func putRoleAssignment(srv *fakearm.Server, scope, name string, props map[string]any) string {
+	id := scope + roleAssignmentsPath + "/" + name
+	srv.PutResource(id, map[string]any{"properties": props})
+	return id
+}
+
+// TestExpectedRoleAssignments tests the scopes and principals of the role assignment inputs.
+func TestExpectedRoleAssignments(t *testing.T) {
+	id := uuid.New()
+	sub := "/subscriptions/" + id.String()
+	rgs := map[string]map[string]any{"rg1": {"name": "rg-one"}}
+
+	got, err := ExpectedRoleAssignments(id, map[string]map[string]any{
+		"sub":      {"principal_id": "p1", "definition": "Reader"},
+		"relative": {"principal_id": "p1", "definition": "Reader", "relative_scope": "/resourceGroups/other"},
+		"rg":       {"principal_id": "p2", "definition": "Reader", "relative_scope": "/ignored", "resource_group_scope_key": "rg1", "principal_type": "User"},
+	}, rgs)
+	require.NoError(t, err)
+	assert.Equal(t, []RoleAssignment{
+		{Key: "relative", Scope: sub + "/resourceGroups/other", PrincipalID: "p1", Definition: "Reader"},
+		{Key: "rg", Scope: sub + "/resourceGroups/rg-one", PrincipalID: "p2", Definition: "Reader", PrincipalType: "User"},
+		{Key: "sub", Scope: sub, PrincipalID: "p1", Definition: "Reader"},
+	}, got)
+
+	got, err = ExpectedUserManagedIdentityRoleAssignments(id, map[string]map[string]any{
+		"umi1": {"name": "umi1", "role_assignments": map[string]any{
+			"reader": map[string]any{"definition": "Reader", "resource_group_scope_key": "rg1"},
+		}},
+		"umi2": {"name": "umi2"},
+	}, map[string]string{"umi1": "umi1-principal"}, rgs)
+	require.NoError(t, err)
+	assert.Equal(t, []RoleAssignment{
+		{Key: "umi1/reader", Scope: sub + "/resourceGroups/rg-one", PrincipalID: "umi1-principal", Definition: "Reader"},
+	}, got)
+
+	_, err = ExpectedRoleAssignments(id, map[string]map[string]any{
+		"rg": {"principal_id": "p2", "definition": "Reader", "resource_group_scope_key": "missing"},
+	}, rgs)
+	assert.ErrorContains(t, err, "resource_group_scope_key missing, which is not in resource_groups")
+}
+
+// TestVerifyRoleAssignments tests matching role assignments by definition name and ID,
+// and that mismatched, missing and extra assignments are reported.
+func TestVerifyRoleAssignments(t *testing.T) {
+	f, srv := newFakeClientFactory(t, nil)
+	id := uuid.New()
+	ctx := context.Background()
+	srv.AddSubscription(fakearm.Subscription{ID: id, DisplayName: "sub", State: "Enabled"})
+	srv.AddResourceGroup(id, "rg1", "westeurope")
+	sub := "/subscriptions/" + id.String()
+	rg := sub + "/resourceGroups/rg1"
+	srv.PutResource(sub+"/providers/Microsoft.Authorization/roleDefinitions/"+readerDefinitionGUID,
+		map[string]any{"properties": map[string]any{"roleName": "Reader"}})
+	srv.PutResource(sub+"/providers/Microsoft.Authorization/roleDefinitions/"+blobContributorDefinition,
+		map[string]any{"properties": map[string]any{"roleName": "Storage Blob Data Contributor"}})
+
+	condition := "@Resource[Microsoft.Storage/storageAccounts/blobServices/containers:name] StringEquals 'logs'"
+	readerID := putRoleAssignment(srv, sub, "ra1", map[string]any{
+		"principalId":      "p1",
+		"principalType":    "ServicePrincipal",
+		"roleDefinitionId": sub + "/providers/Microsoft.Authorization/roleDefinitions/" + readerDefinitionGUID,
+	})
+	blobID := putRoleAssignment(srv, rg, "ra2", map[string]any{
+		"principalId":      "p1",
+		"principalType":    "ServicePrincipal",
+		"roleDefinitionId": sub + "/providers/Microsoft.Authorization/roleDefinitions/" + blobContributorDefinition,
+		"condition":        condition,
+		"conditionVersion": "2.0",
+	})
+	// Assignments to principals that are not expected are ignored.
+	putRoleAssignment(srv, sub, "other", map[string]any{
+		"principalId":      "p9",
+		"roleDefinitionId": sub + "/providers/Microsoft.Authorization/roleDefinitions/" + readerDefinitionGUID,
+	})
+
+	expected := []RoleAssignment{
+		{Key: "reader", Scope: sub, PrincipalID: "p1", Definition: "reader", PrincipalType: "ServicePrincipal"},
+		{
+			Key:              "blob",
+			Scope:            rg,
+			PrincipalID:      "p1",
+			Definition:       "/providers/Microsoft.Authorization/roleDefinitions/" + blobContributorDefinition,
+			Condition:        condition,
+			ConditionVersion: "2.0",
+		},
+	}
+	diff, err := f.VerifyRoleAssignments(ctx, expected)
+	require.NoError(t, err)
+	assert.Empty(t, diff, diff.String())
+
+	expected[0].PrincipalType = "User"
+	expected[1].ConditionVersion = "1.0"
+	expected = append(expected, RoleAssignment{Key: "missing", Scope: rg, PrincipalID: "p1", Definition: "Reader"})
+	extraID := putRoleAssignment(srv, rg, "ra3", map[string]any{
+		"principalId":      "p1",
+		"roleDefinitionId": sub + "/providers/Microsoft.Authorization/roleDefinitions/" + blobContributorDefinition,
+	})
+	diff, err = f.VerifyRoleAssignments(ctx, expected)
+	require.NoError(t, err)
+	assert.Equal(t, Diff{
+		{ResourceID: readerID, Property: "principal_type", Expected: "User", Actual: "ServicePrincipal"},
+		{ResourceID: blobID, Property: "condition_version", Expected: "1.0", Actual: "2.0"},
+		{ResourceID: rg + roleAssignmentsPath, Property: "role_assignment", Expected: "missing: Reader for principal p1"},
+		{
+			ResourceID: extraID,
+			Property:   "role_assignment",
+			Actual:     sub + "/providers/Microsoft.Authorization/roleDefinitions/" + blobContributorDefinition + " for principal p1",
+		},
+	}, diff)
+}
@@ -0,0 +1,260 @@
+package azureutils
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// DefaultLeakSettleTime is how long DetectLeaks waits by default for deleted resources
+	// to disappear from the ARM listings, which are eventually consistent.
+	DefaultLeakSettleTime = 3 * time.Minute
+	// DefaultLeakPollInterval is how often DetectLeaks takes a new snapshot while waiting.
+	DefaultLeakPollInterval = 30 * time.Second
+)
+
+// childResourceTypes are the child resources included in a snapshot, keyed by the lower case parent type.
+// Child resources are not returned by the resource listings, but are what accumulates on a shared hub.
+var childResourceTypes = map[string]string{
+	"microsoft.network/virtualnetworks": "virtualNetworkPeerings",
+	"microsoft.network/virtualhubs":     "hubVirtualNetworkConnections",
+}
+
+// SnapshotResource is a resource in a ResourceSnapshot.
+type SnapshotResource struct {
+	ID   string
+	Type string
+}
+
+// ResourceSnapshot is the set of resources in a subscription at a point in time.
+// It includes resource groups, the resources in them, role assignments made at or below the subscription,
+// and the peerings and hub connections of virtual networks and virtual hubs.
+type ResourceSnapshot struct {
+	SubscriptionID uuid.UUID
+	Time           time.Time
+	// resources is keyed by the lower case resource ID.
+	resources map[string]SnapshotResource
+}
+
+// Resources returns the resources in the snapshot, sorted by ID.
+func (s *ResourceSnapshot) Resources() []SnapshotResource {
+	out := make([]SnapshotResource, 0, len(s.resources))
+	for _, k := range sortedKeys(s.resources) {
+		out = append(out, s.resources[k])
+	}
+	return out
+}
+
+// Added returns the resources in the later snapshot that are not in this snapshot, sorted by ID.
+func (s *ResourceSnapshot) Added(later *ResourceSnapshot) ResourceLeaks {
+	var out ResourceLeaks
+	for _, k := range sortedKeys(later.resources) {
+		if _, ok := s.resources[k]; !ok {
+			out = append(out, later.resources[k])
+		}
+	}
+	return out
+}
+
+// ResourceLeaks are the resources left behind by a test.
+type ResourceLeaks []SnapshotResource
+
+// String returns the leaked resources, one per line with the resource type first.
+func (l ResourceLeaks) String() string {
+	var sb strings.Builder
+	for _, r := range l {
+		fmt.Fprintf(&sb, "  + %s %s\n", r.Type, r.ID)
+	}
+	return sb.String()
+}
+
+// LeakDetectorOptions are the options for DetectLeaks.
+type LeakDetectorOptions struct {
+	// Ignore, if set, excludes resources from the comparison,
+	// e.g. those created by tests that run in parallel in the same subscription.
+	Ignore func(SnapshotResource) bool
+	// Settle is how long to wait for deleted resources to disappear, default DefaultLeakSettleTime.
+	Settle time.Duration
+	// PollInterval is the time between snapshots while waiting, default DefaultLeakPollInterval.
+	PollInterval time.Duration
+}
+
+// IgnoreOtherTests returns a LeakDetectorOptions.Ignore func that ignores the resources of other tests,
+// which are those whose ID contains the prefix, e.g. testdeploy-, but not the name of this test's resources.
+func IgnoreOtherTests(prefix, name string) func(SnapshotResource) bool {
+	prefix, name = strings.ToLower(prefix), strings.ToLower(name)
+	return func(r SnapshotResource) bool {
+		id := strings.ToLower(r.ID)
+		return strings.Contains(id, prefix) && !strings.Contains(id, name)
+	}
+}
+
+// IgnoreAllExcept returns a LeakDetectorOptions.Ignore func that ignores every resource whose ID does not contain the name,
+// e.g. the random name of this test's resources.
+// Use it when other test packages run in parallel in the same subscription: their role assignments, whose IDs are GUIDs,
+// and resources created by Azure, such as NetworkWatcherRG, cannot be told apart from this test's by ID.
+// Resources that the test creates outside its own resource groups, such as the peerings on a shared hub,
+// must then be given names that contain the name.
+func IgnoreAllExcept(name string) func(SnapshotResource) bool {
+	name = strings.ToLower(name)
+	return func(r SnapshotResource) bool {
+		return !strings.Contains(strings.ToLower(r.ID), name)
+	}
+}
+
+// SnapshotResources takes a snapshot of the resources in the subscription,
+// using the DefaultClientFactory.
+func SnapshotResources(ctx context.Context, subID uuid.UUID) (*ResourceSnapshot, error) {
+	f, err := DefaultClientFactory()
+	if err != nil {
+		return nil, err
+	}
+	return f.SnapshotResources(ctx, subID)
+}
+
+// DetectLeaks snapshots the resources in the subscriptions and fails the test if resources are left behind,
+// using the DefaultClientFactory.
+func DetectLeaks(t *testing.T, opts *LeakDetectorOptions, subIDs ...uuid.UUID) {
+	f, err := DefaultClientFactory()
+	if err != nil {
+		t.Fatalf("cannot create client factory, %v", err)
+	}
+	f.DetectLeaks(t, opts, subIDs...)
+}
+
+// SnapshotResources takes a snapshot of the resources in the subscription.
+func (f *ClientFactory) SnapshotResources(ctx context.Context, subID uuid.UUID) (*ResourceSnapshot, error) {
+	s := &ResourceSnapshot{
+		SubscriptionID: subID,
+		Time:           time.Now(),
+		resources:      make(map[string]SnapshotResource),
+	}
+	add := func(id, typ string) {
+		s.resources[strings.ToLower(id)] = SnapshotResource{ID: id, Type: typ}
+	}
+
+	rgs, err := f.ListResourceGroups(ctx, subID)
+	if err != nil {
+		return nil, fmt.Errorf("cannot list resource groups, %v", err)
+	}
+	client, err := f.NewResourcesClient(subID)
+	if err != nil {
+		return nil, err
+	}
+	for _, rg := range rgs {
+		add(stringValue(rg.ID), stringValue(rg.Type))
+		pager := client.NewListByResourceGroupPager(stringValue(rg.Name), nil)
+		for pager.More() {
+			page, err := pager.NextPage(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("cannot list resources in resource group %s, %v", stringValue(rg.Name), err)
+			}
+			for _, r := range page.Value {
+				id, typ := stringValue(r.ID), stringValue(r.Type)
+				add(id, typ)
+				child, ok := childResourceTypes[strings.ToLower(typ)]
+				if !ok {
+					continue
+				}
+				children, err := listARM[armResource](ctx, f, id+"/"+child, networkAPIVersion)
+				if err != nil {
+					return nil, fmt.Errorf("cannot list %s of %s, %v", child, id, err)
+				}
+				for _, c := range children {
+					add(c.ID, typ+"/"+child)
+				}
+			}
+		}
+	}
+
+	// The subscription listing includes the role assignments inherited from management groups.
+	sub := "/subscriptions/" + subID.String()
+	ras, err := f.listRoleAssignments(ctx, sub)
+	if err != nil {
+		return nil, fmt.Errorf("cannot list role assignments, %v", err)
+	}
+	for _, ra := range ras {
+		if id := stringValue(ra.ID); strings.HasPrefix(strings.ToLower(id), strings.ToLower(sub+"/")) {
+			add(id, stringValue(ra.Type))
+		}
+	}
+	return s, nil
+}
+
+// DetectLeaks snapshots the resources in the subscriptions now, and registers a cleanup function that
+// snapshots them again and fails the test with the resources that were added.
+// Call it before deferring the Terraform destroy: cleanup functions run after deferred calls,
+// so the second snapshot is taken after DestroyRetry.
+// As the ARM listings are eventually consistent, the cleanup takes new snapshots until the added resources
+// are gone or the settle time has passed.
+// The subscriptions must not be cancelled by the test, and resources created by other tests in the same
+// subscriptions should be excluded using LeakDetectorOptions.Ignore.
+func (f *ClientFactory) DetectLeaks(t *testing.T, opts *LeakDetectorOptions, subIDs ...uuid.UUID) {
+	if opts == nil {
+		opts = &LeakDetectorOptions{}
+	}
+	settle := opts.Settle
+	if settle == 0 {
+		settle = DefaultLeakSettleTime
+	}
+	interval := opts.PollInterval
+	if interval == 0 {
+		interval = DefaultLeakPollInterval
+	}
+
+	ctx, cancel := NewTestContext(t)
+	defer cancel()
+	before := make([]*ResourceSnapshot, 0, len(subIDs))
+	for _, subID := range subIDs {
+		s, err := f.SnapshotResources(ctx, subID)
+		if err != nil {
+			t.Fatalf("cannot snapshot resources in subscription %s, %v", subID, err)
+		}
+		before = append(before, s)
+	}
+
+	t.Cleanup(func() {
+		ctx, cancel := NewTestContext(t)
+		defer cancel()
+		for _, b := range before {
+			leaks, err := f.waitForLeaks(ctx, b, opts.Ignore, settle, interval)
+			if err != nil {
+				t.Errorf("cannot check for leaked resources in subscription %s, %v", b.SubscriptionID, err)
+				continue
+			}
+			if len(leaks) > 0 {
+				t.Errorf("%d resources were left behind in subscription %s:\n%s", len(leaks), b.SubscriptionID, leaks)
+			}
+		}
+	})
+}
+
+// waitForLeaks takes snapshots until no resources have been added since the before snapshot,
+// or the settle time has passed, and returns the added resources of the last snapshot.
+func (f *ClientFactory) waitForLeaks(ctx context.Context, before *ResourceSnapshot, ignore func(SnapshotResource) bool, settle, interval time.Duration) (ResourceLeaks, error) {
+	deadline := time.Now().Add(settle)
+	for {
+		after, err := f.SnapshotResources(ctx, before.SubscriptionID)
+		if err != nil {
+			return nil, err
+		}
+		leaks := before.Added(after)
+		if ignore != nil {
+			leaks = slices.DeleteFunc(leaks, ignore)
+		}
+		if len(leaks) == 0 || time.Now().Add(interval).After(deadline) {
+			return leaks, nil
+		}
+		select {
+		case <-ctx.Done():
+			return leaks, nil
+		case <-time.After(interval):
+		}
+	}
+}
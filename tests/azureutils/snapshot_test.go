@@ -0,0 +1,86 @@
+package azureutils
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Azure/terraform-azurerm-lz-vending/tests/fakearm"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSnapshotResources tests that resource groups, resources, peerings and role assignments are snapshotted,
+// and that the resources added since a snapshot are reported.
+func TestSnapshotResources(t *testing.T) {
+	f, srv := newFakeClientFactory(t, nil)
+	id := uuid.New()
+	ctx := context.Background()
+	srv.AddSubscription(fakearm.Subscription{ID: id, DisplayName: "sub", State: "Enabled", ManagementGroup: "mg1"})
+	srv.AddResourceGroup(id, "rg-hub", "westeurope")
+	sub := "/subscriptions/" + id.String()
+	hub := sub + "/resourceGroups/rg-hub/providers/Microsoft.Network/virtualNetworks/hub"
+	srv.PutResource(hub, nil)
+	srv.PutResource("/providers/Microsoft.Management/managementGroups/mg1"+roleAssignmentsPath+"/inherited", nil)
+
+	before, err := f.SnapshotResources(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, []SnapshotResource{
+		{ID: sub + "/resourceGroups/rg-hub", Type: "Microsoft.Resources/resourceGroups"},
+		{ID: hub, Type: "Microsoft.Network/virtualNetworks"},
+	}, before.Resources())
+
+	srv.AddResourceGroup(id, "NetworkWatcherRG", "westeurope")
+	srv.PutResource(hub+"/virtualNetworkPeerings/peer-spoke", nil)
+	srv.PutResource(sub+"/resourceGroups/rg-hub"+roleAssignmentsPath+"/ra1", nil)
+
+	after, err := f.SnapshotResources(ctx, id)
+	require.NoError(t, err)
+	leaks := before.Added(after)
+	assert.Equal(t, ResourceLeaks{
+		{ID: sub + "/resourceGroups/NetworkWatcherRG", Type: "Microsoft.Resources/resourceGroups"},
+		{ID: sub + "/resourceGroups/rg-hub" + roleAssignmentsPath + "/ra1", Type: "Microsoft.Authorization/roleAssignments"},
+		{ID: hub + "/virtualNetworkPeerings/peer-spoke", Type: "Microsoft.Network/virtualNetworks/virtualNetworkPeerings"},
+	}, leaks)
+	assert.Contains(t, leaks.String(), "  + Microsoft.Network/virtualNetworks/virtualNetworkPeerings "+hub+"/virtualNetworkPeerings/peer-spoke\n")
+
+	leaks, err = f.waitForLeaks(ctx, before, IgnoreOtherTests("/resourceGroups/", "rg-hub"), time.Millisecond, time.Millisecond)
+	require.NoError(t, err)
+	assert.Len(t, leaks, 2, "NetworkWatcherRG should be ignored")
+
+	leaks, err = f.waitForLeaks(ctx, before, IgnoreAllExcept("PEER-spoke"), time.Millisecond, time.Millisecond)
+	require.NoError(t, err)
+	assert.Equal(t, ResourceLeaks{
+		{ID: hub + "/virtualNetworkPeerings/peer-spoke", Type: "Microsoft.Network/virtualNetworks/virtualNetworkPeerings"},
+	}, leaks, "the role assignment and NetworkWatcherRG should be ignored")
+}
+
+// TestDetectLeaks tests that a resource that is still listed after the destroy, but is gone before the settle time,
+// is not reported.
+func TestDetectLeaks(t *testing.T) {
+	f, srv := newFakeClientFactory(t, nil)
+	id := uuid.New()
+	srv.AddSubscription(fakearm.Subscription{ID: id, DisplayName: "sub", State: "Enabled"})
+	srv.AddResourceGroup(id, "rg-hub", "westeurope")
+	hub := "/subscriptions/" + id.String() + "/resourceGroups/rg-hub/providers/Microsoft.Network/virtualNetworks/hub"
+	srv.PutResource(hub, nil)
+	peering := hub + "/virtualNetworkPeerings/peer-spoke"
+
+	polls := 0
+	t.Run("deploy", func(t *testing.T) {
+		f.DetectLeaks(t, &LeakDetectorOptions{
+			Settle:       time.Minute,
+			PollInterval: time.Millisecond,
+			// The peering disappears after the first snapshot of the cleanup, as ARM catches up with the destroy.
+			Ignore: func(r SnapshotResource) bool {
+				polls++
+				srv.DeleteResource(peering)
+				return false
+			},
+		}, id)
+		srv.PutResource(peering, nil)
+	})
+	assert.Equal(t, 1, polls)
+	assert.False(t, t.Failed())
+}
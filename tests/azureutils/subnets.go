@@ -8,11 +8,20 @@ import (
 	"github.com/google/uuid"
 )
 
+// ListSubnets lists all subnets in the given virtual network
+// using the DefaultClientFactory.
+func ListSubnets(ctx context.Context, rg, vnet string, subid uuid.UUID) ([]*armnetwork.Subnet, error) {
+	f, err := DefaultClientFactory()
+	if err != nil {
+		return nil, err
+	}
+	return f.ListSubnets(ctx, rg, vnet, subid)
+}
+
 // ListSubnets lists all subnets in the given virtual network.
-func ListSubnets(rg, vnet string, subid uuid.UUID) ([]*armnetwork.Subnet, error) {
-	ctx := context.Background()
+func (f *ClientFactory) ListSubnets(ctx context.Context, rg, vnet string, subid uuid.UUID) ([]*armnetwork.Subnet, error) {
 	subnets := make([]*armnetwork.Subnet, 0)
-	client, err := NewSubnetClient(subid)
+	client, err := f.NewSubnetsClient(subid)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create subnet client: %v", err)
 	}
@@ -0,0 +1,26 @@
+package azureutils
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListSubnets(t *testing.T) {
+	f, srv := newFakeClientFactory(t, nil)
+	id := uuid.New()
+	srv.AddResourceGroup(id, "rg1", "westeurope")
+	vnetID := "/subscriptions/" + id.String() + "/resourceGroups/rg1/providers/Microsoft.Network/virtualNetworks/vnet1"
+	srv.PutResource(vnetID, nil)
+	srv.PutResource(vnetID+"/subnets/snet1", map[string]any{"properties": map[string]any{"addressPrefix": "10.0.0.0/24"}})
+	srv.PutResource(vnetID+"/subnets/snet2", map[string]any{"properties": map[string]any{"addressPrefix": "10.0.1.0/24"}})
+
+	subnets, err := f.ListSubnets(context.Background(), "rg1", "vnet1", id)
+	require.NoError(t, err)
+	require.Len(t, subnets, 2)
+	assert.Equal(t, "snet1", *subnets[0].Name)
+	assert.Equal(t, "10.0.0.0/24", *subnets[0].Properties.AddressPrefix)
+}
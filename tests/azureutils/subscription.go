@@ -3,88 +3,132 @@ package azureutils
 import (
 	"context"
 	"fmt"
-	"strings"
-	"testing"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/managementgroups/armmanagementgroups"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/subscription/armsubscription"
-	"github.com/Azure/terratest-terraform-fluent/setuptest"
 	"github.com/google/uuid"
-	"github.com/gruntwork-io/terratest/modules/retry"
-	"golang.org/x/sync/errgroup"
 )
 
-// CancelSubscription cancels the supplied Azure subscription.
-// it retries a few times as the subscription api is eventually consistent.
-func CancelSubscription(t *testing.T, id *uuid.UUID) error {
-	t.Logf("cancelling subscription %s", id.String())
-
-	sub, err := GetSubscription(*id)
+// CancelSubscription cancels the supplied Azure subscription using the DefaultClientFactory.
+// Use NewTestContext to create a context that is cancelled before the test times out.
+func CancelSubscription(ctx context.Context, t TestingT, id *uuid.UUID) error {
+	f, err := DefaultClientFactory()
 	if err != nil {
-		return fmt.Errorf("subscription %s does not exist or cannot successfully check, %s", id, err)
+		return err
 	}
+	return f.CancelSubscription(ctx, t, id)
+}
 
-	client, err := NewSubscriptionClient()
+// ListSubscriptions returns all subscriptions visible to the caller using the DefaultClientFactory.
+func ListSubscriptions(ctx context.Context) ([]*armsubscription.Subscription, error) {
+	f, err := DefaultClientFactory()
 	if err != nil {
-		return fmt.Errorf("cannot create subscription client, %s", err)
+		return nil, err
 	}
-	ctx := context.TODO()
-	g, ctx := errgroup.WithContext(ctx)
-	g.SetLimit(10)
+	return f.ListSubscriptions(ctx)
+}
 
-	rgs, err := ListResourceGroup(ctx, *id)
+// ListAliases returns all subscription aliases visible to the caller using the DefaultClientFactory.
+func ListAliases(ctx context.Context) ([]*armsubscription.AliasResponse, error) {
+	f, err := DefaultClientFactory()
 	if err != nil {
-		return fmt.Errorf("cannot list resource groups for subscription %s, %v", id, err)
+		return nil, err
 	}
+	return f.ListAliases(ctx)
+}
 
-	t.Logf("removing %d resource groups for subscription %s", len(rgs), id)
+// SubscriptionExists checks if the supplied subscription exists using the DefaultClientFactory.
+func SubscriptionExists(ctx context.Context, id uuid.UUID) (bool, error) {
+	f, err := DefaultClientFactory()
+	if err != nil {
+		return false, err
+	}
+	return f.SubscriptionExists(ctx, id)
+}
 
-	for _, rg := range rgs {
-		rg := rg // https://golang.org/doc/faq#closures_and_goroutines
-		g.Go(func() error {
-			t.Logf("removing resource group %s for subscription %s", *rg.Name, id.String())
-			return DeleteResourceGroup(ctx, *rg.Name, *id)
-		})
+// GetSubscription checks if the supplied subscription exists and returns it using the DefaultClientFactory.
+func GetSubscription(ctx context.Context, id uuid.UUID) (armsubscription.SubscriptionsClientGetResponse, error) {
+	f, err := DefaultClientFactory()
+	if err != nil {
+		return armsubscription.SubscriptionsClientGetResponse{}, err
 	}
-	if err := g.Wait(); err != nil {
-		return fmt.Errorf("cannot delete resource groups for subscription %s, %v", id, err)
+	return f.GetSubscription(ctx, id)
+}
+
+// IsSubscriptionInManagementGroup returns nil if the subscription is in the management group,
+// using the DefaultClientFactory.
+func IsSubscriptionInManagementGroup(ctx context.Context, t TestingT, id uuid.UUID, mg string) error {
+	f, err := DefaultClientFactory()
+	if err != nil {
+		return err
 	}
-	t.Logf("removed %d resource groups for subscription %s", len(rgs), id)
+	return f.IsSubscriptionInManagementGroup(ctx, t, id, mg)
+}
 
-	// If the sub is already in warned or disabled state then do not try and cancel again.
-	if *sub.State == "Disabled" || *sub.State == "Warned" {
-		t.Logf("subscription %s is already cancelled", id.String())
-		return nil
+// SetSubscriptionManagementGroup moves the subscription to the management group using the DefaultClientFactory.
+func SetSubscriptionManagementGroup(ctx context.Context, id uuid.UUID, mg string) error {
+	f, err := DefaultClientFactory()
+	if err != nil {
+		return err
 	}
+	return f.SetSubscriptionManagementGroup(ctx, id, mg)
+}
 
-	ctx, cancel := context.WithCancel(context.TODO())
-	defer cancel()
+// CancelSubscription cancels the supplied Azure subscription after deleting its resource groups.
+// It runs the DecommissionStepDeleteResourceGroups and DecommissionStepCancel steps of Decommission,
+// use Decommission directly for the full offboarding workflow.
+// As it is test teardown, the management locks on the resource groups and their resources are removed,
+// e.g. those created by the module with lock_enabled.
+func (f *ClientFactory) CancelSubscription(ctx context.Context, t TestingT, id *uuid.UUID) error {
+	t.Logf("cancelling subscription %s", id.String())
+	_, err := f.Decommission(ctx, t, *id, &DecommissionOptions{
+		Steps: []DecommissionStep{
+			DecommissionStepDeleteResourceGroups,
+			DecommissionStepCancel,
+		},
+		RemoveResourceGroupLocks: true,
+	})
+	return err
+}
 
-	_, err = retry.DoWithRetryE(t, "cancel subscription", setuptest.FastRetry.Max, setuptest.FastRetry.Wait, func() (string, error) {
-		_, err := client.Cancel(ctx, id.String(), nil)
+// ListSubscriptions returns all subscriptions visible to the caller.
+func (f *ClientFactory) ListSubscriptions(ctx context.Context) ([]*armsubscription.Subscription, error) {
+	client, err := f.NewSubscriptionsClient()
+	if err != nil {
+		return nil, fmt.Errorf("cannot create subscriptions client, %s", err)
+	}
+	subs := make([]*armsubscription.Subscription, 0)
+	pager := client.NewListPager(nil)
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
 		if err != nil {
-			if strings.Contains(err.Error(), "Subscription is not in active state") {
-				return "", nil
-			}
-			return "", err
+			return nil, fmt.Errorf("cannot list subscriptions, %s", err)
 		}
-		return "", nil
-	})
+		subs = append(subs, page.Value...)
+	}
+	return subs, nil
+}
 
+// ListAliases returns all subscription aliases visible to the caller.
+// The alias API does not page its results.
+func (f *ClientFactory) ListAliases(ctx context.Context) ([]*armsubscription.AliasResponse, error) {
+	client, err := f.NewAliasClient()
 	if err != nil {
-		return fmt.Errorf("cannot cancel subscription %s, %v", id, err)
+		return nil, fmt.Errorf("cannot create alias client, %s", err)
 	}
-	t.Logf("cancelled subscription %s", id.String())
-	return nil
+	resp, err := client.List(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("cannot list subscription aliases, %s", err)
+	}
+	return resp.Value, nil
 }
 
 // SubscriptionExists checks if the supplied subscription exists
-func SubscriptionExists(id uuid.UUID) (bool, error) {
-	client, err := NewSubscriptionsClient()
+func (f *ClientFactory) SubscriptionExists(ctx context.Context, id uuid.UUID) (bool, error) {
+	client, err := f.NewSubscriptionsClient()
 	if err != nil {
 		return false, fmt.Errorf("cannot create subscriptions client, %s", err)
 	}
-	ctx := context.Background()
 	if _, err := client.Get(ctx, id.String(), nil); err != nil {
 		return false, fmt.Errorf("cannot get subscription, %s", err)
 	}
@@ -92,13 +136,12 @@ func SubscriptionExists(id uuid.UUID) (bool, error) {
 }
 
 // GetSubscription checks if the supplied subscription exists and returns it
-func GetSubscription(id uuid.UUID) (armsubscription.SubscriptionsClientGetResponse, error) {
-	client, err := NewSubscriptionsClient()
+func (f *ClientFactory) GetSubscription(ctx context.Context, id uuid.UUID) (armsubscription.SubscriptionsClientGetResponse, error) {
+	client, err := f.NewSubscriptionsClient()
 	var resp armsubscription.SubscriptionsClientGetResponse
 	if err != nil {
 		return resp, fmt.Errorf("cannot create subscriptions client, %s", err)
 	}
-	ctx := context.Background()
 	resp, err = client.Get(ctx, id.String(), nil)
 	if err != nil {
 		return resp, fmt.Errorf("cannot get subscription, %s", err)
@@ -106,13 +149,14 @@ func GetSubscription(id uuid.UUID) (armsubscription.SubscriptionsClientGetRespon
 	return resp, nil
 }
 
-// IsSubscriptionInManagementGroup returns true if the subscription is a management group.
-func IsSubscriptionInManagementGroup(t *testing.T, id uuid.UUID, mg string) error {
-	if exists, err := SubscriptionExists(id); err != nil || !exists {
+// IsSubscriptionInManagementGroup returns nil if the subscription is in the management group.
+// Not found responses are retried, as the management group api is eventually consistent.
+func (f *ClientFactory) IsSubscriptionInManagementGroup(ctx context.Context, t TestingT, id uuid.UUID, mg string) error {
+	if exists, err := f.SubscriptionExists(ctx, id); err != nil || !exists {
 		return fmt.Errorf("subscription %s does not exist, or could not successfully check, %s", id, err)
 	}
 
-	client, err := NewManagementGroupSubscriptionsClient()
+	client, err := f.NewManagementGroupSubscriptionsClient()
 	if err != nil {
 		return fmt.Errorf("cannot create mg subscriptions client, %s", err)
 	}
@@ -121,12 +165,9 @@ func IsSubscriptionInManagementGroup(t *testing.T, id uuid.UUID, mg string) erro
 	cc := "no-cache"
 	mgopts.CacheControl = &cc
 
-	_, err = retry.DoWithRetryE(t, "is subscription in management group", setuptest.FastRetry.Max, setuptest.FastRetry.Wait, func() (string, error) {
-		_, err := client.GetSubscription(context.Background(), mg, id.String(), &mgopts)
-		if err != nil {
-			return "", err
-		}
-		return "", nil
+	err = f.retryPolicy(ctx).WithRetryOn(ErrorClassNotFound).Do(ctx, func(ctx context.Context) error {
+		_, err := client.GetSubscription(ctx, mg, id.String(), &mgopts)
+		return err
 	})
 	if err != nil {
 		return fmt.Errorf("failed determine if subscription %s in management group %s: %v", id.String(), mg, err)
@@ -135,8 +176,8 @@ func IsSubscriptionInManagementGroup(t *testing.T, id uuid.UUID, mg string) erro
 }
 
 // SetSubscriptionManagementGroup moves the subscription to the management group.
-func SetSubscriptionManagementGroup(id uuid.UUID, mg string) error {
-	client, err := NewManagementGroupSubscriptionsClient()
+func (f *ClientFactory) SetSubscriptionManagementGroup(ctx context.Context, id uuid.UUID, mg string) error {
+	client, err := f.NewManagementGroupSubscriptionsClient()
 	if err != nil {
 		return fmt.Errorf("cannot create mg subscriptions client, %s", err)
 	}
@@ -144,7 +185,7 @@ func SetSubscriptionManagementGroup(id uuid.UUID, mg string) error {
 	opts := armmanagementgroups.ManagementGroupSubscriptionsClientCreateOptions{
 		CacheControl: &cc,
 	}
-	if _, err := client.Create(context.Background(), mg, id.String(), &opts); err != nil {
+	if _, err := client.Create(ctx, mg, id.String(), &opts); err != nil {
 		return fmt.Errorf("cannot create subscription %s in management group %s, %s", id.String(), mg, err)
 	}
 	return nil
@@ -0,0 +1,177 @@
+package azureutils
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/terraform-azurerm-lz-vending/tests/fakearm"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestCancelSubscription tests that the resource groups, and their resources, are deleted
+// and the subscription is cancelled.
+func TestCancelSubscription(t *testing.T) {
+	f, srv := newFakeClientFactory(t, &fakearm.Options{DeletePollCount: 2})
+	id := uuid.New()
+	srv.AddSubscription(fakearm.Subscription{ID: id, DisplayName: "testdeploy-cancel"})
+	srv.AddResourceGroup(id, "rg1", "westeurope")
+	srv.AddResourceGroup(id, "rg2", "westeurope")
+	srv.PutResource("/subscriptions/"+id.String()+"/resourceGroups/rg1/providers/Microsoft.Network/virtualNetworks/vnet1", nil)
+
+	require.NoError(t, f.CancelSubscription(context.Background(), t, &id))
+
+	sub, ok := srv.Subscription(id)
+	require.True(t, ok)
+	assert.Equal(t, fakearm.SubscriptionStateWarned, sub.State)
+	assert.Empty(t, srv.ResourceGroups(id))
+	assert.Empty(t, srv.ResourceIDs("/subscriptions/"+id.String()))
+}
+
+// TestCancelSubscriptionLocked tests that the locks on the resource groups and their resources are removed,
+// so that a subscription whose resource groups were created with lock_enabled can be cancelled.
+func TestCancelSubscriptionLocked(t *testing.T) {
+	f, srv := newFakeClientFactory(t, &fakearm.Options{LockReleaseDelay: 1})
+	id := uuid.New()
+	srv.AddSubscription(fakearm.Subscription{ID: id})
+	srv.AddResourceGroup(id, "rg1", "westeurope")
+	rgID := "/subscriptions/" + id.String() + "/resourceGroups/rg1"
+	srv.PutResource(rgID+"/providers/Microsoft.Authorization/locks/lock-rg1", map[string]any{
+		"properties": map[string]any{"level": "CanNotDelete"},
+	})
+
+	require.NoError(t, f.CancelSubscription(context.Background(), t, &id))
+
+	sub, _ := srv.Subscription(id)
+	assert.Equal(t, fakearm.SubscriptionStateWarned, sub.State)
+	assert.Empty(t, srv.ResourceGroups(id))
+}
+
+// TestCancelSubscriptionAlreadyCancelled tests that the resource groups are deleted
+// but cancel is not called again for a subscription that is already cancelled.
+func TestCancelSubscriptionAlreadyCancelled(t *testing.T) {
+	f, srv := newFakeClientFactory(t, nil)
+	id := uuid.New()
+	srv.AddSubscription(fakearm.Subscription{ID: id, State: fakearm.SubscriptionStateWarned})
+	srv.AddResourceGroup(id, "rg1", "westeurope")
+
+	require.NoError(t, f.CancelSubscription(context.Background(), t, &id))
+
+	assert.Empty(t, srv.ResourceGroups(id))
+	assert.Zero(t, srv.RequestCount(http.MethodPost, "/cancel"))
+}
+
+// TestCancelSubscriptionRetry tests that a transient cancel failure is retried.
+func TestCancelSubscriptionRetry(t *testing.T) {
+	f, srv := newFakeClientFactory(t, nil)
+	id := uuid.New()
+	srv.AddSubscription(fakearm.Subscription{ID: id})
+	srv.InjectFault(fakearm.Fault{
+		Method:       http.MethodPost,
+		PathContains: "/cancel",
+		StatusCode:   http.StatusConflict,
+		Code:         "Conflict",
+		Message:      "Another operation is in progress.",
+		Count:        1,
+	})
+
+	require.NoError(t, f.CancelSubscription(context.Background(), t, &id))
+
+	sub, _ := srv.Subscription(id)
+	assert.Equal(t, fakearm.SubscriptionStateWarned, sub.State)
+	assert.Equal(t, 2, srv.RequestCount(http.MethodPost, "/cancel"))
+}
+
+// TestCancelSubscriptionRetryNotFound tests that the not found response returned by the cancel API
+// shortly after the subscription is created is retried.
+func TestCancelSubscriptionRetryNotFound(t *testing.T) {
+	f, srv := newFakeClientFactory(t, nil)
+	id := uuid.New()
+	srv.AddSubscription(fakearm.Subscription{ID: id})
+	srv.InjectFault(fakearm.Fault{
+		Method:       http.MethodPost,
+		PathContains: "/cancel",
+		StatusCode:   http.StatusNotFound,
+		Code:         "SubscriptionNotFound",
+		Message:      "The subscription could not be found.",
+		Count:        1,
+	})
+
+	require.NoError(t, f.CancelSubscription(context.Background(), t, &id))
+
+	sub, _ := srv.Subscription(id)
+	assert.Equal(t, fakearm.SubscriptionStateWarned, sub.State)
+	assert.Equal(t, 2, srv.RequestCount(http.MethodPost, "/cancel"))
+}
+
+// TestCancelSubscriptionLostResponse tests that a cancel that succeeds but fails to return its response
+// is not retried, as the subscription state shows that it is cancelled.
+func TestCancelSubscriptionLostResponse(t *testing.T) {
+	f, srv := newFakeClientFactory(t, nil)
+	f.options.Transport = lostCancelResponse{next: f.options.Transport}
+	id := uuid.New()
+	srv.AddSubscription(fakearm.Subscription{ID: id})
+
+	require.NoError(t, f.CancelSubscription(context.Background(), t, &id))
+
+	sub, _ := srv.Subscription(id)
+	assert.Equal(t, fakearm.SubscriptionStateWarned, sub.State)
+	assert.Equal(t, 1, srv.RequestCount(http.MethodPost, "/cancel"))
+}
+
+// lostCancelResponse sends the requests to next, but replaces the response to a cancel with a server error.
+type lostCancelResponse struct {
+	next policy.Transporter
+}
+
+func (l lostCancelResponse) Do(req *http.Request) (*http.Response, error) {
+	resp, err := l.next.Do(req)
+	if err != nil || req.Method != http.MethodPost || !strings.HasSuffix(req.URL.Path, "/cancel") {
+		return resp, err
+	}
+	resp.Body.Close()
+	return &http.Response{
+		StatusCode: http.StatusInternalServerError,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(`{"error":{"code":"InternalServerError","message":"Something went wrong."}}`)),
+		Request:    req,
+	}, nil
+}
+
+// TestCancelSubscriptionNotFound tests that an unknown subscription is reported as an error.
+func TestCancelSubscriptionNotFound(t *testing.T) {
+	f, _ := newFakeClientFactory(t, nil)
+	id := uuid.New()
+	assert.ErrorContains(t, f.CancelSubscription(context.Background(), t, &id), "SubscriptionNotFound")
+}
+
+// TestIsSubscriptionInManagementGroup tests that the check retries until
+// the eventually consistent management group API reports the subscription.
+func TestIsSubscriptionInManagementGroup(t *testing.T) {
+	f, srv := newFakeClientFactory(t, &fakearm.Options{ManagementGroupReadDelay: 2})
+	id := uuid.New()
+	srv.AddSubscription(fakearm.Subscription{ID: id})
+
+	ctx := context.Background()
+	require.NoError(t, f.SetSubscriptionManagementGroup(ctx, id, "mg1"))
+	sub, _ := srv.Subscription(id)
+	assert.Equal(t, "mg1", sub.ManagementGroup)
+
+	require.NoError(t, f.IsSubscriptionInManagementGroup(ctx, t, id, "mg1"))
+	assert.Equal(t, 3, srv.RequestCount(http.MethodGet, "/managementGroups/mg1/subscriptions/"))
+}
+
+// TestIsSubscriptionInManagementGroupNotIn tests that the check fails once the retries are exhausted.
+func TestIsSubscriptionInManagementGroupNotIn(t *testing.T) {
+	f, srv := newFakeClientFactory(t, nil)
+	id := uuid.New()
+	srv.AddSubscription(fakearm.Subscription{ID: id, ManagementGroup: "mg1"})
+
+	assert.Error(t, f.IsSubscriptionInManagementGroup(context.Background(), t, id, "mg2"))
+	assert.Equal(t, 4, srv.RequestCount(http.MethodGet, "/managementGroups/mg2/subscriptions/"), "expected the first attempt plus three retries")
+}
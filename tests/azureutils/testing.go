@@ -0,0 +1,12 @@
+package azureutils
+
+import (
+	terratesting "github.com/gruntwork-io/terratest/modules/testing"
+)
+
+// TestingT is the subset of *testing.T used by the helpers that log their progress and retry.
+// It allows the helpers to be used outside of go test, e.g. by the lzjanitor command.
+type TestingT interface {
+	terratesting.TestingT
+	Logf(format string, args ...any)
+}
@@ -0,0 +1,369 @@
+package azureutils
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// networkAPIVersion is the Microsoft.Network API version used by the verifiers.
+// It is newer than the armnetwork SDK in this module, which does not report defaultOutboundAccess.
+const networkAPIVersion = "2024-05-01"
+
+// Difference is a property of a resource whose value in Azure is not the expected value.
+// A nil Expected means the property, or resource, was not expected,
+// and a nil Actual means it was not found.
+type Difference struct {
+	ResourceID string `json:"resource_id"`
+	Property   string `json:"property"`
+	Expected   any    `json:"expected"`
+	Actual     any    `json:"actual"`
+}
+
+// String returns a description of the difference.
+func (d Difference) String() string {
+	return fmt.Sprintf("%s: %s expected %v, actual %v", d.ResourceID, d.Property, d.Expected, d.Actual)
+}
+
+// Diff is the list of differences found by a verifier.
+// An empty Diff means Azure holds the expected configuration.
+type Diff []Difference
+
+// String returns one line per difference.
+func (d Diff) String() string {
+	lines := make([]string, 0, len(d))
+	for _, diff := range d {
+		lines = append(lines, diff.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// compare adds a difference if the values are not equal.
+func (d *Diff) compare(resourceID, property string, expected, actual any) {
+	if !reflect.DeepEqual(expected, actual) {
+		*d = append(*d, Difference{
+			ResourceID: resourceID,
+			Property:   property,
+			Expected:   expected,
+			Actual:     actual,
+		})
+	}
+}
+
+// compareSet adds a difference if the values differ, ignoring order.
+func (d *Diff) compareSet(resourceID, property string, expected, actual []string) {
+	slices.Sort(expected)
+	slices.Sort(actual)
+	d.compare(resourceID, property, expected, actual)
+}
+
+// armID is a reference to another resource, such as a network security group.
+type armID struct {
+	ID string `json:"id"`
+}
+
+type virtualNetwork struct {
+	armResource
+	Properties struct {
+		AddressSpace struct {
+			AddressPrefixes []string `json:"addressPrefixes"`
+		} `json:"addressSpace"`
+		DhcpOptions *struct {
+			DNSServers []string `json:"dnsServers"`
+		} `json:"dhcpOptions"`
+		EnableDdosProtection *bool  `json:"enableDdosProtection"`
+		DdosProtectionPlan   *armID `json:"ddosProtectionPlan"`
+		FlowTimeoutInMinutes *int   `json:"flowTimeoutInMinutes"`
+	} `json:"properties"`
+}
+
+type subnet struct {
+	armResource
+	Properties struct {
+		AddressPrefix        string   `json:"addressPrefix"`
+		AddressPrefixes      []string `json:"addressPrefixes"`
+		NetworkSecurityGroup *armID   `json:"networkSecurityGroup"`
+		RouteTable           *armID   `json:"routeTable"`
+		NatGateway           *armID   `json:"natGateway"`
+		Delegations          []struct {
+			Properties struct {
+				ServiceName string `json:"serviceName"`
+			} `json:"properties"`
+		} `json:"delegations"`
+		ServiceEndpoints []struct {
+			Service string `json:"service"`
+		} `json:"serviceEndpoints"`
+		DefaultOutboundAccess             *bool  `json:"defaultOutboundAccess"`
+		PrivateEndpointNetworkPolicies    string `json:"privateEndpointNetworkPolicies"`
+		PrivateLinkServiceNetworkPolicies string `json:"privateLinkServiceNetworkPolicies"`
+	} `json:"properties"`
+}
+
+// VerifyVirtualNetwork compares the virtual network in Azure with an entry of the
+// virtualnetwork module's virtual_networks input variable, using the DefaultClientFactory.
+func VerifyVirtualNetwork(ctx context.Context, subID uuid.UUID, vnet map[string]any) (Diff, error) {
+	f, err := DefaultClientFactory()
+	if err != nil {
+		return nil, err
+	}
+	return f.VerifyVirtualNetwork(ctx, subID, vnet)
+}
+
+// VerifySubnets compares the subnets of the virtual network in Azure with the subnets of an entry of the
+// virtualnetwork module's virtual_networks input variable, using the DefaultClientFactory.
+func VerifySubnets(ctx context.Context, subID uuid.UUID, vnet map[string]any) (Diff, error) {
+	f, err := DefaultClientFactory()
+	if err != nil {
+		return nil, err
+	}
+	return f.VerifySubnets(ctx, subID, vnet)
+}
+
+// VerifyVirtualNetwork compares the virtual network in Azure with an entry of the
+// virtualnetwork module's virtual_networks input variable.
+// It checks the address space, DNS servers, DDoS protection plan and flow timeout,
+// applying the variable defaults to omitted values.
+// The flow timeout is only checked if it is set.
+func (f *ClientFactory) VerifyVirtualNetwork(ctx context.Context, subID uuid.UUID, vnet map[string]any) (Diff, error) {
+	id := virtualNetworkID(subID, vnet)
+	var actual virtualNetwork
+	if err := f.doARM(ctx, http.MethodGet, id, networkAPIVersion, nil, &actual); err != nil {
+		return nil, fmt.Errorf("cannot get virtual network %s, %v", id, err)
+	}
+	props := actual.Properties
+
+	diff := Diff{}
+	diff.compareSet(id, "address_space", stringSlice(vnet["address_space"]), nilIfEmpty(props.AddressSpace.AddressPrefixes))
+
+	var dns []string
+	if props.DhcpOptions != nil {
+		dns = props.DhcpOptions.DNSServers
+	}
+	// The DNS server order is significant.
+	diff.compare(id, "dns_servers", stringSlice(vnet["dns_servers"]), nilIfEmpty(dns))
+
+	// The module attaches the DDoS protection plan, and enables protection, when a plan ID is supplied.
+	planID, _ := vnet["ddos_protection_plan_id"].(string)
+	actualPlanID := ""
+	if props.DdosProtectionPlan != nil {
+		actualPlanID = props.DdosProtectionPlan.ID
+	}
+	diff.compare(id, "ddos_protection_plan_id", strings.ToLower(planID), strings.ToLower(actualPlanID))
+	diff.compare(id, "ddos_protection_enabled", planID != "", props.EnableDdosProtection != nil && *props.EnableDdosProtection)
+
+	if timeout, ok := intValue(vnet["flow_timeout_in_minutes"]); ok {
+		var actualTimeout any
+		if props.FlowTimeoutInMinutes != nil {
+			actualTimeout = *props.FlowTimeoutInMinutes
+		}
+		diff.compare(id, "flow_timeout_in_minutes", timeout, actualTimeout)
+	}
+	return diff, nil
+}
+
+// VerifySubnets compares the subnets of the virtual network in Azure with the subnets of an entry of the
+// virtualnetwork module's virtual_networks input variable.
+// It checks the address prefixes, network security group, route table, NAT gateway, delegations,
+// service endpoints, network policies and default outbound access of each subnet,
+// applying the variable defaults to omitted values.
+// Subnets that are in Azure but not in the input, and subnets that are missing from Azure, are reported.
+func (f *ClientFactory) VerifySubnets(ctx context.Context, subID uuid.UUID, vnet map[string]any) (Diff, error) {
+	vnetID := virtualNetworkID(subID, vnet)
+	subnets, err := listARM[subnet](ctx, f, vnetID+"/subnets", networkAPIVersion)
+	if err != nil {
+		return nil, fmt.Errorf("cannot list subnets of virtual network %s, %v", vnetID, err)
+	}
+	actual := make(map[string]subnet, len(subnets))
+	for _, s := range subnets {
+		actual[strings.ToLower(s.Name)] = s
+	}
+
+	diff := Diff{}
+	expected := mapOfMaps(vnet["subnets"])
+	keys := make([]string, 0, len(expected))
+	for k := range expected {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	for _, k := range keys {
+		want := expected[k]
+		name, _ := want["name"].(string)
+		id := vnetID + "/subnets/" + name
+		got, ok := actual[strings.ToLower(name)]
+		if !ok {
+			diff.compare(id, "subnet", name, nil)
+			continue
+		}
+		delete(actual, strings.ToLower(name))
+		diff = append(diff, compareSubnet(id, want, got)...)
+	}
+
+	extra := make([]string, 0, len(actual))
+	for _, s := range actual {
+		extra = append(extra, s.Name)
+	}
+	slices.Sort(extra)
+	for _, name := range extra {
+		diff.compare(vnetID+"/subnets/"+name, "subnet", nil, name)
+	}
+	return diff, nil
+}
+
+// compareSubnet compares a subnet in Azure with its input value.
+func compareSubnet(id string, want map[string]any, got subnet) Diff {
+	props := got.Properties
+	diff := Diff{}
+
+	prefixes := props.AddressPrefixes
+	if len(prefixes) == 0 && props.AddressPrefix != "" {
+		prefixes = []string{props.AddressPrefix}
+	}
+	diff.compareSet(id, "address_prefixes", stringSlice(want["address_prefixes"]), nilIfEmpty(prefixes))
+
+	for _, ref := range []struct {
+		property string
+		actual   *armID
+	}{
+		{"network_security_group", props.NetworkSecurityGroup},
+		{"route_table", props.RouteTable},
+		{"nat_gateway", props.NatGateway},
+	} {
+		actualID := ""
+		if ref.actual != nil {
+			actualID = ref.actual.ID
+		}
+		diff.compare(id, ref.property+".id", strings.ToLower(nestedID(want[ref.property])), strings.ToLower(actualID))
+	}
+
+	var delegations []string
+	for _, d := range mapSlice(want["delegations"]) {
+		if sd, ok := d["service_delegation"].(map[string]any); ok {
+			delegations = append(delegations, fmt.Sprint(sd["name"]))
+		}
+	}
+	var actualDelegations []string
+	for _, d := range props.Delegations {
+		actualDelegations = append(actualDelegations, d.Properties.ServiceName)
+	}
+	diff.compareSet(id, "delegations", delegations, actualDelegations)
+
+	var actualEndpoints []string
+	for _, se := range props.ServiceEndpoints {
+		actualEndpoints = append(actualEndpoints, se.Service)
+	}
+	diff.compareSet(id, "service_endpoints", stringSlice(want["service_endpoints"]), actualEndpoints)
+
+	// Azure enables default outbound access when the property is not set.
+	diff.compare(id, "default_outbound_access_enabled",
+		boolValue(want["default_outbound_access_enabled"], false),
+		props.DefaultOutboundAccess == nil || *props.DefaultOutboundAccess)
+
+	pe, _ := want["private_endpoint_network_policies"].(string)
+	if pe == "" {
+		pe = "Enabled"
+	}
+	diff.compare(id, "private_endpoint_network_policies", pe, props.PrivateEndpointNetworkPolicies)
+	diff.compare(id, "private_link_service_network_policies_enabled",
+		boolValue(want["private_link_service_network_policies_enabled"], true),
+		props.PrivateLinkServiceNetworkPolicies == "Enabled")
+	return diff
+}
+
+// virtualNetworkID returns the resource ID of an entry of the virtual_networks input variable.
+func virtualNetworkID(subID uuid.UUID, vnet map[string]any) string {
+	return fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/Microsoft.Network/virtualNetworks/%s",
+		subID, vnet["resource_group_name"], vnet["name"])
+}
+
+// The input variables are built as untyped maps by the tests, or decoded from JSON,
+// so the helpers below accept the forms that each value can take.
+
+// stringSlice returns the strings in a []string or []any, or nil if there are none.
+func stringSlice(v any) []string {
+	var out []string
+	switch s := v.(type) {
+	case []string:
+		out = append(out, s...)
+	case []any:
+		for _, i := range s {
+			out = append(out, fmt.Sprint(i))
+		}
+	}
+	return out
+}
+
+func nilIfEmpty(s []string) []string {
+	if len(s) == 0 {
+		return nil
+	}
+	return s
+}
+
+// mapOfMaps returns a map[string]map[string]any or a map[string]any of maps.
+func mapOfMaps(v any) map[string]map[string]any {
+	switch m := v.(type) {
+	case map[string]map[string]any:
+		return m
+	case map[string]any:
+		out := make(map[string]map[string]any, len(m))
+		for k, i := range m {
+			if im, ok := i.(map[string]any); ok {
+				out[k] = im
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+// mapSlice returns a []map[string]any or a []any of maps.
+func mapSlice(v any) []map[string]any {
+	switch s := v.(type) {
+	case []map[string]any:
+		return s
+	case []any:
+		out := make([]map[string]any, 0, len(s))
+		for _, i := range s {
+			if im, ok := i.(map[string]any); ok {
+				out = append(out, im)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+// nestedID returns the id of an object such as network_security_group, or empty if it is not set.
+func nestedID(v any) string {
+	if m, ok := v.(map[string]any); ok {
+		id, _ := m["id"].(string)
+		return id
+	}
+	return ""
+}
+
+func boolValue(v any, dv bool) bool {
+	if b, ok := v.(bool); ok {
+		return b
+	}
+	return dv
+}
+
+func intValue(v any) (int, bool) {
+	switch i := v.(type) {
+	case int:
+		return i, true
+	case int32:
+		return int(i), true
+	case int64:
+		return int(i), true
+	case float64:
+		return int(i), true
+	}
+	return 0, false
+}
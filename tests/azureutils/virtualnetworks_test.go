@@ -0,0 +1,161 @@
+package azureutils
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Azure/terraform-azurerm-lz-vending/tests/fakearm"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// addVirtualNetwork adds a virtual network with two subnets to the fake server,
+// matching the input returned by virtualNetworkInput.
+func addVirtualNetwork(srv *fakearm.Server, id uuid.UUID) string {
+	srv.AddResourceGroup(id, "rg1", "westeurope")
+	vnetID := "/subscriptions/" + id.String() + "/resourceGroups/rg1/providers/Microsoft.Network/virtualNetworks/vnet1"
+	srv.PutResource(vnetID, map[string]any{"properties": map[string]any{
+		"addressSpace":         map[string]any{"addressPrefixes": []any{"10.0.1.0/24", "10.0.0.0/24"}},
+		"dhcpOptions":          map[string]any{"dnsServers": []any{"10.0.0.4", "10.0.0.5"}},
+		"flowTimeoutInMinutes": 10,
+	}})
+	srv.PutResource(vnetID+"/subnets/snet-default", map[string]any{"properties": map[string]any{
+		"addressPrefix":                     "10.0.0.0/26",
+		"networkSecurityGroup":              map[string]any{"id": "/subscriptions/" + id.String() + "/resourceGroups/rg1/providers/Microsoft.Network/networkSecurityGroups/NSG1"},
+		"serviceEndpoints":                  []any{map[string]any{"service": "Microsoft.Storage"}, map[string]any{"service": "Microsoft.KeyVault"}},
+		"defaultOutboundAccess":             false,
+		"privateEndpointNetworkPolicies":    "Enabled",
+		"privateLinkServiceNetworkPolicies": "Enabled",
+	}})
+	srv.PutResource(vnetID+"/subnets/snet-containers", map[string]any{"properties": map[string]any{
+		"addressPrefixes": []any{"10.0.0.64/26"},
+		"delegations": []any{map[string]any{
+			"name":       "aci",
+			"properties": map[string]any{"serviceName": "Microsoft.ContainerInstance/containerGroups"},
+		}},
+		"defaultOutboundAccess":             true,
+		"privateEndpointNetworkPolicies":    "Disabled",
+		"privateLinkServiceNetworkPolicies": "Disabled",
+	}})
+	return vnetID
+}
+
+// virtualNetworkInput returns a virtual_networks entry, in the form built by the deployment tests.
+func virtualNetworkInput(id uuid.UUID) map[string]any {
+	return map[string]any{
+		"name":                    "vnet1",
+		"resource_group_name":     "rg1",
+		"address_space":           []string{"10.0.0.0/24", "10.0.1.0/24"},
+		"dns_servers":             []string{"10.0.0.4", "10.0.0.5"},
+		"flow_timeout_in_minutes": 10,
+		"subnets": map[string]map[string]any{
+			"default": {
+				"name":             "snet-default",
+				"address_prefixes": []any{"10.0.0.0/26"},
+				"network_security_group": map[string]any{
+					"id": "/subscriptions/" + id.String() + "/resourceGroups/rg1/providers/Microsoft.Network/networkSecurityGroups/nsg1",
+				},
+				"service_endpoints": []any{"Microsoft.KeyVault", "Microsoft.Storage"},
+			},
+			"containers": {
+				"name":                                          "snet-containers",
+				"address_prefixes":                              []any{"10.0.0.64/26"},
+				"default_outbound_access_enabled":               true,
+				"private_endpoint_network_policies":             "Disabled",
+				"private_link_service_network_policies_enabled": false,
+				"delegations": []map[string]any{{
+					"name":               "aci",
+					"service_delegation": map[string]any{"name": "Microsoft.ContainerInstance/containerGroups"},
+				}},
+			},
+		},
+	}
+}
+
+// TestVerifyVirtualNetwork tests that a matching virtual network has no differences,
+// and that each mismatched property is reported.
+func TestVerifyVirtualNetwork(t *testing.T) {
+	f, srv := newFakeClientFactory(t, nil)
+	id := uuid.New()
+	vnetID := addVirtualNetwork(srv, id)
+	ctx := context.Background()
+	in := virtualNetworkInput(id)
+
+	diff, err := f.VerifyVirtualNetwork(ctx, id, in)
+	require.NoError(t, err)
+	assert.Empty(t, diff, diff.String())
+
+	in["dns_servers"] = []string{"10.0.0.5", "10.0.0.4"}
+	in["ddos_protection_plan_id"] = "/subscriptions/" + id.String() + "/resourceGroups/rg1/providers/Microsoft.Network/ddosProtectionPlans/ddos1"
+	in["flow_timeout_in_minutes"] = 4
+	diff, err = f.VerifyVirtualNetwork(ctx, id, in)
+	require.NoError(t, err)
+	props := make([]string, 0, len(diff))
+	for _, d := range diff {
+		assert.Equal(t, vnetID, d.ResourceID)
+		props = append(props, d.Property)
+	}
+	assert.Equal(t, []string{"dns_servers", "ddos_protection_plan_id", "ddos_protection_enabled", "flow_timeout_in_minutes"}, props)
+}
+
+// TestVerifySubnets tests that matching subnets have no differences,
+// and that mismatched, missing and extra subnets are reported.
+func TestVerifySubnets(t *testing.T) {
+	f, srv := newFakeClientFactory(t, nil)
+	id := uuid.New()
+	vnetID := addVirtualNetwork(srv, id)
+	ctx := context.Background()
+	in := virtualNetworkInput(id)
+
+	diff, err := f.VerifySubnets(ctx, id, in)
+	require.NoError(t, err)
+	assert.Empty(t, diff, diff.String())
+
+	subnets := in["subnets"].(map[string]map[string]any)
+	subnets["default"]["service_endpoints"] = []any{"Microsoft.Storage"}
+	subnets["default"]["route_table"] = map[string]any{"id": "/subscriptions/" + id.String() + "/resourceGroups/rg1/providers/Microsoft.Network/routeTables/rt1"}
+	subnets["default"]["default_outbound_access_enabled"] = true
+	delete(subnets["containers"], "delegations")
+	subnets["extra"] = map[string]any{"name": "snet-missing", "address_prefixes": []any{"10.0.0.128/26"}}
+	srv.PutResource(vnetID+"/subnets/snet-unmanaged", map[string]any{"properties": map[string]any{"addressPrefix": "10.0.0.192/26"}})
+
+	diff, err = f.VerifySubnets(ctx, id, in)
+	require.NoError(t, err)
+	assert.Equal(t, Diff{
+		{
+			ResourceID: vnetID + "/subnets/snet-containers",
+			Property:   "delegations",
+			Expected:   []string(nil),
+			Actual:     []string{"Microsoft.ContainerInstance/containerGroups"},
+		},
+		{
+			ResourceID: vnetID + "/subnets/snet-default",
+			Property:   "route_table.id",
+			Expected:   "/subscriptions/" + id.String() + "/resourcegroups/rg1/providers/microsoft.network/routetables/rt1",
+			Actual:     "",
+		},
+		{
+			ResourceID: vnetID + "/subnets/snet-default",
+			Property:   "service_endpoints",
+			Expected:   []string{"Microsoft.Storage"},
+			Actual:     []string{"Microsoft.KeyVault", "Microsoft.Storage"},
+		},
+		{
+			ResourceID: vnetID + "/subnets/snet-default",
+			Property:   "default_outbound_access_enabled",
+			Expected:   true,
+			Actual:     false,
+		},
+		{
+			ResourceID: vnetID + "/subnets/snet-missing",
+			Property:   "subnet",
+			Expected:   "snet-missing",
+		},
+		{
+			ResourceID: vnetID + "/subnets/snet-unmanaged",
+			Property:   "subnet",
+			Actual:     "snet-unmanaged",
+		},
+	}, diff)
+}
@@ -0,0 +1,123 @@
+package budget
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Azure/terraform-azurerm-lz-vending/tests/azureutils"
+	"github.com/Azure/terraform-azurerm-lz-vending/tests/utils"
+	"github.com/Azure/terratest-terraform-fluent/check"
+	"github.com/Azure/terratest-terraform-fluent/setuptest"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestDeployBudgetSubscriptionScope tests the deployment of a budget at subscription scope,
+// and that the budget read back from Cost Management matches the input.
+func TestDeployBudgetSubscriptionScope(t *testing.T) {
+
+	utils.PreCheckDeployTests(t)
+	r, err := utils.RandomHex(4)
+	require.NoErrorf(t, err, "could not generate random hex")
+	subID := uuid.MustParse(os.Getenv("AZURE_SUBSCRIPTION_ID"))
+
+	budget := getValidBudget("testdeploy-" + r)
+	v := moduleVars(budget)
+	v["budget_scope"] = "/subscriptions/" + subID.String()
+
+	test, err := setuptest.Dirs(moduleDir, "").WithVars(v).InitPlanShowWithPrepFunc(t, utils.AzureRmAndRequiredProviders)
+	require.NoError(t, err)
+	defer test.Cleanup()
+
+	check.InPlan(test.PlanStruct).NumberOfResourcesEquals(1).ErrorIsNil(t)
+	defer test.DestroyRetry(setuptest.DefaultRetry) //nolint:errcheck
+	test.ApplyIdempotent().ErrorIsNil(t)
+
+	verifyBudget(t, subID, budget, nil)
+}
+
+// TestDeployBudgetResourceGroupScope tests the deployment of a budget at resource group scope,
+// and that the budget read back from Cost Management matches the input.
+func TestDeployBudgetResourceGroupScope(t *testing.T) {
+
+	utils.PreCheckDeployTests(t)
+	r, err := utils.RandomHex(4)
+	require.NoErrorf(t, err, "could not generate random hex")
+	subID := uuid.MustParse(os.Getenv("AZURE_SUBSCRIPTION_ID"))
+
+	budget := getValidBudget("testdeploy-" + r)
+	budget["resource_group_key"] = "rg"
+	v := moduleVars(budget)
+	v["random_hex"] = r
+
+	testDir := filepath.Join("testdata", t.Name())
+	test, err := setuptest.Dirs(moduleDir, testDir).WithVars(v).InitPlanShowWithPrepFunc(t, utils.AzureRmAndRequiredProviders)
+	require.NoError(t, err)
+	defer test.Cleanup()
+
+	check.InPlan(test.PlanStruct).NumberOfResourcesEquals(2).ErrorIsNil(t)
+	defer test.DestroyRetry(setuptest.DefaultRetry) //nolint:errcheck
+	test.ApplyIdempotent().ErrorIsNil(t)
+
+	verifyBudget(t, subID, budget, map[string]map[string]any{
+		"rg": {"name": "testdeploy-" + r},
+	})
+}
+
+// getValidBudget returns a budget in the form of an entry of the root module's budgets input variable.
+// The time period starts in the current month, as Azure rejects budgets that start in the past.
+func getValidBudget(name string) map[string]any {
+	now := time.Now()
+	return map[string]any{
+		"name":              name,
+		"amount":            1000,
+		"time_grain":        "Monthly",
+		"time_period_start": azureutils.BudgetStartDate(now),
+		"time_period_end":   azureutils.BudgetStartDate(now.AddDate(1, 0, 0)),
+		"notifications": map[string]any{
+			"eightypercent": map[string]any{
+				"enabled":        true,
+				"operator":       "GreaterThan",
+				"threshold":      80,
+				"threshold_type": "Forecasted",
+				"contact_emails": []string{"john@microsoft.com"},
+				"locale":         "en-gb",
+			},
+			"budgetexceeded": map[string]any{
+				"enabled":       true,
+				"operator":      "GreaterThanOrEqualTo",
+				"threshold":     100,
+				"contact_roles": []string{"Owner"},
+			},
+		},
+	}
+}
+
+// moduleVars returns the budget module input variables for a budget of the root module's budgets input variable.
+func moduleVars(budget map[string]any) map[string]any {
+	return map[string]any{
+		"budget_name":       budget["name"],
+		"budget_amount":     budget["amount"],
+		"budget_time_grain": budget["time_grain"],
+		"budget_time_period": map[string]any{
+			"start_date": budget["time_period_start"],
+			"end_date":   budget["time_period_end"],
+		},
+		"budget_notifications": budget["notifications"],
+	}
+}
+
+// verifyBudget checks that the budget in Azure matches the input.
+func verifyBudget(t *testing.T, subID uuid.UUID, budget map[string]any, resourceGroups map[string]map[string]any) {
+	expected, err := azureutils.ExpectedBudgets(subID, map[string]map[string]any{"budget": budget}, resourceGroups)
+	require.NoError(t, err)
+
+	ctx, cancel := azureutils.NewTestContext(t)
+	defer cancel()
+	diff, err := azureutils.VerifyBudgets(ctx, expected)
+	require.NoError(t, err, "cannot verify budget")
+	assert.Emptyf(t, diff, "budget differs from the input:\n%s", diff)
+}
@@ -0,0 +1,443 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/ext/typeexpr"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/zclconf/go-cty/cty"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+)
+
+const (
+	// generatedSuffix is the file name suffix of the generated files in the inputs package.
+	generatedSuffix = "_generated.go"
+	// maxDefaultLength is the longest default value, as JSON, that is included in a doc comment.
+	maxDefaultLength = 60
+)
+
+// modules maps the directory of each module, relative to the root module, to the prefix of its Go type names.
+// A new submodule must be added here, as the prefix cannot be derived from the directory name.
+var modules = []struct {
+	Dir  string
+	Name string
+}{
+	{".", "Root"},
+	{"modules/budget", "Budget"},
+	{"modules/networksecuritygroup", "NetworkSecurityGroup"},
+	{"modules/resourcegroup", "ResourceGroup"},
+	{"modules/resourceprovider", "ResourceProvider"},
+	{"modules/roleassignment", "RoleAssignment"},
+	{"modules/routetable", "RouteTable"},
+	{"modules/subscription", "Subscription"},
+	{"modules/usermanagedidentity", "UserManagedIdentity"},
+	{"modules/virtualnetwork", "VirtualNetwork"},
+}
+
+// initialisms are the words of variable and attribute names that are upper case in Go names.
+var initialisms = map[string]string{
+	"api":  "API",
+	"bgp":  "BGP",
+	"ddos": "DDoS",
+	"dns":  "DNS",
+	"id":   "ID",
+	"ids":  "IDs",
+	"ip":   "IP",
+	"ipv6": "IPv6",
+	"umi":  "UMI",
+	"url":  "URL",
+	"uuid": "UUID",
+	"vwan": "VWAN",
+}
+
+// variable is a Terraform input variable.
+type variable struct {
+	Name        string
+	Description string
+	Type        cty.Type
+	Defaults    *typeexpr.Defaults
+	// Default is nil when the variable is required.
+	Default *cty.Value
+}
+
+// generateAll returns the generated source of each module, indexed by file name.
+// It fails if a module directory exists that is not in modules, or two modules generate the same type name.
+func generateAll(root string) (map[string][]byte, error) {
+	dirs, err := filepath.Glob(filepath.Join(root, "modules", "*", "variables*.tf"))
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range dirs {
+		rel, _ := filepath.Rel(root, filepath.Dir(d))
+		if !slices.ContainsFunc(modules, func(m struct{ Dir, Name string }) bool { return m.Dir == filepath.ToSlash(rel) }) {
+			return nil, fmt.Errorf("module %s has no Go name, add it to modules in the lzinputs command", rel)
+		}
+	}
+
+	files := make(map[string][]byte)
+	types := make(map[string]string)
+	for _, m := range modules {
+		vars, err := parseVariables(filepath.Join(root, m.Dir))
+		if err != nil {
+			return nil, err
+		}
+		g := newGenerator(m.Name, m.Dir)
+		src, err := g.generate(vars)
+		if err != nil {
+			return nil, fmt.Errorf("cannot generate inputs for module %s, %v", m.Dir, err)
+		}
+		for _, name := range g.typeNames {
+			if other, ok := types[name]; ok {
+				return nil, fmt.Errorf("type %s is generated for modules %s and %s", name, other, m.Dir)
+			}
+			types[name] = m.Dir
+		}
+		files[strings.ToLower(m.Name)+generatedSuffix] = src
+	}
+	return files, nil
+}
+
+// writeFiles writes the generated files to the directory, and removes generated files that are no longer generated.
+func writeFiles(dir string, files map[string][]byte) error {
+	existing, err := filepath.Glob(filepath.Join(dir, "*"+generatedSuffix))
+	if err != nil {
+		return err
+	}
+	for _, f := range existing {
+		if _, ok := files[filepath.Base(f)]; !ok {
+			if err := os.Remove(f); err != nil {
+				return err
+			}
+		}
+	}
+	for name, src := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), src, 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseVariables returns the variables declared in the variables*.tf files of the module directory, sorted by name.
+func parseVariables(dir string) ([]variable, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "variables*.tf"))
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no variables*.tf files in %s", dir)
+	}
+	parser := hclparse.NewParser()
+	schema := &hcl.BodySchema{
+		Blocks: []hcl.BlockHeaderSchema{{Type: "variable", LabelNames: []string{"name"}}},
+	}
+	varSchema := &hcl.BodySchema{
+		Attributes: []hcl.AttributeSchema{{Name: "type"}, {Name: "default"}, {Name: "description"}},
+	}
+	var vars []variable
+	for _, file := range files {
+		f, diags := parser.ParseHCLFile(file)
+		if diags.HasErrors() {
+			return nil, fmt.Errorf("cannot parse %s, %s", file, diags.Error())
+		}
+		content, _, diags := f.Body.PartialContent(schema)
+		if diags.HasErrors() {
+			return nil, fmt.Errorf("cannot read %s, %s", file, diags.Error())
+		}
+		for _, block := range content.Blocks {
+			v, err := parseVariable(block, varSchema)
+			if err != nil {
+				return nil, fmt.Errorf("cannot read variable %s in %s, %v", block.Labels[0], file, err)
+			}
+			vars = append(vars, v)
+		}
+	}
+	slices.SortFunc(vars, func(a, b variable) int { return strings.Compare(a.Name, b.Name) })
+	return vars, nil
+}
+
+func parseVariable(block *hcl.Block, schema *hcl.BodySchema) (variable, error) {
+	v := variable{Name: block.Labels[0], Type: cty.DynamicPseudoType}
+	content, _, diags := block.Body.PartialContent(schema)
+	if diags.HasErrors() {
+		return v, diags
+	}
+	if attr, ok := content.Attributes["type"]; ok {
+		v.Type, v.Defaults, diags = typeexpr.TypeConstraintWithDefaults(attr.Expr)
+		if diags.HasErrors() {
+			return v, diags
+		}
+	}
+	if attr, ok := content.Attributes["description"]; ok {
+		val, diags := attr.Expr.Value(nil)
+		if diags.HasErrors() {
+			return v, diags
+		}
+		if val.Type() == cty.String && val.IsKnown() && !val.IsNull() {
+			v.Description = val.AsString()
+		}
+	}
+	if attr, ok := content.Attributes["default"]; ok {
+		val, diags := attr.Expr.Value(nil)
+		if diags.HasErrors() {
+			return v, diags
+		}
+		v.Default = &val
+	}
+	return v, nil
+}
+
+// generator writes the Go source for the variables of one module.
+type generator struct {
+	name string
+	dir  string
+	// structs is the source of the object types, in the order they are first referenced.
+	structs   [][]byte
+	typeNames []string
+}
+
+func newGenerator(name, dir string) *generator {
+	return &generator{name: name, dir: dir}
+}
+
+func (g *generator) generate(vars []variable) ([]byte, error) {
+	var b bytes.Buffer
+	varsType := g.name + "Variables"
+	g.typeNames = append(g.typeNames, varsType)
+
+	fmt.Fprintf(&b, "// Code generated by lzinputs from %s. DO NOT EDIT.\n\n", path(g.dir, "variables*.tf"))
+	b.WriteString("package inputs\n\n")
+
+	fmt.Fprintf(&b, "// %s are the input variables of the %s.\n", varsType, moduleDescription(g.dir))
+	fmt.Fprintf(&b, "// Variables that are not set are not passed to Terraform, so take the module default.\n")
+	fmt.Fprintf(&b, "type %s struct {\n", varsType)
+	var builders bytes.Buffer
+	for _, v := range vars {
+		field := goName(v.Name)
+		typ, err := g.goType(g.name+singularIfCollection(field, v.Type), v.Type, v.Defaults)
+		if err != nil {
+			return nil, fmt.Errorf("variable %s, %v", v.Name, err)
+		}
+		fieldType, param := typ, typ
+		if isScalar(v.Type) || v.Type.IsObjectType() {
+			fieldType = "*" + typ
+		}
+		if summary := firstSentence(v.Description); summary != "" {
+			fmt.Fprintf(&b, "\t// %s\n\t//\n", summary)
+		}
+		fmt.Fprintf(&b, "\t// Terraform variable %s, %s.\n", v.Name, describeDefault(v.Default))
+		fmt.Fprintf(&b, "\t%s %s `json:\"%s,omitzero\"`\n", field, fieldType, v.Name)
+
+		fmt.Fprintf(&builders, "\n// With%s sets the %s variable.\n", field, v.Name)
+		fmt.Fprintf(&builders, "func (v *%s) With%s(value %s) *%s {\n", varsType, field, param, varsType)
+		if fieldType != param {
+			fmt.Fprintf(&builders, "\tv.%s = &value\n", field)
+		} else {
+			fmt.Fprintf(&builders, "\tv.%s = value\n", field)
+		}
+		builders.WriteString("\treturn v\n}\n")
+	}
+	b.WriteString("}\n\n")
+
+	fmt.Fprintf(&b, "// New%s returns an empty %s to build with the With methods.\n", varsType, varsType)
+	fmt.Fprintf(&b, "func New%s() *%s {\n\treturn &%s{}\n}\n\n", varsType, varsType, varsType)
+	fmt.Fprintf(&b, "// Vars returns the variables that are set, for setuptest.WithVars.\n")
+	fmt.Fprintf(&b, "func (v *%s) Vars() map[string]any {\n\treturn toVars(v)\n}\n", varsType)
+	b.Write(builders.Bytes())
+	for _, s := range g.structs {
+		b.Write(s)
+	}
+
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("cannot format generated source, %v", err)
+	}
+	return src, nil
+}
+
+// goType returns the Go type for the Terraform type, generating a struct named name for an object type.
+func (g *generator) goType(name string, ty cty.Type, defaults *typeexpr.Defaults) (string, error) {
+	switch {
+	case ty == cty.String:
+		return "string", nil
+	case ty == cty.Number:
+		return "float64", nil
+	case ty == cty.Bool:
+		return "bool", nil
+	case ty == cty.DynamicPseudoType:
+		return "any", nil
+	case ty.IsListType() || ty.IsSetType():
+		elem, err := g.goType(name, ty.ElementType(), childDefaults(defaults, ""))
+		return "[]" + elem, err
+	case ty.IsMapType():
+		elem, err := g.goType(name, ty.ElementType(), childDefaults(defaults, ""))
+		return "map[string]" + elem, err
+	case ty.IsTupleType():
+		return "[]any", nil
+	case ty.IsObjectType():
+		return name, g.goStruct(name, ty, defaults)
+	}
+	return "", fmt.Errorf("unsupported type %s", ty.FriendlyName())
+}
+
+// goStruct generates the struct for the object type.
+// Optional attributes are omitted from the JSON when they are not set, so that Terraform applies the default.
+func (g *generator) goStruct(name string, ty cty.Type, defaults *typeexpr.Defaults) error {
+	if slices.Contains(g.typeNames, name) {
+		return fmt.Errorf("type name %s is generated twice", name)
+	}
+	g.typeNames = append(g.typeNames, name)
+	slot := len(g.structs)
+	g.structs = append(g.structs, nil)
+
+	var b bytes.Buffer
+	attrs := ty.AttributeTypes()
+	names := make([]string, 0, len(attrs))
+	for n := range attrs {
+		names = append(names, n)
+	}
+	slices.Sort(names)
+
+	fmt.Fprintf(&b, "\n// %s is an object in the %s.\n", name, moduleDescription(g.dir))
+	fmt.Fprintf(&b, "type %s struct {\n", name)
+	for _, n := range names {
+		field := goName(n)
+		attrTy := attrs[n]
+		typ, err := g.goType(name+singularIfCollection(field, attrTy), attrTy, childDefaults(defaults, n))
+		if err != nil {
+			return fmt.Errorf("attribute %s, %v", n, err)
+		}
+		if !ty.AttributeOptional(n) {
+			fmt.Fprintf(&b, "\t%s %s `json:\"%s\"`\n", field, typ, n)
+			continue
+		}
+		if isScalar(attrTy) || attrTy.IsObjectType() {
+			typ = "*" + typ
+		}
+		if def := defaultValue(defaults, n); def != nil {
+			if child := childDefaults(defaults, n); child != nil && !def.IsNull() {
+				applied := child.Apply(*def)
+				def = &applied
+			}
+			fmt.Fprintf(&b, "\t// Optional, %s.\n", describeDefault(def))
+		} else {
+			b.WriteString("\t// Optional.\n")
+		}
+		fmt.Fprintf(&b, "\t%s %s `json:\"%s,omitzero\"`\n", field, typ, n)
+	}
+	b.WriteString("}\n")
+	g.structs[slot] = b.Bytes()
+	return nil
+}
+
+func defaultValue(d *typeexpr.Defaults, attr string) *cty.Value {
+	if d == nil {
+		return nil
+	}
+	if v, ok := d.DefaultValues[attr]; ok {
+		return &v
+	}
+	return nil
+}
+
+func childDefaults(d *typeexpr.Defaults, key string) *typeexpr.Defaults {
+	if d == nil {
+		return nil
+	}
+	return d.Children[key]
+}
+
+// isScalar reports whether the Go type of the Terraform type is a value that has to be a pointer to be optional.
+func isScalar(ty cty.Type) bool {
+	return ty == cty.String || ty == cty.Number || ty == cty.Bool
+}
+
+// describeDefault describes the default value of a variable or optional attribute.
+func describeDefault(def *cty.Value) string {
+	switch {
+	case def == nil:
+		return "required"
+	case def.IsNull():
+		return "defaults to null"
+	}
+	b, err := ctyjson.Marshal(*def, def.Type())
+	if err != nil || len(b) > maxDefaultLength {
+		return "has a default"
+	}
+	return "defaults to " + string(b)
+}
+
+// goName converts a snake case Terraform name to an exported Go name.
+func goName(s string) string {
+	var b strings.Builder
+	for _, w := range strings.Split(s, "_") {
+		if w == "" {
+			continue
+		}
+		if i, ok := initialisms[w]; ok {
+			b.WriteString(i)
+			continue
+		}
+		b.WriteString(strings.ToUpper(w[:1]) + w[1:])
+	}
+	return b.String()
+}
+
+// singularIfCollection returns the singular of the Go name when the type is a collection, for the name of its element type.
+func singularIfCollection(name string, ty cty.Type) string {
+	if ty.IsCollectionType() {
+		return singular(name)
+	}
+	return name
+}
+
+// singular returns the singular of a plural English Go name, as used for Terraform collections.
+func singular(name string) string {
+	switch {
+	case strings.HasSuffix(name, "ies"):
+		return strings.TrimSuffix(name, "ies") + "y"
+	case strings.HasSuffix(name, "sses"), strings.HasSuffix(name, "xes"):
+		return strings.TrimSuffix(name, "es")
+	case strings.HasSuffix(name, "s") && !strings.HasSuffix(name, "ss"):
+		return strings.TrimSuffix(name, "s")
+	}
+	return name
+}
+
+var sentenceEnd = regexp.MustCompile(`[.!?](\s+[A-Z]|$)`)
+
+// firstSentence returns the first sentence of the first paragraph of a variable description, on one line.
+func firstSentence(desc string) string {
+	para, _, _ := strings.Cut(strings.TrimSpace(desc), "\n\n")
+	para = strings.Join(strings.Fields(para), " ")
+	if loc := sentenceEnd.FindStringIndex(para); loc != nil {
+		para = para[:loc[0]]
+	}
+	para = strings.TrimRight(para, ":;,. ")
+	if para == "" {
+		return ""
+	}
+	return para + "."
+}
+
+func moduleDescription(dir string) string {
+	if dir == "." {
+		return "root module"
+	}
+	return filepath.Base(dir) + " submodule"
+}
+
+func path(dir, file string) string {
+	if dir == "." {
+		return file
+	}
+	return dir + "/" + file
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestGeneratedInputsUpToDate tests that the inputs package has been regenerated since the variables last changed.
+// Run make generate to fix it.
+func TestGeneratedInputsUpToDate(t *testing.T) {
+	files, err := generateAll("../../..")
+	require.NoError(t, err)
+	for name, want := range files {
+		got, err := os.ReadFile(filepath.Join("../../inputs", name))
+		require.NoError(t, err, "run make generate")
+		assert.Equal(t, string(want), string(got), "%s is out of date, run make generate", name)
+	}
+	existing, err := filepath.Glob(filepath.Join("../../inputs", "*"+generatedSuffix))
+	require.NoError(t, err)
+	assert.Len(t, existing, len(files), "the inputs package has stale generated files, run make generate")
+}
+
+// TestGenerate tests the Go types generated for required, optional and nested variables.
+func TestGenerate(t *testing.T) {
+	vars, err := parseVariables("testdata/module")
+	require.NoError(t, err)
+	src, err := newGenerator("Test", "modules/test").generate(vars)
+	require.NoError(t, err)
+	s := string(src)
+
+	assert.Contains(t, s, "// The name of the thing, e.g. example.\n\t//\n\t// Terraform variable name, required.\n\tName *string `json:\"name,omitzero\"`")
+	assert.Contains(t, s, "// The networks to create.\n\t//\n\t// Terraform variable networks, defaults to {}.\n\tNetworks map[string]TestNetwork `json:\"networks,omitzero\"`")
+	assert.Contains(t, s, "Settings any `json:\"settings,omitzero\"`")
+	assert.Contains(t, s, "func (v *TestVariables) WithName(value string) *TestVariables {\n\tv.Name = &value")
+
+	assert.Contains(t, s, "AddressSpace []string `json:\"address_space\"`")
+	assert.Contains(t, s, "// Optional, defaults to [].\n\tDNSServers []string `json:\"dns_servers,omitzero\"`")
+	assert.Contains(t, s, "// Optional, defaults to {\"enabled\":true}.\n\tPeering *TestNetworkPeering `json:\"peering,omitzero\"`")
+	assert.Less(t, strings.Index(s, "type TestNetwork struct"), strings.Index(s, "type TestNetworkPeering struct"), "outer types should come first")
+	assert.Contains(t, s, "// Optional.\n\tSubnetIDs []string `json:\"subnet_ids,omitzero\"`")
+	assert.Contains(t, s, "type TestNetworkPeering struct {\n\t// Optional, defaults to true.\n\tEnabled *bool `json:\"enabled,omitzero\"`")
+}
+
+// TestNames tests the conversion of Terraform names to Go names.
+func TestNames(t *testing.T) {
+	assert.Equal(t, "HubNetworkResourceID", goName("hub_network_resource_id"))
+	assert.Equal(t, "EnableOnlyIPv6Peering", goName("enable_only_ipv6_peering"))
+	assert.Equal(t, "Identity", singular("Identities"))
+	assert.Equal(t, "AddressPrefix", singular("AddressPrefixes"))
+	assert.Equal(t, "Subnet", singular("Subnets"))
+	assert.Equal(t, "Access", singular("Access"))
+}
@@ -0,0 +1,37 @@
+// Command lzinputs generates the typed Go input variables in the inputs package
+// from the variables*.tf files of the root module and its submodules.
+//
+// Each module gets a <Name>Variables struct, with a fluent builder whose Vars method
+// returns the map[string]any consumed by setuptest.WithVars,
+// and a struct for every object type in the variable types.
+// Run it with make generate after changing a variable:
+//
+//	go run ./cmd/lzinputs -module .. -out ./inputs
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+)
+
+func main() {
+	log.SetFlags(0)
+	if err := run(os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run(args []string) error {
+	fs := flag.NewFlagSet("lzinputs", flag.ContinueOnError)
+	moduleDir := fs.String("module", "..", "directory of the root module")
+	outDir := fs.String("out", "./inputs", "directory of the inputs package")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	files, err := generateAll(*moduleDir)
+	if err != nil {
+		return err
+	}
+	return writeFiles(*outDir, files)
+}
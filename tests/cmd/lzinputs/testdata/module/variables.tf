@@ -0,0 +1,26 @@
+variable "name" {
+  type        = string
+  description = <<DESCRIPTION
+The name of the thing, e.g. example. It must be unique.
+
+More detail that is not in the doc comment.
+DESCRIPTION
+}
+
+variable "networks" {
+  type = map(object({
+    address_space = list(string)
+    dns_servers   = optional(list(string), [])
+    peering = optional(object({
+      enabled = optional(bool, true)
+    }), {})
+    subnet_ids = optional(set(string))
+  }))
+  default     = {}
+  description = "The networks to create:"
+}
+
+variable "settings" {
+  type    = any
+  default = null
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/subscription/armsubscription"
+	"github.com/Azure/terraform-azurerm-lz-vending/tests/azureutils"
+	"github.com/google/uuid"
+)
+
+// Actions taken, or that would be taken in a dry run, for each resource in the report.
+const (
+	actionCancel = "cancel"
+	actionDelete = "delete"
+	actionSkip   = "skip"
+)
+
+// options configures a janitor run.
+type options struct {
+	// MinAge is the age a resource must reach before it is removed.
+	MinAge time.Duration
+	// NamePattern matches the names of test subscriptions and aliases.
+	NamePattern *regexp.Regexp
+	// ResourceGroupPattern matches the names of test resource groups.
+	ResourceGroupPattern *regexp.Regexp
+	// ResourceGroupSubscriptions are the subscriptions searched for test resource groups.
+	ResourceGroupSubscriptions []uuid.UUID
+	// IncludeUnknownAge removes matching resources whose age cannot be determined.
+	IncludeUnknownAge bool
+	// DryRun reports what would be removed without removing anything.
+	DryRun bool
+	// RemoveLocks removes the management locks on the matching resource groups and their resources before deleting them.
+	RemoveLocks bool
+}
+
+// report is the JSON report of a janitor run.
+type report struct {
+	DryRun         bool      `json:"dry_run"`
+	MinAge         string    `json:"min_age"`
+	StartTime      time.Time `json:"start_time"`
+	Subscriptions  []item    `json:"subscriptions"`
+	Aliases        []item    `json:"aliases"`
+	ResourceGroups []item    `json:"resource_groups"`
+}
+
+// item is a resource that matched the test naming conventions.
+type item struct {
+	ID          string     `json:"id"`
+	Name        string     `json:"name"`
+	CreatedTime *time.Time `json:"created_time,omitempty"`
+	Action      string     `json:"action"`
+	Reason      string     `json:"reason,omitempty"`
+	Error       string     `json:"error,omitempty"`
+}
+
+// errors returns the number of items that could not be removed.
+func (r *report) errors() int {
+	n := 0
+	for _, items := range [][]item{r.Subscriptions, r.Aliases, r.ResourceGroups} {
+		for _, i := range items {
+			if i.Error != "" {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// janitor removes the subscriptions, aliases and resource groups left behind by failed deployment tests.
+type janitor struct {
+	f    *azureutils.ClientFactory
+	opts options
+	now  time.Time
+	t    azureutils.TestingT
+}
+
+// run finds and removes the orphaned test resources.
+// Subscriptions are cancelled before their aliases are deleted.
+// Errors removing individual resources are recorded in the report rather than returned.
+func (j *janitor) run(ctx context.Context) (*report, error) {
+	r := &report{
+		DryRun:         j.opts.DryRun,
+		MinAge:         j.opts.MinAge.String(),
+		StartTime:      j.now,
+		Subscriptions:  make([]item, 0),
+		Aliases:        make([]item, 0),
+		ResourceGroups: make([]item, 0),
+	}
+
+	aliases, err := j.f.ListAliases(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// The subscription API does not report a creation time, so use the time the alias was created.
+	created := make(map[string]time.Time)
+	for _, a := range aliases {
+		if t, ok := aliasCreatedTime(a); ok && a.Properties != nil && a.Properties.SubscriptionID != nil {
+			created[strings.ToLower(*a.Properties.SubscriptionID)] = t
+		}
+	}
+
+	subs, err := j.f.ListSubscriptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// Aliases of subscriptions that could not be cancelled are kept, as they give the subscription age.
+	failed := make(map[string]bool)
+	for _, s := range subs {
+		if s.DisplayName == nil || s.SubscriptionID == nil || !j.opts.NamePattern.MatchString(*s.DisplayName) {
+			continue
+		}
+		subID := strings.ToLower(*s.SubscriptionID)
+		it := j.newItem("/subscriptions/"+subID, *s.DisplayName, created[subID], actionCancel)
+		if s.State != nil && *s.State != armsubscription.SubscriptionStateEnabled {
+			it.Action = actionSkip
+			it.Reason = fmt.Sprintf("subscription is %s", *s.State)
+		}
+		if it.Action == actionCancel && !j.opts.DryRun {
+			id, err := uuid.Parse(*s.SubscriptionID)
+			if err == nil {
+				err = j.f.CancelSubscription(ctx, j.t, &id)
+			}
+			it.setError(err)
+			failed[subID] = err != nil
+		}
+		r.Subscriptions = append(r.Subscriptions, it)
+	}
+
+	for _, a := range aliases {
+		if a.Name == nil || !j.opts.NamePattern.MatchString(*a.Name) {
+			continue
+		}
+		t, _ := aliasCreatedTime(a)
+		it := j.newItem("/providers/Microsoft.Subscription/aliases/"+*a.Name, *a.Name, t, actionDelete)
+		if a.Properties != nil && a.Properties.SubscriptionID != nil && failed[strings.ToLower(*a.Properties.SubscriptionID)] {
+			it.Action = actionSkip
+			it.Reason = "subscription could not be cancelled"
+		}
+		if it.Action == actionDelete && !j.opts.DryRun {
+			it.setError(j.f.DeleteAlias(ctx, *a.Name))
+		}
+		r.Aliases = append(r.Aliases, it)
+	}
+
+	for _, subID := range j.opts.ResourceGroupSubscriptions {
+		rgs, err := j.f.ListResourceGroups(ctx, subID)
+		if err != nil {
+			return nil, err
+		}
+		for _, rg := range rgs {
+			if rg.Name == nil || !j.opts.ResourceGroupPattern.MatchString(*rg.Name) {
+				continue
+			}
+			t, err := j.f.ResourceGroupCreatedTime(ctx, *rg.Name, subID)
+			if err != nil {
+				r.ResourceGroups = append(r.ResourceGroups, item{
+					ID:     *rg.ID,
+					Name:   *rg.Name,
+					Action: actionSkip,
+					Error:  fmt.Sprintf("cannot determine resource group age: %v", err),
+				})
+				continue
+			}
+			it := j.newItem(*rg.ID, *rg.Name, t, actionDelete)
+			if it.Action == actionDelete && !j.opts.DryRun {
+				it.setError(j.f.DeleteResourceGroupWithOptions(ctx, *rg.Name, subID, &azureutils.DeleteResourceGroupOptions{
+					RemoveLocks: j.opts.RemoveLocks,
+				}))
+			}
+			r.ResourceGroups = append(r.ResourceGroups, it)
+		}
+	}
+
+	sortItems(r.Subscriptions)
+	sortItems(r.Aliases)
+	sortItems(r.ResourceGroups)
+	return r, nil
+}
+
+// newItem returns a report item with the supplied action,
+// or skipped if the resource is too young or its age is unknown.
+func (j *janitor) newItem(id, name string, created time.Time, action string) item {
+	it := item{
+		ID:     id,
+		Name:   name,
+		Action: action,
+	}
+	switch {
+	case created.IsZero():
+		if !j.opts.IncludeUnknownAge {
+			it.Action = actionSkip
+			it.Reason = "age is unknown"
+		}
+	case j.now.Sub(created) < j.opts.MinAge:
+		it.CreatedTime = &created
+		it.Action = actionSkip
+		it.Reason = fmt.Sprintf("younger than %s", j.opts.MinAge)
+	default:
+		it.CreatedTime = &created
+	}
+	return it
+}
+
+func (it *item) setError(err error) {
+	if err != nil {
+		it.Error = err.Error()
+	}
+}
+
+// aliasCreatedTime returns the creation time of the alias from its system data or properties.
+func aliasCreatedTime(a *armsubscription.AliasResponse) (time.Time, bool) {
+	if a.SystemData != nil && a.SystemData.CreatedAt != nil {
+		return *a.SystemData.CreatedAt, true
+	}
+	if a.Properties != nil && a.Properties.CreatedTime != nil {
+		if t, err := time.Parse(time.RFC3339, *a.Properties.CreatedTime); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func sortItems(items []item) {
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Name < items[j].Name
+	})
+}
+
+// logT implements azureutils.TestingT by writing to the standard logger.
+type logT struct{}
+
+func (logT) Logf(format string, args ...any)   { log.Printf(format, args...) }
+func (logT) Fail()                             {}
+func (logT) FailNow()                          { os.Exit(1) }
+func (logT) Fatal(args ...any)                 { log.Fatal(args...) }
+func (logT) Fatalf(format string, args ...any) { log.Fatalf(format, args...) }
+func (logT) Error(args ...any)                 { log.Print(args...) }
+func (logT) Errorf(format string, args ...any) { log.Printf(format, args...) }
+func (logT) Name() string                      { return "lzjanitor" }
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/Azure/terraform-azurerm-lz-vending/tests/azureutils"
+	"github.com/Azure/terraform-azurerm-lz-vending/tests/fakearm"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	oldSubID   = uuid.MustParse("00000000-0000-0000-0000-00000000000a")
+	youngSubID = uuid.MustParse("00000000-0000-0000-0000-00000000000b")
+	prodSubID  = uuid.MustParse("00000000-0000-0000-0000-00000000000c")
+	testSubID  = uuid.MustParse("00000000-0000-0000-0000-00000000000d")
+)
+
+// newTestJanitor seeds a fake server with test resources of different ages.
+func newTestJanitor(t *testing.T, dryRun bool) (*janitor, *fakearm.Server) {
+	t.Helper()
+	now := time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+	srv := fakearm.NewServer(t, nil)
+
+	srv.AddSubscription(fakearm.Subscription{ID: oldSubID, DisplayName: "testdeploy-0000000a"})
+	srv.AddAlias(fakearm.Alias{Name: "testdeploy-0000000a", SubscriptionID: oldSubID, CreatedTime: now.Add(-72 * time.Hour)})
+	srv.AddResourceGroup(oldSubID, "rg-0000000a", "northeurope")
+	srv.AddSubscription(fakearm.Subscription{ID: youngSubID, DisplayName: "testdeploy-0000000b"})
+	srv.AddAlias(fakearm.Alias{Name: "testdeploy-0000000b", SubscriptionID: youngSubID, CreatedTime: now.Add(-time.Hour)})
+	srv.AddSubscription(fakearm.Subscription{ID: prodSubID, DisplayName: "production"})
+	srv.AddSubscription(fakearm.Subscription{ID: testSubID, DisplayName: "lz-vending-testing"})
+
+	srv.AddResourceGroup(testSubID, "rg-12345678", "northeurope")
+	srv.PutResource("/subscriptions/"+testSubID.String()+"/resourceGroups/rg-12345678/providers/Microsoft.ManagedIdentity/userAssignedIdentities/umi-12345678", map[string]any{
+		"createdTime": now.Add(-48 * time.Hour).Format(time.RFC3339),
+	})
+	srv.AddResourceGroup(testSubID, "rg-87654321", "northeurope")
+	srv.AddResourceGroup(testSubID, "keep-me", "northeurope")
+
+	f := azureutils.NewClientFactory(srv.Credential(), srv.ClientOptions())
+	return &janitor{
+		f: f,
+		opts: options{
+			MinAge:                     24 * time.Hour,
+			NamePattern:                regexp.MustCompile(defaultNamePattern),
+			ResourceGroupPattern:       regexp.MustCompile(defaultResourceGroupPattern),
+			ResourceGroupSubscriptions: []uuid.UUID{testSubID},
+			DryRun:                     dryRun,
+		},
+		now: now,
+		t:   t,
+	}, srv
+}
+
+func actions(items []item) map[string]string {
+	m := make(map[string]string)
+	for _, i := range items {
+		m[i.Name] = i.Action
+	}
+	return m
+}
+
+func TestJanitorDryRun(t *testing.T) {
+	j, srv := newTestJanitor(t, true)
+	r, err := j.run(context.Background())
+	require.NoError(t, err)
+
+	assert.True(t, r.DryRun)
+	assert.Equal(t, map[string]string{
+		"testdeploy-0000000a": actionCancel,
+		"testdeploy-0000000b": actionSkip,
+	}, actions(r.Subscriptions))
+	assert.Equal(t, map[string]string{
+		"testdeploy-0000000a": actionDelete,
+		"testdeploy-0000000b": actionSkip,
+	}, actions(r.Aliases))
+	// The empty resource group has no resources to give its age.
+	assert.Equal(t, map[string]string{
+		"rg-12345678": actionDelete,
+		"rg-87654321": actionSkip,
+	}, actions(r.ResourceGroups))
+	assert.Equal(t, "age is unknown", r.ResourceGroups[1].Reason)
+
+	assert.Zero(t, srv.RequestCount("POST", "/cancel"))
+	assert.Zero(t, srv.RequestCount("DELETE", ""))
+}
+
+func TestJanitorRemoves(t *testing.T) {
+	j, srv := newTestJanitor(t, false)
+	r, err := j.run(context.Background())
+	require.NoError(t, err)
+	assert.Zero(t, r.errors())
+
+	sub, _ := srv.Subscription(oldSubID)
+	assert.Equal(t, fakearm.SubscriptionStateWarned, sub.State)
+	assert.Empty(t, srv.ResourceGroups(oldSubID))
+	_, ok := srv.Alias("testdeploy-0000000a")
+	assert.False(t, ok)
+
+	sub, _ = srv.Subscription(youngSubID)
+	assert.Equal(t, fakearm.SubscriptionStateEnabled, sub.State)
+	_, ok = srv.Alias("testdeploy-0000000b")
+	assert.True(t, ok)
+
+	assert.Equal(t, []string{"keep-me", "rg-87654321"}, srv.ResourceGroups(testSubID))
+}
+
+// TestJanitorKeepsAliasOfFailedCancel tests that the alias is kept when the subscription cannot be cancelled,
+// so that the subscription age is still known on the next run.
+func TestJanitorKeepsAliasOfFailedCancel(t *testing.T) {
+	j, srv := newTestJanitor(t, false)
+	srv.InjectFault(fakearm.Fault{
+		Method:       "GET",
+		PathContains: "/subscriptions/" + oldSubID.String(),
+		StatusCode:   403,
+		Code:         "AuthorizationFailed",
+		Message:      "not allowed",
+	})
+	r, err := j.run(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, r.errors())
+	assert.Contains(t, r.Subscriptions[0].Error, "AuthorizationFailed")
+	assert.Equal(t, actionSkip, r.Aliases[0].Action)
+	_, ok := srv.Alias("testdeploy-0000000a")
+	assert.True(t, ok)
+}
+
+func TestJanitorIncludeUnknownAge(t *testing.T) {
+	j, srv := newTestJanitor(t, false)
+	j.opts.IncludeUnknownAge = true
+	_, err := j.run(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"keep-me"}, srv.ResourceGroups(testSubID))
+}
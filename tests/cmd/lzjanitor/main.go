@@ -0,0 +1,121 @@
+// Command lzjanitor removes the subscriptions, aliases and resource groups left behind
+// by deployment tests that did not clean up after themselves, e.g. because they panicked.
+//
+// Resources are matched by the test naming conventions and removed once they are older than -min-age.
+// Subscriptions are cancelled, which also deletes their resource groups.
+// A JSON report of every matching resource is written to stdout, or the file given by -report.
+//
+// Authentication uses the same environment variables as the deployment tests.
+//
+//	go run ./cmd/lzjanitor -dry-run
+//	go run ./cmd/lzjanitor -min-age 48h -report report.json
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/Azure/terraform-azurerm-lz-vending/tests/azureutils"
+	"github.com/google/uuid"
+)
+
+const (
+	// defaultNamePattern matches the names generated by getValidInputVariables in the deployment tests.
+	defaultNamePattern = `^testdeploy-[0-9a-f]{8}$`
+	// defaultResourceGroupPattern matches the resource groups created by the deployment tests.
+	defaultResourceGroupPattern = `^(testdeploy-[0-9a-f]{8}(-hub)?|rg-[0-9a-f]{8})$`
+)
+
+func main() {
+	log.SetFlags(log.LstdFlags)
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("lzjanitor", flag.ContinueOnError)
+	minAge := fs.Duration("min-age", 24*time.Hour, "only remove resources older than this")
+	namePattern := fs.String("name-pattern", defaultNamePattern, "regular expression matching test subscription and alias names")
+	rgPattern := fs.String("resource-group-pattern", defaultResourceGroupPattern, "regular expression matching test resource group names")
+	rgSubs := fs.String("resource-group-subscriptions", os.Getenv("AZURE_SUBSCRIPTION_ID"), "comma separated subscription IDs to search for test resource groups")
+	unknownAge := fs.Bool("include-unknown-age", false, "also remove matching resources whose age cannot be determined")
+	dryRun := fs.Bool("dry-run", false, "report what would be removed without removing anything")
+	removeLocks := fs.Bool("remove-locks", false, "remove the management locks on matching resource groups before deleting them")
+	reportPath := fs.String("report", "-", "path of the JSON report, - for stdout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	opts := options{
+		MinAge:            *minAge,
+		IncludeUnknownAge: *unknownAge,
+		DryRun:            *dryRun,
+		RemoveLocks:       *removeLocks,
+	}
+	var err error
+	if opts.NamePattern, err = regexp.Compile(*namePattern); err != nil {
+		return fmt.Errorf("invalid -name-pattern: %v", err)
+	}
+	if opts.ResourceGroupPattern, err = regexp.Compile(*rgPattern); err != nil {
+		return fmt.Errorf("invalid -resource-group-pattern: %v", err)
+	}
+	for _, s := range strings.Split(*rgSubs, ",") {
+		if s = strings.TrimSpace(s); s == "" {
+			continue
+		}
+		id, err := uuid.Parse(s)
+		if err != nil {
+			return fmt.Errorf("invalid subscription ID %q in -resource-group-subscriptions: %v", s, err)
+		}
+		opts.ResourceGroupSubscriptions = append(opts.ResourceGroupSubscriptions, id)
+	}
+
+	f, err := azureutils.DefaultClientFactory()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	j := &janitor{
+		f:    f,
+		opts: opts,
+		now:  time.Now(),
+		t:    logT{},
+	}
+	r, err := j.run(ctx)
+	if err != nil {
+		return err
+	}
+
+	w := stdout
+	if *reportPath != "-" {
+		file, err := os.Create(*reportPath)
+		if err != nil {
+			return fmt.Errorf("cannot create report: %v", err)
+		}
+		defer file.Close()
+		w = file
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(r); err != nil {
+		return fmt.Errorf("cannot write report: %v", err)
+	}
+
+	if n := r.errors(); n > 0 {
+		return fmt.Errorf("%d resources could not be removed, see the report for details", n)
+	}
+	return nil
+}
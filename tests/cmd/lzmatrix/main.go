@@ -0,0 +1,93 @@
+// Command lzmatrix runs the plan tests once for each provider set of a version matrix,
+// and writes a Markdown compatibility table of the tests that did not pass with every set.
+//
+// The matrix is a JSON file, provider-matrix.json by default, listing the packages to test and the provider sets.
+// Each set maps the local name of a provider in the generated terraform.tf (azapi, azurerm, modtm, random or time)
+// to a version or a version constraint; providers that a set does not mention use the defaults of the tests.
+// The terraform.tf and the go test -json output of each set are written to the -results directory.
+// Deployment tests are skipped.
+//
+//	go run ./cmd/lzmatrix -out provider-matrix.md
+//	go run ./cmd/lzmatrix -sets min -run ^TestVirtualNetwork
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+)
+
+func main() {
+	log.SetFlags(log.LstdFlags)
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("lzmatrix", flag.ContinueOnError)
+	configPath := fs.String("config", "provider-matrix.json", "path of the matrix configuration")
+	runPattern := fs.String("run", "", "go test -run pattern, default the run of the configuration")
+	sets := fs.String("sets", "", "comma separated names of the provider sets to run, default all")
+	resultsDir := fs.String("results", "provider-matrix", "directory for the terraform.tf and test output of each set")
+	timeout := fs.String("timeout", "60m", "go test -timeout of each set")
+	all := fs.Bool("all", false, "list every test in the table, not only those that did not pass with every set")
+	outPath := fs.String("out", "-", "path of the Markdown table, - for stdout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	if *runPattern != "" {
+		cfg.Run = *runPattern
+	}
+	if *sets != "" {
+		if cfg.Sets, err = cfg.selectSets(strings.Split(*sets, ",")); err != nil {
+			return err
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	results := make([]setResult, 0, len(cfg.Sets))
+	for _, set := range cfg.Sets {
+		log.Printf("running provider set %s", set.Name)
+		res, err := runSet(ctx, cfg, set, *resultsDir, *timeout)
+		if err != nil {
+			return fmt.Errorf("cannot run provider set %s, %v", set.Name, err)
+		}
+		results = append(results, res)
+	}
+
+	out := stdout
+	if *outPath != "-" {
+		f, err := os.Create(*outPath)
+		if err != nil {
+			return fmt.Errorf("cannot create table, %v", err)
+		}
+		defer f.Close()
+		out = f
+	}
+	if err := writeTable(out, results, *all); err != nil {
+		return fmt.Errorf("cannot write table, %v", err)
+	}
+	failed := 0
+	for _, res := range results {
+		if res.count(statusFail) > 0 {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d provider sets had failing tests", failed, len(results))
+	}
+	return nil
+}
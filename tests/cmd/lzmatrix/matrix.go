@@ -0,0 +1,252 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/Azure/terraform-azurerm-lz-vending/tests/utils"
+)
+
+// config is the matrix configuration.
+type config struct {
+	// Packages are the go test package patterns.
+	Packages []string `json:"packages"`
+	// Run is the go test -run pattern.
+	Run  string        `json:"run"`
+	Sets []providerSet `json:"sets"`
+}
+
+// providerSet is a combination of provider versions to run the tests with.
+type providerSet struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// Providers maps the local name of a provider to a version or version constraint.
+	Providers map[string]string `json:"providers"`
+}
+
+var setName = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// loadConfig reads and validates the matrix configuration.
+func loadConfig(path string) (*config, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read matrix configuration, %v", err)
+	}
+	var cfg config
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		return nil, fmt.Errorf("cannot parse matrix configuration %s, %v", path, err)
+	}
+	if len(cfg.Packages) == 0 {
+		cfg.Packages = []string{"./..."}
+	}
+	if cfg.Run == "" {
+		cfg.Run = "^Test"
+	}
+	if len(cfg.Sets) == 0 {
+		return nil, fmt.Errorf("matrix configuration %s has no provider sets", path)
+	}
+	seen := make(map[string]bool)
+	for _, set := range cfg.Sets {
+		if !setName.MatchString(set.Name) {
+			return nil, fmt.Errorf("provider set name %q must only contain letters, digits, '_', '.' and '-'", set.Name)
+		}
+		if seen[set.Name] {
+			return nil, fmt.Errorf("provider set %s is declared more than once", set.Name)
+		}
+		seen[set.Name] = true
+		for name := range set.Providers {
+			if _, ok := utils.ProviderVersionEnvVars[name]; !ok {
+				return nil, fmt.Errorf("provider set %s has unknown provider %s, known providers are %s",
+					set.Name, name, strings.Join(providerNames(), ", "))
+			}
+		}
+	}
+	return &cfg, nil
+}
+
+// selectSets returns the sets with the names, in the order of the configuration.
+func (c *config) selectSets(names []string) ([]providerSet, error) {
+	for _, name := range names {
+		if !slices.ContainsFunc(c.Sets, func(s providerSet) bool { return s.Name == name }) {
+			return nil, fmt.Errorf("provider set %s is not in the matrix configuration", name)
+		}
+	}
+	return slices.DeleteFunc(slices.Clone(c.Sets), func(s providerSet) bool {
+		return !slices.Contains(names, s.Name)
+	}), nil
+}
+
+// providerNames returns the local names of the providers that a set can version, sorted.
+func providerNames() []string {
+	names := make([]string, 0, len(utils.ProviderVersionEnvVars))
+	for name := range utils.ProviderVersionEnvVars {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// env returns the provider version environment variables of the set.
+// Providers that the set does not mention are set to the empty string, so that the defaults apply.
+func (s providerSet) env() map[string]string {
+	env := make(map[string]string, len(utils.ProviderVersionEnvVars))
+	for name, v := range utils.ProviderVersionEnvVars {
+		env[v] = s.Providers[name]
+	}
+	return env
+}
+
+// requiredProviders returns the data of the terraform.tf that the tests generate for the set.
+func (s providerSet) requiredProviders() utils.RequiredProvidersData {
+	env := s.env()
+	return utils.RequiredProvidersDataFromLookup(func(k string) string { return env[k] })
+}
+
+// status is the outcome of a test.
+type status string
+
+const (
+	statusPass status = "pass"
+	statusFail status = "fail"
+	statusSkip status = "skip"
+)
+
+// setResult is the outcome of each test with a provider set, indexed by package and test name.
+type setResult struct {
+	Set      providerSet
+	Versions utils.RequiredProvidersData
+	Tests    map[string]status
+}
+
+func (r setResult) count(s status) int {
+	n := 0
+	for _, ts := range r.Tests {
+		if ts == s {
+			n++
+		}
+	}
+	return n
+}
+
+// runSet writes the terraform.tf of the set to its results directory, then runs the tests with the set
+// and records their outcomes.
+func runSet(ctx context.Context, cfg *config, set providerSet, resultsDir, timeout string) (setResult, error) {
+	res := setResult{Set: set, Versions: set.requiredProviders()}
+	dir := filepath.Join(resultsDir, set.Name)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return res, err
+	}
+	var tf bytes.Buffer
+	if err := utils.GenerateRequiredProviders(res.Versions, &tf); err != nil {
+		return res, err
+	}
+	if err := os.WriteFile(filepath.Join(dir, "terraform.tf"), tf.Bytes(), 0o644); err != nil {
+		return res, err
+	}
+	out, err := os.Create(filepath.Join(dir, "test.json"))
+	if err != nil {
+		return res, err
+	}
+	defer out.Close()
+
+	args := append([]string{"test", "-json", "-run", cfg.Run, "-timeout", timeout}, cfg.Packages...)
+	cmd := exec.CommandContext(ctx, "go", args...)
+	cmd.Env = os.Environ()
+	for k, v := range set.env() {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+	// The matrix is for the plan tests.
+	cmd.Env = append(cmd.Env, "TERRATEST_DEPLOY=")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return res, err
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		return res, err
+	}
+	res.Tests, err = parseTestEvents(io.TeeReader(stdout, out))
+	if err != nil {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		return res, fmt.Errorf("cannot parse go test output, %v", err)
+	}
+	// go test exits with 1 when a test fails, which is recorded in the results.
+	if err := cmd.Wait(); err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) || len(res.Tests) == 0 {
+			return res, fmt.Errorf("go test failed, %v: %s", err, stderr.String())
+		}
+	}
+	return res, nil
+}
+
+// testEvent is an event of go test -json.
+type testEvent struct {
+	Action     string
+	Package    string
+	ImportPath string
+	Test       string
+}
+
+// parseTestEvents returns the outcome of each top level test, indexed by the package base name and test name,
+// e.g. virtualnetwork.TestVirtualNetworkCreateValid.
+// A package that fails without a failing test, e.g. because it does not build, is recorded as a failure of the package.
+func parseTestEvents(r io.Reader) (map[string]status, error) {
+	tests := make(map[string]status)
+	failedTests := make(map[string]bool)
+	var failedPkgs []string
+	dec := json.NewDecoder(r)
+	for {
+		var ev testEvent
+		err := dec.Decode(&ev)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if ev.Action == "build-fail" {
+			failedPkgs = append(failedPkgs, ev.ImportPath)
+			continue
+		}
+		if ev.Test == "" {
+			if ev.Action == "fail" {
+				failedPkgs = append(failedPkgs, ev.Package)
+			}
+			continue
+		}
+		if strings.Contains(ev.Test, "/") {
+			continue
+		}
+		switch status(ev.Action) {
+		case statusPass, statusFail, statusSkip:
+			tests[path.Base(ev.Package)+"."+ev.Test] = status(ev.Action)
+			if ev.Action == "fail" {
+				failedTests[ev.Package] = true
+			}
+		}
+	}
+	for _, pkg := range failedPkgs {
+		// Build failures are reported with the test binary suffix.
+		pkg = strings.Fields(pkg)[0]
+		if !failedTests[pkg] {
+			tests[path.Base(pkg)+" (package)"] = statusFail
+		}
+	}
+	return tests, nil
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Azure/terraform-azurerm-lz-vending/tests/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestLoadConfig tests that the matrix in the repository is valid and that the sets are selected by name.
+func TestLoadConfig(t *testing.T) {
+	cfg, err := loadConfig("../../provider-matrix.json")
+	require.NoError(t, err)
+	require.NotEmpty(t, cfg.Sets)
+
+	sets, err := cfg.selectSets([]string{cfg.Sets[len(cfg.Sets)-1].Name, cfg.Sets[0].Name})
+	require.NoError(t, err)
+	require.Len(t, sets, 2)
+	assert.Equal(t, cfg.Sets[0].Name, sets[0].Name, "the sets should be in the order of the configuration")
+
+	_, err = cfg.selectSets([]string{"nope"})
+	assert.ErrorContains(t, err, "nope")
+}
+
+// TestLoadConfigInvalid tests that unknown providers and fields, and duplicate sets, are errors.
+func TestLoadConfigInvalid(t *testing.T) {
+	cases := map[string]string{
+		"unknown provider": `{"sets": [{"name": "a", "providers": {"azuread": "3.0.0"}}]}`,
+		"unknown field":    `{"sets": [{"name": "a", "provider": {"azapi": "2.5.0"}}]}`,
+		"duplicate set":    `{"sets": [{"name": "a"}, {"name": "a"}]}`,
+		"invalid name":     `{"sets": [{"name": "a b"}]}`,
+		"no sets":          `{"packages": ["./..."]}`,
+	}
+	for name, content := range cases {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "matrix.json")
+			require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+			_, err := loadConfig(path)
+			assert.Error(t, err)
+		})
+	}
+}
+
+// TestProviderSetRequiredProviders tests that a set pins bare versions, keeps constraints,
+// and leaves the providers it does not mention at the defaults.
+func TestProviderSetRequiredProviders(t *testing.T) {
+	t.Setenv(utils.AzureRMVersionEnvVar, "3.0.0")
+	set := providerSet{Name: "a", Providers: map[string]string{"azapi": "2.5.0", "time": "~> 0.9"}}
+	data := set.requiredProviders()
+	assert.Equal(t, "= 2.5.0", data.AzAPIVersion)
+	assert.Equal(t, "~> 4.0", data.AzureRMVersion, "the environment should not leak into the set")
+	assert.Equal(t, "~> 0.9", data.TimeVersion)
+	assert.Empty(t, data.RandomVersion)
+}
+
+// TestParseTestEvents tests that top level tests are recorded, subtests ignored,
+// and a package that does not build is recorded as a failure.
+func TestParseTestEvents(t *testing.T) {
+	f, err := os.Open("testdata/test.json")
+	require.NoError(t, err)
+	defer f.Close()
+	tests, err := parseTestEvents(f)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]status{
+		"virtualnetwork.TestVirtualNetworkCreateValid": statusPass,
+		"virtualnetwork.TestVirtualNetworkPeering":     statusFail,
+		"virtualnetwork.TestDeployVirtualNetworkValid": statusSkip,
+		"budget (package)":                             statusFail,
+	}, tests)
+}
+
+// TestWriteTable tests that only the tests that did not pass with every set are listed.
+func TestWriteTable(t *testing.T) {
+	results := []setResult{
+		{
+			Set:      providerSet{Name: "min"},
+			Versions: utils.RequiredProvidersData{AzAPIVersion: "= 2.5.0", AzureRMVersion: "= 4.0.0"},
+			Tests:    map[string]status{"vnet.TestA": statusPass, "vnet.TestB": statusFail, "vnet.TestDeployC": statusSkip},
+		},
+		{
+			Set:      providerSet{Name: "latest"},
+			Versions: utils.RequiredProvidersData{AzAPIVersion: "~> 2.0", AzureRMVersion: "~> 4.0", TimeVersion: "~> 0.9"},
+			Tests:    map[string]status{"vnet.TestA": statusPass, "vnet.TestB": statusPass, "vnet.TestDeployC": statusSkip},
+		},
+	}
+	var b strings.Builder
+	require.NoError(t, writeTable(&b, results, false))
+	assert.Equal(t, "## Provider sets\n\n"+
+		"| Set | azapi | azurerm | modtm | random | time | Passed | Failed | Skipped |\n"+
+		"|---|---|---|---|---|---|---|---|---|\n"+
+		"| min | `= 2.5.0` | `= 4.0.0` | - | - | - | 1 | 1 | 1 |\n"+
+		"| latest | `~> 2.0` | `~> 4.0` | - | - | `~> 0.9` | 2 | 0 | 1 |\n"+
+		"\n## Tests\n\n"+
+		"| Test | min | latest |\n"+
+		"|---|---|---|\n"+
+		"| vnet.TestB | **fail** | pass |\n", b.String())
+
+	b.Reset()
+	require.NoError(t, writeTable(&b, results, true))
+	assert.Contains(t, b.String(), "| vnet.TestA | pass | pass |")
+	assert.Contains(t, b.String(), "| vnet.TestDeployC | skip | skip |")
+}
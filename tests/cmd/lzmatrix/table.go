@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// writeTable writes the Markdown compatibility table: the provider versions and test counts of each set,
+// then the outcome of each test with each set.
+// Only the tests that did not pass with every set are listed, unless all is true.
+func writeTable(w io.Writer, results []setResult, all bool) error {
+	var b strings.Builder
+	b.WriteString("## Provider sets\n\n")
+	b.WriteString("| Set | azapi | azurerm | modtm | random | time | Passed | Failed | Skipped |\n")
+	b.WriteString("|---|---|---|---|---|---|---|---|---|\n")
+	for _, res := range results {
+		v := res.Versions
+		fmt.Fprintf(&b, "| %s | %s | %s | %s | %s | %s | %d | %d | %d |\n", res.Set.Name,
+			cell(v.AzAPIVersion), cell(v.AzureRMVersion), cell(v.ModtmVersion), cell(v.RandomVersion), cell(v.TimeVersion),
+			res.count(statusPass), res.count(statusFail), res.count(statusSkip))
+	}
+
+	var tests []string
+	for _, res := range results {
+		for name := range res.Tests {
+			if !slices.Contains(tests, name) {
+				tests = append(tests, name)
+			}
+		}
+	}
+	slices.Sort(tests)
+	if !all {
+		tests = slices.DeleteFunc(tests, func(name string) bool {
+			for _, res := range results {
+				if s := res.Tests[name]; s != statusPass && s != statusSkip {
+					return false
+				}
+			}
+			return true
+		})
+	}
+
+	b.WriteString("\n## Tests\n\n")
+	if len(tests) == 0 {
+		b.WriteString("Every test passed with every provider set.\n")
+		_, err := io.WriteString(w, b.String())
+		return err
+	}
+	b.WriteString("| Test |")
+	for _, res := range results {
+		fmt.Fprintf(&b, " %s |", res.Set.Name)
+	}
+	b.WriteString("\n|---|" + strings.Repeat("---|", len(results)) + "\n")
+	for _, name := range tests {
+		fmt.Fprintf(&b, "| %s |", name)
+		for _, res := range results {
+			s, ok := res.Tests[name]
+			switch {
+			case !ok:
+				b.WriteString(" - |")
+			case s == statusFail:
+				b.WriteString(" **fail** |")
+			default:
+				fmt.Fprintf(&b, " %s |", s)
+			}
+		}
+		b.WriteString("\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// cell returns the value for a table cell, escaping the characters that Markdown tables use.
+// A provider that the required providers file does not constrain is shown as -.
+func cell(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return "`" + strings.ReplaceAll(s, "|", `\|`) + "`"
+}
@@ -0,0 +1,88 @@
+// Command lzmirror populates a provider mirror with the providers required by the root module, its submodules
+// and the test fixtures, so that the plan tests can run without network access.
+// It runs terraform get first, so that the providers of the registry modules that they call are included,
+// and keeps the registry modules in the modules directory of the mirror, so that the tests do not download them either.
+//
+// By default it runs terraform providers mirror, which writes a packed mirror for each platform.
+// With -cache it runs terraform init with TF_PLUGIN_CACHE_DIR instead, which fills a plugin cache
+// for the current platform.
+// Point TERRATEST_PROVIDER_MIRROR at the directory to use it in the tests:
+//
+//	go run ./cmd/lzmirror -module .. -dir ~/.terraform.d/mirror
+//	TERRATEST_PROVIDER_MIRROR=~/.terraform.d/mirror make test
+//
+// The versions of azurerm and azapi follow AZURERM_VERSION and AZAPI_VERSION, as in the tests.
+package main
+
+import (
+	"errors"
+	"flag"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Azure/terraform-azurerm-lz-vending/tests/utils"
+)
+
+func main() {
+	log.SetFlags(0)
+	if err := run(os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// platforms is a repeatable flag.
+type platforms []string
+
+func (p *platforms) String() string { return strings.Join(*p, ",") }
+
+func (p *platforms) Set(v string) error {
+	*p = append(*p, v)
+	return nil
+}
+
+func run(args []string) error {
+	fs := flag.NewFlagSet("lzmirror", flag.ContinueOnError)
+	moduleDir := fs.String("module", "..", "directory of the root module")
+	dir := fs.String("dir", os.Getenv(utils.ProviderMirrorEnvVar), "directory of the mirror, default "+utils.ProviderMirrorEnvVar)
+	cache := fs.Bool("cache", false, "fill a plugin cache with terraform init instead of writing a packed mirror")
+	var plats platforms
+	fs.Var(&plats, "platform", "target platform, e.g. linux_amd64, may be repeated, default the current platform")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *dir == "" {
+		return errors.New("no mirror directory, set -dir or " + utils.ProviderMirrorEnvVar)
+	}
+	if *cache && len(plats) > 0 {
+		return errors.New("cannot use -platform with -cache, terraform init only installs the current platform")
+	}
+	if len(plats) == 0 {
+		plats = platforms{utils.ProviderPlatform}
+	}
+
+	modules := filepath.Join(*dir, utils.ModuleMirrorDir)
+	if err := installModules(*moduleDir, modules); err != nil {
+		return err
+	}
+	reqs, err := collectRequirements(*moduleDir, modules)
+	if err != nil {
+		return err
+	}
+	if *cache {
+		err = fillPluginCache(reqs, *dir)
+	} else {
+		err = writeMirror(reqs, *dir, plats)
+	}
+	if err != nil {
+		return err
+	}
+	for _, p := range plats {
+		if err := reqs.CheckMirror(*dir, p); err != nil {
+			return err
+		}
+	}
+	log.Printf("provider mirror %s has %d providers for %s", *dir, len(reqs), plats.String())
+	return nil
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/Azure/terraform-azurerm-lz-vending/tests/utils"
+)
+
+// moduleDirs returns the root module and every module under its modules directory, including the test fixtures in testdata.
+func moduleDirs(moduleDir string) ([]string, error) {
+	dirs := []string{moduleDir}
+	err := filepath.WalkDir(filepath.Join(moduleDir, "modules"), func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if d.Name() == ".terraform" {
+			return filepath.SkipDir
+		}
+		files, err := filepath.Glob(filepath.Join(p, "*.tf"))
+		if err != nil || len(files) == 0 {
+			return err
+		}
+		dirs = append(dirs, p)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cannot read modules, %v", err)
+	}
+	return dirs, nil
+}
+
+// moduleMirrorDir returns the directory in the module mirror of the module in dir,
+// named after its path relative to the root module, e.g. modules_virtualnetwork.
+func moduleMirrorDir(modules, moduleDir, dir string) (string, error) {
+	rel, err := filepath.Rel(moduleDir, dir)
+	if err != nil {
+		return "", err
+	}
+	name := strings.ReplaceAll(filepath.ToSlash(rel), "/", "_")
+	if name == "." {
+		name = "root"
+	}
+	return filepath.Join(modules, name), nil
+}
+
+// installModules installs the remote modules called by each module, directly or through local modules,
+// into the .terraform directory of the module in the module mirror, so that the repository is left as it is.
+// The tests copy that directory to their copy of the module, see utils.ModuleMirrorDir.
+func installModules(moduleDir, modules string) error {
+	dirs, err := moduleDirs(moduleDir)
+	if err != nil {
+		return err
+	}
+	if err := os.RemoveAll(modules); err != nil {
+		return fmt.Errorf("cannot remove module mirror, %v", err)
+	}
+	for _, dir := range dirs {
+		calls, err := utils.RemoteModuleCalls(dir)
+		if err != nil {
+			return err
+		}
+		if len(calls) == 0 {
+			continue
+		}
+		target, err := moduleMirrorDir(modules, moduleDir, dir)
+		if err != nil {
+			return err
+		}
+		dataDir, err := filepath.Abs(filepath.Join(target, ".terraform"))
+		if err != nil {
+			return err
+		}
+		if err := terraform([]string{"TF_DATA_DIR=" + dataDir}, "-chdir="+dir, "get"); err != nil {
+			return err
+		}
+		if err := relativiseModuleManifest(dir, dataDir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// relativiseModuleManifest rewrites the directories of the modules installed in the data directory as .terraform/modules/...,
+// relative to the module in dir, as terraform get writes them without TF_DATA_DIR.
+// Other fields of the manifest are kept as they are.
+func relativiseModuleManifest(dir, dataDir string) error {
+	manifestPath := filepath.Join(dataDir, "modules", "modules.json")
+	b, err := os.ReadFile(manifestPath)
+	if err != nil {
+		return fmt.Errorf("cannot read module manifest, %v", err)
+	}
+	var manifest map[string]any
+	if err := json.Unmarshal(b, &manifest); err != nil {
+		return fmt.Errorf("cannot parse module manifest %s, %v", manifestPath, err)
+	}
+	dir, err = filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	records, _ := manifest["Modules"].([]any)
+	for _, r := range records {
+		record, ok := r.(map[string]any)
+		if !ok {
+			continue
+		}
+		d, ok := record["Dir"].(string)
+		if !ok {
+			continue
+		}
+		d = filepath.FromSlash(d)
+		if !filepath.IsAbs(d) {
+			d = filepath.Join(dir, d)
+		}
+		rel, err := filepath.Rel(dataDir, d)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		record["Dir"] = path.Join(".terraform", filepath.ToSlash(rel))
+	}
+	b, err = json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(manifestPath, b, 0o644); err != nil {
+		return fmt.Errorf("cannot write module manifest, %v", err)
+	}
+	return nil
+}
+
+// collectRequirements returns the providers required by the root module and every module under modules,
+// including the test fixtures in testdata, merged with the required providers file that the tests generate.
+// The remote modules that they call are read from the module mirror modules, written by installModules.
+func collectRequirements(moduleDir, modules string) (utils.ProviderRequirements, error) {
+	dirs, err := moduleDirs(moduleDir)
+	if err != nil {
+		return nil, err
+	}
+	reqs := utils.NewRequiredProvidersData().Requirements()
+	for _, dir := range dirs {
+		target, err := moduleMirrorDir(modules, moduleDir, dir)
+		if err != nil {
+			return nil, err
+		}
+		opts := &utils.ModuleRequiredProvidersOptions{ModulesDir: filepath.Join(target, ".terraform", "modules")}
+		sub, err := utils.ModuleRequiredProvidersWithOptions(dir, opts)
+		if err != nil {
+			return nil, err
+		}
+		reqs.Merge(sub)
+	}
+	return reqs, nil
+}
+
+// requiredProvidersConfig returns a configuration that requires every provider at a version that meets all of its constraints.
+func requiredProvidersConfig(reqs utils.ProviderRequirements) string {
+	var b strings.Builder
+	b.WriteString("terraform {\n  required_providers {\n")
+	names := make(map[string]bool)
+	for _, source := range reqs.Sources() {
+		// Local names must be unique, so use the namespace as well if two providers have the same type.
+		name := path.Base(source)
+		if names[name] {
+			name = path.Base(path.Dir(source)) + "-" + name
+		}
+		names[name] = true
+		if cs := reqs[source]; len(cs) > 0 {
+			fmt.Fprintf(&b, "    %s = {\n      source  = %q\n      version = %q\n", name, source, strings.Join(cs, ", "))
+		} else {
+			fmt.Fprintf(&b, "    %s = {\n      source = %q\n", name, source)
+		}
+		b.WriteString("    }\n")
+	}
+	b.WriteString("  }\n}\n")
+	return b.String()
+}
+
+// writeConfig writes the required providers configuration to a temporary directory.
+func writeConfig(reqs utils.ProviderRequirements) (string, error) {
+	dir, err := os.MkdirTemp("", "lzmirror")
+	if err != nil {
+		return "", fmt.Errorf("cannot create configuration directory, %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "terraform.tf"), []byte(requiredProvidersConfig(reqs)), 0o644); err != nil {
+		os.RemoveAll(dir)
+		return "", fmt.Errorf("cannot write configuration, %v", err)
+	}
+	return dir, nil
+}
+
+// writeMirror writes a packed mirror of the providers for the platforms.
+func writeMirror(reqs utils.ProviderRequirements, mirror string, platforms []string) error {
+	config, err := writeConfig(reqs)
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(config)
+	mirror, err = filepath.Abs(mirror)
+	if err != nil {
+		return err
+	}
+	args := []string{"-chdir=" + config, "providers", "mirror"}
+	for _, p := range platforms {
+		args = append(args, "-platform="+p)
+	}
+	return terraform(nil, append(args, mirror)...)
+}
+
+// fillPluginCache installs the providers for the current platform into the plugin cache.
+func fillPluginCache(reqs utils.ProviderRequirements, cache string) error {
+	config, err := writeConfig(reqs)
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(config)
+	cache, err = filepath.Abs(cache)
+	if err != nil {
+		return err
+	}
+	// Terraform does not create the plugin cache directory.
+	if err := os.MkdirAll(cache, 0o755); err != nil {
+		return fmt.Errorf("cannot create plugin cache, %v", err)
+	}
+	return terraform([]string{"TF_PLUGIN_CACHE_DIR=" + cache}, "-chdir="+config, "init", "-backend=false", "-input=false")
+}
+
+func terraform(env []string, args ...string) error {
+	cmd := exec.Command("terraform", args...)
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("cannot run terraform %s, %v", strings.Join(args[1:], " "), err)
+	}
+	return nil
+}
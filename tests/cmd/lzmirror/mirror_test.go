@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Azure/terraform-azurerm-lz-vending/tests/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestCollectRequirements tests that the providers of the repository are found,
+// including those only used by a submodule, those of the registry modules, and those of the generated required providers file.
+func TestCollectRequirements(t *testing.T) {
+	t.Setenv("AZURERM_VERSION", "")
+	t.Setenv("AZAPI_VERSION", "")
+	reqs, err := collectRequirements("../../..", newTestModules(t))
+	require.NoError(t, err)
+	for _, source := range []string{
+		"registry.terraform.io/example/registry",
+		"registry.terraform.io/azure/azapi",
+		"registry.terraform.io/hashicorp/azurerm",
+		"registry.terraform.io/hashicorp/random",
+		"registry.terraform.io/hashicorp/time",
+	} {
+		assert.Contains(t, reqs, source)
+	}
+	assert.Contains(t, reqs["registry.terraform.io/hashicorp/azurerm"], "~> 4.0")
+	assert.Contains(t, reqs["registry.terraform.io/azure/azapi"], "~> 2.5")
+}
+
+// newTestModules returns a module mirror, as written by installModules, with the registry modules
+// called by the modules of the repository, each of which requires example/registry.
+func newTestModules(t *testing.T) string {
+	root := "../../.."
+	mirror := t.TempDir()
+	dirs, err := moduleDirs(root)
+	require.NoError(t, err)
+	for _, d := range dirs {
+		calls, err := utils.RemoteModuleCalls(d)
+		require.NoError(t, err)
+		if len(calls) == 0 {
+			continue
+		}
+		target, err := moduleMirrorDir(mirror, root, d)
+		require.NoError(t, err)
+		modulesDir := filepath.Join(target, ".terraform", "modules")
+		modules := []map[string]string{{"Key": "", "Source": "", "Dir": "."}}
+		for key, source := range map[string]string{
+			"role_definitions": "registry.terraform.io/Azure/avm-utl-roledefinitions/azure",
+			"virtual_networks": "registry.terraform.io/Azure/avm-res-network-virtualnetwork/azurerm",
+			"peering":          "registry.terraform.io/Azure/avm-res-network-virtualnetwork/azurerm//modules/peering",
+		} {
+			dir := filepath.Join(modulesDir, key)
+			require.NoError(t, os.MkdirAll(dir, 0o755))
+			require.NoError(t, os.WriteFile(filepath.Join(dir, "terraform.tf"),
+				[]byte("terraform {\n  required_providers {\n    registry = {\n      source = \"example/registry\"\n    }\n  }\n}\n"), 0o644))
+			modules = append(modules, map[string]string{"Key": key, "Source": source, "Dir": ".terraform/modules/" + key})
+		}
+		b, err := json.Marshal(map[string]any{"Modules": modules})
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(filepath.Join(modulesDir, "modules.json"), b, 0o644))
+	}
+	return mirror
+}
+
+// TestCollectRequirementsNotInstalled tests that the registry modules must be installed.
+func TestCollectRequirementsNotInstalled(t *testing.T) {
+	_, err := collectRequirements("../../..", t.TempDir())
+	assert.ErrorContains(t, err, "Azure/avm-utl-roledefinitions/azure")
+}
+
+// TestModuleMirrorDir tests that the modules are named after their path relative to the root module.
+func TestModuleMirrorDir(t *testing.T) {
+	dir, err := moduleMirrorDir("mirror", "..", "..")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join("mirror", "root"), dir)
+	dir, err = moduleMirrorDir("mirror", "..", filepath.Join("..", "modules", "virtualnetwork"))
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join("mirror", "modules_virtualnetwork"), dir)
+}
+
+// TestRelativiseModuleManifest tests that the directories of the modules installed in the data directory
+// are rewritten relative to the module, and that the local modules and other fields are kept.
+func TestRelativiseModuleManifest(t *testing.T) {
+	dir := t.TempDir()
+	dataDir := filepath.Join(t.TempDir(), ".terraform")
+	modulesDir := filepath.Join(dataDir, "modules")
+	require.NoError(t, os.MkdirAll(modulesDir, 0o755))
+	manifest := map[string]any{"Modules": []map[string]string{
+		{"Key": "", "Source": "", "Dir": "."},
+		{"Key": "local", "Source": "./local", "Dir": "local"},
+		{"Key": "local.vnet", "Source": "registry.terraform.io/Azure/avm-res-network-virtualnetwork/azurerm", "Version": "0.8.1", "Dir": filepath.Join(modulesDir, "local.vnet")},
+		{"Key": "local.vnet.subnet", "Source": "./modules/subnet", "Dir": filepath.Join(modulesDir, "local.vnet", "modules", "subnet")},
+	}}
+	b, err := json.Marshal(manifest)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(modulesDir, "modules.json"), b, 0o644))
+
+	require.NoError(t, relativiseModuleManifest(dir, dataDir))
+	b, err = os.ReadFile(filepath.Join(modulesDir, "modules.json"))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"Modules": [
+  {"Key": "", "Source": "", "Dir": "."},
+  {"Key": "local", "Source": "./local", "Dir": "local"},
+  {"Key": "local.vnet", "Source": "registry.terraform.io/Azure/avm-res-network-virtualnetwork/azurerm", "Version": "0.8.1", "Dir": ".terraform/modules/local.vnet"},
+  {"Key": "local.vnet.subnet", "Source": "./modules/subnet", "Dir": ".terraform/modules/local.vnet/modules/subnet"}
+]}`, string(b))
+}
+
+// TestRequiredProvidersConfig tests that the constraints are joined and that local names are unique.
+func TestRequiredProvidersConfig(t *testing.T) {
+	reqs := make(utils.ProviderRequirements)
+	require.NoError(t, reqs.Add("azure/azapi", "~> 2.2", "~> 2.5"))
+	require.NoError(t, reqs.Add("example/azapi"))
+	assert.Equal(t, `terraform {
+  required_providers {
+    azapi = {
+      source  = "registry.terraform.io/azure/azapi"
+      version = "~> 2.2, ~> 2.5"
+    }
+    example-azapi = {
+      source = "registry.terraform.io/example/azapi"
+    }
+  }
+}
+`, requiredProvidersConfig(reqs))
+}
@@ -0,0 +1,75 @@
+package fakearm
+
+import (
+	"net/http"
+)
+
+func (s *Server) identityRoutes() []route {
+	return []route{
+		{http.MethodGet, "{}/v2.0/.well-known/openid-configuration", s.openIDConfiguration},
+		{http.MethodPost, "{}/oauth2/v2.0/token", s.token},
+		{http.MethodGet, "oidc/token", s.oidcToken},
+		{http.MethodPost, "oidc/azuredevops", s.azureDevOpsOidcToken},
+	}
+}
+
+// openIDConfiguration serves the tenant discovery document used by MSAL.
+func (s *Server) openIDConfiguration(w http.ResponseWriter, _ *http.Request, params []string) {
+	base := s.srv.URL + "/" + params[0]
+	writeJSON(w, http.StatusOK, map[string]any{
+		"authorization_endpoint": base + "/oauth2/v2.0/authorize",
+		"token_endpoint":         base + "/oauth2/v2.0/token",
+		"issuer":                 base + "/v2.0",
+	})
+}
+
+// token issues AccessToken for any client assertion, and records the assertion.
+func (s *Server) token(w http.ResponseWriter, r *http.Request, _ []string) {
+	if err := r.ParseForm(); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+	assertion := r.PostForm.Get("client_assertion")
+	if assertion == "" {
+		writeError(w, http.StatusBadRequest, "invalid_client", "client_assertion is required")
+		return
+	}
+	s.assertions = append(s.assertions, assertion)
+	writeJSON(w, http.StatusOK, map[string]any{
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"access_token": AccessToken,
+	})
+}
+
+// oidcToken emulates the GitHub Actions ID token request endpoint.
+func (s *Server) oidcToken(w http.ResponseWriter, r *http.Request, _ []string) {
+	if r.Header.Get("Authorization") != "Bearer "+OidcRequestToken {
+		writeError(w, http.StatusUnauthorized, "Unauthorized", "invalid request token")
+		return
+	}
+	if r.URL.Query().Get("audience") == "" {
+		writeError(w, http.StatusBadRequest, "BadRequest", "audience is required")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"count": 1,
+		"value": OidcIDToken,
+	})
+}
+
+// azureDevOpsOidcToken emulates the Azure DevOps pipeline OIDC token endpoint.
+// The token is only issued for AzureDevOpsServiceConnectionID.
+func (s *Server) azureDevOpsOidcToken(w http.ResponseWriter, r *http.Request, _ []string) {
+	if r.Header.Get("Authorization") != "Bearer "+OidcRequestToken {
+		writeError(w, http.StatusUnauthorized, "Unauthorized", "invalid system access token")
+		return
+	}
+	if id := r.URL.Query().Get("serviceConnectionId"); id != AzureDevOpsServiceConnectionID {
+		writeError(w, http.StatusNotFound, "NotFound", "service connection "+id+" not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"oidcToken": OidcIDToken,
+	})
+}
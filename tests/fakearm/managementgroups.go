@@ -0,0 +1,244 @@
+package fakearm
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+const managementGroupIDPrefix = "/providers/Microsoft.Management/managementGroups/"
+
+// ManagementGroup is the fake server's view of a management group.
+type ManagementGroup struct {
+	Name        string
+	DisplayName string
+	// Parent is the name of the parent management group, TenantID for the tenant root group.
+	Parent string
+}
+
+// AddManagementGroup adds or replaces a management group.
+// If the parent is empty, the group is a child of the tenant root group.
+func (s *Server) AddManagementGroup(mg ManagementGroup) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if mg.Parent == "" {
+		mg.Parent = TenantID
+	}
+	s.managementGroups[key(mg.Name)] = &mg
+}
+
+// ManagementGroups returns the names of the management groups, sorted.
+// The tenant root group is not included.
+func (s *Server) ManagementGroups() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]string, 0, len(s.managementGroups))
+	for _, mg := range s.managementGroups {
+		out = append(out, mg.Name)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// SetSubscriptionManagementGroup moves the subscription to the management group,
+// without any simulated eventual consistency.
+func (s *Server) SetSubscriptionManagementGroup(subID uuid.UUID, mg string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if sub, ok := s.subscriptions[key(subID.String())]; ok {
+		sub.ManagementGroup = mg
+	}
+}
+
+func (s *Server) getManagementGroupSubscription(w http.ResponseWriter, _ *http.Request, params []string) {
+	mg, subID := params[0], params[1]
+	sub, ok := s.subscriptions[key(subID)]
+	if !ok || !strings.EqualFold(sub.ManagementGroup, mg) {
+		writeNotFound(w, "NotFound", "Subscription '%s' not found in management group '%s'.", subID, mg)
+		return
+	}
+	if n := s.mgReadsRemaining[key(subID)]; n > 0 {
+		s.mgReadsRemaining[key(subID)] = n - 1
+		writeNotFound(w, "NotFound", "Subscription '%s' not found in management group '%s'.", subID, mg)
+		return
+	}
+	writeJSON(w, http.StatusOK, managementGroupSubscriptionBody(sub, mg))
+}
+
+func (s *Server) putManagementGroupSubscription(w http.ResponseWriter, _ *http.Request, params []string) {
+	mg, subID := params[0], params[1]
+	sub, ok := s.subscriptions[key(subID)]
+	if !ok {
+		writeNotFound(w, "SubscriptionNotFound", "The subscription '%s' could not be found.", subID)
+		return
+	}
+	sub.ManagementGroup = mg
+	s.mgReadsRemaining[key(subID)] = s.opts.ManagementGroupReadDelay
+	writeJSON(w, http.StatusOK, managementGroupSubscriptionBody(sub, mg))
+}
+
+// deleteManagementGroupSubscription removes the subscription from the management group,
+// which in Azure moves it back to the tenant root group.
+func (s *Server) deleteManagementGroupSubscription(w http.ResponseWriter, _ *http.Request, params []string) {
+	mg, subID := params[0], params[1]
+	sub, ok := s.subscriptions[key(subID)]
+	if !ok || !strings.EqualFold(sub.ManagementGroup, mg) {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	sub.ManagementGroup = ""
+	w.WriteHeader(http.StatusOK)
+}
+
+func managementGroupSubscriptionBody(sub *Subscription, mg string) map[string]any {
+	return map[string]any{
+		"id":   managementGroupIDPrefix + mg + "/subscriptions/" + sub.ID.String(),
+		"name": sub.ID.String(),
+		"type": "Microsoft.Management/managementGroups/subscriptions",
+		"properties": map[string]any{
+			"displayName": sub.DisplayName,
+			"state":       sub.State,
+			"parent": map[string]any{
+				"id": managementGroupIDPrefix + mg,
+			},
+		},
+	}
+}
+
+// getManagementGroup returns a management group, with its child groups and subscriptions if $expand=children.
+func (s *Server) getManagementGroup(w http.ResponseWriter, r *http.Request, params []string) {
+	name := params[0]
+	mg, ok := s.managementGroup(name)
+	if !ok {
+		writeNotFound(w, "NotFound", "Management group '%s' not found.", name)
+		return
+	}
+	if n := s.mgReadsRemaining[key("managementgroups", name)]; n > 0 {
+		s.mgReadsRemaining[key("managementgroups", name)] = n - 1
+		writeNotFound(w, "NotFound", "Management group '%s' not found.", name)
+		return
+	}
+	body := managementGroupBody(mg)
+	if strings.EqualFold(r.URL.Query().Get("$expand"), "children") {
+		body["properties"].(map[string]any)["children"] = s.managementGroupChildren(mg.Name)
+	}
+	writeJSON(w, http.StatusOK, body)
+}
+
+func (s *Server) putManagementGroup(w http.ResponseWriter, r *http.Request, params []string) {
+	var req struct {
+		Properties struct {
+			DisplayName string `json:"displayName"`
+			Details     struct {
+				Parent struct {
+					ID string `json:"id"`
+				} `json:"parent"`
+			} `json:"details"`
+		} `json:"properties"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "InvalidRequestContent", err.Error())
+		return
+	}
+	parent := TenantID
+	if id := req.Properties.Details.Parent.ID; id != "" {
+		parent = id[strings.LastIndex(id, "/")+1:]
+	}
+	if _, ok := s.managementGroup(parent); !ok {
+		writeNotFound(w, "NotFound", "Management group '%s' not found.", parent)
+		return
+	}
+	mg := &ManagementGroup{Name: params[0], DisplayName: req.Properties.DisplayName, Parent: parent}
+	if mg.DisplayName == "" {
+		mg.DisplayName = mg.Name
+	}
+	s.managementGroups[key(mg.Name)] = mg
+	s.mgReadsRemaining[key("managementgroups", mg.Name)] = s.opts.ManagementGroupReadDelay
+	writeJSON(w, http.StatusOK, managementGroupBody(mg))
+}
+
+// deleteManagementGroup deletes a management group, which fails if it has child groups or subscriptions.
+func (s *Server) deleteManagementGroup(w http.ResponseWriter, _ *http.Request, params []string) {
+	mg, ok := s.managementGroups[key(params[0])]
+	if !ok {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if len(s.managementGroupChildren(mg.Name)) > 0 {
+		writeError(w, http.StatusBadRequest, "BadRequest", "Cannot delete a management group that has child management groups or subscriptions.")
+		return
+	}
+	s.startOperation(w, func() {
+		delete(s.managementGroups, key(mg.Name))
+	})
+}
+
+// managementGroup returns the management group with the supplied name, including the tenant root group.
+func (s *Server) managementGroup(name string) (*ManagementGroup, bool) {
+	if strings.EqualFold(name, TenantID) {
+		return &ManagementGroup{Name: TenantID, DisplayName: "Tenant Root Group"}, true
+	}
+	mg, ok := s.managementGroups[key(name)]
+	return mg, ok
+}
+
+// managementGroupChildren returns the child groups and subscriptions of a management group, sorted by name.
+func (s *Server) managementGroupChildren(name string) []any {
+	var groups, subs []string
+	for _, mg := range s.managementGroups {
+		if strings.EqualFold(mg.Parent, name) {
+			groups = append(groups, mg.Name)
+		}
+	}
+	for _, sub := range s.subscriptions {
+		parent := sub.ManagementGroup
+		if parent == "" {
+			parent = TenantID
+		}
+		if strings.EqualFold(parent, name) {
+			subs = append(subs, sub.ID.String())
+		}
+	}
+	sort.Strings(groups)
+	sort.Strings(subs)
+	children := make([]any, 0, len(groups)+len(subs))
+	for _, g := range groups {
+		children = append(children, map[string]any{
+			"id":   managementGroupIDPrefix + g,
+			"name": g,
+			"type": "Microsoft.Management/managementGroups",
+		})
+	}
+	for _, sub := range subs {
+		children = append(children, map[string]any{
+			"id":   "/subscriptions/" + sub,
+			"name": sub,
+			"type": "/subscriptions",
+		})
+	}
+	return children
+}
+
+func managementGroupBody(mg *ManagementGroup) map[string]any {
+	props := map[string]any{
+		"displayName": mg.DisplayName,
+		"tenantId":    TenantID,
+	}
+	if mg.Parent != "" {
+		props["details"] = map[string]any{
+			"parent": map[string]any{
+				"id":   managementGroupIDPrefix + mg.Parent,
+				"name": mg.Parent,
+			},
+		}
+	}
+	return map[string]any{
+		"id":         managementGroupIDPrefix + mg.Name,
+		"name":       mg.Name,
+		"type":       "Microsoft.Management/managementGroups",
+		"properties": props,
+	}
+}
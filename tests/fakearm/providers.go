@@ -0,0 +1,59 @@
+package fakearm
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// SetResourceProvider adds or replaces a resource provider in the subscription, with the supplied registration state.
+func (s *Server) SetResourceProvider(subID uuid.UUID, namespace, state string) {
+	s.PutResource("/subscriptions/"+subID.String()+"/providers/"+namespace, map[string]any{
+		"namespace":         namespace,
+		"registrationState": state,
+	})
+}
+
+// SetFeature adds or replaces a resource provider feature in the subscription, with the supplied state.
+// Features that are Pending also have a subscription feature registration requiring approval.
+func (s *Server) SetFeature(subID uuid.UUID, namespace, feature, state string) {
+	prefix := "/subscriptions/" + subID.String() + "/providers/Microsoft.Features/"
+	s.PutResource(prefix+"providers/"+namespace+"/features/"+feature, map[string]any{
+		"properties": map[string]any{"state": state},
+	})
+	reg := prefix + "featureProviders/" + namespace + "/subscriptionFeatureRegistrations/" + feature
+	if state != "Pending" {
+		s.DeleteResource(reg)
+		return
+	}
+	s.PutResource(reg, map[string]any{"properties": map[string]any{
+		"state":        state,
+		"approvalType": "ApprovalRequired",
+		"authorizationProfile": map[string]any{
+			"requestedTime": "2024-01-02T03:04:05Z",
+		},
+	}})
+}
+
+// listResourceProviders lists the resource providers in the subscription.
+func (s *Server) listResourceProviders(w http.ResponseWriter, _ *http.Request, params []string) {
+	if _, ok := s.subscriptions[key(params[0])]; !ok {
+		writeNotFound(w, "SubscriptionNotFound", "The subscription '%s' could not be found.", params[0])
+		return
+	}
+	prefix := key("", "subscriptions", params[0], "providers") + "/"
+	var keys []string
+	for k := range s.resources {
+		if strings.HasPrefix(k, prefix) && !strings.Contains(strings.TrimPrefix(k, prefix), "/") {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	value := make([]any, 0, len(keys))
+	for _, k := range keys {
+		value = append(value, s.resources[k])
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"value": value})
+}
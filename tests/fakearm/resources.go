@@ -0,0 +1,429 @@
+package fakearm
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// lockIDFragment is contained in the lowercased ID of every management lock.
+const lockIDFragment = "/providers/microsoft.authorization/locks/"
+
+type resourceGroup struct {
+	name     string
+	location string
+	tags     map[string]any
+	deleting bool
+}
+
+// operation is an in-progress long-running operation.
+type operation struct {
+	remaining int
+	complete  func()
+}
+
+// AddResourceGroup adds or replaces a resource group in the subscription.
+func (s *Server) AddResourceGroup(subID uuid.UUID, name, location string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.addResourceGroup(subID.String(), name, location, nil)
+}
+
+// ResourceGroups returns the sorted names of the resource groups in the subscription.
+func (s *Server) ResourceGroups(subID uuid.UUID) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	names := make([]string, 0, len(s.resourceGroups[key(subID.String())]))
+	for _, rg := range s.resourceGroups[key(subID.String())] {
+		names = append(names, rg.name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// PutResource adds or replaces a resource, such as a subnet, with the supplied ARM resource ID.
+// The id, name and type of the body are set from the resource ID.
+func (s *Server) PutResource(id string, body map[string]any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.resources[key(id)] = resourceBody(id, body)
+}
+
+// Resource returns the body of the resource with the supplied ARM resource ID.
+func (s *Server) Resource(id string) (map[string]any, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	body, ok := s.resources[key(id)]
+	return body, ok
+}
+
+// DeleteResource removes the resource with the supplied ARM resource ID, if it exists.
+func (s *Server) DeleteResource(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.resources, key(id))
+}
+
+// ResourceIDs returns the sorted IDs of all resources held by the server that start with the supplied prefix.
+// Resource groups and subscriptions are not included.
+func (s *Server) ResourceIDs(prefix string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var ids []string
+	for k, v := range s.resources {
+		if strings.HasPrefix(k, strings.ToLower(prefix)) {
+			ids = append(ids, v["id"].(string))
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func (s *Server) addResourceGroup(subID, name, location string, tags map[string]any) *resourceGroup {
+	sk := key(subID)
+	if s.resourceGroups[sk] == nil {
+		s.resourceGroups[sk] = make(map[string]*resourceGroup)
+	}
+	rg := &resourceGroup{
+		name:     name,
+		location: location,
+		tags:     tags,
+	}
+	s.resourceGroups[sk][key(name)] = rg
+	return rg
+}
+
+func (rg *resourceGroup) body(subID string) map[string]any {
+	state := "Succeeded"
+	if rg.deleting {
+		state = "Deleting"
+	}
+	body := map[string]any{
+		"id":       fmt.Sprintf("/subscriptions/%s/resourceGroups/%s", subID, rg.name),
+		"name":     rg.name,
+		"type":     "Microsoft.Resources/resourceGroups",
+		"location": rg.location,
+		"properties": map[string]any{
+			"provisioningState": state,
+		},
+	}
+	if rg.tags != nil {
+		body["tags"] = rg.tags
+	}
+	return body
+}
+
+func (s *Server) listResourceGroups(w http.ResponseWriter, _ *http.Request, params []string) {
+	rgs := s.resourceGroups[key(params[0])]
+	keys := make([]string, 0, len(rgs))
+	for k := range rgs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	value := make([]any, 0, len(keys))
+	for _, k := range keys {
+		value = append(value, rgs[k].body(params[0]))
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"value": value})
+}
+
+func (s *Server) getResourceGroup(w http.ResponseWriter, _ *http.Request, params []string) {
+	rg, ok := s.resourceGroups[key(params[0])][key(params[1])]
+	if !ok {
+		writeNotFound(w, "ResourceGroupNotFound", "Resource group '%s' could not be found.", params[1])
+		return
+	}
+	writeJSON(w, http.StatusOK, rg.body(params[0]))
+}
+
+func (s *Server) putResourceGroup(w http.ResponseWriter, r *http.Request, params []string) {
+	var req struct {
+		Location string         `json:"location"`
+		Tags     map[string]any `json:"tags"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "InvalidRequestContent", err.Error())
+		return
+	}
+	status := http.StatusCreated
+	if _, ok := s.resourceGroups[key(params[0])][key(params[1])]; ok {
+		status = http.StatusOK
+	}
+	rg := s.addResourceGroup(params[0], params[1], req.Location, req.Tags)
+	writeJSON(w, status, rg.body(params[0]))
+}
+
+// listResourceGroupResources lists the top-level resources in the resource group.
+// A createdTime is only reported if it was supplied in the body passed to PutResource.
+func (s *Server) listResourceGroupResources(w http.ResponseWriter, _ *http.Request, params []string) {
+	if _, ok := s.resourceGroups[key(params[0])][key(params[1])]; !ok {
+		writeNotFound(w, "ResourceGroupNotFound", "Resource group '%s' could not be found.", params[1])
+		return
+	}
+	prefix := key("", "subscriptions", params[0], "resourcegroups", params[1], "providers") + "/"
+	var keys []string
+	for k := range s.resources {
+		// Top-level resources have a namespace, type and name after the providers segment.
+		if strings.HasPrefix(k, prefix) && strings.Count(strings.TrimPrefix(k, prefix), "/") == 2 {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	value := make([]any, 0, len(keys))
+	for _, k := range keys {
+		value = append(value, s.resources[k])
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"value": value})
+}
+
+// listSubscriptionResources lists the resources of a type, e.g. Microsoft.ManagedIdentity/userAssignedIdentities,
+// at the subscription scope and below.
+// Role assignments at the scope of the subscription's management group are included, as they are inherited.
+func (s *Server) listSubscriptionResources(w http.ResponseWriter, _ *http.Request, params []string) {
+	sub, ok := s.subscriptions[key(params[0])]
+	if !ok {
+		writeNotFound(w, "SubscriptionNotFound", "The subscription '%s' could not be found.", params[0])
+		return
+	}
+	prefixes := []string{key("", "subscriptions", params[0]) + "/"}
+	if strings.EqualFold(params[1], "Microsoft.Authorization") && sub.ManagementGroup != "" {
+		prefixes = append(prefixes, key(managementGroupIDPrefix+sub.ManagementGroup)+"/")
+	}
+	typeFragment := key("", "providers", params[1], params[2]) + "/"
+	var keys []string
+	for k := range s.resources {
+		i := strings.LastIndex(k, typeFragment)
+		if i < 0 || strings.Contains(k[i+len(typeFragment):], "/") {
+			continue
+		}
+		for _, p := range prefixes {
+			if strings.HasPrefix(k, p) {
+				keys = append(keys, k)
+				break
+			}
+		}
+	}
+	sort.Strings(keys)
+	value := make([]any, 0, len(keys))
+	for _, k := range keys {
+		value = append(value, s.resources[k])
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"value": value})
+}
+
+// listResourceGroupLocks lists the management locks on the resource group and on the resources in it,
+// and, as ARM does, the locks inherited from the subscription.
+func (s *Server) listResourceGroupLocks(w http.ResponseWriter, _ *http.Request, params []string) {
+	if _, ok := s.resourceGroups[key(params[0])][key(params[1])]; !ok {
+		writeNotFound(w, "ResourceGroupNotFound", "Resource group '%s' could not be found.", params[1])
+		return
+	}
+	keys := append(s.subscriptionLocks(params[0]), s.locks(key("", "subscriptions", params[0], "resourcegroups", params[1])+"/")...)
+	value := make([]any, 0, len(keys))
+	for _, k := range keys {
+		value = append(value, s.resources[k])
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"value": value})
+}
+
+// locks returns the sorted keys of the management locks with the key prefix.
+func (s *Server) locks(prefix string) []string {
+	var keys []string
+	for k := range s.resources {
+		if strings.HasPrefix(k, prefix) && strings.Contains(k, lockIDFragment) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// subscriptionLocks returns the sorted keys of the management locks at the subscription scope.
+func (s *Server) subscriptionLocks(subID string) []string {
+	return s.locks(key("", "subscriptions", subID) + lockIDFragment)
+}
+
+// deleteResourceGroup starts a long-running delete of the resource group and all of its resources.
+// The delete is refused if the subscription, the resource group, or a resource in it, is locked.
+// Forced deletion types are accepted but have no effect.
+func (s *Server) deleteResourceGroup(w http.ResponseWriter, _ *http.Request, params []string) {
+	rgs := s.resourceGroups[key(params[0])]
+	rg, ok := rgs[key(params[1])]
+	if !ok {
+		writeNotFound(w, "ResourceGroupNotFound", "Resource group '%s' could not be found.", params[1])
+		return
+	}
+	prefix := key("", "subscriptions", params[0], "resourcegroups", params[1]) + "/"
+	if n := s.lockedDeletes[prefix]; len(s.locks(prefix)) > 0 || len(s.subscriptionLocks(params[0])) > 0 || n > 0 {
+		if n > 0 {
+			s.lockedDeletes[prefix] = n - 1
+		}
+		writeError(w, http.StatusConflict, "ScopeLocked",
+			fmt.Sprintf("The scope '/subscriptions/%s/resourceGroups/%s' cannot perform delete operation because following scope(s) are locked.", params[0], params[1]))
+		return
+	}
+	rg.deleting = true
+	s.startOperation(w, func() {
+		delete(rgs, key(params[1]))
+		for k := range s.resources {
+			if strings.HasPrefix(k, prefix) {
+				delete(s.resources, k)
+			}
+		}
+	})
+}
+
+// startOperation writes an accepted response with a Location header for the operation.
+// The operation completes after Options.DeletePollCount polls.
+func (s *Server) startOperation(w http.ResponseWriter, complete func()) {
+	s.nextOperationID++
+	id := fmt.Sprintf("%d", s.nextOperationID)
+	s.operations[id] = &operation{
+		remaining: s.opts.DeletePollCount,
+		complete:  complete,
+	}
+	w.Header().Set("Location", s.srv.URL+"/fakearm/operations/"+id)
+	w.Header().Set("Retry-After-Ms", "1")
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (s *Server) getOperation(w http.ResponseWriter, _ *http.Request, params []string) {
+	op, ok := s.operations[params[0]]
+	if !ok {
+		writeNotFound(w, "NotFound", "operation %s could not be found", params[0])
+		return
+	}
+	if op.remaining > 0 {
+		op.remaining--
+		w.Header().Set("Location", s.srv.URL+"/fakearm/operations/"+params[0])
+		w.Header().Set("Retry-After-Ms", "1")
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	op.complete()
+	delete(s.operations, params[0])
+	w.WriteHeader(http.StatusOK)
+}
+
+// serveResource implements a generic store for resources below a subscription or resource group,
+// e.g. /subscriptions/{}/resourceGroups/{}/providers/Microsoft.Network/virtualNetworks/{}/subnets/{}.
+// A GET of a collection path lists the direct children of that type.
+func (s *Server) serveResource(w http.ResponseWriter, r *http.Request, segs []string) {
+	p := providersIndex(segs)
+	if p < 0 || p+1 >= len(segs) {
+		writeNotFound(w, "InvalidResourceType", "no route for %s %s", r.Method, r.URL.Path)
+		return
+	}
+	id := "/" + strings.Join(segs, "/")
+	collection := (len(segs)-p-2)%2 == 1
+
+	if len(segs) >= 4 && strings.EqualFold(segs[0], "subscriptions") && strings.EqualFold(segs[2], "resourcegroups") {
+		if _, ok := s.resourceGroups[key(segs[1])][key(segs[3])]; !ok {
+			writeNotFound(w, "ResourceGroupNotFound", "Resource group '%s' could not be found.", segs[3])
+			return
+		}
+	}
+
+	switch {
+	case collection && r.Method == http.MethodGet:
+		prefix := key(id) + "/"
+		var keys []string
+		for k := range s.resources {
+			if strings.HasPrefix(k, prefix) && !strings.Contains(strings.TrimPrefix(k, prefix), "/") {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		value := make([]any, 0, len(keys))
+		for _, k := range keys {
+			value = append(value, s.resources[k])
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"value": value})
+
+	case !collection && r.Method == http.MethodGet:
+		body, ok := s.resources[key(id)]
+		if !ok {
+			writeNotFound(w, "ResourceNotFound", "The Resource '%s' was not found.", id)
+			return
+		}
+		writeJSON(w, http.StatusOK, body)
+
+	case !collection && r.Method == http.MethodPut:
+		body := make(map[string]any)
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeError(w, http.StatusBadRequest, "InvalidRequestContent", err.Error())
+			return
+		}
+		status := http.StatusCreated
+		if _, ok := s.resources[key(id)]; ok {
+			status = http.StatusOK
+		}
+		body = resourceBody(id, body)
+		s.resources[key(id)] = body
+		writeJSON(w, status, body)
+
+	case !collection && r.Method == http.MethodDelete:
+		if _, ok := s.resources[key(id)]; !ok {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		prefix := key(id)
+		for k := range s.resources {
+			if k == prefix || strings.HasPrefix(k, prefix+"/") {
+				delete(s.resources, k)
+			}
+		}
+		if strings.Contains(prefix, lockIDFragment) && strings.EqualFold(segs[2], "resourcegroups") {
+			s.lockedDeletes[key("", "subscriptions", segs[1], "resourcegroups", segs[3])+"/"] = s.opts.LockReleaseDelay
+		}
+		w.WriteHeader(http.StatusOK)
+
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "MethodNotAllowed", fmt.Sprintf("%s is not supported for %s", r.Method, id))
+	}
+}
+
+// resourceBody sets the id, name and type of the body from the resource ID,
+// and sets a succeeded provisioning state if the body has none.
+func resourceBody(id string, body map[string]any) map[string]any {
+	segs := strings.Split(strings.Trim(id, "/"), "/")
+	p := providersIndex(segs)
+	out := make(map[string]any, len(body)+3)
+	for k, v := range body {
+		out[k] = v
+	}
+	out["id"] = id
+	out["name"] = segs[len(segs)-1]
+	if p >= 0 && p+1 < len(segs) {
+		types := []string{segs[p+1]}
+		for i := p + 2; i < len(segs); i += 2 {
+			types = append(types, segs[i])
+		}
+		out["type"] = strings.Join(types, "/")
+	}
+	props := make(map[string]any)
+	if in, ok := out["properties"].(map[string]any); ok {
+		for k, v := range in {
+			props[k] = v
+		}
+	}
+	if _, ok := props["provisioningState"]; !ok {
+		props["provisioningState"] = "Succeeded"
+	}
+	out["properties"] = props
+	return out
+}
+
+// providersIndex returns the index of the last "providers" segment, or -1.
+func providersIndex(segs []string) int {
+	for i := len(segs) - 1; i >= 0; i-- {
+		if strings.EqualFold(segs[i], "providers") {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,351 @@
+// Package fakearm provides an in-memory fake of the Azure Resource Manager and Entra ID endpoints
+// used by the azureutils package.
+// It allows the helpers to be tested without access to an Azure tenant.
+package fakearm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+)
+
+const (
+	// AccessToken is the bearer token issued by the fake Entra ID token endpoint and the fake credential.
+	// It is an unsigned JWT with CallerObjectID and TenantID as its oid and tid claims.
+	AccessToken = "eyJhbGciOiJub25lIiwidHlwIjoiSldUIn0." +
+		"eyJvaWQiOiIwMDAwMDAwMC0wMDAwLTAwMDAtMDAwMC0wMDAwMDAwMDAwY2MiLCJ0aWQiOiIwMDAwMDAwMC0wMDAwLTAwMDAtMDAwMC0wMDAwMDAwMDAwYWEifQ." +
+		"fakearm"
+
+	// CallerObjectID is the object ID of the principal that AccessToken is issued to.
+	CallerObjectID = "00000000-0000-0000-0000-0000000000cc"
+
+	// OidcRequestToken is the bearer token that the fake OIDC request endpoint expects,
+	// as supplied by GitHub Actions in ACTIONS_ID_TOKEN_REQUEST_TOKEN.
+	OidcRequestToken = "fakearm-oidc-request-token"
+
+	// OidcIDToken is the ID token returned by the fake OIDC request endpoints.
+	OidcIDToken = "fakearm-oidc-id-token"
+
+	// TenantID is the ID of the fake tenant, which is also the name of its tenant root management group.
+	TenantID = "00000000-0000-0000-0000-0000000000aa"
+
+	// AzureDevOpsServiceConnectionID is the service connection that the fake Azure DevOps OIDC endpoint issues tokens for.
+	AzureDevOpsServiceConnectionID = "fakearm-service-connection"
+)
+
+// Options contains the optional behaviour of the fake server.
+type Options struct {
+	// DeletePollCount is the number of times a long-running delete reports that it is still in progress.
+	DeletePollCount int
+
+	// ManagementGroupReadDelay is the number of reads of a management group subscription
+	// that return not found after the subscription is moved, and of a management group
+	// that return not found after it is created, to simulate eventual consistency.
+	ManagementGroupReadDelay int
+
+	// LockReleaseDelay is the number of resource group deletes that fail with ScopeLocked
+	// after the last lock in the resource group is removed, to simulate eventual consistency.
+	LockReleaseDelay int
+}
+
+// Fault is an error response that is returned instead of the usual response
+// for requests that match the method and contain the path fragment.
+type Fault struct {
+	Method       string
+	PathContains string
+	StatusCode   int
+	Code         string
+	Message      string
+	// Count is the number of matching requests that fail, zero means every request.
+	Count int
+}
+
+// Server is a fake Azure Resource Manager endpoint backed by in-memory state.
+type Server struct {
+	srv  *httptest.Server
+	opts Options
+
+	mu               sync.Mutex
+	subscriptions    map[string]*Subscription
+	aliases          map[string]*Alias
+	resourceGroups   map[string]map[string]*resourceGroup
+	resources        map[string]map[string]any
+	managementGroups map[string]*ManagementGroup
+	mgReadsRemaining map[string]int
+	lockedDeletes    map[string]int
+	operations       map[string]*operation
+	faults           []*Fault
+	requests         []string
+	assertions       []string
+	nextOperationID  int
+}
+
+// NewServer starts a new fake server that is closed when the test completes.
+// If opts is nil, the default options are used.
+func NewServer(t *testing.T, opts *Options) *Server {
+	t.Helper()
+	s := &Server{
+		subscriptions:    make(map[string]*Subscription),
+		aliases:          make(map[string]*Alias),
+		resourceGroups:   make(map[string]map[string]*resourceGroup),
+		resources:        make(map[string]map[string]any),
+		managementGroups: make(map[string]*ManagementGroup),
+		mgReadsRemaining: make(map[string]int),
+		lockedDeletes:    make(map[string]int),
+		operations:       make(map[string]*operation),
+	}
+	if opts != nil {
+		s.opts = *opts
+	}
+	s.srv = httptest.NewTLSServer(s)
+	t.Cleanup(s.srv.Close)
+	return s
+}
+
+// URL returns the base URL of the fake server.
+func (s *Server) URL() string {
+	return s.srv.URL
+}
+
+// Client returns an HTTP client that trusts the fake server's TLS certificate.
+func (s *Server) Client() *http.Client {
+	return s.srv.Client()
+}
+
+// Cloud returns a cloud configuration that targets the fake server
+// for both Entra ID and Azure Resource Manager.
+func (s *Server) Cloud() cloud.Configuration {
+	return cloud.Configuration{
+		ActiveDirectoryAuthorityHost: s.srv.URL + "/",
+		Services: map[cloud.ServiceName]cloud.ServiceConfiguration{
+			cloud.ResourceManager: {
+				Audience: s.srv.URL,
+				Endpoint: s.srv.URL,
+			},
+		},
+	}
+}
+
+// ClientOptions returns ARM client options that target the fake server.
+// Retries are disabled so that faults are visible to the caller.
+func (s *Server) ClientOptions() *arm.ClientOptions {
+	return &arm.ClientOptions{
+		ClientOptions: azcore.ClientOptions{
+			Cloud:     s.Cloud(),
+			Transport: s.Client(),
+			Retry: policy.RetryOptions{
+				MaxRetries: -1,
+			},
+		},
+		DisableRPRegistration: true,
+	}
+}
+
+// Credential returns a credential that always issues AccessToken.
+func (s *Server) Credential() azcore.TokenCredential {
+	return staticCredential{}
+}
+
+// OidcRequestURL returns the URL of the fake GitHub Actions ID token request endpoint.
+func (s *Server) OidcRequestURL() string {
+	return s.srv.URL + "/oidc/token"
+}
+
+// AzureDevOpsOidcRequestURL returns the URL of the fake Azure DevOps pipeline OIDC request endpoint,
+// as supplied in SYSTEM_OIDCREQUEST_URI.
+func (s *Server) AzureDevOpsOidcRequestURL() string {
+	return s.srv.URL + "/oidc/azuredevops"
+}
+
+// InjectFault adds a fault to the server.
+// Faults are evaluated in the order they are added.
+func (s *Server) InjectFault(f Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = append(s.faults, &f)
+}
+
+// Requests returns the method, path and query of each request received, in order.
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.requests...)
+}
+
+// RequestCount returns the number of requests received with the method that contain the path fragment.
+func (s *Server) RequestCount(method, pathContains string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for _, r := range s.requests {
+		m, p, _ := strings.Cut(r, " ")
+		p, _, _ = strings.Cut(p, "?")
+		if m == method && strings.Contains(strings.ToLower(p), strings.ToLower(pathContains)) {
+			n++
+		}
+	}
+	return n
+}
+
+// ClientAssertions returns the client assertions presented to the fake token endpoint, in order.
+func (s *Server) ClientAssertions() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.assertions...)
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests = append(s.requests, r.Method+" "+r.URL.RequestURI())
+	if s.fault(w, r) {
+		return
+	}
+
+	segs := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+
+	// Entra ID and OIDC endpoints do not require a bearer token.
+	for _, rt := range s.identityRoutes() {
+		if params, ok := rt.match(r.Method, segs); ok {
+			rt.handler(w, r, params)
+			return
+		}
+	}
+
+	if r.Header.Get("Authorization") != "Bearer "+AccessToken {
+		writeError(w, http.StatusUnauthorized, "AuthenticationFailed", "missing or invalid bearer token")
+		return
+	}
+
+	for _, rt := range s.armRoutes() {
+		if params, ok := rt.match(r.Method, segs); ok {
+			rt.handler(w, r, params)
+			return
+		}
+	}
+
+	s.serveResource(w, r, segs)
+}
+
+// fault writes the first matching fault and reports whether one was found.
+func (s *Server) fault(w http.ResponseWriter, r *http.Request) bool {
+	for i, f := range s.faults {
+		if f.Method != r.Method || !strings.Contains(strings.ToLower(r.URL.Path), strings.ToLower(f.PathContains)) {
+			continue
+		}
+		if f.Count > 0 {
+			f.Count--
+			if f.Count == 0 {
+				s.faults = append(s.faults[:i], s.faults[i+1:]...)
+			}
+		}
+		writeError(w, f.StatusCode, f.Code, f.Message)
+		return true
+	}
+	return false
+}
+
+func (s *Server) armRoutes() []route {
+	return []route{
+		{http.MethodGet, "subscriptions", s.listSubscriptions},
+		{http.MethodGet, "subscriptions/{}", s.getSubscription},
+		{http.MethodPost, "subscriptions/{}/providers/Microsoft.Subscription/cancel", s.cancelSubscription},
+		{http.MethodPost, "subscriptions/{}/providers/Microsoft.Subscription/rename", s.renameSubscription},
+		{http.MethodPatch, "subscriptions/{}/providers/Microsoft.Resources/tags/default", s.patchSubscriptionTags},
+		{http.MethodGet, "subscriptions/{}/providers", s.listResourceProviders},
+		{http.MethodGet, "subscriptions/{}/providers/{}/{}", s.listSubscriptionResources},
+		{http.MethodGet, "providers/Microsoft.Subscription/aliases", s.listAliases},
+		{http.MethodGet, "providers/Microsoft.Subscription/aliases/{}", s.getAlias},
+		{http.MethodPut, "providers/Microsoft.Subscription/aliases/{}", s.putAlias},
+		{http.MethodDelete, "providers/Microsoft.Subscription/aliases/{}", s.deleteAlias},
+		{http.MethodGet, "subscriptions/{}/resourcegroups", s.listResourceGroups},
+		{http.MethodGet, "subscriptions/{}/resourcegroups/{}", s.getResourceGroup},
+		{http.MethodPut, "subscriptions/{}/resourcegroups/{}", s.putResourceGroup},
+		{http.MethodDelete, "subscriptions/{}/resourcegroups/{}", s.deleteResourceGroup},
+		{http.MethodGet, "subscriptions/{}/resourcegroups/{}/resources", s.listResourceGroupResources},
+		{http.MethodGet, "subscriptions/{}/resourcegroups/{}/providers/Microsoft.Authorization/locks", s.listResourceGroupLocks},
+		{http.MethodGet, "fakearm/operations/{}", s.getOperation},
+		{http.MethodGet, "providers/Microsoft.Management/managementGroups/{}", s.getManagementGroup},
+		{http.MethodPut, "providers/Microsoft.Management/managementGroups/{}", s.putManagementGroup},
+		{http.MethodDelete, "providers/Microsoft.Management/managementGroups/{}", s.deleteManagementGroup},
+		{http.MethodGet, "providers/Microsoft.Management/managementGroups/{}/subscriptions/{}", s.getManagementGroupSubscription},
+		{http.MethodPut, "providers/Microsoft.Management/managementGroups/{}/subscriptions/{}", s.putManagementGroupSubscription},
+		{http.MethodDelete, "providers/Microsoft.Management/managementGroups/{}/subscriptions/{}", s.deleteManagementGroupSubscription},
+	}
+}
+
+// route maps a method and path pattern to a handler.
+// Literal pattern segments are matched case-insensitively, and {} matches any single segment.
+type route struct {
+	method  string
+	pattern string
+	handler func(w http.ResponseWriter, r *http.Request, params []string)
+}
+
+func (rt route) match(method string, segs []string) ([]string, bool) {
+	if method != rt.method {
+		return nil, false
+	}
+	pattern := strings.Split(rt.pattern, "/")
+	if len(pattern) != len(segs) {
+		return nil, false
+	}
+	var params []string
+	for i, p := range pattern {
+		if p == "{}" {
+			params = append(params, segs[i])
+			continue
+		}
+		if !strings.EqualFold(p, segs[i]) {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+type staticCredential struct{}
+
+// GetToken implements azcore.TokenCredential.
+func (staticCredential) GetToken(_ context.Context, _ policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	return azcore.AccessToken{
+		Token:     AccessToken,
+		ExpiresOn: time.Now().Add(time.Hour),
+	}, nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("x-ms-error-code", code)
+	writeJSON(w, status, map[string]any{
+		"error": map[string]any{
+			"code":    code,
+			"message": message,
+		},
+	})
+}
+
+func writeNotFound(w http.ResponseWriter, code, format string, args ...any) {
+	writeError(w, http.StatusNotFound, code, fmt.Sprintf(format, args...))
+}
+
+func key(parts ...string) string {
+	return strings.ToLower(strings.Join(parts, "/"))
+}
@@ -0,0 +1,275 @@
+package fakearm
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Subscription states reported by the fake server.
+const (
+	SubscriptionStateEnabled  = "Enabled"
+	SubscriptionStateWarned   = "Warned"
+	SubscriptionStateDisabled = "Disabled"
+)
+
+// Subscription is the fake server's view of an Azure subscription.
+type Subscription struct {
+	ID          uuid.UUID
+	DisplayName string
+	State       string
+	// ManagementGroup is the ID of the management group that the subscription is in.
+	ManagementGroup string
+	// Tags are the tags on the subscription.
+	Tags map[string]string
+}
+
+// Alias is the fake server's view of a subscription alias.
+type Alias struct {
+	Name              string
+	SubscriptionID    uuid.UUID
+	ProvisioningState string
+	// CreatedTime is reported in the alias system data, if it is not zero.
+	CreatedTime time.Time
+}
+
+// AddSubscription adds or replaces a subscription.
+// If the state is empty, the subscription is enabled.
+func (s *Server) AddSubscription(sub Subscription) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if sub.State == "" {
+		sub.State = SubscriptionStateEnabled
+	}
+	s.subscriptions[key(sub.ID.String())] = &sub
+}
+
+// Subscription returns a copy of the subscription with the supplied ID.
+func (s *Server) Subscription(id uuid.UUID) (Subscription, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sub, ok := s.subscriptions[key(id.String())]
+	if !ok {
+		return Subscription{}, false
+	}
+	return *sub, true
+}
+
+// AddAlias adds or replaces a subscription alias.
+// If the provisioning state is empty, the alias has succeeded.
+func (s *Server) AddAlias(a Alias) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if a.ProvisioningState == "" {
+		a.ProvisioningState = "Succeeded"
+	}
+	s.aliases[key(a.Name)] = &a
+}
+
+// Alias returns a copy of the alias with the supplied name.
+func (s *Server) Alias(name string) (Alias, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a, ok := s.aliases[key(name)]
+	if !ok {
+		return Alias{}, false
+	}
+	return *a, true
+}
+
+func (sub *Subscription) body() map[string]any {
+	return map[string]any{
+		"id":             "/subscriptions/" + sub.ID.String(),
+		"subscriptionId": sub.ID.String(),
+		"displayName":    sub.DisplayName,
+		"state":          sub.State,
+	}
+}
+
+func (a *Alias) body() map[string]any {
+	body := map[string]any{
+		"id":   "/providers/Microsoft.Subscription/aliases/" + a.Name,
+		"name": a.Name,
+		"type": "Microsoft.Subscription/aliases",
+		"properties": map[string]any{
+			"subscriptionId":    a.SubscriptionID.String(),
+			"provisioningState": a.ProvisioningState,
+		},
+	}
+	if !a.CreatedTime.IsZero() {
+		body["systemData"] = map[string]any{
+			"createdAt": a.CreatedTime.UTC().Format(time.RFC3339),
+		}
+	}
+	return body
+}
+
+func (s *Server) listSubscriptions(w http.ResponseWriter, _ *http.Request, _ []string) {
+	keys := make([]string, 0, len(s.subscriptions))
+	for k := range s.subscriptions {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	value := make([]any, 0, len(keys))
+	for _, k := range keys {
+		value = append(value, s.subscriptions[k].body())
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"value": value})
+}
+
+func (s *Server) getSubscription(w http.ResponseWriter, _ *http.Request, params []string) {
+	sub, ok := s.subscriptions[key(params[0])]
+	if !ok {
+		writeNotFound(w, "SubscriptionNotFound", "The subscription '%s' could not be found.", params[0])
+		return
+	}
+	writeJSON(w, http.StatusOK, sub.body())
+}
+
+func (s *Server) cancelSubscription(w http.ResponseWriter, _ *http.Request, params []string) {
+	sub, ok := s.subscriptions[key(params[0])]
+	if !ok {
+		writeNotFound(w, "SubscriptionNotFound", "The subscription '%s' could not be found.", params[0])
+		return
+	}
+	if sub.State != SubscriptionStateEnabled {
+		writeError(w, http.StatusConflict, "SubscriptionNotActive", "Subscription is not in active state.")
+		return
+	}
+	sub.State = SubscriptionStateWarned
+	writeJSON(w, http.StatusOK, map[string]any{"subscriptionId": sub.ID.String()})
+}
+
+func (s *Server) renameSubscription(w http.ResponseWriter, r *http.Request, params []string) {
+	sub, ok := s.subscriptions[key(params[0])]
+	if !ok {
+		writeNotFound(w, "SubscriptionNotFound", "The subscription '%s' could not be found.", params[0])
+		return
+	}
+	var req struct {
+		SubscriptionName string `json:"subscriptionName"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "InvalidRequestContent", err.Error())
+		return
+	}
+	if sub.State != SubscriptionStateEnabled {
+		writeError(w, http.StatusConflict, "SubscriptionNotActive", "Subscription is not in active state.")
+		return
+	}
+	sub.DisplayName = req.SubscriptionName
+	writeJSON(w, http.StatusOK, map[string]any{"subscriptionId": sub.ID.String()})
+}
+
+// patchSubscriptionTags implements the merge, replace and delete operations of the tags API
+// for the subscription scope.
+func (s *Server) patchSubscriptionTags(w http.ResponseWriter, r *http.Request, params []string) {
+	sub, ok := s.subscriptions[key(params[0])]
+	if !ok {
+		writeNotFound(w, "SubscriptionNotFound", "The subscription '%s' could not be found.", params[0])
+		return
+	}
+	var req struct {
+		Operation  string `json:"operation"`
+		Properties struct {
+			Tags map[string]string `json:"tags"`
+		} `json:"properties"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "InvalidRequestContent", err.Error())
+		return
+	}
+	if sub.Tags == nil || req.Operation == "Replace" {
+		sub.Tags = make(map[string]string)
+	}
+	for k, v := range req.Properties.Tags {
+		if req.Operation == "Delete" {
+			delete(sub.Tags, k)
+			continue
+		}
+		sub.Tags[k] = v
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"id":   "/subscriptions/" + sub.ID.String() + "/providers/Microsoft.Resources/tags/default",
+		"name": "default",
+		"type": "Microsoft.Resources/tags",
+		"properties": map[string]any{
+			"tags": sub.Tags,
+		},
+	})
+}
+
+func (s *Server) listAliases(w http.ResponseWriter, _ *http.Request, _ []string) {
+	keys := make([]string, 0, len(s.aliases))
+	for k := range s.aliases {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	value := make([]any, 0, len(keys))
+	for _, k := range keys {
+		value = append(value, s.aliases[k].body())
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"value": value})
+}
+
+func (s *Server) getAlias(w http.ResponseWriter, _ *http.Request, params []string) {
+	a, ok := s.aliases[key(params[0])]
+	if !ok {
+		writeNotFound(w, "NotFound", "The alias '%s' could not be found.", params[0])
+		return
+	}
+	writeJSON(w, http.StatusOK, a.body())
+}
+
+// putAlias creates the alias and, unless an existing subscription ID is supplied, a new subscription.
+func (s *Server) putAlias(w http.ResponseWriter, r *http.Request, params []string) {
+	var req struct {
+		Properties struct {
+			DisplayName    string `json:"displayName"`
+			SubscriptionID string `json:"subscriptionId"`
+		} `json:"properties"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "InvalidRequestContent", err.Error())
+		return
+	}
+	if a, ok := s.aliases[key(params[0])]; ok {
+		writeJSON(w, http.StatusOK, a.body())
+		return
+	}
+	id := uuid.New()
+	if req.Properties.SubscriptionID != "" {
+		var err error
+		if id, err = uuid.Parse(req.Properties.SubscriptionID); err != nil {
+			writeError(w, http.StatusBadRequest, "InvalidSubscriptionId", err.Error())
+			return
+		}
+	}
+	if _, ok := s.subscriptions[key(id.String())]; !ok {
+		s.subscriptions[key(id.String())] = &Subscription{
+			ID:          id,
+			DisplayName: req.Properties.DisplayName,
+			State:       SubscriptionStateEnabled,
+		}
+	}
+	a := &Alias{
+		Name:              params[0],
+		SubscriptionID:    id,
+		ProvisioningState: "Succeeded",
+		CreatedTime:       time.Now(),
+	}
+	s.aliases[key(params[0])] = a
+	writeJSON(w, http.StatusOK, a.body())
+}
+
+func (s *Server) deleteAlias(w http.ResponseWriter, _ *http.Request, params []string) {
+	if _, ok := s.aliases[key(params[0])]; !ok {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	delete(s.aliases, key(params[0]))
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,101 @@
+// Code generated by lzinputs from modules/budget/variables*.tf. DO NOT EDIT.
+
+package inputs
+
+// BudgetVariables are the input variables of the budget submodule.
+// Variables that are not set are not passed to Terraform, so take the module default.
+type BudgetVariables struct {
+	// The total amount of cost to track with the budget.
+	//
+	// Terraform variable budget_amount, required.
+	BudgetAmount *float64 `json:"budget_amount,omitzero"`
+	// The name of the budget.
+	//
+	// Terraform variable budget_name, required.
+	BudgetName *string `json:"budget_name,omitzero"`
+	// The notifications for the budget.
+	//
+	// Terraform variable budget_notifications, defaults to {}.
+	BudgetNotifications map[string]BudgetBudgetNotification `json:"budget_notifications,omitzero"`
+	// The scope of the budget.
+	//
+	// Terraform variable budget_scope, required.
+	BudgetScope *string `json:"budget_scope,omitzero"`
+	// The time grain of the budget.
+	//
+	// Terraform variable budget_time_grain, required.
+	BudgetTimeGrain *string `json:"budget_time_grain,omitzero"`
+	// The time period of the budget.
+	//
+	// Terraform variable budget_time_period, required.
+	BudgetTimePeriod *BudgetBudgetTimePeriod `json:"budget_time_period,omitzero"`
+}
+
+// NewBudgetVariables returns an empty BudgetVariables to build with the With methods.
+func NewBudgetVariables() *BudgetVariables {
+	return &BudgetVariables{}
+}
+
+// Vars returns the variables that are set, for setuptest.WithVars.
+func (v *BudgetVariables) Vars() map[string]any {
+	return toVars(v)
+}
+
+// WithBudgetAmount sets the budget_amount variable.
+func (v *BudgetVariables) WithBudgetAmount(value float64) *BudgetVariables {
+	v.BudgetAmount = &value
+	return v
+}
+
+// WithBudgetName sets the budget_name variable.
+func (v *BudgetVariables) WithBudgetName(value string) *BudgetVariables {
+	v.BudgetName = &value
+	return v
+}
+
+// WithBudgetNotifications sets the budget_notifications variable.
+func (v *BudgetVariables) WithBudgetNotifications(value map[string]BudgetBudgetNotification) *BudgetVariables {
+	v.BudgetNotifications = value
+	return v
+}
+
+// WithBudgetScope sets the budget_scope variable.
+func (v *BudgetVariables) WithBudgetScope(value string) *BudgetVariables {
+	v.BudgetScope = &value
+	return v
+}
+
+// WithBudgetTimeGrain sets the budget_time_grain variable.
+func (v *BudgetVariables) WithBudgetTimeGrain(value string) *BudgetVariables {
+	v.BudgetTimeGrain = &value
+	return v
+}
+
+// WithBudgetTimePeriod sets the budget_time_period variable.
+func (v *BudgetVariables) WithBudgetTimePeriod(value BudgetBudgetTimePeriod) *BudgetVariables {
+	v.BudgetTimePeriod = &value
+	return v
+}
+
+// BudgetBudgetNotification is an object in the budget submodule.
+type BudgetBudgetNotification struct {
+	// Optional, defaults to [].
+	ContactEmails []string `json:"contact_emails,omitzero"`
+	// Optional, defaults to [].
+	ContactGroups []string `json:"contact_groups,omitzero"`
+	// Optional, defaults to [].
+	ContactRoles []string `json:"contact_roles,omitzero"`
+	Enabled      bool     `json:"enabled"`
+	// Optional, defaults to "en-us".
+	Locale    *string `json:"locale,omitzero"`
+	Operator  string  `json:"operator"`
+	Threshold float64 `json:"threshold"`
+	// Optional, defaults to "Actual".
+	ThresholdType *string `json:"threshold_type,omitzero"`
+}
+
+// BudgetBudgetTimePeriod is an object in the budget submodule.
+type BudgetBudgetTimePeriod struct {
+	EndDate   string `json:"end_date"`
+	StartDate string `json:"start_date"`
+}
@@ -0,0 +1,34 @@
+// Package inputs has typed input variables for the root module and each submodule,
+// generated from their variables*.tf files by the lzinputs command.
+// A misspelt variable or attribute is a compile error rather than a Terraform error.
+//
+//	v := inputs.NewVirtualNetworkVariables().
+//		WithSubscriptionID("00000000-0000-0000-0000-000000000000").
+//		WithVirtualNetworks(map[string]inputs.VirtualNetworkVirtualNetwork{...})
+//	test, err := setuptest.Dirs(moduleDir, "").WithVars(v.Vars()).InitPlanShow(t)
+package inputs
+
+//go:generate go run ../cmd/lzinputs -module ../.. -out .
+
+import "encoding/json"
+
+// Ptr returns a pointer to the value, for setting optional attributes.
+func Ptr[T any](v T) *T {
+	return &v
+}
+
+// toVars converts the variables to the untyped map used by setuptest.WithVars, using the JSON field names.
+// Numbers are float64, objects are map[string]any and collections are []any,
+// which terratest converts to HCL in the same way as the hand written maps.
+func toVars(v any) map[string]any {
+	b, err := json.Marshal(v)
+	if err != nil {
+		// The generated types only have fields that can be marshalled, apart from the any fields.
+		panic("cannot convert input variables, " + err.Error())
+	}
+	var vars map[string]any
+	if err := json.Unmarshal(b, &vars); err != nil {
+		panic("cannot convert input variables, " + err.Error())
+	}
+	return vars
+}
@@ -0,0 +1,44 @@
+package inputs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestVars tests that only the variables and optional attributes that are set are passed to Terraform,
+// including when they are set to the zero value.
+func TestVars(t *testing.T) {
+	v := NewVirtualNetworkVariables().
+		WithSubscriptionID("00000000-0000-0000-0000-000000000000").
+		WithEnableTelemetry(false).
+		WithVirtualNetworks(map[string]VirtualNetworkVirtualNetwork{
+			"primary": {
+				Name:                 "primary-vnet",
+				ResourceGroupName:    "primary-rg",
+				AddressSpace:         []string{"192.168.0.0/24"},
+				DNSServers:           []string{},
+				FlowTimeoutInMinutes: Ptr(10.0),
+				HubPeeringOptionsTohub: &VirtualNetworkVirtualNetworkHubPeeringOptionsTohub{
+					UseRemoteGateways: Ptr(false),
+				},
+			},
+		})
+
+	assert.Equal(t, map[string]any{
+		"subscription_id":  "00000000-0000-0000-0000-000000000000",
+		"enable_telemetry": false,
+		"virtual_networks": map[string]any{
+			"primary": map[string]any{
+				"name":                    "primary-vnet",
+				"resource_group_name":     "primary-rg",
+				"address_space":           []any{"192.168.0.0/24"},
+				"dns_servers":             []any{},
+				"flow_timeout_in_minutes": 10.0,
+				"hub_peering_options_tohub": map[string]any{
+					"use_remote_gateways": false,
+				},
+			},
+		},
+	}, v.Vars())
+}
@@ -0,0 +1,99 @@
+// Code generated by lzinputs from modules/networksecuritygroup/variables*.tf. DO NOT EDIT.
+
+package inputs
+
+// NetworkSecurityGroupVariables are the input variables of the networksecuritygroup submodule.
+// Variables that are not set are not passed to Terraform, so take the module default.
+type NetworkSecurityGroupVariables struct {
+	// (Required) Specifies the supported Azure location where the resource exists.
+	//
+	// Terraform variable location, required.
+	Location *string `json:"location,omitzero"`
+	// (Required) Specifies the name of the network security group.
+	//
+	// Terraform variable name, required.
+	Name *string `json:"name,omitzero"`
+	// The ID of the parent resource to which this user-assigned managed identity.
+	//
+	// Terraform variable parent_id, required.
+	ParentID *string `json:"parent_id,omitzero"`
+	// These are the security rule configuration properties. - `access` - (Required) Specifies whether network traffic is allowed or denied.
+	//
+	// Terraform variable security_rules, defaults to {}.
+	SecurityRules map[string]NetworkSecurityGroupSecurityRule `json:"security_rules,omitzero"`
+	// (Optional) A mapping of tags to assign to the resource.
+	//
+	// Terraform variable tags, defaults to null.
+	Tags map[string]string `json:"tags,omitzero"`
+}
+
+// NewNetworkSecurityGroupVariables returns an empty NetworkSecurityGroupVariables to build with the With methods.
+func NewNetworkSecurityGroupVariables() *NetworkSecurityGroupVariables {
+	return &NetworkSecurityGroupVariables{}
+}
+
+// Vars returns the variables that are set, for setuptest.WithVars.
+func (v *NetworkSecurityGroupVariables) Vars() map[string]any {
+	return toVars(v)
+}
+
+// WithLocation sets the location variable.
+func (v *NetworkSecurityGroupVariables) WithLocation(value string) *NetworkSecurityGroupVariables {
+	v.Location = &value
+	return v
+}
+
+// WithName sets the name variable.
+func (v *NetworkSecurityGroupVariables) WithName(value string) *NetworkSecurityGroupVariables {
+	v.Name = &value
+	return v
+}
+
+// WithParentID sets the parent_id variable.
+func (v *NetworkSecurityGroupVariables) WithParentID(value string) *NetworkSecurityGroupVariables {
+	v.ParentID = &value
+	return v
+}
+
+// WithSecurityRules sets the security_rules variable.
+func (v *NetworkSecurityGroupVariables) WithSecurityRules(value map[string]NetworkSecurityGroupSecurityRule) *NetworkSecurityGroupVariables {
+	v.SecurityRules = value
+	return v
+}
+
+// WithTags sets the tags variable.
+func (v *NetworkSecurityGroupVariables) WithTags(value map[string]string) *NetworkSecurityGroupVariables {
+	v.Tags = value
+	return v
+}
+
+// NetworkSecurityGroupSecurityRule is an object in the networksecuritygroup submodule.
+type NetworkSecurityGroupSecurityRule struct {
+	Access string `json:"access"`
+	// Optional.
+	Description *string `json:"description,omitzero"`
+	// Optional.
+	DestinationAddressPrefix *string `json:"destination_address_prefix,omitzero"`
+	// Optional.
+	DestinationAddressPrefixes []string `json:"destination_address_prefixes,omitzero"`
+	// Optional.
+	DestinationApplicationSecurityGroupIDs []string `json:"destination_application_security_group_ids,omitzero"`
+	// Optional.
+	DestinationPortRange *string `json:"destination_port_range,omitzero"`
+	// Optional.
+	DestinationPortRanges []string `json:"destination_port_ranges,omitzero"`
+	Direction             string   `json:"direction"`
+	Name                  string   `json:"name"`
+	Priority              float64  `json:"priority"`
+	Protocol              string   `json:"protocol"`
+	// Optional.
+	SourceAddressPrefix *string `json:"source_address_prefix,omitzero"`
+	// Optional.
+	SourceAddressPrefixes []string `json:"source_address_prefixes,omitzero"`
+	// Optional.
+	SourceApplicationSecurityGroupIDs []string `json:"source_application_security_group_ids,omitzero"`
+	// Optional.
+	SourcePortRange *string `json:"source_port_range,omitzero"`
+	// Optional.
+	SourcePortRanges []string `json:"source_port_ranges,omitzero"`
+}
@@ -0,0 +1,78 @@
+// Code generated by lzinputs from modules/resourcegroup/variables*.tf. DO NOT EDIT.
+
+package inputs
+
+// ResourceGroupVariables are the input variables of the resourcegroup submodule.
+// Variables that are not set are not passed to Terraform, so take the module default.
+type ResourceGroupVariables struct {
+	// The Azure region to deploy resources into.
+	//
+	// Terraform variable location, required.
+	Location *string `json:"location,omitzero"`
+	// Whether to enable resource group lock for the resource group.
+	//
+	// Terraform variable lock_enabled, defaults to false.
+	LockEnabled *bool `json:"lock_enabled,omitzero"`
+	// The name of the resource group lock for the resource group, if `null` will be set to `lock-<resource_group_name>`.
+	//
+	// Terraform variable lock_name, defaults to null.
+	LockName *string `json:"lock_name,omitzero"`
+	// The name of the resource group E.g. `rg-test`.
+	//
+	// Terraform variable resource_group_name, required.
+	ResourceGroupName *string `json:"resource_group_name,omitzero"`
+	// The ID of the subscription to deploy resources into.
+	//
+	// Terraform variable subscription_id, required.
+	SubscriptionID *string `json:"subscription_id,omitzero"`
+	// Map of tags to be applied to the resource group.
+	//
+	// Terraform variable tags, defaults to {}.
+	Tags map[string]string `json:"tags,omitzero"`
+}
+
+// NewResourceGroupVariables returns an empty ResourceGroupVariables to build with the With methods.
+func NewResourceGroupVariables() *ResourceGroupVariables {
+	return &ResourceGroupVariables{}
+}
+
+// Vars returns the variables that are set, for setuptest.WithVars.
+func (v *ResourceGroupVariables) Vars() map[string]any {
+	return toVars(v)
+}
+
+// WithLocation sets the location variable.
+func (v *ResourceGroupVariables) WithLocation(value string) *ResourceGroupVariables {
+	v.Location = &value
+	return v
+}
+
+// WithLockEnabled sets the lock_enabled variable.
+func (v *ResourceGroupVariables) WithLockEnabled(value bool) *ResourceGroupVariables {
+	v.LockEnabled = &value
+	return v
+}
+
+// WithLockName sets the lock_name variable.
+func (v *ResourceGroupVariables) WithLockName(value string) *ResourceGroupVariables {
+	v.LockName = &value
+	return v
+}
+
+// WithResourceGroupName sets the resource_group_name variable.
+func (v *ResourceGroupVariables) WithResourceGroupName(value string) *ResourceGroupVariables {
+	v.ResourceGroupName = &value
+	return v
+}
+
+// WithSubscriptionID sets the subscription_id variable.
+func (v *ResourceGroupVariables) WithSubscriptionID(value string) *ResourceGroupVariables {
+	v.SubscriptionID = &value
+	return v
+}
+
+// WithTags sets the tags variable.
+func (v *ResourceGroupVariables) WithTags(value map[string]string) *ResourceGroupVariables {
+	v.Tags = value
+	return v
+}
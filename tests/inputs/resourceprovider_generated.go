@@ -0,0 +1,48 @@
+// Code generated by lzinputs from modules/resourceprovider/variables*.tf. DO NOT EDIT.
+
+package inputs
+
+// ResourceProviderVariables are the input variables of the resourceprovider submodule.
+// Variables that are not set are not passed to Terraform, so take the module default.
+type ResourceProviderVariables struct {
+	// The resource provider features to register, e.g. [`MyFeature`].
+	//
+	// Terraform variable features, defaults to [].
+	Features []string `json:"features,omitzero"`
+	// The resource provider namespace, e.g. `Microsoft.Compute`.
+	//
+	// Terraform variable resource_provider, required.
+	ResourceProvider *string `json:"resource_provider,omitzero"`
+	// The subscription id to register the resource providers in.
+	//
+	// Terraform variable subscription_id, required.
+	SubscriptionID *string `json:"subscription_id,omitzero"`
+}
+
+// NewResourceProviderVariables returns an empty ResourceProviderVariables to build with the With methods.
+func NewResourceProviderVariables() *ResourceProviderVariables {
+	return &ResourceProviderVariables{}
+}
+
+// Vars returns the variables that are set, for setuptest.WithVars.
+func (v *ResourceProviderVariables) Vars() map[string]any {
+	return toVars(v)
+}
+
+// WithFeatures sets the features variable.
+func (v *ResourceProviderVariables) WithFeatures(value []string) *ResourceProviderVariables {
+	v.Features = value
+	return v
+}
+
+// WithResourceProvider sets the resource_provider variable.
+func (v *ResourceProviderVariables) WithResourceProvider(value string) *ResourceProviderVariables {
+	v.ResourceProvider = &value
+	return v
+}
+
+// WithSubscriptionID sets the subscription_id variable.
+func (v *ResourceProviderVariables) WithSubscriptionID(value string) *ResourceProviderVariables {
+	v.SubscriptionID = &value
+	return v
+}
@@ -0,0 +1,121 @@
+// Code generated by lzinputs from modules/roleassignment/variables*.tf. DO NOT EDIT.
+
+package inputs
+
+// RoleAssignmentVariables are the input variables of the roleassignment submodule.
+// Variables that are not set are not passed to Terraform, so take the module default.
+type RoleAssignmentVariables struct {
+	// Terraform variable enable_telemetry, defaults to true.
+	EnableTelemetry *bool `json:"enable_telemetry,omitzero"`
+	// Terraform variable retry, defaults to null.
+	Retry *RoleAssignmentRetry `json:"retry,omitzero"`
+	// (Optional) The condition that limits the resources that the role can be assigned to.
+	//
+	// Terraform variable role_assignment_condition, defaults to null.
+	RoleAssignmentCondition *string `json:"role_assignment_condition,omitzero"`
+	// The version of the condition.
+	//
+	// Terraform variable role_assignment_condition_version, defaults to null.
+	RoleAssignmentConditionVersion *string `json:"role_assignment_condition_version,omitzero"`
+	// Either the role definition resource id, e.g. `/subscriptions/00000000-0000-0000-0000-000000000000/providers/Microsoft.Authorization/roleDefinitions/b24988ac-6180-42a0-ab88-20f7382dd24c`.
+	//
+	// Terraform variable role_assignment_definition, required.
+	RoleAssignmentDefinition *string `json:"role_assignment_definition,omitzero"`
+	// Whether to look up the role definition resource id from the role definition name.
+	//
+	// Terraform variable role_assignment_definition_lookup_enabled, defaults to true.
+	RoleAssignmentDefinitionLookupEnabled *bool `json:"role_assignment_definition_lookup_enabled,omitzero"`
+	// The principal (object) ID of the role assignment.
+	//
+	// Terraform variable role_assignment_principal_id, required.
+	RoleAssignmentPrincipalID *string `json:"role_assignment_principal_id,omitzero"`
+	// Required when using attribute based access control (ABAC).
+	//
+	// Terraform variable role_assignment_principal_type, defaults to null.
+	RoleAssignmentPrincipalType *string `json:"role_assignment_principal_type,omitzero"`
+	// The scope of the role assignment.
+	//
+	// Terraform variable role_assignment_scope, required.
+	RoleAssignmentScope *string `json:"role_assignment_scope,omitzero"`
+	// Whether to use a random UUID for the role assignment name.
+	//
+	// Terraform variable role_assignment_use_random_uuid, defaults to false.
+	RoleAssignmentUseRandomUUID *bool `json:"role_assignment_use_random_uuid,omitzero"`
+}
+
+// NewRoleAssignmentVariables returns an empty RoleAssignmentVariables to build with the With methods.
+func NewRoleAssignmentVariables() *RoleAssignmentVariables {
+	return &RoleAssignmentVariables{}
+}
+
+// Vars returns the variables that are set, for setuptest.WithVars.
+func (v *RoleAssignmentVariables) Vars() map[string]any {
+	return toVars(v)
+}
+
+// WithEnableTelemetry sets the enable_telemetry variable.
+func (v *RoleAssignmentVariables) WithEnableTelemetry(value bool) *RoleAssignmentVariables {
+	v.EnableTelemetry = &value
+	return v
+}
+
+// WithRetry sets the retry variable.
+func (v *RoleAssignmentVariables) WithRetry(value RoleAssignmentRetry) *RoleAssignmentVariables {
+	v.Retry = &value
+	return v
+}
+
+// WithRoleAssignmentCondition sets the role_assignment_condition variable.
+func (v *RoleAssignmentVariables) WithRoleAssignmentCondition(value string) *RoleAssignmentVariables {
+	v.RoleAssignmentCondition = &value
+	return v
+}
+
+// WithRoleAssignmentConditionVersion sets the role_assignment_condition_version variable.
+func (v *RoleAssignmentVariables) WithRoleAssignmentConditionVersion(value string) *RoleAssignmentVariables {
+	v.RoleAssignmentConditionVersion = &value
+	return v
+}
+
+// WithRoleAssignmentDefinition sets the role_assignment_definition variable.
+func (v *RoleAssignmentVariables) WithRoleAssignmentDefinition(value string) *RoleAssignmentVariables {
+	v.RoleAssignmentDefinition = &value
+	return v
+}
+
+// WithRoleAssignmentDefinitionLookupEnabled sets the role_assignment_definition_lookup_enabled variable.
+func (v *RoleAssignmentVariables) WithRoleAssignmentDefinitionLookupEnabled(value bool) *RoleAssignmentVariables {
+	v.RoleAssignmentDefinitionLookupEnabled = &value
+	return v
+}
+
+// WithRoleAssignmentPrincipalID sets the role_assignment_principal_id variable.
+func (v *RoleAssignmentVariables) WithRoleAssignmentPrincipalID(value string) *RoleAssignmentVariables {
+	v.RoleAssignmentPrincipalID = &value
+	return v
+}
+
+// WithRoleAssignmentPrincipalType sets the role_assignment_principal_type variable.
+func (v *RoleAssignmentVariables) WithRoleAssignmentPrincipalType(value string) *RoleAssignmentVariables {
+	v.RoleAssignmentPrincipalType = &value
+	return v
+}
+
+// WithRoleAssignmentScope sets the role_assignment_scope variable.
+func (v *RoleAssignmentVariables) WithRoleAssignmentScope(value string) *RoleAssignmentVariables {
+	v.RoleAssignmentScope = &value
+	return v
+}
+
+// WithRoleAssignmentUseRandomUUID sets the role_assignment_use_random_uuid variable.
+func (v *RoleAssignmentVariables) WithRoleAssignmentUseRandomUUID(value bool) *RoleAssignmentVariables {
+	v.RoleAssignmentUseRandomUUID = &value
+	return v
+}
+
+// RoleAssignmentRetry is an object in the roleassignment submodule.
+type RoleAssignmentRetry struct {
+	ErrorMessageRegex []string `json:"error_message_regex"`
+	// Optional, defaults to 30.
+	IntervalSeconds *float64 `json:"interval_seconds,omitzero"`
+}
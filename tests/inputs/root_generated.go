@@ -0,0 +1,703 @@
+// Code generated by lzinputs from variables*.tf. DO NOT EDIT.
+
+package inputs
+
+// RootVariables are the input variables of the root module.
+// Variables that are not set are not passed to Terraform, so take the module default.
+type RootVariables struct {
+	// Whether to create budgets.
+	//
+	// Terraform variable budget_enabled, defaults to false.
+	BudgetEnabled *bool `json:"budget_enabled,omitzero"`
+	// Map of budgets to create for the subscription.
+	//
+	// Terraform variable budgets, defaults to {}.
+	Budgets map[string]RootBudget `json:"budgets,omitzero"`
+	// To disable tracking, we have included this variable with a simple boolean flag.
+	//
+	// Terraform variable disable_telemetry, defaults to false.
+	DisableTelemetry *bool `json:"disable_telemetry,omitzero"`
+	// The default location of resources created by this module.
+	//
+	// Terraform variable location, required.
+	Location *string `json:"location,omitzero"`
+	// Whether to create network security groups and security rules in the target subscription.
+	//
+	// Terraform variable network_security_group_enabled, defaults to false.
+	NetworkSecurityGroupEnabled *bool `json:"network_security_group_enabled,omitzero"`
+	// A map of the network security groups to create.
+	//
+	// Terraform variable network_security_groups, defaults to {}.
+	NetworkSecurityGroups map[string]RootNetworkSecurityGroup `json:"network_security_groups,omitzero"`
+	// Whether to create additional resource groups in the target subscription.
+	//
+	// Terraform variable resource_group_creation_enabled, defaults to false.
+	ResourceGroupCreationEnabled *bool `json:"resource_group_creation_enabled,omitzero"`
+	// A map of the resource groups to create.
+	//
+	// Terraform variable resource_groups, defaults to {}.
+	ResourceGroups map[string]RootResourceGroup `json:"resource_groups,omitzero"`
+	// Whether to create role assignments.
+	//
+	// Terraform variable role_assignment_enabled, defaults to false.
+	RoleAssignmentEnabled *bool `json:"role_assignment_enabled,omitzero"`
+	// Supply a map of objects containing the details of the role assignments to create.
+	//
+	// Terraform variable role_assignments, defaults to {}.
+	RoleAssignments map[string]RootRoleAssignment `json:"role_assignments,omitzero"`
+	// Whether to create route tables and routes in the target subscription.
+	//
+	// Terraform variable route_table_enabled, defaults to false.
+	RouteTableEnabled *bool `json:"route_table_enabled,omitzero"`
+	// A map defining route tables and their associated routes to be created.
+	//
+	// Terraform variable route_tables, defaults to {}.
+	RouteTables map[string]RootRouteTable `json:"route_tables,omitzero"`
+	// Whether to create a new subscription using the subscription alias resource.
+	//
+	// Terraform variable subscription_alias_enabled, defaults to false.
+	SubscriptionAliasEnabled *bool `json:"subscription_alias_enabled,omitzero"`
+	// The name of the subscription alias.
+	//
+	// Terraform variable subscription_alias_name, defaults to null.
+	SubscriptionAliasName *string `json:"subscription_alias_name,omitzero"`
+	// The billing scope for the new subscription alias.
+	//
+	// Terraform variable subscription_billing_scope, defaults to null.
+	SubscriptionBillingScope *string `json:"subscription_billing_scope,omitzero"`
+	// The display name of the subscription alias.
+	//
+	// Terraform variable subscription_display_name, defaults to null.
+	SubscriptionDisplayName *string `json:"subscription_display_name,omitzero"`
+	// An existing subscription id.
+	//
+	// Terraform variable subscription_id, defaults to null.
+	SubscriptionID *string `json:"subscription_id,omitzero"`
+	// Whether to create the management group association resource.
+	//
+	// Terraform variable subscription_management_group_association_enabled, defaults to false.
+	SubscriptionManagementGroupAssociationEnabled *bool `json:"subscription_management_group_association_enabled,omitzero"`
+	// The destination management group ID for the new subscription.
+	//
+	// Terraform variable subscription_management_group_id, defaults to null.
+	SubscriptionManagementGroupID *string `json:"subscription_management_group_id,omitzero"`
+	// The map of resource providers to register.
+	//
+	// Terraform variable subscription_register_resource_providers_and_features, has a default.
+	SubscriptionRegisterResourceProvidersAndFeatures map[string][]string `json:"subscription_register_resource_providers_and_features,omitzero"`
+	// Whether to register resource providers for the subscription.
+	//
+	// Terraform variable subscription_register_resource_providers_enabled, defaults to false.
+	SubscriptionRegisterResourceProvidersEnabled *bool `json:"subscription_register_resource_providers_enabled,omitzero"`
+	// A map of tags to assign to the newly created subscription.
+	//
+	// Terraform variable subscription_tags, defaults to {}.
+	SubscriptionTags map[string]string `json:"subscription_tags,omitzero"`
+	// Whether to update an existing subscription with the supplied tags and display name.
+	//
+	// Terraform variable subscription_update_existing, defaults to false.
+	SubscriptionUpdateExisting *bool `json:"subscription_update_existing,omitzero"`
+	// The billing scope for the new subscription alias.
+	//
+	// Terraform variable subscription_workload, defaults to null.
+	SubscriptionWorkload *string `json:"subscription_workload,omitzero"`
+	// Whether to enable the creation of a user-assigned managed identity.
+	//
+	// Terraform variable umi_enabled, defaults to false.
+	UMIEnabled *bool `json:"umi_enabled,omitzero"`
+	// A map of user-managed identities to create.
+	//
+	// Terraform variable user_managed_identities, defaults to {}.
+	UserManagedIdentities map[string]RootUserManagedIdentity `json:"user_managed_identities,omitzero"`
+	// Enables and disables the virtual network submodule.
+	//
+	// Terraform variable virtual_network_enabled, defaults to false.
+	VirtualNetworkEnabled *bool `json:"virtual_network_enabled,omitzero"`
+	// A map of the virtual networks to create.
+	//
+	// Terraform variable virtual_networks, defaults to {}.
+	VirtualNetworks map[string]RootVirtualNetwork `json:"virtual_networks,omitzero"`
+	// The duration to wait after vending a subscription before performing subscription operations.
+	//
+	// Terraform variable wait_for_subscription_before_subscription_operations, defaults to {}.
+	WaitForSubscriptionBeforeSubscriptionOperations *RootWaitForSubscriptionBeforeSubscriptionOperations `json:"wait_for_subscription_before_subscription_operations,omitzero"`
+}
+
+// NewRootVariables returns an empty RootVariables to build with the With methods.
+func NewRootVariables() *RootVariables {
+	return &RootVariables{}
+}
+
+// Vars returns the variables that are set, for setuptest.WithVars.
+func (v *RootVariables) Vars() map[string]any {
+	return toVars(v)
+}
+
+// WithBudgetEnabled sets the budget_enabled variable.
+func (v *RootVariables) WithBudgetEnabled(value bool) *RootVariables {
+	v.BudgetEnabled = &value
+	return v
+}
+
+// WithBudgets sets the budgets variable.
+func (v *RootVariables) WithBudgets(value map[string]RootBudget) *RootVariables {
+	v.Budgets = value
+	return v
+}
+
+// WithDisableTelemetry sets the disable_telemetry variable.
+func (v *RootVariables) WithDisableTelemetry(value bool) *RootVariables {
+	v.DisableTelemetry = &value
+	return v
+}
+
+// WithLocation sets the location variable.
+func (v *RootVariables) WithLocation(value string) *RootVariables {
+	v.Location = &value
+	return v
+}
+
+// WithNetworkSecurityGroupEnabled sets the network_security_group_enabled variable.
+func (v *RootVariables) WithNetworkSecurityGroupEnabled(value bool) *RootVariables {
+	v.NetworkSecurityGroupEnabled = &value
+	return v
+}
+
+// WithNetworkSecurityGroups sets the network_security_groups variable.
+func (v *RootVariables) WithNetworkSecurityGroups(value map[string]RootNetworkSecurityGroup) *RootVariables {
+	v.NetworkSecurityGroups = value
+	return v
+}
+
+// WithResourceGroupCreationEnabled sets the resource_group_creation_enabled variable.
+func (v *RootVariables) WithResourceGroupCreationEnabled(value bool) *RootVariables {
+	v.ResourceGroupCreationEnabled = &value
+	return v
+}
+
+// WithResourceGroups sets the resource_groups variable.
+func (v *RootVariables) WithResourceGroups(value map[string]RootResourceGroup) *RootVariables {
+	v.ResourceGroups = value
+	return v
+}
+
+// WithRoleAssignmentEnabled sets the role_assignment_enabled variable.
+func (v *RootVariables) WithRoleAssignmentEnabled(value bool) *RootVariables {
+	v.RoleAssignmentEnabled = &value
+	return v
+}
+
+// WithRoleAssignments sets the role_assignments variable.
+func (v *RootVariables) WithRoleAssignments(value map[string]RootRoleAssignment) *RootVariables {
+	v.RoleAssignments = value
+	return v
+}
+
+// WithRouteTableEnabled sets the route_table_enabled variable.
+func (v *RootVariables) WithRouteTableEnabled(value bool) *RootVariables {
+	v.RouteTableEnabled = &value
+	return v
+}
+
+// WithRouteTables sets the route_tables variable.
+func (v *RootVariables) WithRouteTables(value map[string]RootRouteTable) *RootVariables {
+	v.RouteTables = value
+	return v
+}
+
+// WithSubscriptionAliasEnabled sets the subscription_alias_enabled variable.
+func (v *RootVariables) WithSubscriptionAliasEnabled(value bool) *RootVariables {
+	v.SubscriptionAliasEnabled = &value
+	return v
+}
+
+// WithSubscriptionAliasName sets the subscription_alias_name variable.
+func (v *RootVariables) WithSubscriptionAliasName(value string) *RootVariables {
+	v.SubscriptionAliasName = &value
+	return v
+}
+
+// WithSubscriptionBillingScope sets the subscription_billing_scope variable.
+func (v *RootVariables) WithSubscriptionBillingScope(value string) *RootVariables {
+	v.SubscriptionBillingScope = &value
+	return v
+}
+
+// WithSubscriptionDisplayName sets the subscription_display_name variable.
+func (v *RootVariables) WithSubscriptionDisplayName(value string) *RootVariables {
+	v.SubscriptionDisplayName = &value
+	return v
+}
+
+// WithSubscriptionID sets the subscription_id variable.
+func (v *RootVariables) WithSubscriptionID(value string) *RootVariables {
+	v.SubscriptionID = &value
+	return v
+}
+
+// WithSubscriptionManagementGroupAssociationEnabled sets the subscription_management_group_association_enabled variable.
+func (v *RootVariables) WithSubscriptionManagementGroupAssociationEnabled(value bool) *RootVariables {
+	v.SubscriptionManagementGroupAssociationEnabled = &value
+	return v
+}
+
+// WithSubscriptionManagementGroupID sets the subscription_management_group_id variable.
+func (v *RootVariables) WithSubscriptionManagementGroupID(value string) *RootVariables {
+	v.SubscriptionManagementGroupID = &value
+	return v
+}
+
+// WithSubscriptionRegisterResourceProvidersAndFeatures sets the subscription_register_resource_providers_and_features variable.
+func (v *RootVariables) WithSubscriptionRegisterResourceProvidersAndFeatures(value map[string][]string) *RootVariables {
+	v.SubscriptionRegisterResourceProvidersAndFeatures = value
+	return v
+}
+
+// WithSubscriptionRegisterResourceProvidersEnabled sets the subscription_register_resource_providers_enabled variable.
+func (v *RootVariables) WithSubscriptionRegisterResourceProvidersEnabled(value bool) *RootVariables {
+	v.SubscriptionRegisterResourceProvidersEnabled = &value
+	return v
+}
+
+// WithSubscriptionTags sets the subscription_tags variable.
+func (v *RootVariables) WithSubscriptionTags(value map[string]string) *RootVariables {
+	v.SubscriptionTags = value
+	return v
+}
+
+// WithSubscriptionUpdateExisting sets the subscription_update_existing variable.
+func (v *RootVariables) WithSubscriptionUpdateExisting(value bool) *RootVariables {
+	v.SubscriptionUpdateExisting = &value
+	return v
+}
+
+// WithSubscriptionWorkload sets the subscription_workload variable.
+func (v *RootVariables) WithSubscriptionWorkload(value string) *RootVariables {
+	v.SubscriptionWorkload = &value
+	return v
+}
+
+// WithUMIEnabled sets the umi_enabled variable.
+func (v *RootVariables) WithUMIEnabled(value bool) *RootVariables {
+	v.UMIEnabled = &value
+	return v
+}
+
+// WithUserManagedIdentities sets the user_managed_identities variable.
+func (v *RootVariables) WithUserManagedIdentities(value map[string]RootUserManagedIdentity) *RootVariables {
+	v.UserManagedIdentities = value
+	return v
+}
+
+// WithVirtualNetworkEnabled sets the virtual_network_enabled variable.
+func (v *RootVariables) WithVirtualNetworkEnabled(value bool) *RootVariables {
+	v.VirtualNetworkEnabled = &value
+	return v
+}
+
+// WithVirtualNetworks sets the virtual_networks variable.
+func (v *RootVariables) WithVirtualNetworks(value map[string]RootVirtualNetwork) *RootVariables {
+	v.VirtualNetworks = value
+	return v
+}
+
+// WithWaitForSubscriptionBeforeSubscriptionOperations sets the wait_for_subscription_before_subscription_operations variable.
+func (v *RootVariables) WithWaitForSubscriptionBeforeSubscriptionOperations(value RootWaitForSubscriptionBeforeSubscriptionOperations) *RootVariables {
+	v.WaitForSubscriptionBeforeSubscriptionOperations = &value
+	return v
+}
+
+// RootBudget is an object in the root module.
+type RootBudget struct {
+	Amount float64 `json:"amount"`
+	Name   string  `json:"name"`
+	// Optional, defaults to {}.
+	Notifications map[string]RootBudgetNotification `json:"notifications,omitzero"`
+	// Optional, defaults to "".
+	RelativeScope *string `json:"relative_scope,omitzero"`
+	// Optional.
+	ResourceGroupKey *string `json:"resource_group_key,omitzero"`
+	TimeGrain        string  `json:"time_grain"`
+	TimePeriodEnd    string  `json:"time_period_end"`
+	TimePeriodStart  string  `json:"time_period_start"`
+}
+
+// RootBudgetNotification is an object in the root module.
+type RootBudgetNotification struct {
+	// Optional, defaults to [].
+	ContactEmails []string `json:"contact_emails,omitzero"`
+	// Optional, defaults to [].
+	ContactGroups []string `json:"contact_groups,omitzero"`
+	// Optional, defaults to [].
+	ContactRoles []string `json:"contact_roles,omitzero"`
+	Enabled      bool     `json:"enabled"`
+	// Optional, defaults to "en-us".
+	Locale    *string `json:"locale,omitzero"`
+	Operator  string  `json:"operator"`
+	Threshold float64 `json:"threshold"`
+	// Optional, defaults to "Actual".
+	ThresholdType *string `json:"threshold_type,omitzero"`
+}
+
+// RootNetworkSecurityGroup is an object in the root module.
+type RootNetworkSecurityGroup struct {
+	// Optional.
+	Location *string `json:"location,omitzero"`
+	Name     string  `json:"name"`
+	// Optional.
+	ResourceGroupKey *string `json:"resource_group_key,omitzero"`
+	// Optional.
+	ResourceGroupNameExisting *string `json:"resource_group_name_existing,omitzero"`
+	// Optional.
+	SecurityRules map[string]RootNetworkSecurityGroupSecurityRule `json:"security_rules,omitzero"`
+	// Optional.
+	Tags map[string]string `json:"tags,omitzero"`
+}
+
+// RootNetworkSecurityGroupSecurityRule is an object in the root module.
+type RootNetworkSecurityGroupSecurityRule struct {
+	Access string `json:"access"`
+	// Optional.
+	Description *string `json:"description,omitzero"`
+	// Optional.
+	DestinationAddressPrefix *string `json:"destination_address_prefix,omitzero"`
+	// Optional.
+	DestinationAddressPrefixes []string `json:"destination_address_prefixes,omitzero"`
+	// Optional.
+	DestinationApplicationSecurityGroupIDs []string `json:"destination_application_security_group_ids,omitzero"`
+	// Optional.
+	DestinationPortRange *string `json:"destination_port_range,omitzero"`
+	// Optional.
+	DestinationPortRanges []string `json:"destination_port_ranges,omitzero"`
+	Direction             string   `json:"direction"`
+	Name                  string   `json:"name"`
+	Priority              float64  `json:"priority"`
+	Protocol              string   `json:"protocol"`
+	// Optional.
+	SourceAddressPrefix *string `json:"source_address_prefix,omitzero"`
+	// Optional.
+	SourceAddressPrefixes []string `json:"source_address_prefixes,omitzero"`
+	// Optional.
+	SourceApplicationSecurityGroupIDs []string `json:"source_application_security_group_ids,omitzero"`
+	// Optional.
+	SourcePortRange *string `json:"source_port_range,omitzero"`
+	// Optional.
+	SourcePortRanges []string `json:"source_port_ranges,omitzero"`
+}
+
+// RootResourceGroup is an object in the root module.
+type RootResourceGroup struct {
+	// Optional.
+	Location *string `json:"location,omitzero"`
+	// Optional, defaults to false.
+	LockEnabled *bool `json:"lock_enabled,omitzero"`
+	// Optional, defaults to "".
+	LockName *string `json:"lock_name,omitzero"`
+	Name     string  `json:"name"`
+	// Optional, defaults to {}.
+	Tags map[string]string `json:"tags,omitzero"`
+}
+
+// RootRoleAssignment is an object in the root module.
+type RootRoleAssignment struct {
+	// Optional.
+	Condition *string `json:"condition,omitzero"`
+	// Optional.
+	ConditionVersion *string `json:"condition_version,omitzero"`
+	Definition       string  `json:"definition"`
+	// Optional, defaults to false.
+	DefinitionLookupEnabled *bool  `json:"definition_lookup_enabled,omitzero"`
+	PrincipalID             string `json:"principal_id"`
+	// Optional.
+	PrincipalType *string `json:"principal_type,omitzero"`
+	// Optional, defaults to "".
+	RelativeScope *string `json:"relative_scope,omitzero"`
+	// Optional.
+	ResourceGroupScopeKey *string `json:"resource_group_scope_key,omitzero"`
+	// Optional, defaults to false.
+	UseRandomUUID *bool `json:"use_random_uuid,omitzero"`
+}
+
+// RootRouteTable is an object in the root module.
+type RootRouteTable struct {
+	// Optional, defaults to true.
+	BGPRoutePropagationEnabled *bool  `json:"bgp_route_propagation_enabled,omitzero"`
+	Location                   string `json:"location"`
+	Name                       string `json:"name"`
+	// Optional.
+	ResourceGroupKey *string `json:"resource_group_key,omitzero"`
+	// Optional.
+	ResourceGroupNameExisting *string `json:"resource_group_name_existing,omitzero"`
+	// Optional, defaults to {}.
+	Routes map[string]RootRouteTableRoute `json:"routes,omitzero"`
+	// Optional.
+	Tags map[string]string `json:"tags,omitzero"`
+}
+
+// RootRouteTableRoute is an object in the root module.
+type RootRouteTableRoute struct {
+	AddressPrefix string `json:"address_prefix"`
+	Name          string `json:"name"`
+	// Optional.
+	NextHopInIPAddress *string `json:"next_hop_in_ip_address,omitzero"`
+	NextHopType        string  `json:"next_hop_type"`
+}
+
+// RootUserManagedIdentity is an object in the root module.
+type RootUserManagedIdentity struct {
+	// Optional, defaults to {}.
+	FederatedCredentialsAdvanced map[string]RootUserManagedIdentityFederatedCredentialsAdvanced `json:"federated_credentials_advanced,omitzero"`
+	// Optional, defaults to {}.
+	FederatedCredentialsGithub map[string]RootUserManagedIdentityFederatedCredentialsGithub `json:"federated_credentials_github,omitzero"`
+	// Optional, defaults to {}.
+	FederatedCredentialsTerraformCloud map[string]RootUserManagedIdentityFederatedCredentialsTerraformCloud `json:"federated_credentials_terraform_cloud,omitzero"`
+	// Optional.
+	Location *string `json:"location,omitzero"`
+	Name     string  `json:"name"`
+	// Optional.
+	ResourceGroupKey *string `json:"resource_group_key,omitzero"`
+	// Optional.
+	ResourceGroupNameExisting *string `json:"resource_group_name_existing,omitzero"`
+	// Optional, defaults to {}.
+	RoleAssignments map[string]RootUserManagedIdentityRoleAssignment `json:"role_assignments,omitzero"`
+	// Optional, defaults to {}.
+	Tags map[string]string `json:"tags,omitzero"`
+}
+
+// RootUserManagedIdentityFederatedCredentialsAdvanced is an object in the root module.
+type RootUserManagedIdentityFederatedCredentialsAdvanced struct {
+	// Optional, defaults to ["api://AzureADTokenExchange"].
+	Audiences         []string `json:"audiences,omitzero"`
+	IssuerURL         string   `json:"issuer_url"`
+	Name              string   `json:"name"`
+	SubjectIdentifier string   `json:"subject_identifier"`
+}
+
+// RootUserManagedIdentityFederatedCredentialsGithub is an object in the root module.
+type RootUserManagedIdentityFederatedCredentialsGithub struct {
+	// Optional.
+	EnterpriseSlug *string `json:"enterprise_slug,omitzero"`
+	Entity         string  `json:"entity"`
+	// Optional.
+	Name         *string `json:"name,omitzero"`
+	Organization string  `json:"organization"`
+	Repository   string  `json:"repository"`
+	// Optional.
+	Value *string `json:"value,omitzero"`
+}
+
+// RootUserManagedIdentityFederatedCredentialsTerraformCloud is an object in the root module.
+type RootUserManagedIdentityFederatedCredentialsTerraformCloud struct {
+	// Optional.
+	Name         *string `json:"name,omitzero"`
+	Organization string  `json:"organization"`
+	Project      string  `json:"project"`
+	RunPhase     string  `json:"run_phase"`
+	Workspace    string  `json:"workspace"`
+}
+
+// RootUserManagedIdentityRoleAssignment is an object in the root module.
+type RootUserManagedIdentityRoleAssignment struct {
+	// Optional.
+	Condition *string `json:"condition,omitzero"`
+	// Optional.
+	ConditionVersion *string `json:"condition_version,omitzero"`
+	Definition       string  `json:"definition"`
+	// Optional, defaults to false.
+	DefinitionLookupEnabled *bool `json:"definition_lookup_enabled,omitzero"`
+	// Optional.
+	PrincipalType *string `json:"principal_type,omitzero"`
+	// Optional, defaults to "".
+	RelativeScope *string `json:"relative_scope,omitzero"`
+	// Optional.
+	ResourceGroupScopeKey *string `json:"resource_group_scope_key,omitzero"`
+	// Optional, defaults to false.
+	UseRandomUUID *bool `json:"use_random_uuid,omitzero"`
+}
+
+// RootVirtualNetwork is an object in the root module.
+type RootVirtualNetwork struct {
+	AddressSpace []string `json:"address_space"`
+	// Optional, defaults to false.
+	DDoSProtectionEnabled *bool `json:"ddos_protection_enabled,omitzero"`
+	// Optional.
+	DDoSProtectionPlanID *string `json:"ddos_protection_plan_id,omitzero"`
+	// Optional, defaults to [].
+	DNSServers []string `json:"dns_servers,omitzero"`
+	// Optional.
+	FlowTimeoutInMinutes *float64 `json:"flow_timeout_in_minutes,omitzero"`
+	// Optional.
+	HubNetworkResourceID *string `json:"hub_network_resource_id,omitzero"`
+	// Optional, defaults to "both".
+	HubPeeringDirection *string `json:"hub_peering_direction,omitzero"`
+	// Optional, defaults to false.
+	HubPeeringEnabled *bool `json:"hub_peering_enabled,omitzero"`
+	// Optional.
+	HubPeeringNameFromhub *string `json:"hub_peering_name_fromhub,omitzero"`
+	// Optional.
+	HubPeeringNameTohub *string `json:"hub_peering_name_tohub,omitzero"`
+	// Optional, has a default.
+	HubPeeringOptionsFromhub *RootVirtualNetworkHubPeeringOptionsFromhub `json:"hub_peering_options_fromhub,omitzero"`
+	// Optional, has a default.
+	HubPeeringOptionsTohub *RootVirtualNetworkHubPeeringOptionsTohub `json:"hub_peering_options_tohub,omitzero"`
+	// Optional.
+	Location *string `json:"location,omitzero"`
+	// Optional, defaults to false.
+	MeshPeeringAllowForwardedTraffic *bool `json:"mesh_peering_allow_forwarded_traffic,omitzero"`
+	// Optional, defaults to false.
+	MeshPeeringEnabled *bool  `json:"mesh_peering_enabled,omitzero"`
+	Name               string `json:"name"`
+	// Optional.
+	ResourceGroupKey *string `json:"resource_group_key,omitzero"`
+	// Optional.
+	ResourceGroupNameExisting *string `json:"resource_group_name_existing,omitzero"`
+	// Optional, defaults to {}.
+	Subnets map[string]RootVirtualNetworkSubnet `json:"subnets,omitzero"`
+	// Optional, defaults to {}.
+	Tags map[string]string `json:"tags,omitzero"`
+	// Optional.
+	VWANAssociatedRoutetableResourceID *string `json:"vwan_associated_routetable_resource_id,omitzero"`
+	// Optional, defaults to false.
+	VWANConnectionEnabled *bool `json:"vwan_connection_enabled,omitzero"`
+	// Optional.
+	VWANConnectionName *string `json:"vwan_connection_name,omitzero"`
+	// Optional.
+	VWANHubResourceID *string `json:"vwan_hub_resource_id,omitzero"`
+	// Optional, defaults to [].
+	VWANPropagatedRoutetablesLabels []string `json:"vwan_propagated_routetables_labels,omitzero"`
+	// Optional, defaults to [].
+	VWANPropagatedRoutetablesResourceIDs []string `json:"vwan_propagated_routetables_resource_ids,omitzero"`
+	// Optional, has a default.
+	VWANSecurityConfiguration *RootVirtualNetworkVWANSecurityConfiguration `json:"vwan_security_configuration,omitzero"`
+}
+
+// RootVirtualNetworkHubPeeringOptionsFromhub is an object in the root module.
+type RootVirtualNetworkHubPeeringOptionsFromhub struct {
+	// Optional, defaults to true.
+	AllowForwardedTraffic *bool `json:"allow_forwarded_traffic,omitzero"`
+	// Optional, defaults to true.
+	AllowGatewayTransit *bool `json:"allow_gateway_transit,omitzero"`
+	// Optional, defaults to true.
+	AllowVirtualNetworkAccess *bool `json:"allow_virtual_network_access,omitzero"`
+	// Optional, defaults to false.
+	DoNotVerifyRemoteGateways *bool `json:"do_not_verify_remote_gateways,omitzero"`
+	// Optional, defaults to false.
+	EnableOnlyIPv6Peering *bool `json:"enable_only_ipv6_peering,omitzero"`
+	// Optional, defaults to [].
+	LocalPeeredAddressSpaces []string `json:"local_peered_address_spaces,omitzero"`
+	// Optional, defaults to [].
+	LocalPeeredSubnets []string `json:"local_peered_subnets,omitzero"`
+	// Optional, defaults to true.
+	PeerCompleteVnets *bool `json:"peer_complete_vnets,omitzero"`
+	// Optional, defaults to [].
+	RemotePeeredAddressSpaces []string `json:"remote_peered_address_spaces,omitzero"`
+	// Optional, defaults to [].
+	RemotePeeredSubnets []string `json:"remote_peered_subnets,omitzero"`
+	// Optional, defaults to false.
+	UseRemoteGateways *bool `json:"use_remote_gateways,omitzero"`
+}
+
+// RootVirtualNetworkHubPeeringOptionsTohub is an object in the root module.
+type RootVirtualNetworkHubPeeringOptionsTohub struct {
+	// Optional, defaults to true.
+	AllowForwardedTraffic *bool `json:"allow_forwarded_traffic,omitzero"`
+	// Optional, defaults to false.
+	AllowGatewayTransit *bool `json:"allow_gateway_transit,omitzero"`
+	// Optional, defaults to true.
+	AllowVirtualNetworkAccess *bool `json:"allow_virtual_network_access,omitzero"`
+	// Optional, defaults to false.
+	DoNotVerifyRemoteGateways *bool `json:"do_not_verify_remote_gateways,omitzero"`
+	// Optional, defaults to false.
+	EnableOnlyIPv6Peering *bool `json:"enable_only_ipv6_peering,omitzero"`
+	// Optional, defaults to [].
+	LocalPeeredAddressSpaces []string `json:"local_peered_address_spaces,omitzero"`
+	// Optional, defaults to [].
+	LocalPeeredSubnets []string `json:"local_peered_subnets,omitzero"`
+	// Optional, defaults to true.
+	PeerCompleteVnets *bool `json:"peer_complete_vnets,omitzero"`
+	// Optional, defaults to [].
+	RemotePeeredAddressSpaces []string `json:"remote_peered_address_spaces,omitzero"`
+	// Optional, defaults to [].
+	RemotePeeredSubnets []string `json:"remote_peered_subnets,omitzero"`
+	// Optional, defaults to true.
+	UseRemoteGateways *bool `json:"use_remote_gateways,omitzero"`
+}
+
+// RootVirtualNetworkSubnet is an object in the root module.
+type RootVirtualNetworkSubnet struct {
+	AddressPrefixes []string `json:"address_prefixes"`
+	// Optional, defaults to false.
+	DefaultOutboundAccessEnabled *bool `json:"default_outbound_access_enabled,omitzero"`
+	// Optional.
+	Delegations []RootVirtualNetworkSubnetDelegation `json:"delegations,omitzero"`
+	Name        string                               `json:"name"`
+	// Optional.
+	NatGateway *RootVirtualNetworkSubnetNatGateway `json:"nat_gateway,omitzero"`
+	// Optional.
+	NetworkSecurityGroup *RootVirtualNetworkSubnetNetworkSecurityGroup `json:"network_security_group,omitzero"`
+	// Optional, defaults to "Enabled".
+	PrivateEndpointNetworkPolicies *string `json:"private_endpoint_network_policies,omitzero"`
+	// Optional, defaults to true.
+	PrivateLinkServiceNetworkPoliciesEnabled *bool `json:"private_link_service_network_policies_enabled,omitzero"`
+	// Optional.
+	RouteTable *RootVirtualNetworkSubnetRouteTable `json:"route_table,omitzero"`
+	// Optional.
+	ServiceEndpointPolicies map[string]RootVirtualNetworkSubnetServiceEndpointPolicy `json:"service_endpoint_policies,omitzero"`
+	// Optional.
+	ServiceEndpoints []string `json:"service_endpoints,omitzero"`
+}
+
+// RootVirtualNetworkSubnetDelegation is an object in the root module.
+type RootVirtualNetworkSubnetDelegation struct {
+	Name              string                                              `json:"name"`
+	ServiceDelegation RootVirtualNetworkSubnetDelegationServiceDelegation `json:"service_delegation"`
+}
+
+// RootVirtualNetworkSubnetDelegationServiceDelegation is an object in the root module.
+type RootVirtualNetworkSubnetDelegationServiceDelegation struct {
+	Name string `json:"name"`
+}
+
+// RootVirtualNetworkSubnetNatGateway is an object in the root module.
+type RootVirtualNetworkSubnetNatGateway struct {
+	ID string `json:"id"`
+}
+
+// RootVirtualNetworkSubnetNetworkSecurityGroup is an object in the root module.
+type RootVirtualNetworkSubnetNetworkSecurityGroup struct {
+	// Optional.
+	ID *string `json:"id,omitzero"`
+	// Optional.
+	KeyReference *string `json:"key_reference,omitzero"`
+}
+
+// RootVirtualNetworkSubnetRouteTable is an object in the root module.
+type RootVirtualNetworkSubnetRouteTable struct {
+	// Optional.
+	ID *string `json:"id,omitzero"`
+	// Optional.
+	KeyReference *string `json:"key_reference,omitzero"`
+}
+
+// RootVirtualNetworkSubnetServiceEndpointPolicy is an object in the root module.
+type RootVirtualNetworkSubnetServiceEndpointPolicy struct {
+	ID string `json:"id"`
+}
+
+// RootVirtualNetworkVWANSecurityConfiguration is an object in the root module.
+type RootVirtualNetworkVWANSecurityConfiguration struct {
+	// Optional, defaults to false.
+	RoutingIntentEnabled *bool `json:"routing_intent_enabled,omitzero"`
+	// Optional, defaults to false.
+	SecureInternetTraffic *bool `json:"secure_internet_traffic,omitzero"`
+	// Optional, defaults to false.
+	SecurePrivateTraffic *bool `json:"secure_private_traffic,omitzero"`
+}
+
+// RootWaitForSubscriptionBeforeSubscriptionOperations is an object in the root module.
+type RootWaitForSubscriptionBeforeSubscriptionOperations struct {
+	// Optional, defaults to "30s".
+	Create *string `json:"create,omitzero"`
+	// Optional, defaults to "0s".
+	Destroy *string `json:"destroy,omitzero"`
+}
@@ -0,0 +1,87 @@
+// Code generated by lzinputs from modules/routetable/variables*.tf. DO NOT EDIT.
+
+package inputs
+
+// RouteTableVariables are the input variables of the routetable submodule.
+// Variables that are not set are not passed to Terraform, so take the module default.
+type RouteTableVariables struct {
+	// Whether BGP route propagation is enabled.
+	//
+	// Terraform variable bgp_route_propagation_enabled, defaults to true.
+	BGPRoutePropagationEnabled *bool `json:"bgp_route_propagation_enabled,omitzero"`
+	// The location of the route table.
+	//
+	// Terraform variable location, required.
+	Location *string `json:"location,omitzero"`
+	// The name of the route table to create.
+	//
+	// Terraform variable name, required.
+	Name *string `json:"name,omitzero"`
+	// The ID of the parent resource to which this user-assigned managed identity.
+	//
+	// Terraform variable parent_id, required.
+	ParentID *string `json:"parent_id,omitzero"`
+	// A list of objects defining route tables and their associated routes to be created.
+	//
+	// Terraform variable routes, defaults to [].
+	Routes []RouteTableRoute `json:"routes,omitzero"`
+	// A map of tags to assign to the route table.
+	//
+	// Terraform variable tags, defaults to {}.
+	Tags map[string]string `json:"tags,omitzero"`
+}
+
+// NewRouteTableVariables returns an empty RouteTableVariables to build with the With methods.
+func NewRouteTableVariables() *RouteTableVariables {
+	return &RouteTableVariables{}
+}
+
+// Vars returns the variables that are set, for setuptest.WithVars.
+func (v *RouteTableVariables) Vars() map[string]any {
+	return toVars(v)
+}
+
+// WithBGPRoutePropagationEnabled sets the bgp_route_propagation_enabled variable.
+func (v *RouteTableVariables) WithBGPRoutePropagationEnabled(value bool) *RouteTableVariables {
+	v.BGPRoutePropagationEnabled = &value
+	return v
+}
+
+// WithLocation sets the location variable.
+func (v *RouteTableVariables) WithLocation(value string) *RouteTableVariables {
+	v.Location = &value
+	return v
+}
+
+// WithName sets the name variable.
+func (v *RouteTableVariables) WithName(value string) *RouteTableVariables {
+	v.Name = &value
+	return v
+}
+
+// WithParentID sets the parent_id variable.
+func (v *RouteTableVariables) WithParentID(value string) *RouteTableVariables {
+	v.ParentID = &value
+	return v
+}
+
+// WithRoutes sets the routes variable.
+func (v *RouteTableVariables) WithRoutes(value []RouteTableRoute) *RouteTableVariables {
+	v.Routes = value
+	return v
+}
+
+// WithTags sets the tags variable.
+func (v *RouteTableVariables) WithTags(value map[string]string) *RouteTableVariables {
+	v.Tags = value
+	return v
+}
+
+// RouteTableRoute is an object in the routetable submodule.
+type RouteTableRoute struct {
+	AddressPrefix string `json:"address_prefix"`
+	Name          string `json:"name"`
+	// Optional.
+	NextHopInIPAddress *string `json:"next_hop_in_ip_address,omitzero"`
+	NextHopType        string  `json:"next_hop_type"`
+}
@@ -0,0 +1,134 @@
+// Code generated by lzinputs from modules/subscription/variables*.tf. DO NOT EDIT.
+
+package inputs
+
+// SubscriptionVariables are the input variables of the subscription submodule.
+// Variables that are not set are not passed to Terraform, so take the module default.
+type SubscriptionVariables struct {
+	// Whether to create a new subscription using the subscription alias resource.
+	//
+	// Terraform variable subscription_alias_enabled, defaults to false.
+	SubscriptionAliasEnabled *bool `json:"subscription_alias_enabled,omitzero"`
+	// The name of the subscription alias.
+	//
+	// Terraform variable subscription_alias_name, defaults to null.
+	SubscriptionAliasName *string `json:"subscription_alias_name,omitzero"`
+	// The billing scope for the new subscription alias.
+	//
+	// Terraform variable subscription_billing_scope, defaults to null.
+	SubscriptionBillingScope *string `json:"subscription_billing_scope,omitzero"`
+	// The display name of the subscription alias.
+	//
+	// Terraform variable subscription_display_name, defaults to null.
+	SubscriptionDisplayName *string `json:"subscription_display_name,omitzero"`
+	// Terraform variable subscription_id, defaults to null.
+	SubscriptionID *string `json:"subscription_id,omitzero"`
+	// Whether to create the subscription_association resource.
+	//
+	// Terraform variable subscription_management_group_association_enabled, defaults to false.
+	SubscriptionManagementGroupAssociationEnabled *bool `json:"subscription_management_group_association_enabled,omitzero"`
+	// The destination management group ID for the new subscription.
+	//
+	// Terraform variable subscription_management_group_id, defaults to null.
+	SubscriptionManagementGroupID *string `json:"subscription_management_group_id,omitzero"`
+	// A map of tags to assign to the newly created subscription.
+	//
+	// Terraform variable subscription_tags, defaults to {}.
+	SubscriptionTags map[string]string `json:"subscription_tags,omitzero"`
+	// Whether to update an existing subscription with the supplied tags and display name.
+	//
+	// Terraform variable subscription_update_existing, defaults to false.
+	SubscriptionUpdateExisting *bool `json:"subscription_update_existing,omitzero"`
+	// The billing scope for the new subscription alias.
+	//
+	// Terraform variable subscription_workload, defaults to null.
+	SubscriptionWorkload *string `json:"subscription_workload,omitzero"`
+	// The duration to wait after vending a subscription before performing subscription operations.
+	//
+	// Terraform variable wait_for_subscription_before_subscription_operations, defaults to {}.
+	WaitForSubscriptionBeforeSubscriptionOperations *SubscriptionWaitForSubscriptionBeforeSubscriptionOperations `json:"wait_for_subscription_before_subscription_operations,omitzero"`
+}
+
+// NewSubscriptionVariables returns an empty SubscriptionVariables to build with the With methods.
+func NewSubscriptionVariables() *SubscriptionVariables {
+	return &SubscriptionVariables{}
+}
+
+// Vars returns the variables that are set, for setuptest.WithVars.
+func (v *SubscriptionVariables) Vars() map[string]any {
+	return toVars(v)
+}
+
+// WithSubscriptionAliasEnabled sets the subscription_alias_enabled variable.
+func (v *SubscriptionVariables) WithSubscriptionAliasEnabled(value bool) *SubscriptionVariables {
+	v.SubscriptionAliasEnabled = &value
+	return v
+}
+
+// WithSubscriptionAliasName sets the subscription_alias_name variable.
+func (v *SubscriptionVariables) WithSubscriptionAliasName(value string) *SubscriptionVariables {
+	v.SubscriptionAliasName = &value
+	return v
+}
+
+// WithSubscriptionBillingScope sets the subscription_billing_scope variable.
+func (v *SubscriptionVariables) WithSubscriptionBillingScope(value string) *SubscriptionVariables {
+	v.SubscriptionBillingScope = &value
+	return v
+}
+
+// WithSubscriptionDisplayName sets the subscription_display_name variable.
+func (v *SubscriptionVariables) WithSubscriptionDisplayName(value string) *SubscriptionVariables {
+	v.SubscriptionDisplayName = &value
+	return v
+}
+
+// WithSubscriptionID sets the subscription_id variable.
+func (v *SubscriptionVariables) WithSubscriptionID(value string) *SubscriptionVariables {
+	v.SubscriptionID = &value
+	return v
+}
+
+// WithSubscriptionManagementGroupAssociationEnabled sets the subscription_management_group_association_enabled variable.
+func (v *SubscriptionVariables) WithSubscriptionManagementGroupAssociationEnabled(value bool) *SubscriptionVariables {
+	v.SubscriptionManagementGroupAssociationEnabled = &value
+	return v
+}
+
+// WithSubscriptionManagementGroupID sets the subscription_management_group_id variable.
+func (v *SubscriptionVariables) WithSubscriptionManagementGroupID(value string) *SubscriptionVariables {
+	v.SubscriptionManagementGroupID = &value
+	return v
+}
+
+// WithSubscriptionTags sets the subscription_tags variable.
+func (v *SubscriptionVariables) WithSubscriptionTags(value map[string]string) *SubscriptionVariables {
+	v.SubscriptionTags = value
+	return v
+}
+
+// WithSubscriptionUpdateExisting sets the subscription_update_existing variable.
+func (v *SubscriptionVariables) WithSubscriptionUpdateExisting(value bool) *SubscriptionVariables {
+	v.SubscriptionUpdateExisting = &value
+	return v
+}
+
+// WithSubscriptionWorkload sets the subscription_workload variable.
+func (v *SubscriptionVariables) WithSubscriptionWorkload(value string) *SubscriptionVariables {
+	v.SubscriptionWorkload = &value
+	return v
+}
+
+// WithWaitForSubscriptionBeforeSubscriptionOperations sets the wait_for_subscription_before_subscription_operations variable.
+func (v *SubscriptionVariables) WithWaitForSubscriptionBeforeSubscriptionOperations(value SubscriptionWaitForSubscriptionBeforeSubscriptionOperations) *SubscriptionVariables {
+	v.WaitForSubscriptionBeforeSubscriptionOperations = &value
+	return v
+}
+
+// SubscriptionWaitForSubscriptionBeforeSubscriptionOperations is an object in the subscription submodule.
+type SubscriptionWaitForSubscriptionBeforeSubscriptionOperations struct {
+	// Optional, defaults to "30s".
+	Create *string `json:"create,omitzero"`
+	// Optional, defaults to "0s".
+	Destroy *string `json:"destroy,omitzero"`
+}
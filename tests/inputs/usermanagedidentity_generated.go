@@ -0,0 +1,120 @@
+// Code generated by lzinputs from modules/usermanagedidentity/variables*.tf. DO NOT EDIT.
+
+package inputs
+
+// UserManagedIdentityVariables are the input variables of the usermanagedidentity submodule.
+// Variables that are not set are not passed to Terraform, so take the module default.
+type UserManagedIdentityVariables struct {
+	// Configure federated identity credentials, using OpenID Connect, for use scenarios outside GitHub Actions and Terraform Cloud.
+	//
+	// Terraform variable federated_credentials_advanced, defaults to {}.
+	FederatedCredentialsAdvanced map[string]UserManagedIdentityFederatedCredentialsAdvanced `json:"federated_credentials_advanced,omitzero"`
+	// Configure federated identity credentials, using OpenID Connect, for use in GitHub actions.
+	//
+	// Terraform variable federated_credentials_github, defaults to {}.
+	FederatedCredentialsGithub map[string]UserManagedIdentityFederatedCredentialsGithub `json:"federated_credentials_github,omitzero"`
+	// Configure federated identity credentials, using OpenID Connect, for use in Terraform Cloud.
+	//
+	// Terraform variable federated_credentials_terraform_cloud, defaults to {}.
+	FederatedCredentialsTerraformCloud map[string]UserManagedIdentityFederatedCredentialsTerraformCloud `json:"federated_credentials_terraform_cloud,omitzero"`
+	// The location of the user-assigned managed identity.
+	//
+	// Terraform variable location, required.
+	Location *string `json:"location,omitzero"`
+	// The name of the user managed identity.
+	//
+	// Terraform variable name, required.
+	Name *string `json:"name,omitzero"`
+	// The ID of the parent resource to which this user-assigned managed identity.
+	//
+	// Terraform variable parent_id, required.
+	ParentID *string `json:"parent_id,omitzero"`
+	// The tags to apply to the user-assigned managed identity.
+	//
+	// Terraform variable tags, defaults to {}.
+	Tags map[string]string `json:"tags,omitzero"`
+}
+
+// NewUserManagedIdentityVariables returns an empty UserManagedIdentityVariables to build with the With methods.
+func NewUserManagedIdentityVariables() *UserManagedIdentityVariables {
+	return &UserManagedIdentityVariables{}
+}
+
+// Vars returns the variables that are set, for setuptest.WithVars.
+func (v *UserManagedIdentityVariables) Vars() map[string]any {
+	return toVars(v)
+}
+
+// WithFederatedCredentialsAdvanced sets the federated_credentials_advanced variable.
+func (v *UserManagedIdentityVariables) WithFederatedCredentialsAdvanced(value map[string]UserManagedIdentityFederatedCredentialsAdvanced) *UserManagedIdentityVariables {
+	v.FederatedCredentialsAdvanced = value
+	return v
+}
+
+// WithFederatedCredentialsGithub sets the federated_credentials_github variable.
+func (v *UserManagedIdentityVariables) WithFederatedCredentialsGithub(value map[string]UserManagedIdentityFederatedCredentialsGithub) *UserManagedIdentityVariables {
+	v.FederatedCredentialsGithub = value
+	return v
+}
+
+// WithFederatedCredentialsTerraformCloud sets the federated_credentials_terraform_cloud variable.
+func (v *UserManagedIdentityVariables) WithFederatedCredentialsTerraformCloud(value map[string]UserManagedIdentityFederatedCredentialsTerraformCloud) *UserManagedIdentityVariables {
+	v.FederatedCredentialsTerraformCloud = value
+	return v
+}
+
+// WithLocation sets the location variable.
+func (v *UserManagedIdentityVariables) WithLocation(value string) *UserManagedIdentityVariables {
+	v.Location = &value
+	return v
+}
+
+// WithName sets the name variable.
+func (v *UserManagedIdentityVariables) WithName(value string) *UserManagedIdentityVariables {
+	v.Name = &value
+	return v
+}
+
+// WithParentID sets the parent_id variable.
+func (v *UserManagedIdentityVariables) WithParentID(value string) *UserManagedIdentityVariables {
+	v.ParentID = &value
+	return v
+}
+
+// WithTags sets the tags variable.
+func (v *UserManagedIdentityVariables) WithTags(value map[string]string) *UserManagedIdentityVariables {
+	v.Tags = value
+	return v
+}
+
+// UserManagedIdentityFederatedCredentialsAdvanced is an object in the usermanagedidentity submodule.
+type UserManagedIdentityFederatedCredentialsAdvanced struct {
+	// Optional, defaults to ["api://AzureADTokenExchange"].
+	Audiences         []string `json:"audiences,omitzero"`
+	IssuerURL         string   `json:"issuer_url"`
+	Name              string   `json:"name"`
+	SubjectIdentifier string   `json:"subject_identifier"`
+}
+
+// UserManagedIdentityFederatedCredentialsGithub is an object in the usermanagedidentity submodule.
+type UserManagedIdentityFederatedCredentialsGithub struct {
+	// Optional.
+	EnterpriseSlug *string `json:"enterprise_slug,omitzero"`
+	Entity         string  `json:"entity"`
+	// Optional.
+	Name         *string `json:"name,omitzero"`
+	Organization string  `json:"organization"`
+	Repository   string  `json:"repository"`
+	// Optional.
+	Value *string `json:"value,omitzero"`
+}
+
+// UserManagedIdentityFederatedCredentialsTerraformCloud is an object in the usermanagedidentity submodule.
+type UserManagedIdentityFederatedCredentialsTerraformCloud struct {
+	// Optional.
+	Name         *string `json:"name,omitzero"`
+	Organization string  `json:"organization"`
+	Project      string  `json:"project"`
+	RunPhase     string  `json:"run_phase"`
+	Workspace    string  `json:"workspace"`
+}
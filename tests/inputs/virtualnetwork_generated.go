@@ -0,0 +1,229 @@
+// Code generated by lzinputs from modules/virtualnetwork/variables*.tf. DO NOT EDIT.
+
+package inputs
+
+// VirtualNetworkVariables are the input variables of the virtualnetwork submodule.
+// Variables that are not set are not passed to Terraform, so take the module default.
+type VirtualNetworkVariables struct {
+	// This variable controls whether or not telemetry is enabled for the module.
+	//
+	// Terraform variable enable_telemetry, defaults to true.
+	EnableTelemetry *bool `json:"enable_telemetry,omitzero"`
+	// The default location of resources created by this module.
+	//
+	// Terraform variable location, defaults to "".
+	Location *string `json:"location,omitzero"`
+	// The subscription ID of the subscription to create the virtual network in.
+	//
+	// Terraform variable subscription_id, required.
+	SubscriptionID *string `json:"subscription_id,omitzero"`
+	// A map of the virtual networks to create.
+	//
+	// Terraform variable virtual_networks, required.
+	VirtualNetworks map[string]VirtualNetworkVirtualNetwork `json:"virtual_networks,omitzero"`
+}
+
+// NewVirtualNetworkVariables returns an empty VirtualNetworkVariables to build with the With methods.
+func NewVirtualNetworkVariables() *VirtualNetworkVariables {
+	return &VirtualNetworkVariables{}
+}
+
+// Vars returns the variables that are set, for setuptest.WithVars.
+func (v *VirtualNetworkVariables) Vars() map[string]any {
+	return toVars(v)
+}
+
+// WithEnableTelemetry sets the enable_telemetry variable.
+func (v *VirtualNetworkVariables) WithEnableTelemetry(value bool) *VirtualNetworkVariables {
+	v.EnableTelemetry = &value
+	return v
+}
+
+// WithLocation sets the location variable.
+func (v *VirtualNetworkVariables) WithLocation(value string) *VirtualNetworkVariables {
+	v.Location = &value
+	return v
+}
+
+// WithSubscriptionID sets the subscription_id variable.
+func (v *VirtualNetworkVariables) WithSubscriptionID(value string) *VirtualNetworkVariables {
+	v.SubscriptionID = &value
+	return v
+}
+
+// WithVirtualNetworks sets the virtual_networks variable.
+func (v *VirtualNetworkVariables) WithVirtualNetworks(value map[string]VirtualNetworkVirtualNetwork) *VirtualNetworkVariables {
+	v.VirtualNetworks = value
+	return v
+}
+
+// VirtualNetworkVirtualNetwork is an object in the virtualnetwork submodule.
+type VirtualNetworkVirtualNetwork struct {
+	AddressSpace []string `json:"address_space"`
+	// Optional, defaults to false.
+	DDoSProtectionEnabled *bool `json:"ddos_protection_enabled,omitzero"`
+	// Optional.
+	DDoSProtectionPlanID *string `json:"ddos_protection_plan_id,omitzero"`
+	// Optional, defaults to [].
+	DNSServers []string `json:"dns_servers,omitzero"`
+	// Optional.
+	FlowTimeoutInMinutes *float64 `json:"flow_timeout_in_minutes,omitzero"`
+	// Optional.
+	HubNetworkResourceID *string `json:"hub_network_resource_id,omitzero"`
+	// Optional, defaults to "both".
+	HubPeeringDirection *string `json:"hub_peering_direction,omitzero"`
+	// Optional, defaults to false.
+	HubPeeringEnabled *bool `json:"hub_peering_enabled,omitzero"`
+	// Optional.
+	HubPeeringNameFromhub *string `json:"hub_peering_name_fromhub,omitzero"`
+	// Optional.
+	HubPeeringNameTohub *string `json:"hub_peering_name_tohub,omitzero"`
+	// Optional, has a default.
+	HubPeeringOptionsFromhub *VirtualNetworkVirtualNetworkHubPeeringOptionsFromhub `json:"hub_peering_options_fromhub,omitzero"`
+	// Optional, has a default.
+	HubPeeringOptionsTohub *VirtualNetworkVirtualNetworkHubPeeringOptionsTohub `json:"hub_peering_options_tohub,omitzero"`
+	// Optional.
+	Location *string `json:"location,omitzero"`
+	// Optional, defaults to false.
+	MeshPeeringAllowForwardedTraffic *bool `json:"mesh_peering_allow_forwarded_traffic,omitzero"`
+	// Optional, defaults to false.
+	MeshPeeringEnabled *bool  `json:"mesh_peering_enabled,omitzero"`
+	Name               string `json:"name"`
+	ResourceGroupName  string `json:"resource_group_name"`
+	// Optional, defaults to {}.
+	Subnets map[string]VirtualNetworkVirtualNetworkSubnet `json:"subnets,omitzero"`
+	// Optional, defaults to {}.
+	Tags map[string]string `json:"tags,omitzero"`
+	// Optional.
+	VWANAssociatedRoutetableResourceID *string `json:"vwan_associated_routetable_resource_id,omitzero"`
+	// Optional, defaults to false.
+	VWANConnectionEnabled *bool `json:"vwan_connection_enabled,omitzero"`
+	// Optional.
+	VWANConnectionName *string `json:"vwan_connection_name,omitzero"`
+	// Optional.
+	VWANHubResourceID *string `json:"vwan_hub_resource_id,omitzero"`
+	// Optional, defaults to [].
+	VWANPropagatedRoutetablesLabels []string `json:"vwan_propagated_routetables_labels,omitzero"`
+	// Optional, defaults to [].
+	VWANPropagatedRoutetablesResourceIDs []string `json:"vwan_propagated_routetables_resource_ids,omitzero"`
+	// Optional, has a default.
+	VWANSecurityConfiguration *VirtualNetworkVirtualNetworkVWANSecurityConfiguration `json:"vwan_security_configuration,omitzero"`
+}
+
+// VirtualNetworkVirtualNetworkHubPeeringOptionsFromhub is an object in the virtualnetwork submodule.
+type VirtualNetworkVirtualNetworkHubPeeringOptionsFromhub struct {
+	// Optional, defaults to true.
+	AllowForwardedTraffic *bool `json:"allow_forwarded_traffic,omitzero"`
+	// Optional, defaults to true.
+	AllowGatewayTransit *bool `json:"allow_gateway_transit,omitzero"`
+	// Optional, defaults to true.
+	AllowVirtualNetworkAccess *bool `json:"allow_virtual_network_access,omitzero"`
+	// Optional, defaults to false.
+	DoNotVerifyRemoteGateways *bool `json:"do_not_verify_remote_gateways,omitzero"`
+	// Optional, defaults to false.
+	EnableOnlyIPv6Peering *bool `json:"enable_only_ipv6_peering,omitzero"`
+	// Optional, defaults to [].
+	LocalPeeredAddressSpaces []string `json:"local_peered_address_spaces,omitzero"`
+	// Optional, defaults to [].
+	LocalPeeredSubnets []string `json:"local_peered_subnets,omitzero"`
+	// Optional, defaults to true.
+	PeerCompleteVnets *bool `json:"peer_complete_vnets,omitzero"`
+	// Optional, defaults to [].
+	RemotePeeredAddressSpaces []string `json:"remote_peered_address_spaces,omitzero"`
+	// Optional, defaults to [].
+	RemotePeeredSubnets []string `json:"remote_peered_subnets,omitzero"`
+	// Optional, defaults to false.
+	UseRemoteGateways *bool `json:"use_remote_gateways,omitzero"`
+}
+
+// VirtualNetworkVirtualNetworkHubPeeringOptionsTohub is an object in the virtualnetwork submodule.
+type VirtualNetworkVirtualNetworkHubPeeringOptionsTohub struct {
+	// Optional, defaults to true.
+	AllowForwardedTraffic *bool `json:"allow_forwarded_traffic,omitzero"`
+	// Optional, defaults to false.
+	AllowGatewayTransit *bool `json:"allow_gateway_transit,omitzero"`
+	// Optional, defaults to true.
+	AllowVirtualNetworkAccess *bool `json:"allow_virtual_network_access,omitzero"`
+	// Optional, defaults to false.
+	DoNotVerifyRemoteGateways *bool `json:"do_not_verify_remote_gateways,omitzero"`
+	// Optional, defaults to false.
+	EnableOnlyIPv6Peering *bool `json:"enable_only_ipv6_peering,omitzero"`
+	// Optional, defaults to [].
+	LocalPeeredAddressSpaces []string `json:"local_peered_address_spaces,omitzero"`
+	// Optional, defaults to [].
+	LocalPeeredSubnets []string `json:"local_peered_subnets,omitzero"`
+	// Optional, defaults to true.
+	PeerCompleteVnets *bool `json:"peer_complete_vnets,omitzero"`
+	// Optional, defaults to [].
+	RemotePeeredAddressSpaces []string `json:"remote_peered_address_spaces,omitzero"`
+	// Optional, defaults to [].
+	RemotePeeredSubnets []string `json:"remote_peered_subnets,omitzero"`
+	// Optional, defaults to true.
+	UseRemoteGateways *bool `json:"use_remote_gateways,omitzero"`
+}
+
+// VirtualNetworkVirtualNetworkSubnet is an object in the virtualnetwork submodule.
+type VirtualNetworkVirtualNetworkSubnet struct {
+	AddressPrefixes []string `json:"address_prefixes"`
+	// Optional, defaults to false.
+	DefaultOutboundAccessEnabled *bool `json:"default_outbound_access_enabled,omitzero"`
+	// Optional.
+	Delegations []VirtualNetworkVirtualNetworkSubnetDelegation `json:"delegations,omitzero"`
+	Name        string                                         `json:"name"`
+	// Optional.
+	NatGateway *VirtualNetworkVirtualNetworkSubnetNatGateway `json:"nat_gateway,omitzero"`
+	// Optional.
+	NetworkSecurityGroup *VirtualNetworkVirtualNetworkSubnetNetworkSecurityGroup `json:"network_security_group,omitzero"`
+	// Optional, defaults to "Enabled".
+	PrivateEndpointNetworkPolicies *string `json:"private_endpoint_network_policies,omitzero"`
+	// Optional, defaults to true.
+	PrivateLinkServiceNetworkPoliciesEnabled *bool `json:"private_link_service_network_policies_enabled,omitzero"`
+	// Optional.
+	RouteTable *VirtualNetworkVirtualNetworkSubnetRouteTable `json:"route_table,omitzero"`
+	// Optional.
+	ServiceEndpointPolicies map[string]VirtualNetworkVirtualNetworkSubnetServiceEndpointPolicy `json:"service_endpoint_policies,omitzero"`
+	// Optional.
+	ServiceEndpoints []string `json:"service_endpoints,omitzero"`
+}
+
+// VirtualNetworkVirtualNetworkSubnetDelegation is an object in the virtualnetwork submodule.
+type VirtualNetworkVirtualNetworkSubnetDelegation struct {
+	Name              string                                                        `json:"name"`
+	ServiceDelegation VirtualNetworkVirtualNetworkSubnetDelegationServiceDelegation `json:"service_delegation"`
+}
+
+// VirtualNetworkVirtualNetworkSubnetDelegationServiceDelegation is an object in the virtualnetwork submodule.
+type VirtualNetworkVirtualNetworkSubnetDelegationServiceDelegation struct {
+	Name string `json:"name"`
+}
+
+// VirtualNetworkVirtualNetworkSubnetNatGateway is an object in the virtualnetwork submodule.
+type VirtualNetworkVirtualNetworkSubnetNatGateway struct {
+	ID string `json:"id"`
+}
+
+// VirtualNetworkVirtualNetworkSubnetNetworkSecurityGroup is an object in the virtualnetwork submodule.
+type VirtualNetworkVirtualNetworkSubnetNetworkSecurityGroup struct {
+	ID string `json:"id"`
+}
+
+// VirtualNetworkVirtualNetworkSubnetRouteTable is an object in the virtualnetwork submodule.
+type VirtualNetworkVirtualNetworkSubnetRouteTable struct {
+	// Optional.
+	ID *string `json:"id,omitzero"`
+}
+
+// VirtualNetworkVirtualNetworkSubnetServiceEndpointPolicy is an object in the virtualnetwork submodule.
+type VirtualNetworkVirtualNetworkSubnetServiceEndpointPolicy struct {
+	ID string `json:"id"`
+}
+
+// VirtualNetworkVirtualNetworkVWANSecurityConfiguration is an object in the virtualnetwork submodule.
+type VirtualNetworkVirtualNetworkVWANSecurityConfiguration struct {
+	// Optional, defaults to false.
+	RoutingIntentEnabled *bool `json:"routing_intent_enabled,omitzero"`
+	// Optional, defaults to false.
+	SecureInternetTraffic *bool `json:"secure_internet_traffic,omitzero"`
+	// Optional, defaults to false.
+	SecurePrivateTraffic *bool `json:"secure_private_traffic,omitzero"`
+}
@@ -3,11 +3,13 @@ package integration
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/Azure/terraform-azurerm-lz-vending/tests/azureutils"
+	"github.com/Azure/terraform-azurerm-lz-vending/tests/recording"
 	"github.com/Azure/terraform-azurerm-lz-vending/tests/utils"
 	"github.com/Azure/terratest-terraform-fluent/check"
 	"github.com/Azure/terratest-terraform-fluent/setuptest"
@@ -18,27 +20,53 @@ import (
 
 func TestDeployIntegrationHubAndSpoke(t *testing.T) {
 
+	rec := recording.Start(t, filepath.Join(moduleDir, "testdata", t.Name()))
 	utils.PreCheckDeployTests(t)
-	testDir := "testdata/" + t.Name()
+	ctx, cancel := azureutils.NewTestContext(t)
+	defer cancel()
+	f, err := rec.ClientFactory()
+	require.NoError(t, err)
+
 	v, err := getValidInputVariables()
 	require.NoErrorf(t, err, "could not generate valid input variables")
-	test, err := setuptest.Dirs(moduleDir, testDir).WithVars(v).InitPlanShowWithPrepFunc(t, utils.AzureRmAndRequiredProviders)
-	require.NoError(t, err)
-	defer test.Cleanup()
 
 	// get the random hex name from vars
 	name := v["subscription_alias_name"].(string)
 
+	// The hub is in the test subscription, so check that the destroy removes the peering from it.
+	// Other test packages run in parallel in the subscription, so only resources named after this test are checked,
+	// and the peering is given a name that contains it.
+	subID := uuid.MustParse(os.Getenv("AZURE_SUBSCRIPTION_ID"))
+	f.DetectLeaks(t, &azureutils.LeakDetectorOptions{
+		Ignore: azureutils.IgnoreAllExcept(name),
+	}, subID)
+
+	hub := hubNetwork(t, rec, f, subID, name)
+	primary := v["virtual_networks"].(map[string]map[string]any)["primary"]
+	primary["hub_network_resource_id"] = hub.ID
+	primary["hub_peering_name_fromhub"] = name + "-fromhub"
+	caller, err := f.CallerObjectID(ctx)
+	require.NoError(t, err)
+	v["role_assignments"] = map[string]map[string]any{
+		"test": {
+			"principal_id":   caller,
+			"definition":     "Storage Blob Data Contributor",
+			"relative_scope": "",
+		},
+	}
+
+	test, err := setuptest.Dirs(moduleDir, "").WithVars(v).InitPlanShowWithPrepFunc(t, rec.PrepFunc(utils.AzureRmAndRequiredProviders))
+	require.NoError(t, err)
+	defer test.Cleanup()
+
 	// List of resources to find in the plan, excluding the role assignment
 	resources := []string{
-		"azurerm_resource_group.hub",
-		"azurerm_virtual_network.hub",
-		"module.lz_vending.azapi_resource.telemetry_root[0]",
-		"module.lz_vending.module.subscription[0].azurerm_subscription.this[0]",
-		"module.lz_vending.module.virtualnetwork[0].azapi_resource.peering_hub_inbound[\"primary\"]",
-		"module.lz_vending.module.virtualnetwork[0].azapi_resource.peering_hub_outbound[\"primary\"]",
-		fmt.Sprintf("module.lz_vending.module.resourcegroup[\"%s\"].azapi_resource.rg", name),
-		"module.lz_vending.module.virtualnetwork[0].azapi_resource.vnet[\"primary\"]",
+		"azapi_resource.telemetry_root[0]",
+		"module.subscription[0].azurerm_subscription.this[0]",
+		"module.virtualnetwork[0].azapi_resource.peering_hub_inbound[\"primary\"]",
+		"module.virtualnetwork[0].azapi_resource.peering_hub_outbound[\"primary\"]",
+		fmt.Sprintf("module.resourcegroup[\"%s\"].azapi_resource.rg", name),
+		"module.virtualnetwork[0].azapi_resource.vnet[\"primary\"]",
 	}
 
 	for _, v := range resources {
@@ -50,7 +78,7 @@ func TestDeployIntegrationHubAndSpoke(t *testing.T) {
 	// Instead, we search for the role assignment prefix in the ResourcePlannedValuesMap.
 	i := 0
 	for k := range test.PlanStruct.ResourceChangesMap {
-		if !strings.Contains(k, "module.lz_vending.module.roleassignment[") {
+		if !strings.Contains(k, "module.roleassignment[") {
 			continue
 		}
 		i++
@@ -60,13 +88,11 @@ func TestDeployIntegrationHubAndSpoke(t *testing.T) {
 
 	// Defer the cleanup of the subscription alias to the end of the test.
 	// Should be run after the Terraform destroy.
-	// We don't know the sub ID yet, so use zeros for now and then
-	// update it after the apply.
-	u := uuid.MustParse("00000000-0000-0000-0000-000000000000")
+	// The subscription ID is resolved from the alias name when the cleanup runs.
+	alias := f.NewSubscriptionAlias(name)
 	defer func() {
-		err := azureutils.CancelSubscription(t, &u)
-		if err != nil {
-			t.Logf("failed to cancel subscription: %v", err)
+		if err := alias.Cleanup(ctx, t); err != nil {
+			t.Logf("failed to clean up subscription alias: %v", err)
 		}
 	}()
 
@@ -79,25 +105,39 @@ func TestDeployIntegrationHubAndSpoke(t *testing.T) {
 	defer test.DestroyRetry(rty) //nolint:errcheck
 	test.ApplyIdempotent().ErrorIsNil(t)
 
+	a, err := alias.Wait(ctx, 0)
+	require.NoErrorf(t, err, "subscription alias %s is not provisioned", name)
+	u := a.SubscriptionID
 	id, err := test.Output("subscription_id").GetValue()
 	assert.NoErrorf(t, err, "failed to get subscription id output")
-	ids, ok := id.(string)
-	assert.Truef(t, ok, "failed to cast subscription id output to string")
-	u, err = uuid.Parse(ids)
-	assert.NoErrorf(t, err, "cannot parse subscription id as uuid: %s", id)
+	assert.Equalf(t, u.String(), id, "subscription id output does not match alias %s", name)
+
+	// The module registers its default resource providers in the new subscription.
+	providers, err := utils.DefaultResourceProviders(moduleDir)
+	require.NoError(t, err)
+	_, err = f.WaitForResourceProviders(ctx, u, providers, nil)
+	assert.NoErrorf(t, err, "default resource providers are not registered")
+	diff, err := f.DiffResourceProviders(ctx, u, providers)
+	require.NoError(t, err)
+	for _, d := range diff {
+		if d.Expected == nil {
+			t.Logf("resource provider registered outside the module defaults: %s", d.ResourceID)
+		}
+	}
 }
 
 func TestDeployIntegrationResourceGroupsRpRegUmiAndRoleAssignments(t *testing.T) {
 
-	utils.PreCheckDeployTests(t)
 	testDir := "testdata/" + t.Name()
+	rec := recording.Start(t, filepath.Join(moduleDir, testDir))
+	utils.PreCheckDeployTests(t)
 	r, err := utils.RandomHex(4)
 	require.NoError(t, err)
 	v := map[string]any{
 		"random_hex":      r,
 		"subscription_id": os.Getenv("AZURE_SUBSCRIPTION_ID"),
 	}
-	test, err := setuptest.Dirs(moduleDir, testDir).WithVars(v).InitPlanShow(t)
+	test, err := setuptest.Dirs(moduleDir, testDir).WithVars(v).InitPlanShowWithPrepFunc(t, rec.PrepFunc(nil))
 	require.NoError(t, err)
 	defer test.Cleanup()
 
@@ -105,6 +145,56 @@ func TestDeployIntegrationResourceGroupsRpRegUmiAndRoleAssignments(t *testing.T)
 	test.ApplyIdempotent().ErrorIsNil(t)
 }
 
+// TestDeployIntegrationLockedResourceGroup tests that a subscription whose resource group is created with lock_enabled
+// is cancelled by the test teardown, which removes the lock, rather than by a Terraform destroy, which would remove it first.
+func TestDeployIntegrationLockedResourceGroup(t *testing.T) {
+
+	utils.PreCheckDeployTests(t)
+	ctx, cancel := azureutils.NewTestContext(t)
+	defer cancel()
+
+	v, err := getValidInputVariables()
+	require.NoErrorf(t, err, "could not generate valid input variables")
+	name := v["subscription_alias_name"].(string)
+	delete(v, "virtual_networks")
+	v["virtual_network_enabled"] = false
+	v["role_assignment_enabled"] = false
+	rg := v["resource_groups"].(map[string]map[string]any)["rg1"]
+	rg["lock_enabled"] = true
+
+	test, err := setuptest.Dirs(moduleDir, "").WithVars(v).InitPlanShowWithPrepFunc(t, utils.AzureRmAndRequiredProviders)
+	require.NoError(t, err)
+	defer test.Cleanup()
+	check.InPlan(test.PlanStruct).That(`module.resourcegroup["rg1"].azapi_resource.rg_lock[0]`).Exists().ErrorIsNil(t)
+
+	alias, err := azureutils.NewSubscriptionAlias(name)
+	require.NoError(t, err)
+	cleanedUp := false
+	defer func() {
+		if cleanedUp {
+			return
+		}
+		if err := alias.Cleanup(ctx, t); err != nil {
+			t.Logf("failed to clean up subscription alias: %v", err)
+		}
+	}()
+
+	test.ApplyIdempotent().ErrorIsNil(t)
+	a, err := alias.Wait(ctx, 0)
+	require.NoErrorf(t, err, "subscription alias %s is not provisioned", name)
+	locks, err := azureutils.ListResourceGroupLocks(ctx, rg["name"].(string), a.SubscriptionID)
+	require.NoError(t, err)
+	require.NotEmpty(t, locks, "expected the resource group to be locked")
+
+	cleanedUp = true
+	require.NoError(t, alias.Cleanup(ctx, t), "the locked resource group should not stop the subscription being cancelled")
+	rgs, err := azureutils.ListResourceGroup(ctx, a.SubscriptionID)
+	require.NoError(t, err)
+	for _, got := range rgs {
+		assert.NotEqual(t, rg["name"], *got.Name, "the locked resource group should be deleted")
+	}
+}
+
 func getValidInputVariables() (map[string]any, error) {
 	r, err := utils.RandomHex(4)
 	if err != nil {
@@ -129,14 +219,6 @@ func getValidInputVariables() (map[string]any, error) {
 				"hub_peering_enabled":             true,
 				"hub_peering_use_remote_gateways": false,
 			},
-			"secondary": {
-				"name":                            name,
-				"resource_group_name_existing":    fmt.Sprintf("rg-%s", r),
-				"location":                        "northeurope",
-				"address_space":                   []string{"10.1.0.0/24", "172.16.1.0/24"},
-				"hub_peering_enabled":             true,
-				"hub_peering_use_remote_gateways": false,
-			},
 		},
 		"resource_group_creation_enabled": true,
 		"resource_groups": map[string]map[string]any{
@@ -51,6 +51,7 @@ func TestIntegrationHubAndSpoke(t *testing.T) {
 
 	check.InPlan(test.PlanStruct).PlannedResourcesAre(resources...).ErrorIsNil(t)
 	check.InPlan(test.PlanStruct).That("azapi_resource.telemetry_root[0]").Key("name").ContainsString("00000305").ErrorIsNil(t)
+	utils.MatchPlanSnapshot(t, test.PlanStruct, &utils.SnapshotOptions{Replace: utils.EnvironmentReplacements()})
 }
 
 // TestIntegrationVirtualNetworkMissingResourceGroupReference ensures validation fails when
@@ -75,8 +76,13 @@ func TestIntegrationVirtualNetworkMissingResourceGroupReference(t *testing.T) {
 
 	test, err := setuptest.Dirs(moduleDir, "").WithVars(v).InitPlanShowWithPrepFunc(t, utils.AzureRmAndRequiredProviders)
 	defer test.Cleanup()
-	assert.ErrorContains(t, err, "Each virtual network must specify either 'resource_group_key' or")
-	assert.ErrorContains(t, err, "'resource_group_name_existing'")
+	require.Error(t, err)
+
+	diags, err := utils.PlanDiagnostics(t, test.Options)
+	require.NoError(t, err)
+	d, ok := diags.FindError("var.virtual_networks", utils.SummaryInvalidVariableValue)
+	require.True(t, ok, diags.String())
+	assert.Contains(t, d.Detail, "Each virtual network must specify either 'resource_group_key' or 'resource_group_name_existing'.")
 }
 
 // TestIntegrationVwan tests the resource plan when creating a new subscription,
@@ -113,6 +119,7 @@ func TestIntegrationVwan(t *testing.T) {
 
 	check.InPlan(test.PlanStruct).PlannedResourcesAre(resources...).ErrorIsNil(t)
 	check.InPlan(test.PlanStruct).That("azapi_resource.telemetry_root[0]").Key("name").ContainsString("00000505").ErrorIsNil(t)
+	utils.MatchPlanSnapshot(t, test.PlanStruct, &utils.SnapshotOptions{Replace: utils.EnvironmentReplacements()})
 }
 
 // TestIntegrationSubscriptionAndRoleAssignmentOnly tests the resource plan when creating a new subscription,
@@ -149,6 +156,7 @@ func TestIntegrationSubscriptionAndRoleAssignmentOnly(t *testing.T) {
 	}
 
 	check.InPlan(test.PlanStruct).PlannedResourcesAre(resources...).ErrorIsNil(t)
+	utils.MatchPlanSnapshot(t, test.PlanStruct, &utils.SnapshotOptions{Replace: utils.EnvironmentReplacements()})
 }
 
 // TestIntegrationHubAndSpokeExistingSubscription tests the resource plan when supplying an existing subscription,
@@ -0,0 +1,53 @@
+package integration
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Azure/terraform-azurerm-lz-vending/tests/azureutils"
+	"github.com/Azure/terraform-azurerm-lz-vending/tests/recording"
+	"github.com/Azure/terraform-azurerm-lz-vending/tests/utils"
+	"github.com/google/uuid"
+)
+
+// hubLocation is the location of the hub network, which is the location of the spoke virtual networks.
+const hubLocation = "northeurope"
+
+// fixtures are the hub networks shared by the deploy tests in this package.
+var fixtures = azureutils.NewFixturePool(nil)
+
+// fixturePrefix is the name prefix of the shared fixtures, unique to this test run.
+var fixturePrefix string
+
+func TestMain(m *testing.M) {
+	r, err := utils.RandomHex(4)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cannot generate random hex, %s\n", err)
+		os.Exit(1)
+	}
+	fixturePrefix = "testdeploy-" + r
+
+	code := m.Run()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+	if err := fixtures.Close(ctx); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		if code == 0 {
+			code = 1
+		}
+	}
+	os.Exit(code)
+}
+
+// hubNetwork returns the shared hub network.
+// When the test is recorded or replayed, a hub network named after the test is created instead,
+// using the client factory of the recorder, so that its creation and deletion are in the cassette.
+func hubNetwork(t *testing.T, rec *recording.Recorder, f *azureutils.ClientFactory, subID uuid.UUID, name string) *azureutils.HubNetwork {
+	if rec.Mode() != recording.ModeOff {
+		return f.NewHubNetwork(t, subID, &azureutils.HubNetworkOptions{Name: name + "-hub", Location: hubLocation})
+	}
+	return fixtures.HubNetwork(t, subID, &azureutils.HubNetworkOptions{Name: fixturePrefix + "-hub", Location: hubLocation})
+}
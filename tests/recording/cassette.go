@@ -0,0 +1,171 @@
+package recording
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// recordedHeaders are the response headers that are kept in a cassette.
+// Other headers are dropped as they contain request IDs, timestamps and other values that change between runs.
+var recordedHeaders = []string{
+	"Azure-AsyncOperation",
+	"Content-Type",
+	"Location",
+	"Operation-Location",
+	"Retry-After",
+	"X-Ms-Error-Code",
+}
+
+// Cassette is the sanitised traffic recorded for a single test.
+type Cassette struct {
+	// Random is the hex encoded bytes read by utils.RandomHex during the test.
+	Random       string        `json:"random,omitempty"`
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Interaction is a single request and its response.
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+// Request is a recorded request.
+// The URL is the path and sorted query, without the scheme and host.
+type Request struct {
+	Method string `json:"method"`
+	URL    string `json:"url"`
+	Body   string `json:"body,omitempty"`
+}
+
+// Response is a recorded response.
+type Response struct {
+	StatusCode int               `json:"status_code"`
+	Headers    map[string]string `json:"headers,omitempty"`
+	Body       string            `json:"body,omitempty"`
+}
+
+// key returns the value used to match a request to a recorded interaction.
+func (r Request) key() string {
+	return r.Method + " " + r.URL
+}
+
+// fuzzyKey returns the key with every UUID replaced,
+// so that requests for resources with generated names can be matched.
+func (r Request) fuzzyKey() string {
+	return uuidRegexp.ReplaceAllString(r.key(), "{uuid}")
+}
+
+// loadCassette reads the cassette from the supplied path.
+func loadCassette(path string) (*Cassette, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c := new(Cassette)
+	if err := json.Unmarshal(b, c); err != nil {
+		return nil, fmt.Errorf("cannot parse cassette %s: %v", path, err)
+	}
+	return c, nil
+}
+
+// save writes the cassette to the supplied path, creating the directory if required.
+func (c *Cassette) save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("cannot create cassette directory: %v", err)
+	}
+	b, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("cannot marshal cassette: %v", err)
+	}
+	return os.WriteFile(path, append(b, '\n'), 0644)
+}
+
+// player serves the interactions in a cassette.
+// Interactions with the same key are served in the order they were recorded,
+// and the last is served again once they are exhausted, so that a poller that
+// makes more requests than were recorded sees the final state.
+type player struct {
+	mu           sync.Mutex
+	interactions []Interaction
+	used         []bool
+	last         map[string]int
+}
+
+func newPlayer(c *Cassette) *player {
+	return &player{
+		interactions: c.Interactions,
+		used:         make([]bool, len(c.Interactions)),
+		last:         make(map[string]int),
+	}
+}
+
+// next returns the interaction that matches the request.
+// It matches the method and URL exactly, then ignoring UUIDs,
+// then repeats the last exact match.
+func (p *player) next(req Request) (Interaction, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, match := range []func(Request) bool{
+		func(r Request) bool { return r.key() == req.key() },
+		func(r Request) bool { return r.fuzzyKey() == req.fuzzyKey() },
+	} {
+		for i, in := range p.interactions {
+			if p.used[i] || !match(in.Request) {
+				continue
+			}
+			p.used[i] = true
+			p.last[req.key()] = i
+			return in, true
+		}
+	}
+	if i, ok := p.last[req.key()]; ok {
+		return p.interactions[i], true
+	}
+	return Interaction{}, false
+}
+
+// unused returns the number of interactions that were not replayed.
+func (p *player) unused() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	n := 0
+	for _, u := range p.used {
+		if !u {
+			n++
+		}
+	}
+	return n
+}
+
+// normaliseURL returns the path and query of the URL with the query parameters sorted.
+func normaliseURL(u *url.URL) string {
+	s := u.EscapedPath()
+	if q := u.Query(); len(q) > 0 {
+		s += "?" + q.Encode()
+	}
+	return s
+}
+
+// normaliseBody returns JSON bodies re-encoded with sorted keys and no insignificant whitespace,
+// so that they can be compared. Other bodies are returned unchanged.
+func normaliseBody(b []byte) string {
+	if len(bytes.TrimSpace(b)) == 0 {
+		return ""
+	}
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	var v any
+	if err := d.Decode(&v); err != nil {
+		return string(b)
+	}
+	out, err := json.Marshal(v)
+	if err != nil {
+		return string(b)
+	}
+	return string(out)
+}
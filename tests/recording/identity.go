@@ -0,0 +1,92 @@
+package recording
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+)
+
+// replayToken returns an unsigned access token with the placeholder identity as its claims.
+// The Terraform providers read the object and tenant IDs of the caller from the token.
+func replayToken(audience string) string {
+	enc := func(v any) string {
+		b, _ := json.Marshal(v)
+		return base64.RawURLEncoding.EncodeToString(b)
+	}
+	now := time.Now()
+	header := enc(map[string]any{"alg": "none", "typ": "JWT"})
+	claims := enc(map[string]any{
+		"aud":   audience,
+		"iss":   "https://sts.windows.net/" + TenantPlaceholder + "/",
+		"iat":   now.Unix(),
+		"nbf":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+		"appid": ClientPlaceholder,
+		"azp":   ClientPlaceholder,
+		"oid":   ObjectPlaceholder,
+		"sub":   ObjectPlaceholder,
+		"tid":   TenantPlaceholder,
+	})
+	return header + "." + claims + ".replay"
+}
+
+// serveIdentity emulates the Entra ID endpoints used to acquire a token with a client secret,
+// so that the Terraform providers can authenticate while a cassette is replayed.
+func serveIdentity(w http.ResponseWriter, r *http.Request, audience string) {
+	base := "https://" + r.URL.Host
+	segs := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case strings.HasSuffix(r.URL.Path, "/discovery/instance"):
+		writeJSON(w, map[string]any{
+			"tenant_discovery_endpoint": base + "/" + TenantPlaceholder + "/v2.0/.well-known/openid-configuration",
+			"api-version":               "1.1",
+			"metadata": []any{
+				map[string]any{
+					"preferred_network": r.URL.Host,
+					"preferred_cache":   r.URL.Host,
+					"aliases":           []string{r.URL.Host},
+				},
+			},
+		})
+	case strings.HasSuffix(r.URL.Path, "/.well-known/openid-configuration"):
+		tenant := base + "/" + segs[0]
+		writeJSON(w, map[string]any{
+			"authorization_endpoint": tenant + "/oauth2/v2.0/authorize",
+			"token_endpoint":         tenant + "/oauth2/v2.0/token",
+			"issuer":                 tenant + "/v2.0",
+		})
+	case strings.Contains(r.URL.Path, "/oauth2/") && strings.HasSuffix(r.URL.Path, "token"):
+		writeJSON(w, map[string]any{
+			"token_type":     "Bearer",
+			"expires_in":     3599,
+			"ext_expires_in": 3599,
+			"access_token":   replayToken(audience),
+		})
+	default:
+		http.Error(w, "the recording proxy does not emulate "+r.URL.Path, http.StatusNotFound)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v) //nolint:errcheck
+}
+
+// replayCredential issues replayToken to the Go SDK clients while a cassette is replayed.
+type replayCredential struct {
+	audience string
+}
+
+// GetToken implements azcore.TokenCredential.
+func (c replayCredential) GetToken(_ context.Context, _ policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	return azcore.AccessToken{
+		Token:     replayToken(c.audience),
+		ExpiresOn: time.Now().Add(time.Hour),
+	}, nil
+}
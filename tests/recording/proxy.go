@@ -0,0 +1,278 @@
+package recording
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// proxy is an HTTP CONNECT proxy that terminates TLS for the intercepted hosts,
+// using certificates issued by its own certificate authority, and passes their requests to the handler.
+// Connections to other hosts, such as the Terraform registry, are tunnelled unchanged.
+type proxy struct {
+	ln        net.Listener
+	srv       *http.Server
+	intercept func(host string) bool
+	handler   http.Handler
+
+	ca    *x509.Certificate
+	caKey *ecdsa.PrivateKey
+	caPEM []byte
+
+	mu    sync.Mutex
+	certs map[string]*tls.Certificate
+	conns map[net.Conn]struct{}
+}
+
+// newProxy starts a proxy listening on a random loopback port.
+func newProxy(intercept func(host string) bool, handler http.Handler) (*proxy, error) {
+	p := &proxy{
+		intercept: intercept,
+		handler:   handler,
+		certs:     make(map[string]*tls.Certificate),
+		conns:     make(map[net.Conn]struct{}),
+	}
+	if err := p.newCA(); err != nil {
+		return nil, err
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("cannot start recording proxy: %v", err)
+	}
+	p.ln = ln
+	p.srv = &http.Server{
+		Handler:           http.HandlerFunc(p.serveConnect),
+		ReadHeaderTimeout: 30 * time.Second,
+	}
+	go p.srv.Serve(ln) //nolint:errcheck
+	return p, nil
+}
+
+// URL returns the proxy URL, for use in HTTPS_PROXY.
+func (p *proxy) URL() *url.URL {
+	return &url.URL{Scheme: "http", Host: p.ln.Addr().String()}
+}
+
+// CertPool returns a pool containing the proxy's certificate authority.
+func (p *proxy) CertPool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(p.ca)
+	return pool
+}
+
+// Client returns an HTTP client that uses the proxy and trusts its certificate authority.
+func (p *proxy) Client() *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{
+			Proxy:           http.ProxyURL(p.URL()),
+			TLSClientConfig: &tls.Config{RootCAs: p.CertPool()},
+		},
+	}
+}
+
+// Close stops the proxy and closes any open connections.
+func (p *proxy) Close() {
+	p.srv.Close() //nolint:errcheck
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for c := range p.conns {
+		c.Close() //nolint:errcheck
+	}
+}
+
+func (p *proxy) track(c net.Conn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.conns[c] = struct{}{}
+}
+
+func (p *proxy) untrack(c net.Conn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.conns, c)
+	c.Close() //nolint:errcheck
+}
+
+// serveConnect handles a CONNECT request by intercepting or tunnelling the connection.
+func (p *proxy) serveConnect(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodConnect {
+		http.Error(w, "the recording proxy only supports CONNECT", http.StatusMethodNotAllowed)
+		return
+	}
+	host, _, err := net.SplitHostPort(r.Host)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "connection cannot be hijacked", http.StatusInternalServerError)
+		return
+	}
+	conn, _, err := hj.Hijack()
+	if err != nil {
+		return
+	}
+	p.track(conn)
+	defer p.untrack(conn)
+	if _, err := io.WriteString(conn, "HTTP/1.1 200 Connection Established\r\n\r\n"); err != nil {
+		return
+	}
+
+	if !p.intercept(host) {
+		p.tunnel(conn, r.Host)
+		return
+	}
+
+	tlsConn := tls.Server(conn, &tls.Config{
+		GetCertificate: p.certificate,
+		NextProtos:     []string{"http/1.1"},
+	})
+	l := newConnListener(tlsConn)
+	srv := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			req.URL.Scheme = "https"
+			req.URL.Host = host
+			p.handler.ServeHTTP(w, req)
+		}),
+		ConnState: func(_ net.Conn, state http.ConnState) {
+			if state == http.StateClosed || state == http.StateHijacked {
+				l.Close() //nolint:errcheck
+			}
+		},
+		ReadHeaderTimeout: 30 * time.Second,
+	}
+	srv.Serve(l) //nolint:errcheck
+}
+
+// tunnel copies data between the client connection and the upstream address until either side closes.
+func (p *proxy) tunnel(conn net.Conn, addr string) {
+	upstream, err := net.DialTimeout("tcp", addr, 30*time.Second)
+	if err != nil {
+		return
+	}
+	p.track(upstream)
+	defer p.untrack(upstream)
+	done := make(chan struct{}, 2)
+	go func() {
+		io.Copy(upstream, conn) //nolint:errcheck
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(conn, upstream) //nolint:errcheck
+		done <- struct{}{}
+	}()
+	<-done
+}
+
+// newCA creates the short-lived certificate authority that issues the intercepted host certificates.
+func (p *proxy) newCA() error {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return fmt.Errorf("cannot create recording proxy key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "lz-vending recording proxy"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return fmt.Errorf("cannot create recording proxy certificate authority: %v", err)
+	}
+	ca, err := x509.ParseCertificate(der)
+	if err != nil {
+		return fmt.Errorf("cannot parse recording proxy certificate authority: %v", err)
+	}
+	p.ca = ca
+	p.caKey = key
+	p.caPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	return nil
+}
+
+// certificate returns a certificate for the requested server name, issued by the proxy certificate authority.
+func (p *proxy) certificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if c, ok := p.certs[hello.ServerName]; ok {
+		return c, nil
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: hello.ServerName},
+		DNSNames:     []string{hello.ServerName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, p.ca, &key.PublicKey, p.caKey)
+	if err != nil {
+		return nil, err
+	}
+	c := &tls.Certificate{
+		Certificate: [][]byte{der},
+		PrivateKey:  key,
+	}
+	p.certs[hello.ServerName] = c
+	return c, nil
+}
+
+// connListener is a net.Listener that returns a single connection,
+// so that an http.Server can serve an intercepted connection.
+type connListener struct {
+	conn net.Conn
+	once sync.Once
+	ch   chan net.Conn
+	done chan struct{}
+}
+
+func newConnListener(conn net.Conn) *connListener {
+	l := &connListener{
+		conn: conn,
+		ch:   make(chan net.Conn, 1),
+		done: make(chan struct{}),
+	}
+	l.ch <- conn
+	return l
+}
+
+func (l *connListener) Accept() (net.Conn, error) {
+	select {
+	case c := <-l.ch:
+		return c, nil
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *connListener) Close() error {
+	l.once.Do(func() {
+		close(l.done)
+	})
+	return nil
+}
+
+func (l *connListener) Addr() net.Addr {
+	return l.conn.LocalAddr()
+}
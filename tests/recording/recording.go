@@ -0,0 +1,480 @@
+// Package recording records the Azure Resource Manager traffic of a deployment test to a cassette,
+// and replays the cassette so that the test can be run again without Azure access.
+//
+// Traffic from the Go SDK clients and the Terraform providers is captured by a local HTTPS proxy.
+// Set TERRATEST_RECORDING to "record" to run against Azure and write the cassette,
+// or to "replay" to serve the cassette from the proxy.
+// Cassettes are sanitised of tokens, subscription IDs and tenant IDs before they are written.
+package recording
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
+	"github.com/Azure/terraform-azurerm-lz-vending/tests/azureutils"
+	"github.com/Azure/terraform-azurerm-lz-vending/tests/utils"
+	"github.com/Azure/terratest-terraform-fluent/setuptest"
+)
+
+// Mode is the recording mode of a test.
+type Mode string
+
+const (
+	// ModeOff runs the test against Azure without recording.
+	ModeOff Mode = ""
+	// ModeRecord runs the test against Azure and writes the cassette.
+	ModeRecord Mode = "record"
+	// ModeReplay serves the cassette instead of calling Azure.
+	ModeReplay Mode = "replay"
+
+	// ModeEnvVar is the environment variable that selects the mode.
+	ModeEnvVar = "TERRATEST_RECORDING"
+
+	// CassetteSuffix is appended to the test directory to give the cassette path.
+	CassetteSuffix = ".cassette.json"
+)
+
+// caBundlePaths are the locations of the system certificate bundle on common Linux distributions.
+// The bundle is combined with the proxy certificate authority so that Terraform can still reach
+// the hosts that are not intercepted.
+var caBundlePaths = []string{
+	"/etc/ssl/certs/ca-certificates.crt",
+	"/etc/pki/tls/certs/ca-bundle.crt",
+	"/etc/ssl/ca-bundle.pem",
+	"/etc/ssl/cert.pem",
+}
+
+// Recorder records or replays the traffic of a single test.
+type Recorder struct {
+	t        *testing.T
+	mode     Mode
+	path     string
+	options  *arm.ClientOptions
+	proxy    *proxy
+	caFile   string
+	armHost  string
+	authHost string
+
+	// cred is the credential used by the Go SDK clients when recording.
+	// If nil, the credential of the azureutils.DefaultClientFactory is used.
+	cred azcore.TokenCredential
+	// upstream sends the intercepted requests to Azure when recording.
+	upstream http.RoundTripper
+
+	sanitiser *sanitiser
+	player    *player
+
+	mu           sync.Mutex
+	random       bytes.Buffer
+	interactions []Interaction
+	errs         []string
+}
+
+// ModeFromEnv returns the mode selected by the TERRATEST_RECORDING env var.
+func ModeFromEnv() (Mode, error) {
+	switch m := Mode(strings.ToLower(os.Getenv(ModeEnvVar))); m {
+	case ModeOff, ModeRecord, ModeReplay:
+		return m, nil
+	default:
+		return ModeOff, fmt.Errorf("unknown %s value %q, must be %q or %q", ModeEnvVar, m, ModeRecord, ModeReplay)
+	}
+}
+
+// Start starts recording or replaying the test, using the mode selected by the TERRATEST_RECORDING env var.
+// The cassette is stored next to the supplied test directory, e.g. testdata/TestName.cassette.json.
+//
+// Call Start before utils.PreCheckDeployTests, as when replaying it sets the environment
+// that the deployment tests expect, using placeholder values.
+// It also controls utils.RandomHex, so generate any names after it is called.
+// Start does nothing if the mode is off.
+func Start(t *testing.T, testDir string) *Recorder {
+	t.Helper()
+	mode, err := ModeFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts, err := azureutils.DefaultClientOptions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := newRecorder(t, mode, filepath.Clean(testDir)+CassetteSuffix, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+// newRecorder creates a recorder for the cassette at path.
+// The intercepted hosts are taken from the cloud configuration in options.
+func newRecorder(t *testing.T, mode Mode, path string, options *arm.ClientOptions) (*Recorder, error) {
+	r := &Recorder{
+		t:         t,
+		mode:      mode,
+		path:      path,
+		options:   options,
+		sanitiser: newSanitiser(),
+		upstream: &http.Transport{
+			Proxy:             http.ProxyFromEnvironment,
+			ForceAttemptHTTP2: true,
+		},
+	}
+	if mode == ModeOff {
+		return r, nil
+	}
+
+	armURL, err := url.Parse(options.Cloud.Services[cloud.ResourceManager].Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse resource manager endpoint: %v", err)
+	}
+	authURL, err := url.Parse(options.Cloud.ActiveDirectoryAuthorityHost)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse authority host: %v", err)
+	}
+	r.armHost = armURL.Hostname()
+	r.authHost = authURL.Hostname()
+
+	switch mode {
+	case ModeRecord:
+		r.recordEnv()
+		utils.SetRandomReader(t, io.TeeReader(rand.Reader, &r.random))
+	case ModeReplay:
+		c, err := loadCassette(path)
+		if os.IsNotExist(err) {
+			t.Skipf("no cassette at %s, run with %s=%s to record one", path, ModeEnvVar, ModeRecord)
+		}
+		if err != nil {
+			return nil, err
+		}
+		random, err := hex.DecodeString(c.Random)
+		if err != nil {
+			return nil, fmt.Errorf("cannot decode cassette random bytes: %v", err)
+		}
+		r.player = newPlayer(c)
+		r.replayEnv()
+		utils.SetRandomReader(t, bytes.NewReader(random))
+	}
+
+	p, err := newProxy(r.intercept, r)
+	if err != nil {
+		return nil, err
+	}
+	r.proxy = p
+	if r.caFile, err = r.writeCABundle(); err != nil {
+		p.Close()
+		return nil, err
+	}
+	t.Cleanup(r.stop)
+	return r, nil
+}
+
+// Mode returns the recording mode.
+func (r *Recorder) Mode() Mode {
+	return r.mode
+}
+
+// ClientFactory returns an azureutils.ClientFactory whose clients send their requests through the recorder.
+// If the mode is off, the azureutils.DefaultClientFactory is returned.
+func (r *Recorder) ClientFactory() (*azureutils.ClientFactory, error) {
+	if r.mode == ModeOff {
+		return azureutils.DefaultClientFactory()
+	}
+	cred := r.cred
+	switch {
+	case r.mode == ModeReplay:
+		cred = replayCredential{audience: r.options.Cloud.Services[cloud.ResourceManager].Audience}
+	case cred == nil:
+		f, err := azureutils.DefaultClientFactory()
+		if err != nil {
+			return nil, err
+		}
+		cred = f.Credential()
+	}
+	opts := *r.options
+	opts.Transport = r.proxy.Client()
+	return azureutils.NewClientFactory(cred, &opts), nil
+}
+
+// PrepFunc returns a setuptest.PrepFunc that routes the Terraform provider traffic through the recorder,
+// and then calls next, if it is not nil.
+func (r *Recorder) PrepFunc(next setuptest.PrepFunc) setuptest.PrepFunc {
+	return func(resp setuptest.Response) error {
+		if r.mode != ModeOff {
+			if resp.Options.EnvVars == nil {
+				resp.Options.EnvVars = make(map[string]string)
+			}
+			for k, v := range r.terraformEnv() {
+				resp.Options.EnvVars[k] = v
+			}
+		}
+		if next == nil {
+			return nil
+		}
+		return next(resp)
+	}
+}
+
+// terraformEnv returns the environment that sends the Terraform traffic to the proxy.
+// SSL_CERT_FILE is honoured by Go programs on Linux, including Terraform and its providers.
+func (r *Recorder) terraformEnv() map[string]string {
+	return map[string]string{
+		"HTTPS_PROXY":   r.proxy.URL().String(),
+		"https_proxy":   r.proxy.URL().String(),
+		"NO_PROXY":      "",
+		"no_proxy":      "",
+		"SSL_CERT_FILE": r.caFile,
+	}
+}
+
+// recordEnv adds the identity from the environment to the sanitiser.
+func (r *Recorder) recordEnv() {
+	for _, e := range []string{"AZURE_SUBSCRIPTION_ID", "ARM_SUBSCRIPTION_ID"} {
+		r.sanitiser.addID(os.Getenv(e), SubscriptionPlaceholder)
+	}
+	for _, e := range []string{"AZURE_TENANT_ID", "ARM_TENANT_ID"} {
+		r.sanitiser.addID(os.Getenv(e), TenantPlaceholder)
+	}
+	for _, e := range []string{"AZURE_CLIENT_ID", "ARM_CLIENT_ID"} {
+		r.sanitiser.addID(os.Getenv(e), ClientPlaceholder)
+	}
+	r.sanitiser.addLiteral(os.Getenv("AZURE_BILLING_SCOPE"), BillingScopePlaceholder)
+}
+
+// replayEnv sets the environment used by the deployment tests and the Terraform providers to the placeholders.
+// The providers authenticate with a client secret, which is accepted by the emulated Entra ID endpoints.
+func (r *Recorder) replayEnv() {
+	env := map[string]string{
+		"TERRATEST_DEPLOY":      "true",
+		"AZURE_BILLING_SCOPE":   BillingScopePlaceholder,
+		"AZURE_SUBSCRIPTION_ID": SubscriptionPlaceholder,
+		"AZURE_TENANT_ID":       TenantPlaceholder,
+		"ARM_SUBSCRIPTION_ID":   SubscriptionPlaceholder,
+		"ARM_TENANT_ID":         TenantPlaceholder,
+		"ARM_CLIENT_ID":         ClientPlaceholder,
+		"ARM_CLIENT_SECRET":     "replay",
+		"ARM_USE_CLI":           "false",
+		"ARM_USE_MSI":           "false",
+		"ARM_USE_OIDC":          "false",
+	}
+	for k, v := range env {
+		r.t.Setenv(k, v)
+	}
+}
+
+// writeCABundle writes the proxy certificate authority and the system certificate bundle to a temporary file.
+func (r *Recorder) writeCABundle() (string, error) {
+	bundle := append([]byte(nil), r.proxy.caPEM...)
+	for _, p := range caBundlePaths {
+		if b, err := os.ReadFile(p); err == nil {
+			bundle = append(bundle, b...)
+			break
+		}
+	}
+	path := filepath.Join(r.t.TempDir(), "recording-ca.pem")
+	if err := os.WriteFile(path, bundle, 0600); err != nil {
+		return "", fmt.Errorf("cannot write recording proxy certificate bundle: %v", err)
+	}
+	return path, nil
+}
+
+// intercept reports whether the proxy should terminate TLS for the host.
+// Entra ID is only intercepted when replaying, so that tokens are never recorded.
+func (r *Recorder) intercept(host string) bool {
+	if strings.EqualFold(host, r.armHost) {
+		return true
+	}
+	return r.mode == ModeReplay && strings.EqualFold(host, r.authHost)
+}
+
+// ServeHTTP handles a request intercepted by the proxy.
+func (r *Recorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if r.mode == ModeReplay {
+		r.replay(w, req, body)
+		return
+	}
+	r.record(w, req, body)
+}
+
+// record sends the request to Azure and keeps the interaction.
+// The interactions are sanitised when the test completes, once every ID has been seen.
+func (r *Recorder) record(w http.ResponseWriter, req *http.Request, body []byte) {
+	r.sanitiser.addToken(req.Header.Get("Authorization"))
+
+	out, err := http.NewRequestWithContext(req.Context(), req.Method, req.URL.String(), bytes.NewReader(body))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	out.Header = req.Header.Clone()
+	// Let the transport negotiate compression so that the recorded body is plain text.
+	out.Header.Del("Accept-Encoding")
+	removeHopHeaders(out.Header)
+
+	resp, err := r.upstream.RoundTrip(out)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	headers := make(map[string]string)
+	for _, h := range recordedHeaders {
+		if v := resp.Header.Get(h); v != "" {
+			headers[h] = v
+		}
+	}
+	r.mu.Lock()
+	r.interactions = append(r.interactions, Interaction{
+		Request: Request{
+			Method: req.Method,
+			URL:    normaliseURL(req.URL),
+			Body:   normaliseBody(body),
+		},
+		Response: Response{
+			StatusCode: resp.StatusCode,
+			Headers:    headers,
+			Body:       string(respBody),
+		},
+	})
+	r.mu.Unlock()
+
+	removeHopHeaders(resp.Header)
+	resp.Header.Del("Content-Length")
+	for k, v := range resp.Header {
+		w.Header()[k] = v
+	}
+	w.WriteHeader(resp.StatusCode)
+	w.Write(respBody) //nolint:errcheck
+}
+
+// replay serves the recorded response for the request.
+// A request body that differs from the recording is reported as a test error when the test completes.
+func (r *Recorder) replay(w http.ResponseWriter, req *http.Request, body []byte) {
+	if strings.EqualFold(req.URL.Hostname(), r.authHost) {
+		serveIdentity(w, req, r.options.Cloud.Services[cloud.ResourceManager].Audience)
+		return
+	}
+	got := Request{
+		Method: req.Method,
+		URL:    r.sanitiser.sanitise(normaliseURL(req.URL)),
+		Body:   r.sanitiser.sanitise(normaliseBody(body)),
+	}
+	in, ok := r.player.next(got)
+	if !ok {
+		r.errorf("no recorded interaction for %s", got.key())
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Ms-Error-Code", "RecordingNotFound")
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, `{"error":{"code":"RecordingNotFound","message":"no recorded interaction for %s"}}`, got.key())
+		return
+	}
+	if got.Body != in.Request.Body {
+		r.errorf("request body for %s differs from the recording:\nrecorded: %s\nreplayed: %s", got.key(), in.Request.Body, got.Body)
+	}
+	for k, v := range in.Response.Headers {
+		// Do not make the replay wait as long as Azure asked the recording to.
+		if k == "Retry-After" {
+			v = "1"
+		}
+		w.Header().Set(k, v)
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(len(in.Response.Body)))
+	w.WriteHeader(in.Response.StatusCode)
+	io.WriteString(w, in.Response.Body) //nolint:errcheck
+}
+
+func (r *Recorder) errorf(format string, args ...any) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.errs = append(r.errs, fmt.Sprintf(format, args...))
+}
+
+// stop closes the proxy, reports any replay errors and, when recording, writes the cassette.
+// A cassette is not written for a test that failed or was skipped.
+func (r *Recorder) stop() {
+	r.proxy.Close()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	switch r.mode {
+	case ModeReplay:
+		for _, e := range r.errs {
+			r.t.Error(e)
+		}
+		if n := r.player.unused(); n > 0 {
+			r.t.Logf("%d recorded interactions were not replayed", n)
+		}
+	case ModeRecord:
+		if r.t.Failed() || r.t.Skipped() {
+			r.t.Logf("not writing cassette %s as the test did not pass", r.path)
+			return
+		}
+		if err := r.cassette().save(r.path); err != nil {
+			r.t.Errorf("cannot write cassette: %v", err)
+			return
+		}
+		r.t.Logf("wrote %d interactions to cassette %s", len(r.interactions), r.path)
+	}
+}
+
+// cassette returns the sanitised cassette of the recorded interactions.
+func (r *Recorder) cassette() *Cassette {
+	for _, in := range r.interactions {
+		r.sanitiser.discover(in.Request.URL)
+		r.sanitiser.discover(in.Request.Body)
+		r.sanitiser.discover(in.Response.Body)
+	}
+	c := &Cassette{
+		Random:       hex.EncodeToString(r.random.Bytes()),
+		Interactions: make([]Interaction, 0, len(r.interactions)),
+	}
+	for _, in := range r.interactions {
+		headers := make(map[string]string, len(in.Response.Headers))
+		for k, v := range in.Response.Headers {
+			headers[k] = r.sanitiser.sanitise(v)
+		}
+		c.Interactions = append(c.Interactions, Interaction{
+			Request: Request{
+				Method: in.Request.Method,
+				URL:    r.sanitiser.sanitise(in.Request.URL),
+				Body:   r.sanitiser.sanitise(in.Request.Body),
+			},
+			Response: Response{
+				StatusCode: in.Response.StatusCode,
+				Headers:    headers,
+				Body:       r.sanitiser.sanitise(in.Response.Body),
+			},
+		})
+	}
+	return c
+}
+
+// removeHopHeaders removes the hop-by-hop headers that must not be forwarded by a proxy.
+func removeHopHeaders(h http.Header) {
+	for _, k := range []string{"Connection", "Keep-Alive", "Proxy-Authenticate", "Proxy-Authorization", "Proxy-Connection", "Te", "Trailer", "Transfer-Encoding", "Upgrade"} {
+		h.Del(k)
+	}
+}
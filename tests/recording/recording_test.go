@@ -0,0 +1,177 @@
+package recording
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+	"github.com/Azure/terraform-azurerm-lz-vending/tests/azureutils"
+	"github.com/Azure/terraform-azurerm-lz-vending/tests/fakearm"
+	"github.com/Azure/terraform-azurerm-lz-vending/tests/utils"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeUpstream sends the requests intercepted by the recorder to a fake ARM server.
+type fakeUpstream struct {
+	srv *fakearm.Server
+}
+
+func (u fakeUpstream) RoundTrip(req *http.Request) (*http.Response, error) {
+	target, err := url.Parse(u.srv.URL())
+	if err != nil {
+		return nil, err
+	}
+	req.URL.Host = target.Host
+	req.Host = ""
+	return u.srv.Client().Transport.RoundTrip(req)
+}
+
+// newTestRecorder creates a recorder for the public cloud that records from the fake server.
+func newTestRecorder(t *testing.T, mode Mode, path string, srv *fakearm.Server) *Recorder {
+	t.Helper()
+	opts, err := azureutils.DefaultClientOptions()
+	require.NoError(t, err)
+	opts.Retry = policy.RetryOptions{MaxRetries: -1}
+	r, err := newRecorder(t, mode, path, opts)
+	require.NoError(t, err)
+	if srv != nil {
+		r.cred = srv.Credential()
+		r.upstream = fakeUpstream{srv: srv}
+	}
+	return r
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	srv := fakearm.NewServer(t, nil)
+	subID := uuid.New()
+	srv.AddSubscription(fakearm.Subscription{ID: subID, State: fakearm.SubscriptionStateEnabled})
+	srv.AddResourceGroup(subID, "rg-test", "northeurope")
+	path := filepath.Join(t.TempDir(), t.Name()+CassetteSuffix)
+
+	var recordedHex string
+	t.Run("record", func(t *testing.T) {
+		r := newTestRecorder(t, ModeRecord, path, srv)
+		f, err := r.ClientFactory()
+		require.NoError(t, err)
+		rgs, err := f.ListResourceGroups(context.Background(), subID)
+		require.NoError(t, err)
+		require.Len(t, rgs, 1)
+		recordedHex, err = utils.RandomHex(4)
+		require.NoError(t, err)
+	})
+
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.NotContains(t, string(b), subID.String())
+	assert.NotContains(t, string(b), fakearm.AccessToken)
+	sanitisedID := placeholderPrefix + "000000000100"
+	assert.Contains(t, string(b), "/subscriptions/"+sanitisedID+"/resourcegroups")
+
+	t.Run("replay", func(t *testing.T) {
+		r := newTestRecorder(t, ModeReplay, path, nil)
+		assert.Equal(t, SubscriptionPlaceholder, os.Getenv("AZURE_SUBSCRIPTION_ID"))
+		f, err := r.ClientFactory()
+		require.NoError(t, err)
+		rgs, err := f.ListResourceGroups(context.Background(), uuid.MustParse(sanitisedID))
+		require.NoError(t, err)
+		require.Len(t, rgs, 1)
+		assert.Equal(t, "rg-test", *rgs[0].Name)
+		assert.Equal(t, "/subscriptions/"+sanitisedID+"/resourceGroups/rg-test", *rgs[0].ID)
+		h, err := utils.RandomHex(4)
+		require.NoError(t, err)
+		assert.Equal(t, recordedHex, h)
+	})
+}
+
+func TestReplayRequestMismatch(t *testing.T) {
+	srv := fakearm.NewServer(t, nil)
+	subID := uuid.New()
+	srv.AddSubscription(fakearm.Subscription{ID: subID, State: fakearm.SubscriptionStateEnabled})
+	path := filepath.Join(t.TempDir(), t.Name()+CassetteSuffix)
+
+	createRG := func(t *testing.T, r *Recorder, subID uuid.UUID, location string) error {
+		f, err := r.ClientFactory()
+		require.NoError(t, err)
+		client, err := f.NewResourceGroupsClient(subID)
+		require.NoError(t, err)
+		_, err = client.CreateOrUpdate(context.Background(), "rg-test", armresources.ResourceGroup{
+			Location: to.Ptr(location),
+		}, nil)
+		return err
+	}
+
+	t.Run("record", func(t *testing.T) {
+		r := newTestRecorder(t, ModeRecord, path, srv)
+		require.NoError(t, createRG(t, r, subID, "northeurope"))
+	})
+
+	t.Run("replay", func(t *testing.T) {
+		r := newTestRecorder(t, ModeReplay, path, nil)
+		sanitisedID := uuid.MustParse(placeholderPrefix + "000000000100")
+		require.NoError(t, createRG(t, r, sanitisedID, "westeurope"))
+		err := createRG(t, r, uuid.MustParse(SubscriptionPlaceholder), "northeurope")
+		var respErr *azcore.ResponseError
+		require.ErrorAs(t, err, &respErr)
+		assert.Equal(t, "RecordingNotFound", respErr.ErrorCode)
+
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		require.Len(t, r.errs, 2)
+		assert.Contains(t, r.errs[0], "differs from the recording")
+		assert.Contains(t, r.errs[1], "no recorded interaction")
+		// The errors are expected, do not report them when the test completes.
+		r.errs = nil
+	})
+}
+
+func TestReplayIdentity(t *testing.T) {
+	path := filepath.Join(t.TempDir(), t.Name()+CassetteSuffix)
+	require.NoError(t, (&Cassette{}).save(path))
+	r := newTestRecorder(t, ModeReplay, path, nil)
+
+	// The Terraform providers authenticate with the client secret set by the recorder.
+	cred, err := azidentity.NewClientSecretCredential(os.Getenv("ARM_TENANT_ID"), os.Getenv("ARM_CLIENT_ID"), os.Getenv("ARM_CLIENT_SECRET"),
+		&azidentity.ClientSecretCredentialOptions{
+			ClientOptions: azcore.ClientOptions{
+				Transport: r.proxy.Client(),
+			},
+		})
+	require.NoError(t, err)
+	tok, err := cred.GetToken(context.Background(), policy.TokenRequestOptions{
+		Scopes: []string{"https://management.azure.com/.default"},
+	})
+	require.NoError(t, err)
+
+	parts := strings.Split(tok.Token, ".")
+	require.Len(t, parts, 3)
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	require.NoError(t, err)
+	var claims map[string]any
+	require.NoError(t, json.Unmarshal(payload, &claims))
+	assert.Equal(t, ObjectPlaceholder, claims["oid"])
+	assert.Equal(t, TenantPlaceholder, claims["tid"])
+}
+
+func TestModeFromEnv(t *testing.T) {
+	t.Setenv(ModeEnvVar, "Replay")
+	m, err := ModeFromEnv()
+	require.NoError(t, err)
+	assert.Equal(t, ModeReplay, m)
+
+	t.Setenv(ModeEnvVar, "rewind")
+	_, err = ModeFromEnv()
+	assert.ErrorContains(t, err, "unknown TERRATEST_RECORDING value")
+}
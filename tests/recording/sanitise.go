@@ -0,0 +1,147 @@
+package recording
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Placeholders replace the identifiers of the recording identity in cassettes.
+// They are also used as the identity when a cassette is replayed.
+const (
+	SubscriptionPlaceholder = "00000000-0000-0000-0000-000000000001"
+	TenantPlaceholder       = "00000000-0000-0000-0000-000000000002"
+	ClientPlaceholder       = "00000000-0000-0000-0000-000000000003"
+	ObjectPlaceholder       = "00000000-0000-0000-0000-000000000004"
+	BillingScopePlaceholder = "/providers/Microsoft.Billing/billingAccounts/0000000/enrollmentAccounts/000000"
+
+	// placeholderPrefix is shared by all placeholders so that they are not sanitised twice.
+	placeholderPrefix = "00000000-0000-0000-0000-"
+	// firstDiscoveredPlaceholder is the number of the placeholder used for the first subscription
+	// or tenant ID that is found in the traffic, rather than supplied by the environment.
+	firstDiscoveredPlaceholder = 100
+
+	redacted = "REDACTED"
+)
+
+var (
+	uuidPattern = `[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`
+	uuidRegexp  = regexp.MustCompile(uuidPattern)
+
+	// discoverPatterns find subscription and tenant IDs in URLs and JSON bodies.
+	discoverPatterns = []*regexp.Regexp{
+		regexp.MustCompile(`(?i)/subscriptions/(` + uuidPattern + `)`),
+		regexp.MustCompile(`(?i)"(?:subscriptionId|tenantId|homeTenantId|managedByTenantId)"\s*:\s*"(` + uuidPattern + `)"`),
+	}
+
+	jwtRegexp    = regexp.MustCompile(`eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`)
+	secretRegexp = regexp.MustCompile(`(?i)"(access_token|refresh_token|id_token|client_secret|client_assertion)"\s*:\s*"[^"]*"`)
+)
+
+// sanitiser replaces identifiers and secrets in recorded traffic.
+// Each subscription or tenant ID is replaced by the same placeholder wherever it appears,
+// so that the relationships between requests are kept.
+type sanitiser struct {
+	mu       sync.Mutex
+	ids      map[string]string
+	literals map[string]string
+	next     int
+}
+
+func newSanitiser() *sanitiser {
+	return &sanitiser{
+		ids:      make(map[string]string),
+		literals: make(map[string]string),
+		next:     firstDiscoveredPlaceholder,
+	}
+}
+
+// addID replaces the ID with the placeholder, unless the ID already has one.
+func (s *sanitiser) addID(id, placeholder string) {
+	if id == "" || strings.HasPrefix(id, placeholderPrefix) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.ids[strings.ToLower(id)]; !ok {
+		s.ids[strings.ToLower(id)] = placeholder
+	}
+}
+
+// addLiteral replaces every occurrence of the value with the placeholder.
+func (s *sanitiser) addLiteral(value, placeholder string) {
+	if value == "" {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.literals[value] = placeholder
+}
+
+// addToken adds the object, tenant and application IDs from the claims of an access token.
+// The token signature is not verified.
+func (s *sanitiser) addToken(authorization string) {
+	token, ok := strings.CutPrefix(authorization, "Bearer ")
+	if !ok {
+		return
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return
+	}
+	var claims struct {
+		Oid   string `json:"oid"`
+		Tid   string `json:"tid"`
+		AppID string `json:"appid"`
+		Azp   string `json:"azp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return
+	}
+	s.addID(claims.Tid, TenantPlaceholder)
+	s.addID(claims.AppID, ClientPlaceholder)
+	s.addID(claims.Azp, ClientPlaceholder)
+	s.addID(claims.Oid, ObjectPlaceholder)
+}
+
+// discover finds subscription and tenant IDs in the text and gives each a new placeholder.
+func (s *sanitiser) discover(text string) {
+	for _, re := range discoverPatterns {
+		for _, m := range re.FindAllStringSubmatch(text, -1) {
+			id := strings.ToLower(m[1])
+			if strings.HasPrefix(id, placeholderPrefix) {
+				continue
+			}
+			s.mu.Lock()
+			if _, ok := s.ids[id]; !ok {
+				s.ids[id] = fmt.Sprintf("%s%012d", placeholderPrefix, s.next)
+				s.next++
+			}
+			s.mu.Unlock()
+		}
+	}
+}
+
+// sanitise returns the text with known IDs replaced by their placeholders and secrets redacted.
+func (s *sanitiser) sanitise(text string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for value, placeholder := range s.literals {
+		text = strings.ReplaceAll(text, value, placeholder)
+	}
+	text = uuidRegexp.ReplaceAllStringFunc(text, func(id string) string {
+		if placeholder, ok := s.ids[strings.ToLower(id)]; ok {
+			return placeholder
+		}
+		return id
+	})
+	text = jwtRegexp.ReplaceAllString(text, redacted)
+	return secretRegexp.ReplaceAllString(text, `"$1":"`+redacted+`"`)
+}
@@ -0,0 +1,34 @@
+package recording
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSanitiser(t *testing.T) {
+	s := newSanitiser()
+	s.addID("11111111-2222-3333-4444-555555555555", SubscriptionPlaceholder)
+	s.addLiteral("/providers/Microsoft.Billing/billingAccounts/1234/enrollmentAccounts/5678", BillingScopePlaceholder)
+	claims := base64.RawURLEncoding.EncodeToString([]byte(`{"oid":"aaaaaaaa-0000-0000-0000-000000000000","tid":"bbbbbbbb-0000-0000-0000-000000000000"}`))
+	s.addToken("Bearer eyJhbGciOiJub25lIn0." + claims + ".sig")
+
+	text := `{"id":"/subscriptions/CCCCCCCC-0000-0000-0000-000000000000/resourceGroups/rg",` +
+		`"tenantId":"bbbbbbbb-0000-0000-0000-000000000000",` +
+		`"principalId":"aaaaaaaa-0000-0000-0000-000000000000",` +
+		`"billingScope":"/providers/Microsoft.Billing/billingAccounts/1234/enrollmentAccounts/5678",` +
+		`"roleDefinitionId":"/subscriptions/11111111-2222-3333-4444-555555555555/providers/Microsoft.Authorization/roleDefinitions/ba92f5b4-2d11-453d-a403-e96b0029c9fe",` +
+		`"access_token":"secret","token":"eyJhbGciOiJub25lIn0.` + claims + `.sig"}`
+	s.discover(text)
+
+	assert.Equal(t, `{"id":"/subscriptions/00000000-0000-0000-0000-000000000100/resourceGroups/rg",`+
+		`"tenantId":"00000000-0000-0000-0000-000000000002",`+
+		`"principalId":"00000000-0000-0000-0000-000000000004",`+
+		`"billingScope":"/providers/Microsoft.Billing/billingAccounts/0000000/enrollmentAccounts/000000",`+
+		`"roleDefinitionId":"/subscriptions/00000000-0000-0000-0000-000000000001/providers/Microsoft.Authorization/roleDefinitions/ba92f5b4-2d11-453d-a403-e96b0029c9fe",`+
+		`"access_token":"REDACTED","token":"REDACTED"}`, s.sanitise(text))
+
+	// Placeholders are not sanitised again.
+	assert.Equal(t, "/subscriptions/00000000-0000-0000-0000-000000000100", s.sanitise("/subscriptions/00000000-0000-0000-0000-000000000100"))
+}
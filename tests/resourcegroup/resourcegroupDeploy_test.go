@@ -1,7 +1,6 @@
 package resourcegroup
 
 import (
-	"context"
 	"fmt"
 	"os"
 	"testing"
@@ -24,7 +23,7 @@ func TestDeployNetworkWatcherRg(t *testing.T) {
 	require.NoError(t, err)
 
 	// delete the resource group if it already exists
-	ctx, cancel := context.WithCancel(context.TODO())
+	ctx, cancel := azureutils.NewTestContext(t)
 	defer cancel()
 
 	sid, err := uuid.Parse(os.Getenv("AZURE_SUBSCRIPTION_ID"))
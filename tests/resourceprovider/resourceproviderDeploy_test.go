@@ -4,9 +4,11 @@ import (
 	"os"
 	"testing"
 
+	"github.com/Azure/terraform-azurerm-lz-vending/tests/azureutils"
 	"github.com/Azure/terraform-azurerm-lz-vending/tests/utils"
 	"github.com/Azure/terratest-terraform-fluent/check"
 	"github.com/Azure/terratest-terraform-fluent/setuptest"
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/require"
 )
 
@@ -27,4 +29,15 @@ func TestDeploySubscriptionDeployExistingWithRpFeatureRegistration(t *testing.T)
 	test.ApplyIdempotent().ErrorIsNil(t)
 
 	check.InPlan(test.PlanStruct).NumberOfResourcesEquals(2).ErrorIsNil(t)
+
+	// The feature requires approval, so Pending is as far as registration can get.
+	ctx, cancel := azureutils.NewTestContext(t)
+	defer cancel()
+	status, err := azureutils.WaitForResourceProviders(ctx, uuid.MustParse(v["subscription_id"].(string)), map[string][]string{
+		"Microsoft.PowerBI": {"DailyPrivateLinkServicesForPowerBI"},
+	}, nil)
+	require.NoError(t, err)
+	if pending := status.PendingApproval(); len(pending) > 0 {
+		t.Logf("features pending approval: %v", pending)
+	}
 }
@@ -6,9 +6,11 @@ import (
 	"path/filepath"
 	"testing"
 
+	"github.com/Azure/terraform-azurerm-lz-vending/tests/azureutils"
 	"github.com/Azure/terraform-azurerm-lz-vending/tests/utils"
 	"github.com/Azure/terratest-terraform-fluent/check"
 	"github.com/Azure/terratest-terraform-fluent/setuptest"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
@@ -32,6 +34,7 @@ func TestDeployRoleAssignmentDefinitionName(t *testing.T) {
 	check.InPlan(test.PlanStruct).NumberOfResourcesEquals(3).ErrorIsNil(t)
 	defer test.DestroyRetry(setuptest.DefaultRetry) //nolint:errcheck
 	test.ApplyIdempotent().ErrorIsNil(t)
+	verifyRoleAssignment(t, test, v["role_definition"].(string))
 }
 
 // TestDeployRoleAssignmentDefinitionId tests the deployment of a role assignment
@@ -57,4 +60,25 @@ func TestDeployRoleAssignmentDefinitionId(t *testing.T) {
 	defer test.DestroyRetry(setuptest.DefaultRetry) //nolint:errcheck
 
 	test.ApplyIdempotent().ErrorIsNil(t)
+	verifyRoleAssignment(t, test, v["role_definition"].(string))
+}
+
+// verifyRoleAssignment checks that the role assignment exists in Azure,
+// using the principal_id and scope outputs of the testdata.
+func verifyRoleAssignment(t *testing.T, test setuptest.Response, definition string) {
+	principalID, err := test.Output("principal_id").GetValue()
+	require.NoError(t, err, "cannot get principal_id output")
+	scope, err := test.Output("scope").GetValue()
+	require.NoError(t, err, "cannot get scope output")
+
+	ctx, cancel := azureutils.NewTestContext(t)
+	defer cancel()
+	diff, err := azureutils.VerifyRoleAssignments(ctx, []azureutils.RoleAssignment{{
+		Key:         "roleassignment_test",
+		Scope:       scope.(string),
+		PrincipalID: principalID.(string),
+		Definition:  definition,
+	}})
+	require.NoError(t, err, "cannot verify role assignment")
+	assert.Emptyf(t, diff, "role assignment differs from the input:\n%s", diff)
 }
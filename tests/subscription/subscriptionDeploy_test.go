@@ -1,23 +1,22 @@
 package subscription
 
 import (
+	"context"
 	"fmt"
 	"os"
-	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/Azure/terraform-azurerm-lz-vending/tests/azureutils"
 	"github.com/Azure/terraform-azurerm-lz-vending/tests/utils"
 	"github.com/Azure/terratest-terraform-fluent/check"
 	"github.com/Azure/terratest-terraform-fluent/setuptest"
-	"github.com/google/uuid"
 	"github.com/gruntwork-io/terratest/modules/terraform"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
 var billingScope = os.Getenv("AZURE_BILLING_SCOPE")
-var tenantID = os.Getenv("AZURE_TENANT_ID")
 
 // TestDeploySubscriptionAliasValid tests the deployment of a subscription alias
 // with valid input variables.
@@ -26,6 +25,8 @@ var tenantID = os.Getenv("AZURE_TENANT_ID")
 func TestDeploySubscriptionAliasValid(t *testing.T) {
 
 	utils.PreCheckDeployTests(t)
+	ctx, cancel := azureutils.NewTestContext(t)
+	defer cancel()
 
 	v, err := getValidInputVariables(billingScope)
 	require.NoError(t, err)
@@ -37,53 +38,55 @@ func TestDeploySubscriptionAliasValid(t *testing.T) {
 
 	// Defer the cleanup of the subscription alias to the end of the test.
 	// Should be run after the Terraform destroy.
-	// We don't know the sub ID yet, so use zeros for now and then
-	// update it after the apply.
-	u := uuid.MustParse("00000000-0000-0000-0000-000000000000")
+	// The subscription ID is resolved from the alias name when the cleanup runs.
+	alias, err := azureutils.NewSubscriptionAlias(v["subscription_alias_name"].(string))
+	require.NoError(t, err)
 	defer func() {
-		err := azureutils.CancelSubscription(t, &u)
-		if err != nil {
-			t.Logf("cannot cancel subscription: %v", err)
+		if err := alias.Cleanup(ctx, t); err != nil {
+			t.Logf("cannot clean up subscription alias: %v", err)
 		}
 	}()
 
 	defer test.DestroyRetry(setuptest.DefaultRetry) //nolint:errcheck
 	test.ApplyIdempotent().ErrorIsNil(t)
 
+	a, err := alias.Wait(ctx, 0)
+	require.NoError(t, err)
 	sid, err := test.Output("subscription_id").GetValue()
 	assert.NoError(t, err)
-	sids, ok := sid.(string)
-	assert.True(t, ok, "subscription_id is not a string")
-	u, err = uuid.Parse(sids)
-	require.NoErrorf(t, err, "subscription id %s is not a valid uuid", sid)
+	assert.Equal(t, a.SubscriptionID.String(), sid, "subscription_id output does not match the alias")
 }
 
 // TestDeploySubscriptionAliasManagementGroupValid tests the deployment of a subscription alias
 // with valid input variables.
+// The management group is a throwaway tree created by the test, which moves the subscription
+// back to the tenant root group before it is deleted.
 func TestDeploySubscriptionAliasManagementGroupValid(t *testing.T) {
 	utils.PreCheckDeployTests(t)
+	ctx, cancel := azureutils.NewTestContext(t)
+	defer cancel()
 
 	v, err := getValidInputVariables(billingScope)
 	require.NoError(t, err)
+	mgs := azureutils.NewManagementGroupTree(t, &azureutils.ManagementGroupTreeOptions{
+		Name: v["subscription_alias_name"].(string),
+	})
 	v["subscription_billing_scope"] = billingScope
-	v["subscription_management_group_id"] = v["subscription_alias_name"]
+	v["subscription_management_group_id"] = mgs.Root
 	v["subscription_management_group_association_enabled"] = true
 
-	testDir := filepath.Join("testdata", t.Name())
-	test, err := setuptest.Dirs(moduleDir, testDir).WithVars(v).InitPlanShowWithPrepFunc(t, utils.AzureRmAndRequiredProviders)
+	test, err := setuptest.Dirs(moduleDir, "").WithVars(v).InitPlanShowWithPrepFunc(t, utils.AzureRmAndRequiredProviders)
 	require.NoError(t, err)
 	defer test.Cleanup()
-	require.NoError(t, err)
 
 	// Defer the cleanup of the subscription alias to the end of the test.
 	// Should be run after the Terraform destroy.
-	// We don't know the sub ID yet, so use zeros for now and then
-	// update it after the apply.
-	u := uuid.MustParse("00000000-0000-0000-0000-000000000000")
+	// The subscription ID is resolved from the alias name when the cleanup runs.
+	alias, err := azureutils.NewSubscriptionAlias(v["subscription_alias_name"].(string))
+	require.NoError(t, err)
 	defer func() {
-		err := azureutils.CancelSubscription(t, &u)
-		if err != nil {
-			t.Logf("cannot cancel subscription: %v", err)
+		if err := alias.Cleanup(ctx, t); err != nil {
+			t.Logf("cannot clean up subscription alias: %v", err)
 		}
 	}()
 
@@ -92,18 +95,18 @@ func TestDeploySubscriptionAliasManagementGroupValid(t *testing.T) {
 	defer test.DestroyRetry(setuptest.DefaultRetry) //nolint:errcheck
 	test.ApplyIdempotent().ErrorIsNil(t)
 
+	a, err := alias.Wait(ctx, 0)
+	require.NoError(t, err)
+	u := a.SubscriptionID
 	sid, err := terraform.OutputE(t, test.Options, "subscription_id")
 	assert.NoError(t, err)
+	assert.Equal(t, u.String(), sid, "subscription_id output does not match the alias")
 
-	u, err = uuid.Parse(sid)
-	assert.NoErrorf(t, err, "subscription id %s is not a valid uuid", sid)
-
-	// err = azureutils.IsSubscriptionInManagementGroup(t, u, v["subscription_management_group_id"].(string))
-	// assert.NoErrorf(t, err, "subscription %s is not in management group %s", sid, v["subscription_management_group_id"].(string))
-
-	if err := azureutils.SetSubscriptionManagementGroup(u, tenantID); err != nil {
-		t.Logf("cannot move subscription to tenant root group: %v", err)
-	}
+	// the management group API is eventually consistent, so allow time for the move to be visible
+	waitCtx, waitCancel := context.WithTimeout(ctx, 10*time.Minute)
+	defer waitCancel()
+	err = mgs.WaitForSubscription(waitCtx, u, mgs.Root)
+	assert.NoErrorf(t, err, "subscription %s is not in management group %s", sid, mgs.Root)
 }
 
 // getValidInputVariables returns a set of valid input variables that can be used and modified for testing scenarios.
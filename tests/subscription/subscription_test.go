@@ -44,6 +44,7 @@ func TestSubscriptionAliasCreateValid(t *testing.T) {
 	check.InPlan(test.PlanStruct).That("azapi_resource.subscription[0]").Key("body").Query("properties.displayName").HasValue(v["subscription_display_name"]).ErrorIsNil(t)
 	check.InPlan(test.PlanStruct).That("azapi_resource.subscription[0]").Key("body").Query("properties.workload").HasValue(v["subscription_workload"]).ErrorIsNil(t)
 	check.InPlan(test.PlanStruct).That("azapi_resource.subscription[0]").Key("body").Query("properties.additionalProperties.tags").HasValue(v["subscription_tags"]).ErrorIsNil(t)
+	utils.MatchPlanSnapshot(t, test.PlanStruct, &utils.SnapshotOptions{Replace: utils.EnvironmentReplacements()})
 }
 
 // TestSubscriptionAliasCreateValidWithManagementGroup tests the
@@ -102,8 +103,13 @@ func TestSubscriptionAliasCreateInvalidWorkload(t *testing.T) {
 	v["subscription_workload"] = "PRoduction"
 	test, err := setuptest.Dirs(moduleDir, "").WithVars(v).InitPlanShowWithPrepFunc(t, utils.AzureRmAndRequiredProviders)
 	defer test.Cleanup()
+	require.Error(t, err)
 
-	assert.ErrorContains(t, err, "The workload type can be either Production or DevTest and is case sensitive.")
+	diags, err := utils.PlanDiagnostics(t, test.Options)
+	require.NoError(t, err)
+	d, ok := diags.FindError("var.subscription_workload", utils.SummaryInvalidVariableValue)
+	require.True(t, ok, diags.String())
+	assert.Contains(t, d.Detail, "The workload type can be either Production or DevTest and is case sensitive.")
 }
 
 // TestSubscriptionAliasCreateInvalidManagementGroupIdInvalidChars tests the validation function of the
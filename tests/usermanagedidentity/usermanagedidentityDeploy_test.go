@@ -0,0 +1,109 @@
+package usermanagedidentity
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Azure/terraform-azurerm-lz-vending/tests/azureutils"
+	"github.com/Azure/terraform-azurerm-lz-vending/tests/utils"
+	"github.com/Azure/terratest-terraform-fluent/setuptest"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	rootDir = "../../"
+)
+
+// TestDeployUserManagedIdentityFederatedCredentials tests the deployment of a user managed identity
+// with GitHub, Terraform Cloud and advanced federated credentials using the root module,
+// and that the credentials and the umi_client_ids and umi_principal_ids outputs match Azure.
+func TestDeployUserManagedIdentityFederatedCredentials(t *testing.T) {
+
+	utils.PreCheckDeployTests(t)
+	r, err := utils.RandomHex(4)
+	require.NoErrorf(t, err, "could not generate random hex")
+	subID := uuid.MustParse(os.Getenv("AZURE_SUBSCRIPTION_ID"))
+
+	umis := map[string]map[string]any{
+		"default": {
+			"name":               "umi-" + r,
+			"resource_group_key": "rg1",
+			"federated_credentials_github": map[string]any{
+				"branch": map[string]any{
+					"organization": "my-organization",
+					"repository":   "my-repository",
+					"entity":       "branch",
+					"value":        "main",
+				},
+				"environment": map[string]any{
+					"organization":    "my-organization",
+					"repository":      "my-repository",
+					"entity":          "environment",
+					"value":           "production",
+					"enterprise_slug": "my-enterprise",
+				},
+				"pr": map[string]any{
+					"organization": "my-organization",
+					"repository":   "my-repository",
+					"entity":       "pull_request",
+				},
+			},
+			"federated_credentials_terraform_cloud": map[string]any{
+				"apply": map[string]any{
+					"organization": "my-organization",
+					"project":      "my-project",
+					"workspace":    "my-workspace",
+					"run_phase":    "apply",
+				},
+			},
+			"federated_credentials_advanced": map[string]any{
+				"k8s": map[string]any{
+					"name":               "k8s",
+					"issuer_url":         "https://oidc.example.com",
+					"subject_identifier": "system:serviceaccount:default:workload",
+				},
+			},
+		},
+	}
+	v := map[string]any{
+		"random_hex":              r,
+		"subscription_id":         subID.String(),
+		"user_managed_identities": umis,
+	}
+
+	testDir := filepath.Join("testdata", t.Name())
+	test, err := setuptest.Dirs(rootDir, testDir).WithVars(v).InitPlanShow(t)
+	require.NoError(t, err)
+	defer test.Cleanup()
+
+	defer test.DestroyRetry(setuptest.DefaultRetry) //nolint:errcheck
+	test.ApplyIdempotent().ErrorIsNil(t)
+
+	clientIDs := stringMapOutput(t, test, "umi_client_ids")
+	principalIDs := stringMapOutput(t, test, "umi_principal_ids")
+	require.Len(t, clientIDs, len(umis))
+
+	ctx, cancel := azureutils.NewTestContext(t)
+	defer cancel()
+	diff, err := azureutils.VerifyUserManagedIdentities(ctx, subID, umis, map[string]map[string]any{
+		"rg1": {"name": "rg-" + r},
+	}, clientIDs, principalIDs)
+	require.NoError(t, err, "cannot verify user managed identities")
+	assert.Emptyf(t, diff, "user managed identities differ from the input:\n%s", diff)
+}
+
+// stringMapOutput returns a Terraform output of type map(string).
+func stringMapOutput(t *testing.T, test setuptest.Response, name string) map[string]string {
+	val, err := test.Output(name).GetValue()
+	require.NoErrorf(t, err, "cannot get %s output", name)
+	m, ok := val.(map[string]any)
+	require.Truef(t, ok, "output %s is not a map", name)
+	out := make(map[string]string, len(m))
+	for k, v := range m {
+		out[k], _ = v.(string)
+	}
+	return out
+}
@@ -0,0 +1,231 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/gruntwork-io/terratest/modules/testing"
+)
+
+// SummaryInvalidVariableValue is the summary of the diagnostic Terraform reports when a variable validation rule fails.
+// The error_message of the rule is in the detail.
+const SummaryInvalidVariableValue = "Invalid value for variable"
+
+// Severity is the severity of a Terraform diagnostic.
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// SourcePos is a position in a source file. Line and Column start at 1, Byte at 0.
+type SourcePos struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+	Byte   int `json:"byte"`
+}
+
+// SourceRange is the range of source that a diagnostic refers to.
+type SourceRange struct {
+	Filename string    `json:"filename"`
+	Start    SourcePos `json:"start"`
+	End      SourcePos `json:"end"`
+}
+
+// Diagnostic is an error or warning reported by Terraform.
+type Diagnostic struct {
+	Severity Severity
+	Summary  string
+	Detail   string
+	// Address is the address of the object the diagnostic is about, e.g. var.virtual_networks,
+	// azapi_resource.subscription or module.virtual_networks.
+	// It is empty if Terraform does not relate the diagnostic to an object.
+	Address string
+	// Range is nil if the diagnostic does not refer to source.
+	Range *SourceRange
+}
+
+func (d Diagnostic) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: %s", d.Severity, d.Summary)
+	if d.Address != "" {
+		fmt.Fprintf(&b, " (%s)", d.Address)
+	}
+	if d.Range != nil {
+		fmt.Fprintf(&b, " at %s:%d,%d", d.Range.Filename, d.Range.Start.Line, d.Range.Start.Column)
+	}
+	if d.Detail != "" {
+		fmt.Fprintf(&b, ": %s", d.Detail)
+	}
+	return b.String()
+}
+
+// Diagnostics are the diagnostics reported by a Terraform command.
+type Diagnostics []Diagnostic
+
+// Errors returns the error diagnostics.
+func (ds Diagnostics) Errors() Diagnostics {
+	var errs Diagnostics
+	for _, d := range ds {
+		if d.Severity == SeverityError {
+			errs = append(errs, d)
+		}
+	}
+	return errs
+}
+
+// HasErrors reports whether any of the diagnostics is an error.
+func (ds Diagnostics) HasErrors() bool {
+	return len(ds.Errors()) > 0
+}
+
+// ForAddress returns the diagnostics about the object with the address.
+func (ds Diagnostics) ForAddress(address string) Diagnostics {
+	var found Diagnostics
+	for _, d := range ds {
+		if d.Address == address {
+			found = append(found, d)
+		}
+	}
+	return found
+}
+
+// FindError returns the first error about the object with the address that has the summary.
+func (ds Diagnostics) FindError(address, summary string) (Diagnostic, bool) {
+	for _, d := range ds.Errors().ForAddress(address) {
+		if d.Summary == summary {
+			return d, true
+		}
+	}
+	return Diagnostic{}, false
+}
+
+// String returns the diagnostics one per line, for test failure messages.
+func (ds Diagnostics) String() string {
+	lines := make([]string, len(ds))
+	for i, d := range ds {
+		lines[i] = d.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// PlanDiagnostics runs terraform plan -json in the working directory of the options, which must already be initialised,
+// and returns the diagnostics.
+// The error is only for failing to run Terraform or read its output, not for error diagnostics.
+// With setuptest, pass the Options of the Response.
+func PlanDiagnostics(t testing.TestingT, opts *terraform.Options) (Diagnostics, error) {
+	return runForDiagnostics(t, opts, terraform.FormatArgs(opts, "plan", "-json", "-input=false")...)
+}
+
+// ValidateDiagnostics runs terraform validate -json in the working directory of the options, which must already be initialised,
+// and returns the diagnostics.
+// Validate does not use the variable values, so variable validation rules are not checked.
+func ValidateDiagnostics(t testing.TestingT, opts *terraform.Options) (Diagnostics, error) {
+	return runForDiagnostics(t, opts, "validate", "-json")
+}
+
+func runForDiagnostics(t testing.TestingT, opts *terraform.Options, args ...string) (Diagnostics, error) {
+	if opts == nil {
+		return nil, errors.New("cannot get diagnostics, no terraform options")
+	}
+	stdout, stderr, exit, err := terraform.RunTerraformCommandAndGetStdOutErrCodeE(t, opts, args...)
+	diags, parseErr := ParseDiagnostics(strings.NewReader(stdout))
+	if parseErr != nil {
+		return nil, fmt.Errorf("cannot parse terraform %s output, %v: %s", args[0], parseErr, stderr)
+	}
+	if err != nil && !diags.HasErrors() {
+		return diags, fmt.Errorf("terraform %s exited with %d without error diagnostics, %v: %s", args[0], exit, err, stderr)
+	}
+	return diags, nil
+}
+
+// jsonDiagnostic is a diagnostic in the Terraform JSON output format.
+type jsonDiagnostic struct {
+	Severity string       `json:"severity"`
+	Summary  string       `json:"summary"`
+	Detail   string       `json:"detail"`
+	Address  string       `json:"address"`
+	Range    *SourceRange `json:"range"`
+	Snippet  *struct {
+		Context *string `json:"context"`
+	} `json:"snippet"`
+}
+
+// jsonMessage is either a message of the machine readable UI stream of plan and apply,
+// or the document written by validate.
+type jsonMessage struct {
+	Type        string           `json:"type"`
+	Diagnostic  *jsonDiagnostic  `json:"diagnostic"`
+	Diagnostics []jsonDiagnostic `json:"diagnostics"`
+}
+
+// ParseDiagnostics reads the diagnostics from the JSON output of terraform plan, apply or validate.
+func ParseDiagnostics(r io.Reader) (Diagnostics, error) {
+	var diags Diagnostics
+	dec := json.NewDecoder(r)
+	for {
+		var msg jsonMessage
+		err := dec.Decode(&msg)
+		if errors.Is(err, io.EOF) {
+			return diags, nil
+		}
+		if err != nil {
+			return diags, err
+		}
+		if msg.Type == "diagnostic" && msg.Diagnostic != nil {
+			diags = append(diags, newDiagnostic(msg.Diagnostic))
+		}
+		for i := range msg.Diagnostics {
+			diags = append(diags, newDiagnostic(&msg.Diagnostics[i]))
+		}
+	}
+}
+
+var (
+	// blockHeader matches the snippet context of a diagnostic about a configuration block, e.g. resource "type" "name".
+	blockHeader = regexp.MustCompile(`^(variable|output|module|resource|data) "([^"]+)"(?: "([^"]+)")?`)
+	// inputValue matches the file name of a diagnostic about a variable value supplied on the command line.
+	inputValue = regexp.MustCompile(`^<value for (var\.[^>]+)>$`)
+)
+
+func newDiagnostic(j *jsonDiagnostic) Diagnostic {
+	d := Diagnostic{
+		Severity: Severity(j.Severity),
+		Summary:  j.Summary,
+		Detail:   j.Detail,
+		Address:  j.Address,
+		Range:    j.Range,
+	}
+	if d.Address == "" && j.Snippet != nil && j.Snippet.Context != nil {
+		d.Address = blockAddress(*j.Snippet.Context)
+	}
+	if d.Address == "" && j.Range != nil {
+		if m := inputValue.FindStringSubmatch(j.Range.Filename); m != nil {
+			d.Address = m[1]
+		}
+	}
+	return d
+}
+
+// blockAddress returns the address of the block with the header, or the empty string.
+func blockAddress(header string) string {
+	m := blockHeader.FindStringSubmatch(header)
+	if m == nil {
+		return ""
+	}
+	switch m[1] {
+	case "variable":
+		return "var." + m[2]
+	case "output", "module":
+		return m[1] + "." + m[2]
+	case "data":
+		return "data." + m[2] + "." + m[3]
+	}
+	return m[2] + "." + m[3]
+}
@@ -0,0 +1,61 @@
+package utils
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestParsePlanDiagnostics tests that the diagnostics are read from the plan message stream,
+// with the address taken from the diagnostic, its snippet context or the variable value it refers to.
+func TestParsePlanDiagnostics(t *testing.T) {
+	f, err := os.Open("testdata/plan-diagnostics.json")
+	require.NoError(t, err)
+	defer f.Close()
+
+	diags, err := ParseDiagnostics(f)
+	require.NoError(t, err)
+	require.Len(t, diags, 3)
+	assert.Len(t, diags.Errors(), 2)
+	assert.Equal(t, SeverityWarning, diags.ForAddress("azapi_resource.subscription")[0].Severity)
+
+	d, ok := diags.FindError("var.subscription_workload", SummaryInvalidVariableValue)
+	require.True(t, ok, diags.String())
+	assert.Contains(t, d.Detail, "The workload type can be either Production or DevTest and is case sensitive.")
+	assert.Equal(t, &SourceRange{
+		Filename: "variables.tf",
+		Start:    SourcePos{Line: 205, Column: 1, Byte: 6120},
+		End:      SourcePos{Line: 205, Column: 31, Byte: 6150},
+	}, d.Range)
+
+	_, ok = diags.FindError("var.flow_timeout", "Invalid value for input variable")
+	assert.True(t, ok, diags.String())
+}
+
+// TestParseValidateDiagnostics tests that the diagnostics are read from the validate document.
+func TestParseValidateDiagnostics(t *testing.T) {
+	f, err := os.Open("testdata/validate-diagnostics.json")
+	require.NoError(t, err)
+	defer f.Close()
+
+	diags, err := ParseDiagnostics(f)
+	require.NoError(t, err)
+	_, ok := diags.FindError("azapi_resource.vnet", "Reference to undeclared resource")
+	assert.True(t, ok, diags.String())
+}
+
+// TestBlockAddress tests the addresses of the blocks that diagnostics refer to.
+func TestBlockAddress(t *testing.T) {
+	for header, want := range map[string]string{
+		`variable "location"`:                  "var.location",
+		`module "virtual_networks"`:            "module.virtual_networks",
+		`output "subscription_id"`:             "output.subscription_id",
+		`data "azapi_client_config" "current"`: "data.azapi_client_config.current",
+		`resource "azapi_resource" "this"`:     "azapi_resource.this",
+		`locals`:                               "",
+	} {
+		assert.Equal(t, want, blockAddress(header), header)
+	}
+}
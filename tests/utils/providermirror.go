@@ -0,0 +1,650 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
+	"strings"
+
+	"github.com/Azure/terratest-terraform-fluent/setuptest"
+	"github.com/hashicorp/go-version"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/zclconf/go-cty/cty"
+)
+
+const (
+	// ProviderMirrorEnvVar is the directory of a provider mirror, populated by cmd/lzmirror.
+	// When it is set, AzureRmAndRequiredProviders and RequiredProviders install the providers from the mirror
+	// instead of the registry.
+	ProviderMirrorEnvVar = "TERRATEST_PROVIDER_MIRROR"
+	// ProviderMirrorConfigFile is the name of the Terraform CLI configuration written to the test directory.
+	ProviderMirrorConfigFile = "_mirror.tfrc"
+	// DefaultProviderRegistry is the registry of provider sources without a hostname.
+	DefaultProviderRegistry = "registry.terraform.io"
+	// ModuleMirrorDir is the directory of the provider mirror with the remote modules installed by cmd/lzmirror.
+	// It has a directory for each module that calls remote modules, with the .terraform data directory
+	// written by terraform get, which is copied to the test directory so that terraform init does not download them.
+	ModuleMirrorDir = "modules"
+)
+
+// ProviderPlatform is the platform of the running Terraform, e.g. linux_amd64.
+var ProviderPlatform = runtime.GOOS + "_" + runtime.GOARCH
+
+// WithProviderMirror is a setuptest.PrepFunc that runs next, which may be nil,
+// then makes Terraform install the providers from the mirror directory and nowhere else.
+// It fails if a provider required by the module, at a version that meets all the constraints,
+// is not in the mirror for the current platform.
+// The mirror may be a packed mirror written by terraform providers mirror, or a TF_PLUGIN_CACHE_DIR.
+func WithProviderMirror(mirror string, next setuptest.PrepFunc) setuptest.PrepFunc {
+	return func(resp setuptest.Response) error {
+		if next != nil {
+			if err := next(resp); err != nil {
+				return err
+			}
+		}
+		return useProviderMirror(resp, mirror)
+	}
+}
+
+// providerMirrorFromEnv uses the mirror in TERRATEST_PROVIDER_MIRROR, if it is set.
+func providerMirrorFromEnv(resp setuptest.Response) error {
+	mirror := os.Getenv(ProviderMirrorEnvVar)
+	if mirror == "" {
+		return nil
+	}
+	return useProviderMirror(resp, mirror)
+}
+
+func useProviderMirror(resp setuptest.Response, mirror string) error {
+	if resp.Options == nil {
+		return errors.New("cannot use provider mirror, no terraform options")
+	}
+	mirror, err := filepath.Abs(mirror)
+	if err != nil {
+		return fmt.Errorf("cannot use provider mirror, %v", err)
+	}
+	// The providers of remote modules are only known when the modules are installed,
+	// and terraform init cannot install them without network access, so install them from the mirror.
+	if err := seedModules(resp.TmpDir, mirror); err != nil {
+		return err
+	}
+	reqs, err := ModuleRequiredProviders(resp.TmpDir)
+	if err != nil {
+		return fmt.Errorf("cannot use provider mirror, %v", err)
+	}
+	if err := reqs.CheckMirror(mirror, ProviderPlatform); err != nil {
+		return err
+	}
+	path, err := filepath.Abs(filepath.Join(resp.TmpDir, ProviderMirrorConfigFile))
+	if err != nil {
+		return fmt.Errorf("cannot use provider mirror, %v", err)
+	}
+	if err := os.WriteFile(path, []byte(providerMirrorConfig(mirror)), 0o644); err != nil {
+		return fmt.Errorf("cannot write provider mirror configuration, %v", err)
+	}
+	if resp.Options.EnvVars == nil {
+		resp.Options.EnvVars = make(map[string]string)
+	}
+	resp.Options.EnvVars["TF_CLI_CONFIG_FILE"] = path
+	return nil
+}
+
+// seedModules copies the remote modules installed in the mirror for the module in dir to its .terraform directory.
+// It uses the first module in the mirror that has every remote module called by the module under the same key,
+// with the same source and a version that meets the constraint, as terraform init then uses them as they are.
+func seedModules(dir, mirror string) error {
+	calls, err := RemoteModuleCalls(dir)
+	if err != nil {
+		return fmt.Errorf("cannot use provider mirror, %v", err)
+	}
+	if len(calls) == 0 {
+		return nil
+	}
+	if _, err := os.Stat(filepath.Join(dir, ".terraform", "modules", "modules.json")); err == nil {
+		return nil
+	}
+	entries, err := os.ReadDir(filepath.Join(mirror, ModuleMirrorDir))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("cannot read module mirror, %v", err)
+	}
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		dataDir := filepath.Join(mirror, ModuleMirrorDir, e.Name(), ".terraform")
+		manifest, err := loadModuleManifest(filepath.Join(dataDir, "modules"))
+		if err != nil {
+			return err
+		}
+		if manifest == nil || !manifest.installs(calls) {
+			continue
+		}
+		if err := os.CopyFS(filepath.Join(dir, ".terraform"), os.DirFS(dataDir)); err != nil {
+			return fmt.Errorf("cannot copy modules from the mirror, %v", err)
+		}
+		return nil
+	}
+	missing := make([]string, 0, len(calls))
+	for _, key := range sortedKeys(calls) {
+		missing = append(missing, fmt.Sprintf("  %s %s %q", key, calls[key].Source, calls[key].Version))
+	}
+	return fmt.Errorf("provider mirror %s has no remote modules for module %s, run make mirror to populate it:\n%s",
+		mirror, dir, strings.Join(missing, "\n"))
+}
+
+// providerMirrorConfig returns a CLI configuration that installs every provider from the mirror.
+// There is no direct block, so Terraform does not fall back to the registry.
+func providerMirrorConfig(mirror string) string {
+	return fmt.Sprintf(`provider_installation {
+  filesystem_mirror {
+    path    = %q
+    include = ["*/*/*"]
+  }
+}
+`, filepath.ToSlash(mirror))
+}
+
+// ProviderRequirements are the version constraints of providers, indexed by source address,
+// e.g. registry.terraform.io/hashicorp/azurerm.
+type ProviderRequirements map[string][]string
+
+// Add adds the constraints to the provider with the source, which may omit the hostname and namespace.
+func (r ProviderRequirements) Add(source string, constraints ...string) error {
+	addr, err := NormaliseProviderSource(source)
+	if err != nil {
+		return err
+	}
+	cs := r[addr]
+	for _, c := range constraints {
+		c = strings.TrimSpace(c)
+		if c != "" && !slices.Contains(cs, c) {
+			cs = append(cs, c)
+		}
+	}
+	r[addr] = cs
+	return nil
+}
+
+// Merge adds the requirements in other.
+func (r ProviderRequirements) Merge(other ProviderRequirements) {
+	for source, cs := range other {
+		r[source] = append(r[source], slices.DeleteFunc(slices.Clone(cs), func(c string) bool {
+			return slices.Contains(r[source], c)
+		})...)
+	}
+}
+
+// Sources returns the provider source addresses, sorted.
+func (r ProviderRequirements) Sources() []string {
+	return sortedKeys(r)
+}
+
+// CheckMirror returns an error naming every provider that is not in the mirror directory for the platform
+// at a version that meets all of its constraints.
+func (r ProviderRequirements) CheckMirror(mirror, platform string) error {
+	var missing []string
+	for _, source := range r.Sources() {
+		constraint := strings.Join(r[source], ", ")
+		if constraint == "" {
+			constraint = ">= 0"
+		}
+		constraints, err := version.NewConstraint(constraint)
+		if err != nil {
+			return fmt.Errorf("cannot parse version constraints of provider %s, %v", source, err)
+		}
+		versions, err := MirrorProviderVersions(mirror, source, platform)
+		if err != nil {
+			return err
+		}
+		if slices.ContainsFunc(versions, constraints.Check) {
+			continue
+		}
+		available := "none"
+		if len(versions) > 0 {
+			available = joinVersions(versions)
+		}
+		missing = append(missing, fmt.Sprintf("  %s %q, available: %s", source, constraint, available))
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("provider mirror %s is missing providers for %s, run make mirror to populate it:\n%s",
+			mirror, platform, strings.Join(missing, "\n"))
+	}
+	return nil
+}
+
+func joinVersions(vs []*version.Version) string {
+	s := make([]string, len(vs))
+	for i, v := range vs {
+		s[i] = v.String()
+	}
+	return strings.Join(s, ", ")
+}
+
+// packedProvider matches the file name of a provider package in a packed mirror.
+var packedProvider = regexp.MustCompile(`^terraform-provider-[^_]+_([^_]+)_([^_]+_[^_]+)\.zip$`)
+
+// MirrorProviderVersions returns the versions of the provider with the source address in the mirror directory for the platform, sorted.
+// It reads both the packed layout, HOSTNAME/NAMESPACE/TYPE/terraform-provider-TYPE_VERSION_PLATFORM.zip,
+// and the unpacked layout of a mirror or plugin cache, HOSTNAME/NAMESPACE/TYPE/VERSION/PLATFORM.
+func MirrorProviderVersions(mirror, source, platform string) ([]*version.Version, error) {
+	dir := filepath.Join(mirror, filepath.FromSlash(source))
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot read provider mirror, %v", err)
+	}
+	var versions []*version.Version
+	for _, e := range entries {
+		name := e.Name()
+		if !e.IsDir() {
+			m := packedProvider.FindStringSubmatch(name)
+			if m == nil || m[2] != platform {
+				continue
+			}
+			name = m[1]
+		} else if _, err := os.Stat(filepath.Join(dir, name, platform)); err != nil {
+			continue
+		}
+		v, err := version.NewVersion(name)
+		if err != nil {
+			continue
+		}
+		if !slices.ContainsFunc(versions, v.Equal) {
+			versions = append(versions, v)
+		}
+	}
+	slices.SortFunc(versions, func(a, b *version.Version) int { return a.Compare(b) })
+	return versions, nil
+}
+
+// NormaliseProviderSource returns the full source address of a provider, in lower case,
+// e.g. registry.terraform.io/azure/azapi for Azure/azapi and registry.terraform.io/hashicorp/random for random.
+func NormaliseProviderSource(source string) (string, error) {
+	parts := strings.Split(strings.ToLower(strings.TrimSpace(source)), "/")
+	if slices.Contains(parts, "") {
+		return "", fmt.Errorf("invalid provider source %q", source)
+	}
+	switch len(parts) {
+	case 1:
+		return DefaultProviderRegistry + "/hashicorp/" + parts[0], nil
+	case 2:
+		return DefaultProviderRegistry + "/" + parts[0] + "/" + parts[1], nil
+	case 3:
+		return strings.Join(parts, "/"), nil
+	}
+	return "", fmt.Errorf("invalid provider source %q", source)
+}
+
+// ModuleRequiredProvidersOptions are the options for ModuleRequiredProvidersWithOptions.
+type ModuleRequiredProvidersOptions struct {
+	// ModulesDir is the directory of the remote modules installed by terraform init or terraform get,
+	// which contains modules.json. Defaults to .terraform/modules in the module directory.
+	ModulesDir string
+}
+
+// ModuleRequiredProviders returns the providers required by the module in the directory and the modules it calls,
+// using the remote modules installed in its .terraform directory.
+func ModuleRequiredProviders(dir string) (ProviderRequirements, error) {
+	return ModuleRequiredProvidersWithOptions(dir, nil)
+}
+
+// ModuleRequiredProvidersWithOptions returns the providers required by the module in the directory and the modules it calls.
+// Local modules are read from their directory, and remote modules, e.g. from the registry, from where Terraform installed them.
+// A remote module that is not installed is an error, as the providers that it requires are unknown.
+// Providers used without a required_providers entry are the hashicorp provider of the same name, as in Terraform.
+// opts may be nil.
+func ModuleRequiredProvidersWithOptions(dir string, opts *ModuleRequiredProvidersOptions) (ProviderRequirements, error) {
+	modulesDir := filepath.Join(dir, ".terraform", "modules")
+	if opts != nil && opts.ModulesDir != "" {
+		modulesDir = opts.ModulesDir
+	}
+	installed, err := readModuleManifest(modulesDir)
+	if err != nil {
+		return nil, err
+	}
+	w := &moduleWalker{
+		reqs:      make(ProviderRequirements),
+		installed: installed,
+		seen:      make(map[string]bool),
+	}
+	if err := w.walk(dir); err != nil {
+		return nil, err
+	}
+	if len(w.missing) > 0 {
+		slices.Sort(w.missing)
+		return nil, fmt.Errorf("module %s calls remote modules that are not installed in %s, run terraform get to install them:\n%s",
+			dir, modulesDir, strings.Join(slices.Compact(w.missing), "\n"))
+	}
+	return w.reqs, nil
+}
+
+// moduleManifest is the modules.json written by terraform init and terraform get.
+type moduleManifest struct {
+	Modules []moduleRecord `json:"Modules"`
+}
+
+// moduleRecord is an installed module in the manifest.
+type moduleRecord struct {
+	Key     string `json:"Key"`
+	Source  string `json:"Source"`
+	Version string `json:"Version"`
+	Dir     string `json:"Dir"`
+}
+
+// loadModuleManifest reads the manifest in the modules directory, or returns nil if there is none.
+func loadModuleManifest(modulesDir string) (*moduleManifest, error) {
+	b, err := os.ReadFile(filepath.Join(modulesDir, "modules.json"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot read module manifest, %v", err)
+	}
+	var manifest moduleManifest
+	if err := json.Unmarshal(b, &manifest); err != nil {
+		return nil, fmt.Errorf("cannot parse module manifest %s, %v", filepath.Join(modulesDir, "modules.json"), err)
+	}
+	return &manifest, nil
+}
+
+// installs reports whether the manifest has every module call, under the same key and source,
+// at a version that meets its constraint.
+func (m *moduleManifest) installs(calls map[string]ModuleCall) bool {
+	for key, call := range calls {
+		i := slices.IndexFunc(m.Modules, func(r moduleRecord) bool { return r.Key == key })
+		if i < 0 || normaliseModuleSource(m.Modules[i].Source) != call.Source {
+			return false
+		}
+		if call.Version == "" {
+			continue
+		}
+		constraints, err := version.NewConstraint(call.Version)
+		if err != nil {
+			return false
+		}
+		v, err := version.NewVersion(m.Modules[i].Version)
+		if err != nil || !constraints.Check(v) {
+			return false
+		}
+	}
+	return true
+}
+
+// readModuleManifest returns the directories of the installed remote modules, indexed by normalised source.
+// A directory without a manifest has no installed modules.
+func readModuleManifest(modulesDir string) (map[string]string, error) {
+	manifest, err := loadModuleManifest(modulesDir)
+	if err != nil {
+		return nil, err
+	}
+	installed := make(map[string]string)
+	if manifest == nil {
+		return installed, nil
+	}
+	for _, m := range manifest.Modules {
+		// The root module, and local modules, have no directory of their own.
+		if m.Key == "" || isLocalModuleSource(m.Source) {
+			continue
+		}
+		dir := filepath.FromSlash(m.Dir)
+		if !filepath.IsAbs(dir) {
+			// Relative directories are relative to the working directory of Terraform,
+			// which contains the .terraform data directory.
+			dir = filepath.Join(modulesDir, "..", "..", dir)
+		}
+		source := normaliseModuleSource(m.Source)
+		if _, ok := installed[source]; !ok {
+			installed[source] = dir
+		}
+	}
+	return installed, nil
+}
+
+// isLocalModuleSource reports whether the module source is a local path.
+func isLocalModuleSource(source string) bool {
+	return strings.HasPrefix(source, "./") || strings.HasPrefix(source, "../")
+}
+
+// ModuleCall is the source and version constraint of a module call.
+type ModuleCall struct {
+	// Source is normalised as in the module manifest, see normaliseModuleSource.
+	Source  string
+	Version string
+}
+
+var moduleCallSchema = &hcl.BodySchema{
+	Blocks: []hcl.BlockHeaderSchema{{Type: "module", LabelNames: []string{"name"}}},
+}
+
+// RemoteModuleCalls returns the remote modules called by the module in the directory, directly or through local modules,
+// indexed by their key in the module manifest, e.g. virtualnetwork.virtual_networks.
+// The modules that remote modules call are not included.
+func RemoteModuleCalls(dir string) (map[string]ModuleCall, error) {
+	calls := make(map[string]ModuleCall)
+	if err := addRemoteModuleCalls(calls, dir, ""); err != nil {
+		return nil, err
+	}
+	return calls, nil
+}
+
+func addRemoteModuleCalls(calls map[string]ModuleCall, dir, prefix string) error {
+	files, err := filepath.Glob(filepath.Join(dir, "*.tf"))
+	if err != nil {
+		return err
+	}
+	parser := hclparse.NewParser()
+	for _, file := range files {
+		f, diags := parser.ParseHCLFile(file)
+		if diags.HasErrors() {
+			return fmt.Errorf("cannot parse %s, %s", file, diags.Error())
+		}
+		content, _, diags := f.Body.PartialContent(moduleCallSchema)
+		if diags.HasErrors() {
+			return fmt.Errorf("cannot read %s, %s", file, diags.Error())
+		}
+		for _, block := range content.Blocks {
+			attrs, _ := block.Body.JustAttributes()
+			src, ok := attrs["source"]
+			if !ok {
+				continue
+			}
+			s, ok := stringValue(src.Expr)
+			if !ok {
+				continue
+			}
+			key := prefix + block.Labels[0]
+			if isLocalModuleSource(s) {
+				if err := addRemoteModuleCalls(calls, filepath.Join(dir, filepath.FromSlash(s)), key+"."); err != nil {
+					return err
+				}
+				continue
+			}
+			call := ModuleCall{Source: normaliseModuleSource(s)}
+			if v, ok := attrs["version"]; ok {
+				call.Version, _ = stringValue(v.Expr)
+			}
+			calls[key] = call
+		}
+	}
+	return nil
+}
+
+// normaliseModuleSource returns the source of a module in the form of the module manifest,
+// in lower case and with the registry hostname added to registry sources without one,
+// e.g. registry.terraform.io/azure/avm-utl-regions/azurerm for Azure/avm-utl-regions/azurerm.
+func normaliseModuleSource(source string) string {
+	source = strings.ToLower(strings.TrimSpace(source))
+	addr, subdir, hasSubdir := strings.Cut(source, "//")
+	if strings.Contains(addr, ":") {
+		return source
+	}
+	if strings.Count(addr, "/") == 2 {
+		addr = DefaultProviderRegistry + "/" + addr
+	}
+	if hasSubdir {
+		return addr + "//" + subdir
+	}
+	return addr
+}
+
+// moduleWalker reads the providers required by a module and the modules it calls.
+type moduleWalker struct {
+	reqs      ProviderRequirements
+	installed map[string]string
+	seen      map[string]bool
+	// missing are the remote modules that are not installed.
+	missing []string
+}
+
+var moduleSchema = &hcl.BodySchema{
+	Blocks: []hcl.BlockHeaderSchema{
+		{Type: "terraform"},
+		{Type: "provider", LabelNames: []string{"name"}},
+		{Type: "resource", LabelNames: []string{"type", "name"}},
+		{Type: "data", LabelNames: []string{"type", "name"}},
+		{Type: "ephemeral", LabelNames: []string{"type", "name"}},
+		{Type: "module", LabelNames: []string{"name"}},
+	},
+}
+
+var terraformBlockSchema = &hcl.BodySchema{
+	Blocks: []hcl.BlockHeaderSchema{{Type: "required_providers"}},
+}
+
+func (w *moduleWalker) walk(dir string) error {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	if w.seen[abs] {
+		return nil
+	}
+	w.seen[abs] = true
+	files, err := filepath.Glob(filepath.Join(dir, "*.tf"))
+	if err != nil {
+		return err
+	}
+	parser := hclparse.NewParser()
+	// declared holds the local names in required_providers.
+	declared := make(map[string]bool)
+	var used, children []string
+	for _, file := range files {
+		f, diags := parser.ParseHCLFile(file)
+		if diags.HasErrors() {
+			return fmt.Errorf("cannot parse %s, %s", file, diags.Error())
+		}
+		content, _, diags := f.Body.PartialContent(moduleSchema)
+		if diags.HasErrors() {
+			return fmt.Errorf("cannot read %s, %s", file, diags.Error())
+		}
+		for _, block := range content.Blocks {
+			switch block.Type {
+			case "terraform":
+				if err := addRequiredProvidersBlock(w.reqs, declared, block, file); err != nil {
+					return err
+				}
+			case "provider":
+				used = append(used, block.Labels[0])
+			case "resource", "data", "ephemeral":
+				used = append(used, strings.SplitN(block.Labels[0], "_", 2)[0])
+			case "module":
+				attrs, _ := block.Body.JustAttributes()
+				src, ok := attrs["source"]
+				if !ok {
+					continue
+				}
+				s, ok := stringValue(src.Expr)
+				switch {
+				case !ok:
+				case isLocalModuleSource(s):
+					children = append(children, filepath.Join(dir, filepath.FromSlash(s)))
+				case w.installed[normaliseModuleSource(s)] != "":
+					children = append(children, w.installed[normaliseModuleSource(s)])
+				default:
+					w.missing = append(w.missing, fmt.Sprintf("  %s, called by module %s in %s", s, block.Labels[0], dir))
+				}
+			}
+		}
+	}
+	for _, name := range used {
+		// The terraform provider is built in.
+		if name == "terraform" || declared[name] {
+			continue
+		}
+		if err := w.reqs.Add(name); err != nil {
+			return err
+		}
+	}
+	for _, child := range children {
+		if err := w.walk(child); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func addRequiredProvidersBlock(reqs ProviderRequirements, declared map[string]bool, block *hcl.Block, file string) error {
+	content, _, diags := block.Body.PartialContent(terraformBlockSchema)
+	if diags.HasErrors() {
+		return fmt.Errorf("cannot read %s, %s", file, diags.Error())
+	}
+	for _, rp := range content.Blocks {
+		attrs, diags := rp.Body.JustAttributes()
+		if diags.HasErrors() {
+			return fmt.Errorf("cannot read required providers in %s, %s", file, diags.Error())
+		}
+		for name, attr := range attrs {
+			declared[name] = true
+			// The legacy form is a version constraint string.
+			if constraint, ok := stringValue(attr.Expr); ok {
+				if err := reqs.Add(name, constraint); err != nil {
+					return err
+				}
+				continue
+			}
+			pairs, diags := hcl.ExprMap(attr.Expr)
+			if diags.HasErrors() {
+				return fmt.Errorf("cannot read required provider %s in %s, %s", name, file, diags.Error())
+			}
+			source, constraint := name, ""
+			for _, pair := range pairs {
+				key, diags := pair.Key.Value(nil)
+				if diags.HasErrors() || key.Type() != cty.String {
+					continue
+				}
+				switch key.AsString() {
+				case "source":
+					if s, ok := stringValue(pair.Value); ok {
+						source = s
+					}
+				case "version":
+					if s, ok := stringValue(pair.Value); ok {
+						constraint = s
+					}
+				}
+			}
+			if err := reqs.Add(source, constraint); err != nil {
+				return fmt.Errorf("cannot read required provider %s in %s, %v", name, file, err)
+			}
+		}
+	}
+	return nil
+}
+
+// stringValue returns the value of a constant string expression.
+func stringValue(expr hcl.Expression) (string, bool) {
+	val, diags := expr.Value(nil)
+	if diags.HasErrors() || val.IsNull() || !val.IsKnown() || val.Type() != cty.String {
+		return "", false
+	}
+	return val.AsString(), true
+}
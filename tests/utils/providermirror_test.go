@@ -0,0 +1,217 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Azure/terratest-terraform-fluent/setuptest"
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestModuleRequiredProviders tests that the providers of the module, its local child modules and its installed remote modules are found,
+// including the legacy constraint form and providers used without a required_providers entry.
+func TestModuleRequiredProviders(t *testing.T) {
+	reqs, err := ModuleRequiredProviders("testdata/mirror-module")
+	require.NoError(t, err)
+	assert.Equal(t, ProviderRequirements{
+		"registry.terraform.io/azure/azapi":       {"~> 2.5", ">= 2.0, < 3.0"},
+		"registry.terraform.io/hashicorp/random":  {"~> 3.6"},
+		"registry.terraform.io/hashicorp/time":    nil,
+		"registry.terraform.io/hashicorp/azurerm": nil,
+		"registry.terraform.io/azure/modtm":       {"~> 0.3"},
+	}, reqs)
+}
+
+// TestModuleRequiredProvidersRemoteModules tests that remote modules are read from the modules directory,
+// and that a remote module that is not installed is an error naming it.
+func TestModuleRequiredProvidersRemoteModules(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.tf"), []byte(`
+module "vnet" {
+  source  = "azure/avm-res-network-virtualnetwork/azurerm"
+  version = "0.8.1"
+}
+
+module "peering" {
+  source = "Azure/avm-res-network-virtualnetwork/azurerm//modules/peering"
+}
+`), 0o644))
+
+	_, err := ModuleRequiredProviders(dir)
+	require.Error(t, err)
+	assert.ErrorContains(t, err, "azure/avm-res-network-virtualnetwork/azurerm, called by module vnet")
+	assert.ErrorContains(t, err, "Azure/avm-res-network-virtualnetwork/azurerm//modules/peering, called by module peering")
+
+	modulesDir := t.TempDir()
+	peering := filepath.Join(modulesDir, "vnet", "modules", "peering")
+	require.NoError(t, os.MkdirAll(peering, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(modulesDir, "vnet", "main.tf"), []byte(`resource "azapi_resource" "this" {}`), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(peering, "main.tf"), []byte(`resource "random_uuid" "this" {}`), 0o644))
+	manifest := fmt.Sprintf(`{"Modules": [
+  {"Key": "", "Source": "", "Dir": "."},
+  {"Key": "vnet", "Source": "registry.terraform.io/Azure/avm-res-network-virtualnetwork/azurerm", "Version": "0.8.1", "Dir": %q},
+  {"Key": "peering", "Source": "registry.terraform.io/Azure/avm-res-network-virtualnetwork/azurerm//modules/peering", "Dir": %q}
+]}`, filepath.Join(modulesDir, "vnet"), peering)
+	require.NoError(t, os.WriteFile(filepath.Join(modulesDir, "modules.json"), []byte(manifest), 0o644))
+
+	reqs, err := ModuleRequiredProvidersWithOptions(dir, &ModuleRequiredProvidersOptions{ModulesDir: modulesDir})
+	require.NoError(t, err)
+	assert.Equal(t, ProviderRequirements{
+		"registry.terraform.io/hashicorp/azapi":  nil,
+		"registry.terraform.io/hashicorp/random": nil,
+	}, reqs)
+}
+
+// TestNormaliseProviderSource tests that the hostname and namespace are defaulted and the address is lower case.
+func TestNormaliseProviderSource(t *testing.T) {
+	for source, want := range map[string]string{
+		"random":                            "registry.terraform.io/hashicorp/random",
+		"Azure/azapi":                       "registry.terraform.io/azure/azapi",
+		"registry.terraform.io/Azure/modtm": "registry.terraform.io/azure/modtm",
+	} {
+		got, err := NormaliseProviderSource(source)
+		require.NoError(t, err)
+		assert.Equal(t, want, got)
+	}
+	for _, source := range []string{"", "azure//azapi", "a/b/c/d"} {
+		_, err := NormaliseProviderSource(source)
+		assert.Error(t, err, source)
+	}
+}
+
+// newTestMirror returns a mirror with azapi 2.5.0 in the packed layout and random 3.6.3 in the unpacked layout,
+// and azapi 2.6.0 for another platform.
+func newTestMirror(t *testing.T) string {
+	mirror := t.TempDir()
+	azapi := filepath.Join(mirror, "registry.terraform.io", "azure", "azapi")
+	require.NoError(t, os.MkdirAll(azapi, 0o755))
+	for _, name := range []string{
+		"terraform-provider-azapi_2.5.0_" + ProviderPlatform + ".zip",
+		"terraform-provider-azapi_2.6.0_plan9_arm.zip",
+		"terraform-provider-azapi_2.5.0.json",
+	} {
+		require.NoError(t, os.WriteFile(filepath.Join(azapi, name), nil, 0o644))
+	}
+	require.NoError(t, os.MkdirAll(filepath.Join(mirror, "registry.terraform.io", "hashicorp", "random", "3.6.3", ProviderPlatform), 0o755))
+	return mirror
+}
+
+// TestCheckMirror tests that a provider is found in either layout, only for the platform,
+// and that the error names every missing provider with its constraints.
+func TestCheckMirror(t *testing.T) {
+	mirror := newTestMirror(t)
+
+	versions, err := MirrorProviderVersions(mirror, "registry.terraform.io/azure/azapi", ProviderPlatform)
+	require.NoError(t, err)
+	assert.Equal(t, "2.5.0", joinVersions(versions))
+
+	reqs := make(ProviderRequirements)
+	require.NoError(t, reqs.Add("Azure/azapi", "~> 2.5"))
+	require.NoError(t, reqs.Add("random", "~> 3.6"))
+	assert.NoError(t, reqs.CheckMirror(mirror, ProviderPlatform))
+
+	require.NoError(t, reqs.Add("azure/azapi", ">= 2.6"))
+	require.NoError(t, reqs.Add("hashicorp/time"))
+	err = reqs.CheckMirror(mirror, ProviderPlatform)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `registry.terraform.io/azure/azapi "~> 2.5, >= 2.6", available: 2.5.0`)
+	assert.Contains(t, err.Error(), `registry.terraform.io/hashicorp/time ">= 0", available: none`)
+	assert.NotContains(t, err.Error(), "hashicorp/random")
+}
+
+// TestWithProviderMirror tests that the prep func writes a CLI configuration with only a filesystem mirror
+// and points Terraform at it, that it fails before init when a provider or a registry module is missing,
+// and that it copies the registry modules from the mirror.
+func TestWithProviderMirror(t *testing.T) {
+	mirror := newTestMirror(t)
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.tf"), []byte(`resource "random_uuid" "this" {}`), 0o644))
+
+	resp := setuptest.Response{TmpDir: dir, Options: &terraform.Options{}}
+	nextRun := false
+	next := func(setuptest.Response) error {
+		nextRun = true
+		return nil
+	}
+	require.NoError(t, WithProviderMirror(mirror, next)(resp))
+	assert.True(t, nextRun)
+
+	path := resp.Options.EnvVars["TF_CLI_CONFIG_FILE"]
+	assert.Equal(t, ProviderMirrorConfigFile, filepath.Base(path))
+	config, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(config), "filesystem_mirror")
+	assert.Contains(t, string(config), filepath.ToSlash(mirror))
+	assert.NotContains(t, string(config), "direct")
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "time.tf"), []byte(`resource "time_sleep" "this" {}`), 0o644))
+	err = WithProviderMirror(mirror, nil)(setuptest.Response{TmpDir: dir, Options: &terraform.Options{}})
+	assert.ErrorContains(t, err, "registry.terraform.io/hashicorp/time")
+
+	require.NoError(t, os.Remove(filepath.Join(dir, "time.tf")))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "regions.tf"), []byte(`module "regions" {
+  source  = "Azure/avm-utl-regions/azurerm"
+  version = "~> 0.5"
+}`), 0o644))
+	err = WithProviderMirror(mirror, nil)(setuptest.Response{TmpDir: dir, Options: &terraform.Options{}})
+	assert.ErrorContains(t, err, `regions registry.terraform.io/azure/avm-utl-regions/azurerm "~> 0.5"`)
+
+	// A registry module installed at a version that does not meet the constraint is not used.
+	newTestModuleMirror(t, mirror, "other", "0.4.0")
+	err = WithProviderMirror(mirror, nil)(setuptest.Response{TmpDir: dir, Options: &terraform.Options{}})
+	assert.ErrorContains(t, err, "has no remote modules for module")
+
+	newTestModuleMirror(t, mirror, "root", "0.5.0")
+	require.NoError(t, WithProviderMirror(mirror, nil)(setuptest.Response{TmpDir: dir, Options: &terraform.Options{}}))
+	_, err = os.Stat(filepath.Join(dir, ".terraform", "modules", "regions", "terraform.tf"))
+	assert.NoError(t, err)
+	reqs, err := ModuleRequiredProviders(dir)
+	require.NoError(t, err)
+	assert.Contains(t, reqs, "registry.terraform.io/hashicorp/random")
+}
+
+// newTestModuleMirror adds a module to the module mirror that installs Azure/avm-utl-regions/azurerm at the version
+// under the key regions, as written by cmd/lzmirror.
+func newTestModuleMirror(t *testing.T, mirror, name, version string) {
+	modulesDir := filepath.Join(mirror, ModuleMirrorDir, name, ".terraform", "modules")
+	require.NoError(t, os.MkdirAll(filepath.Join(modulesDir, "regions"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(modulesDir, "regions", "terraform.tf"),
+		[]byte("terraform {\n  required_providers {\n    random = \"~> 3.6\"\n  }\n}\n"), 0o644))
+	manifest := fmt.Sprintf(`{"Modules": [
+  {"Key": "", "Source": "", "Dir": "."},
+  {"Key": "regions", "Source": "registry.terraform.io/Azure/avm-utl-regions/azurerm", "Version": %q, "Dir": ".terraform/modules/regions"}
+]}`, version)
+	require.NoError(t, os.WriteFile(filepath.Join(modulesDir, "modules.json"), []byte(manifest), 0o644))
+}
+
+// TestRemoteModuleCalls tests that the remote modules called through local modules are found under their key.
+func TestRemoteModuleCalls(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "child"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.tf"), []byte(`
+module "child" {
+  source = "./child"
+}
+
+module "regions" {
+  source  = "Azure/avm-utl-regions/azurerm"
+  version = "0.5.0"
+}
+`), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "child", "main.tf"), []byte(`
+module "peering" {
+  source = "Azure/avm-res-network-virtualnetwork/azurerm//modules/peering"
+}
+`), 0o644))
+
+	calls, err := RemoteModuleCalls(dir)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]ModuleCall{
+		"regions":       {Source: "registry.terraform.io/azure/avm-utl-regions/azurerm", Version: "0.5.0"},
+		"child.peering": {Source: "registry.terraform.io/azure/avm-res-network-virtualnetwork/azurerm//modules/peering"},
+	}, calls)
+}
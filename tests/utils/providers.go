@@ -5,6 +5,7 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"text/template"
 
 	"github.com/Azure/terratest-terraform-fluent/setuptest"
@@ -12,9 +13,32 @@ import (
 
 // RequiredProvidersData is the data struct for the Terraform required providers block.
 // It should ordinarily be generated using utils.NewRequiredProvidersData().
+// The modtm, random and time providers are only constrained if their version is set.
 type RequiredProvidersData struct {
 	AzAPIVersion   string
 	AzureRMVersion string
+	ModtmVersion   string
+	RandomVersion  string
+	TimeVersion    string
+}
+
+// The environment variables holding the provider versions of the required providers file.
+// The value is a version, a version constraint such as "~> 2.5", or "latest".
+const (
+	AzAPIVersionEnvVar   = "AZAPI_VERSION"
+	AzureRMVersionEnvVar = "AZURERM_VERSION"
+	ModtmVersionEnvVar   = "MODTM_VERSION"
+	RandomVersionEnvVar  = "RANDOM_VERSION"
+	TimeVersionEnvVar    = "TIME_VERSION"
+)
+
+// ProviderVersionEnvVars maps the local name of each provider in the required providers file to its version environment variable.
+var ProviderVersionEnvVars = map[string]string{
+	"azapi":   AzAPIVersionEnvVar,
+	"azurerm": AzureRMVersionEnvVar,
+	"modtm":   ModtmVersionEnvVar,
+	"random":  RandomVersionEnvVar,
+	"time":    TimeVersionEnvVar,
 }
 
 const (
@@ -30,6 +54,24 @@ terraform {
 			source  = "azure/azapi"
 			version = "{{ .AzAPIVersion }}"
 		}
+{{- if .ModtmVersion }}
+		modtm = {
+			source  = "azure/modtm"
+			version = "{{ .ModtmVersion }}"
+		}
+{{- end }}
+{{- if .RandomVersion }}
+		random = {
+			source  = "hashicorp/random"
+			version = "{{ .RandomVersion }}"
+		}
+{{- end }}
+{{- if .TimeVersion }}
+		time = {
+			source  = "hashicorp/time"
+			version = "{{ .TimeVersion }}"
+		}
+{{- end }}
 	}
 }`
 )
@@ -38,19 +80,46 @@ terraform {
 //
 // - a required providers file in the given temporary directory (with version constraints from env vars)
 // - a azurerm providers file in the given temporary directory
+//
+// If TERRATEST_PROVIDER_MIRROR is set, the providers are installed from the mirror, see WithProviderMirror.
 var AzureRmAndRequiredProviders setuptest.PrepFunc = func(resp setuptest.Response) error {
 	if err := createAzureRmProvidersFile(resp.TmpDir); err != nil {
 		return err
 	}
-	return generateRequiredProvidersFile(newRequiredProvidersData(), filepath.Clean(resp.TmpDir+"/terraform.tf"))
+	if err := generateRequiredProvidersFile(NewRequiredProvidersData(), filepath.Clean(resp.TmpDir+"/terraform.tf")); err != nil {
+		return err
+	}
+	return providerMirrorFromEnv(resp)
 }
 
 // RequiredProviders is a setuptest.SetupTestPrepFunc that will create a required providers file in the given temporary directory.
+// If TERRATEST_PROVIDER_MIRROR is set, the providers are installed from the mirror, see WithProviderMirror.
 var RequiredProviders setuptest.PrepFunc = func(resp setuptest.Response) error {
-	return generateRequiredProvidersFile(newRequiredProvidersData(), filepath.Clean(resp.TmpDir+"/terraform.tf"))
+	if err := generateRequiredProvidersFile(NewRequiredProvidersData(), filepath.Clean(resp.TmpDir+"/terraform.tf")); err != nil {
+		return err
+	}
+	return providerMirrorFromEnv(resp)
+}
+
+// Requirements returns the providers of the required providers file.
+func (d RequiredProvidersData) Requirements() ProviderRequirements {
+	reqs := make(ProviderRequirements)
+	_ = reqs.Add("hashicorp/azurerm", d.AzureRMVersion)
+	_ = reqs.Add("azure/azapi", d.AzAPIVersion)
+	if d.ModtmVersion != "" {
+		_ = reqs.Add("azure/modtm", d.ModtmVersion)
+	}
+	if d.RandomVersion != "" {
+		_ = reqs.Add("hashicorp/random", d.RandomVersion)
+	}
+	if d.TimeVersion != "" {
+		_ = reqs.Add("hashicorp/time", d.TimeVersion)
+	}
+	return reqs
 }
 
-func generateRequiredProviders(data RequiredProvidersData, w io.Writer) error {
+// GenerateRequiredProviders writes the required providers file for the data.
+func GenerateRequiredProviders(data RequiredProvidersData, w io.Writer) error {
 	tmpl := template.Must(template.New("terraformtf").Parse(requiredProvidersContent))
 	return tmpl.Execute(w, data)
 }
@@ -63,26 +132,40 @@ func generateRequiredProvidersFile(data RequiredProvidersData, path string) erro
 		return err
 	}
 	defer f.Close()
-	return generateRequiredProviders(data, f)
+	return GenerateRequiredProviders(data, f)
 }
 
-// newRequiredProvidersData generated a new version of the required providers data struct.
-// It will use environment variables "AZAPI_VERSION" and "AZURERM_VERSION" to generate the data.
+// NewRequiredProvidersData generates a new version of the required providers data struct.
+// It will use the environment variables "AZAPI_VERSION", "AZURERM_VERSION", "MODTM_VERSION", "RANDOM_VERSION" and "TIME_VERSION"
+// to generate the data.
 // If the environment variables are not set or the value is "latest", it will use the default values.
-func newRequiredProvidersData() RequiredProvidersData {
-	var rpd RequiredProvidersData
-	azapiver := "~> 2.2"
-	azurermver := "~> 4.0"
+func NewRequiredProvidersData() RequiredProvidersData {
+	return RequiredProvidersDataFromLookup(os.Getenv)
+}
 
-	if val := os.Getenv("AZAPI_VERSION"); val != "" && val != "latest" {
-		azapiver = "= " + val
+// RequiredProvidersDataFromLookup generates the required providers data struct from the version variables returned by lookup,
+// as NewRequiredProvidersData does from the environment.
+func RequiredProvidersDataFromLookup(lookup func(string) string) RequiredProvidersData {
+	return RequiredProvidersData{
+		AzAPIVersion:   versionConstraint(lookup(AzAPIVersionEnvVar), "~> 2.2"),
+		AzureRMVersion: versionConstraint(lookup(AzureRMVersionEnvVar), "~> 4.0"),
+		ModtmVersion:   versionConstraint(lookup(ModtmVersionEnvVar), ""),
+		RandomVersion:  versionConstraint(lookup(RandomVersionEnvVar), ""),
+		TimeVersion:    versionConstraint(lookup(TimeVersionEnvVar), ""),
 	}
-	if val := os.Getenv("AZURERM_VERSION"); val != "" && val != "latest" {
-		azurermver = "= " + val
+}
+
+// versionConstraint returns the constraint for the value of a version variable.
+// A bare version is pinned, a constraint is used as is.
+func versionConstraint(val, def string) string {
+	val = strings.TrimSpace(val)
+	switch {
+	case val == "" || val == "latest":
+		return def
+	case strings.ContainsAny(val[:1], "=!<>~"):
+		return val
 	}
-	rpd.AzAPIVersion = azapiver
-	rpd.AzureRMVersion = azurermver
-	return rpd
+	return "= " + val
 }
 
 // createAzureRmProvidersFile creates an azurerm terraform providers file in the supplied directory.
@@ -0,0 +1,36 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestRequiredProvidersDataFromLookup tests that bare versions are pinned, constraints are kept,
+// and that the optional providers are only constrained when set.
+func TestRequiredProvidersDataFromLookup(t *testing.T) {
+	env := map[string]string{
+		AzAPIVersionEnvVar:   "2.5.0",
+		AzureRMVersionEnvVar: "latest",
+		TimeVersionEnvVar:    "~> 0.9",
+	}
+	data := RequiredProvidersDataFromLookup(func(k string) string { return env[k] })
+	assert.Equal(t, RequiredProvidersData{
+		AzAPIVersion:   "= 2.5.0",
+		AzureRMVersion: "~> 4.0",
+		TimeVersion:    "~> 0.9",
+	}, data)
+
+	var b strings.Builder
+	require.NoError(t, GenerateRequiredProviders(data, &b))
+	assert.Contains(t, b.String(), `source  = "hashicorp/time"`)
+	assert.Contains(t, b.String(), `version = "~> 0.9"`)
+	assert.NotContains(t, b.String(), "random")
+	assert.NotContains(t, b.String(), "modtm")
+
+	reqs := data.Requirements()
+	assert.Equal(t, []string{"~> 0.9"}, reqs["registry.terraform.io/hashicorp/time"])
+	assert.NotContains(t, reqs, "registry.terraform.io/hashicorp/random")
+}
@@ -0,0 +1,340 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+)
+
+const (
+	// SnapshotUnknown replaces the values that are known after apply.
+	SnapshotUnknown = "(known after apply)"
+	// SnapshotSensitive replaces sensitive values.
+	SnapshotSensitive = "(sensitive)"
+	// SnapshotSubscriptionID replaces the subscription ID of the environment.
+	SnapshotSubscriptionID = "(subscription id)"
+	// SnapshotTenantID replaces the tenant ID of the environment.
+	SnapshotTenantID = "(tenant id)"
+	// UpdateSnapshotsEnvVar regenerates the golden files when set to a non empty value, as does -update.
+	UpdateSnapshotsEnvVar = "UPDATE_SNAPSHOTS"
+)
+
+// updateSnapshots is registered once here, so -update can only be passed to packages that import utils,
+// e.g. go test ./subscription -update.
+var updateSnapshots = flag.Bool("update", false, "regenerate the golden plan snapshots in testdata")
+
+// SnapshotOptions are the options for MatchPlanSnapshot.
+type SnapshotOptions struct {
+	// Dir is the directory of the golden files, default testdata.
+	Dir string
+	// Name is the golden file name without the .plan.json extension, default the test name.
+	Name string
+	// Replace maps substrings of string values to their replacement,
+	// e.g. a random name generated by the test to a placeholder, so that the snapshot is stable.
+	Replace map[string]string
+}
+
+// PlanSnapshot is the normalised form of a plan that is compared to a golden file.
+type PlanSnapshot struct {
+	// Resources are indexed by address.
+	Resources map[string]SnapshotResource `json:"resources"`
+}
+
+// SnapshotResource is the planned change to a resource.
+type SnapshotResource struct {
+	Actions []string `json:"actions"`
+	// Values are the planned values, with unknown and sensitive values replaced by SnapshotUnknown and SnapshotSensitive.
+	Values any `json:"values"`
+}
+
+// NormalisePlan returns the planned changes of the plan, with unknown and sensitive values replaced
+// and the replacements in opts applied.
+// opts may be nil.
+func NormalisePlan(plan *terraform.PlanStruct, opts *SnapshotOptions) (*PlanSnapshot, error) {
+	if plan == nil {
+		return nil, errors.New("cannot normalise plan, plan is nil")
+	}
+	if opts == nil {
+		opts = &SnapshotOptions{}
+	}
+	snap := &PlanSnapshot{Resources: make(map[string]SnapshotResource)}
+	for _, rc := range plan.RawPlan.ResourceChanges {
+		if rc.Change == nil {
+			continue
+		}
+		actions := make([]string, len(rc.Change.Actions))
+		for i, a := range rc.Change.Actions {
+			actions[i] = string(a)
+		}
+		snap.Resources[rc.Address] = SnapshotResource{
+			Actions: actions,
+			Values:  normaliseValue(rc.Change.After, rc.Change.AfterUnknown, rc.Change.AfterSensitive, opts.Replace),
+		}
+	}
+	return snap, nil
+}
+
+// normaliseValue merges the after_unknown and after_sensitive trees of a change into its after value.
+func normaliseValue(v, unknown, sensitive any, replace map[string]string) any {
+	if sensitive == true {
+		return SnapshotSensitive
+	}
+	if unknown == true {
+		return SnapshotUnknown
+	}
+	switch v := v.(type) {
+	case map[string]any:
+		unknowns, _ := unknown.(map[string]any)
+		sensitives, _ := sensitive.(map[string]any)
+		out := make(map[string]any, len(v))
+		for k, e := range v {
+			out[k] = normaliseValue(e, unknowns[k], sensitives[k], replace)
+		}
+		// Unknown attributes are missing from the after value.
+		for k, u := range unknowns {
+			if _, ok := v[k]; !ok {
+				out[k] = normaliseValue(nil, u, sensitives[k], replace)
+			}
+		}
+		return out
+	case []any:
+		unknowns, _ := unknown.([]any)
+		sensitives, _ := sensitive.([]any)
+		out := make([]any, max(len(v), len(unknowns)))
+		for i := range out {
+			var e, u, s any
+			if i < len(v) {
+				e = v[i]
+			}
+			if i < len(unknowns) {
+				u = unknowns[i]
+			}
+			if i < len(sensitives) {
+				s = sensitives[i]
+			}
+			out[i] = normaliseValue(e, u, s, replace)
+		}
+		return out
+	case string:
+		for _, old := range sortedKeys(replace) {
+			v = strings.ReplaceAll(v, old, replace[old])
+		}
+		return v
+	}
+	return v
+}
+
+// MarshalPlanSnapshot returns the snapshot as indented JSON, with object keys sorted.
+func MarshalPlanSnapshot(snap *PlanSnapshot) ([]byte, error) {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(snap); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// MatchPlanSnapshot compares the normalised plan to the golden file testdata/<test name>.plan.json,
+// and fails the test with a structural diff if they differ.
+// Run the test with -update, or with UPDATE_SNAPSHOTS set, to write the golden file instead.
+// opts may be nil.
+func MatchPlanSnapshot(t testing.TB, plan *terraform.PlanStruct, opts *SnapshotOptions) {
+	t.Helper()
+	if opts == nil {
+		opts = &SnapshotOptions{}
+	}
+	snap, err := NormalisePlan(plan, opts)
+	if err != nil {
+		t.Fatalf("cannot snapshot plan, %v", err)
+		return
+	}
+	got, err := MarshalPlanSnapshot(snap)
+	if err != nil {
+		t.Fatalf("cannot snapshot plan, %v", err)
+		return
+	}
+	path := snapshotPath(t, opts)
+
+	if *updateSnapshots || os.Getenv(UpdateSnapshotsEnvVar) != "" {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("cannot create snapshot directory, %v", err)
+			return
+		}
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatalf("cannot write snapshot, %v", err)
+			return
+		}
+		t.Logf("updated plan snapshot %s", path)
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("plan snapshot %s does not exist, run the test with -update or %s=1 to create it", path, UpdateSnapshotsEnvVar)
+		return
+	}
+	if err != nil {
+		t.Fatalf("cannot read plan snapshot, %v", err)
+		return
+	}
+	var wantSnap PlanSnapshot
+	if err := json.Unmarshal(want, &wantSnap); err != nil {
+		t.Fatalf("cannot read plan snapshot %s, %v", path, err)
+		return
+	}
+	// Round trip the plan so that both sides have the same JSON types.
+	var gotSnap PlanSnapshot
+	if err := json.Unmarshal(got, &gotSnap); err != nil {
+		t.Fatalf("cannot read plan snapshot, %v", err)
+		return
+	}
+	if diff := DiffPlanSnapshots(&wantSnap, &gotSnap); diff != "" {
+		t.Errorf("plan does not match snapshot %s, run the test with -update or %s=1 if the change is expected:\n%s", path, UpdateSnapshotsEnvVar, diff)
+	}
+}
+
+// EnvironmentReplacements returns the replacements of the subscription and tenant IDs of the environment,
+// which are read at plan time by the azapi_client_config data sources, e.g. for the telemetry tags.
+// Use them as SnapshotOptions.Replace so that the golden files do not depend on who generated them.
+func EnvironmentReplacements() map[string]string {
+	replace := make(map[string]string)
+	for _, v := range []string{"AZURE_SUBSCRIPTION_ID", "ARM_SUBSCRIPTION_ID"} {
+		if id := os.Getenv(v); id != "" {
+			replace[id] = SnapshotSubscriptionID
+		}
+	}
+	for _, v := range []string{"AZURE_TENANT_ID", "ARM_TENANT_ID"} {
+		if id := os.Getenv(v); id != "" {
+			replace[id] = SnapshotTenantID
+		}
+	}
+	return replace
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+
+func snapshotPath(t testing.TB, opts *SnapshotOptions) string {
+	dir := opts.Dir
+	if dir == "" {
+		dir = "testdata"
+	}
+	name := opts.Name
+	if name == "" {
+		name = unsafeFileChars.ReplaceAllString(t.Name(), "_")
+	}
+	return filepath.Join(dir, name+".plan.json")
+}
+
+// DiffPlanSnapshots returns the differences between the snapshots, one per line, or the empty string if they are equal.
+// Lines start with - for a value only in want, + for a value only in got, and ~ for a changed value.
+func DiffPlanSnapshots(want, got *PlanSnapshot) string {
+	var lines []string
+	addresses := sortedKeys(want.Resources)
+	for _, a := range sortedKeys(got.Resources) {
+		if _, ok := want.Resources[a]; !ok {
+			addresses = append(addresses, a)
+		}
+	}
+	slices.Sort(addresses)
+	for _, a := range addresses {
+		w, inWant := want.Resources[a]
+		g, inGot := got.Resources[a]
+		switch {
+		case !inGot:
+			lines = append(lines, fmt.Sprintf("- %s", a))
+		case !inWant:
+			lines = append(lines, fmt.Sprintf("+ %s %v", a, g.Actions))
+		default:
+			if !reflect.DeepEqual(w.Actions, g.Actions) {
+				lines = append(lines, fmt.Sprintf("~ %s actions: %v => %v", a, w.Actions, g.Actions))
+			}
+			diffValues(a+":", w.Values, g.Values, &lines)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func diffValues(path string, want, got any, lines *[]string) {
+	switch w := want.(type) {
+	case map[string]any:
+		g, ok := got.(map[string]any)
+		if !ok {
+			break
+		}
+		keys := sortedKeys(w)
+		for _, k := range sortedKeys(g) {
+			if _, ok := w[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		slices.Sort(keys)
+		for _, k := range keys {
+			wv, inWant := w[k]
+			gv, inGot := g[k]
+			p := joinPath(path, k)
+			switch {
+			case !inGot:
+				*lines = append(*lines, fmt.Sprintf("- %s %s", p, compactJSON(wv)))
+			case !inWant:
+				*lines = append(*lines, fmt.Sprintf("+ %s %s", p, compactJSON(gv)))
+			default:
+				diffValues(p, wv, gv, lines)
+			}
+		}
+		return
+	case []any:
+		g, ok := got.([]any)
+		if !ok || len(g) != len(w) {
+			break
+		}
+		for i := range w {
+			diffValues(fmt.Sprintf("%s[%d]", path, i), w[i], g[i], lines)
+		}
+		return
+	}
+	if !reflect.DeepEqual(want, got) {
+		*lines = append(*lines, fmt.Sprintf("~ %s %s => %s", path, compactJSON(want), compactJSON(got)))
+	}
+}
+
+var identifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+
+func joinPath(path, key string) string {
+	if !identifier.MatchString(key) {
+		return fmt.Sprintf("%s[%q]", path, key)
+	}
+	if strings.HasSuffix(path, ":") {
+		return path + " " + key
+	}
+	return path + "." + key
+}
+
+func compactJSON(v any) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,139 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testPlan returns a plan with a resource that has unknown and sensitive values.
+func testPlan(location string) *terraform.PlanStruct {
+	return &terraform.PlanStruct{RawPlan: tfjson.Plan{
+		ResourceChanges: []*tfjson.ResourceChange{
+			{
+				Address: `module.virtual_networks["primary"].azapi_resource.vnet`,
+				Change: &tfjson.Change{
+					Actions: tfjson.Actions{tfjson.ActionCreate},
+					After: map[string]any{
+						"name":     "vnet-1234abcd",
+						"location": location,
+						"body":     map[string]any{"properties": map[string]any{"addressSpace": []any{"10.0.0.0/24"}}},
+						"tags":     []any{"a", nil},
+					},
+					AfterUnknown:   map[string]any{"id": true, "tags": []any{false, true}},
+					AfterSensitive: map[string]any{"body": map[string]any{"properties": map[string]any{"secret": true}}},
+				},
+			},
+			{
+				Address: "azapi_resource.subscription",
+				Change:  &tfjson.Change{Actions: tfjson.Actions{tfjson.ActionNoop}, After: map[string]any{}},
+			},
+		},
+	}}
+}
+
+// TestNormalisePlan tests that unknown and sensitive values are replaced, as are the strings in the options.
+func TestNormalisePlan(t *testing.T) {
+	snap, err := NormalisePlan(testPlan("westeurope"), &SnapshotOptions{Replace: map[string]string{"1234abcd": "<random>"}})
+	require.NoError(t, err)
+	assert.Equal(t, SnapshotResource{
+		Actions: []string{"create"},
+		Values: map[string]any{
+			"id":       SnapshotUnknown,
+			"name":     "vnet-<random>",
+			"location": "westeurope",
+			"body":     map[string]any{"properties": map[string]any{"addressSpace": []any{"10.0.0.0/24"}}},
+			"tags":     []any{"a", SnapshotUnknown},
+		},
+	}, snap.Resources[`module.virtual_networks["primary"].azapi_resource.vnet`])
+	assert.Len(t, snap.Resources, 2)
+
+	sensitive := testPlan("westeurope")
+	sensitive.RawPlan.ResourceChanges[0].Change.After.(map[string]any)["body"].(map[string]any)["properties"].(map[string]any)["secret"] = "hunter2"
+	snap, err = NormalisePlan(sensitive, nil)
+	require.NoError(t, err)
+	b, err := MarshalPlanSnapshot(snap)
+	require.NoError(t, err)
+	assert.NotContains(t, string(b), "hunter2")
+	assert.Contains(t, string(b), SnapshotSensitive)
+}
+
+// snapshotT records the failures of MatchPlanSnapshot so that they can be asserted on.
+type snapshotT struct {
+	testing.TB
+	failures []string
+}
+
+func (t *snapshotT) Errorf(format string, args ...any) {
+	t.failures = append(t.failures, fmt.Sprintf(format, args...))
+}
+
+func (t *snapshotT) Fatalf(format string, args ...any) {
+	t.Errorf(format, args...)
+}
+
+// TestMatchPlanSnapshot tests that a missing golden file fails, that UPDATE_SNAPSHOTS and -update write it,
+// and that a changed plan fails with a structural diff.
+func TestMatchPlanSnapshot(t *testing.T) {
+	opts := &SnapshotOptions{Dir: t.TempDir()}
+
+	st := &snapshotT{TB: t}
+	MatchPlanSnapshot(st, testPlan("westeurope"), opts)
+	require.Len(t, st.failures, 1)
+	assert.Contains(t, st.failures[0], "run the test with -update or UPDATE_SNAPSHOTS=1")
+
+	t.Setenv(UpdateSnapshotsEnvVar, "1")
+	MatchPlanSnapshot(t, testPlan("westeurope"), opts)
+	_, err := os.Stat(filepath.Join(opts.Dir, "TestMatchPlanSnapshot.plan.json"))
+	require.NoError(t, err)
+	t.Setenv(UpdateSnapshotsEnvVar, "")
+
+	MatchPlanSnapshot(t, testPlan("westeurope"), opts)
+
+	st = &snapshotT{TB: t}
+	MatchPlanSnapshot(st, testPlan("northeurope"), opts)
+	require.Len(t, st.failures, 1)
+	assert.Contains(t, st.failures[0], `~ module.virtual_networks["primary"].azapi_resource.vnet: location "westeurope" => "northeurope"`)
+
+	*updateSnapshots = true
+	t.Cleanup(func() { *updateSnapshots = false })
+	MatchPlanSnapshot(t, testPlan("northeurope"), opts)
+	*updateSnapshots = false
+	MatchPlanSnapshot(t, testPlan("northeurope"), opts)
+}
+
+// TestEnvironmentReplacements tests that the subscription and tenant IDs of the environment are masked.
+func TestEnvironmentReplacements(t *testing.T) {
+	t.Setenv("AZURE_SUBSCRIPTION_ID", "11111111-1111-1111-1111-111111111111")
+	t.Setenv("ARM_SUBSCRIPTION_ID", "")
+	t.Setenv("AZURE_TENANT_ID", "")
+	t.Setenv("ARM_TENANT_ID", "22222222-2222-2222-2222-222222222222")
+	assert.Equal(t, map[string]string{
+		"11111111-1111-1111-1111-111111111111": SnapshotSubscriptionID,
+		"22222222-2222-2222-2222-222222222222": SnapshotTenantID,
+	}, EnvironmentReplacements())
+}
+
+// TestDiffPlanSnapshots tests the diff of added, removed and changed resources and values.
+func TestDiffPlanSnapshots(t *testing.T) {
+	want := &PlanSnapshot{Resources: map[string]SnapshotResource{
+		"azapi_resource.a": {Actions: []string{"create"}, Values: map[string]any{"body": map[string]any{"x": 1.0, "y": []any{"a"}}}},
+		"azapi_resource.b": {Actions: []string{"create"}},
+	}}
+	got := &PlanSnapshot{Resources: map[string]SnapshotResource{
+		"azapi_resource.a": {Actions: []string{"create"}, Values: map[string]any{"body": map[string]any{"x": 2.0, "z": true, "y": []any{"a", "b"}}}},
+		"azapi_resource.c": {Actions: []string{"create"}},
+	}}
+	assert.Equal(t, `~ azapi_resource.a: body.x 1 => 2
+~ azapi_resource.a: body.y ["a"] => ["a","b"]
++ azapi_resource.a: body.z true
+- azapi_resource.b
++ azapi_resource.c [create]`, DiffPlanSnapshots(want, got))
+	assert.Empty(t, DiffPlanSnapshots(want, want))
+}
@@ -0,0 +1,16 @@
+terraform {
+  required_providers {
+    azapi = {
+      source                = "azure/azapi"
+      version               = ">= 2.0, < 3.0"
+      configuration_aliases = [azapi.alternate]
+    }
+  }
+}
+
+resource "azapi_resource" "this" {
+  type      = "Microsoft.Resources/resourceGroups@2021-04-01"
+  name      = "rg"
+  parent_id = "/subscriptions/00000000-0000-0000-0000-000000000000"
+  location  = "westeurope"
+}
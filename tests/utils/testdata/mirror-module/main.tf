@@ -0,0 +1,22 @@
+provider "azurerm" {
+  features {}
+}
+
+resource "time_sleep" "wait" {
+  create_duration = "30s"
+}
+
+resource "terraform_data" "this" {
+  input = random_uuid.this.result
+}
+
+resource "random_uuid" "this" {}
+
+module "child" {
+  source = "./child"
+}
+
+module "registry" {
+  source  = "Azure/avm-utl-regions/azurerm"
+  version = "0.5.0"
+}
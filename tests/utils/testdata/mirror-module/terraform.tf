@@ -0,0 +1,10 @@
+terraform {
+  required_version = "~> 1.10"
+  required_providers {
+    azapi = {
+      source  = "Azure/azapi"
+      version = "~> 2.5"
+    }
+    random = "~> 3.6"
+  }
+}
@@ -3,10 +3,12 @@ package utils
 import (
 	"crypto/rand"
 	"encoding/hex"
+	"io"
 	"os"
 	"path/filepath"
 	"runtime"
 	"strings"
+	"sync"
 	"testing"
 
 	"github.com/gruntwork-io/terratest/modules/logger"
@@ -14,6 +16,7 @@ import (
 
 // SanitiseErrorMessage replaces the newline characters in an error.Error() output with a single space to allow us to check for the entire error message.
 // We need to do this because Terraform adds newline characters depending on the width of the console window.
+// Prefer PlanDiagnostics, which does not depend on the console width.
 // TODO: Test on Windows if we get \r\n instead of just \n.
 func SanitiseErrorMessage(err error) string {
 	return strings.ReplaceAll(err.Error(), "\n", " ")
@@ -52,12 +55,40 @@ func PreCheckDeployTests(t *testing.T) {
 	}
 }
 
+// randReader is the source of the bytes used by RandomHex, guarded by randMu.
+var (
+	randMu     sync.Mutex
+	randReader io.Reader = rand.Reader
+)
+
+// randomReaderEnvVar is set by SetRandomReader so that t.Setenv rejects its use in parallel tests.
+const randomReaderEnvVar = "TERRATEST_RANDOM_READER"
+
+// SetRandomReader replaces the source of the bytes used by RandomHex until the test completes.
+// It is used by the recording package so that generated names are the same when a test is replayed.
+// The reader is global, so like t.Setenv it panics if the test or an ancestor is parallel,
+// and the test cannot call t.Parallel afterwards.
+func SetRandomReader(t *testing.T, r io.Reader) {
+	t.Setenv(randomReaderEnvVar, t.Name())
+	randMu.Lock()
+	old := randReader
+	randReader = r
+	randMu.Unlock()
+	t.Cleanup(func() {
+		randMu.Lock()
+		randReader = old
+		randMu.Unlock()
+	})
+}
+
 // RandomHex generates a random hex string of the given byte length.
 // Uses crypto/rand for generating the random bytes not math/rand
 // as we kept getting the same results from the math/rand generator.
 func RandomHex(n int) (string, error) {
 	bytes := make([]byte, n)
-	if _, err := rand.Read(bytes); err != nil {
+	randMu.Lock()
+	defer randMu.Unlock()
+	if _, err := io.ReadFull(randReader, bytes); err != nil {
 		return "", err
 	}
 	return hex.EncodeToString(bytes), nil
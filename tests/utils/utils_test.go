@@ -0,0 +1,29 @@
+package utils
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSetRandomReader tests that RandomHex reads from the reader until the test completes,
+// and that the reader cannot be replaced in a parallel test.
+func TestSetRandomReader(t *testing.T) {
+	t.Run("replaced", func(t *testing.T) {
+		SetRandomReader(t, bytes.NewReader([]byte{0xde, 0xad, 0xbe, 0xef}))
+		s, err := RandomHex(4)
+		require.NoError(t, err)
+		assert.Equal(t, "deadbeef", s)
+	})
+	t.Run("restored", func(t *testing.T) {
+		s, err := RandomHex(4)
+		require.NoError(t, err)
+		assert.NotEqual(t, "deadbeef", s)
+	})
+	t.Run("parallel", func(t *testing.T) {
+		t.Parallel()
+		assert.Panics(t, func() { SetRandomReader(t, bytes.NewReader(nil)) })
+	})
+}
@@ -0,0 +1,71 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+)
+
+// DefaultResourceProvidersVariable is the root module variable holding the resource providers and features to register.
+const DefaultResourceProvidersVariable = "subscription_register_resource_providers_and_features"
+
+// VariableDefault decodes the default value of a variable declared in the .tf files of the module directory into out,
+// which is a pointer as for json.Unmarshal.
+// The default must be a constant expression.
+func VariableDefault(dir, name string, out any) error {
+	files, err := filepath.Glob(filepath.Join(dir, "*.tf"))
+	if err != nil {
+		return err
+	}
+	parser := hclparse.NewParser()
+	schema := &hcl.BodySchema{
+		Blocks: []hcl.BlockHeaderSchema{{Type: "variable", LabelNames: []string{"name"}}},
+	}
+	for _, file := range files {
+		f, diags := parser.ParseHCLFile(file)
+		if diags.HasErrors() {
+			return fmt.Errorf("cannot parse %s, %s", file, diags.Error())
+		}
+		content, _, diags := f.Body.PartialContent(schema)
+		if diags.HasErrors() {
+			return fmt.Errorf("cannot read %s, %s", file, diags.Error())
+		}
+		for _, block := range content.Blocks {
+			if block.Labels[0] != name {
+				continue
+			}
+			attrs, diags := block.Body.JustAttributes()
+			if diags.HasErrors() {
+				return fmt.Errorf("cannot read variable %s in %s, %s", name, file, diags.Error())
+			}
+			attr, ok := attrs["default"]
+			if !ok {
+				return fmt.Errorf("variable %s in %s has no default", name, file)
+			}
+			val, diags := attr.Expr.Value(nil)
+			if diags.HasErrors() {
+				return fmt.Errorf("cannot evaluate default of variable %s, %s", name, diags.Error())
+			}
+			b, err := ctyjson.Marshal(val, val.Type())
+			if err != nil {
+				return fmt.Errorf("cannot convert default of variable %s, %s", name, err)
+			}
+			return json.Unmarshal(b, out)
+		}
+	}
+	return fmt.Errorf("variable %s not found in %s", name, dir)
+}
+
+// DefaultResourceProviders returns the default resource providers and features of the root module,
+// in the module directory.
+func DefaultResourceProviders(dir string) (map[string][]string, error) {
+	var providers map[string][]string
+	if err := VariableDefault(dir, DefaultResourceProvidersVariable, &providers); err != nil {
+		return nil, err
+	}
+	return providers, nil
+}
@@ -0,0 +1,65 @@
+package virtualnetwork
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Azure/terraform-azurerm-lz-vending/tests/azureutils"
+	"github.com/Azure/terraform-azurerm-lz-vending/tests/utils"
+	"github.com/google/uuid"
+)
+
+// fixtures are the hub networks and virtual hubs shared by the deploy tests in this package.
+var fixtures = azureutils.NewFixturePool(nil)
+
+// fixturePrefix is the name prefix of the shared fixtures, unique to this test run.
+var fixturePrefix string
+
+func TestMain(m *testing.M) {
+	r, err := utils.RandomHex(4)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cannot generate random hex, %s\n", err)
+		os.Exit(1)
+	}
+	fixturePrefix = "testdeploy-" + r
+
+	code := m.Run()
+	// deleting a virtual hub can take more than 30 minutes
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+	if err := fixtures.Close(ctx); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		if code == 0 {
+			code = 1
+		}
+	}
+	os.Exit(code)
+}
+
+// hubNetwork returns the shared hub network with a gateway subnet.
+func hubNetwork(t *testing.T, subID uuid.UUID) *azureutils.HubNetwork {
+	return fixtures.HubNetwork(t, subID, &azureutils.HubNetworkOptions{
+		Name:          fixturePrefix + "-hub",
+		GatewaySubnet: true,
+	})
+}
+
+// virtualHub returns the shared virtual WAN hub.
+func virtualHub(t *testing.T, subID uuid.UUID) *azureutils.VirtualHub {
+	return fixtures.VirtualHub(t, subID, &azureutils.VirtualHubOptions{
+		Name: fixturePrefix + "-vhub",
+	})
+}
+
+// routingIntentVirtualHub returns the shared virtual WAN hub with a firewall and routing intent.
+// It is separate from virtualHub as routing intent applies to every connection of the hub.
+func routingIntentVirtualHub(t *testing.T, subID uuid.UUID) *azureutils.VirtualHub {
+	return fixtures.VirtualHub(t, subID, &azureutils.VirtualHubOptions{
+		Name:          fixturePrefix + "-vhub-ri",
+		AddressPrefix: "192.168.102.0/23",
+		RoutingIntent: true,
+	})
+}
@@ -125,7 +125,7 @@ func TestDeployVirtualNetworkValidSubnets(t *testing.T) {
 		"containers": {
 			"name":             "snet-containers",
 			"address_prefixes": []any{"192.168.1.64/26"},
-			"delegation":       delegations,
+			"delegations":      delegations,
 		},
 	}
 
@@ -153,6 +153,19 @@ func TestDeployVirtualNetworkValidSubnets(t *testing.T) {
 	// check there two outputs for the virtual network resource ids
 	test.Output("virtual_network_resource_ids").Query("primary").Exists().ErrorIsNil(t)
 	test.Output("virtual_network_resource_ids").Query("secondary").Exists().ErrorIsNil(t)
+
+	// check that Azure holds the configuration in the input variables
+	ctx, cancel := azureutils.NewTestContext(t)
+	defer cancel()
+	subID := uuid.MustParse(v["subscription_id"].(string))
+	for k, vnet := range v["virtual_networks"].(map[string]map[string]any) {
+		diff, err := azureutils.VerifyVirtualNetwork(ctx, subID, vnet)
+		require.NoErrorf(t, err, "cannot verify virtual network %s", k)
+		assert.Emptyf(t, diff, "virtual network %s differs from the input:\n%s", k, diff)
+		diff, err = azureutils.VerifySubnets(ctx, subID, vnet)
+		require.NoErrorf(t, err, "cannot verify subnets of virtual network %s", k)
+		assert.Emptyf(t, diff, "subnets of virtual network %s differ from the input:\n%s", k, diff)
+	}
 }
 
 // TestDeployVirtualNetworkValidVnetPeering tests the deployment of a virtual network
@@ -160,14 +173,17 @@ func TestDeployVirtualNetworkValidSubnets(t *testing.T) {
 func TestDeployVirtualNetworkValidVnetPeering(t *testing.T) {
 
 	utils.PreCheckDeployTests(t)
-	testDir := "testdata/" + t.Name()
 	v, err := getValidInputVariables()
 	require.NoErrorf(t, err, "could not generate valid input variables, %s", err)
 
 	SetupResourceGroups(t, v["virtual_networks"].(map[string]map[string]any), v["subscription_id"].(string))
+	subID := uuid.MustParse(v["subscription_id"].(string))
+	hub := hubNetwork(t, subID)
 
 	primaryvnet := v["virtual_networks"].(map[string]map[string]any)["primary"]
 	secondaryvnet := v["virtual_networks"].(map[string]map[string]any)["secondary"]
+	primaryvnet["hub_network_resource_id"] = hub.ID
+	secondaryvnet["hub_network_resource_id"] = hub.ID
 	primaryvnet["hub_peering_enabled"] = true
 	secondaryvnet["hub_peering_enabled"] = true
 	primaryvnet["hub_peering_options_tohub"] = map[string]any{
@@ -177,20 +193,19 @@ func TestDeployVirtualNetworkValidVnetPeering(t *testing.T) {
 		"use_remote_gateways": false,
 	}
 
-	test, err := setuptest.Dirs(moduleDir, testDir).WithVars(v).InitPlanShowWithPrepFunc(t, utils.AzureRmAndRequiredProviders)
+	test, err := setuptest.Dirs(moduleDir, "").WithVars(v).InitPlanShowWithPrepFunc(t, utils.AzureRmAndRequiredProviders)
 	require.NoError(t, err)
 	defer test.Cleanup()
 
-	// This includes the hub, hub rg as well as the resources below
-	check.InPlan(test.PlanStruct).NumberOfResourcesEquals(8).ErrorIsNil(t)
+	check.InPlan(test.PlanStruct).NumberOfResourcesEquals(6).ErrorIsNil(t)
 
 	resources := []string{
-		"module.virtualnetwork_test.module.virtual_networks[\"primary\"].azapi_resource.vnet",
-		"module.virtualnetwork_test.module.virtual_networks[\"secondary\"].azapi_resource.vnet",
-		"module.virtualnetwork_test.module.peering_hub_inbound[\"primary\"].azapi_resource.this[0]",
-		"module.virtualnetwork_test.module.peering_hub_inbound[\"secondary\"].azapi_resource.this[0]",
-		"module.virtualnetwork_test.module.peering_hub_outbound[\"primary\"].azapi_resource.this[0]",
-		"module.virtualnetwork_test.module.peering_hub_outbound[\"secondary\"].azapi_resource.this[0]",
+		"module.virtual_networks[\"primary\"].azapi_resource.vnet",
+		"module.virtual_networks[\"secondary\"].azapi_resource.vnet",
+		"module.peering_hub_inbound[\"primary\"].azapi_resource.this[0]",
+		"module.peering_hub_inbound[\"secondary\"].azapi_resource.this[0]",
+		"module.peering_hub_outbound[\"primary\"].azapi_resource.this[0]",
+		"module.peering_hub_outbound[\"secondary\"].azapi_resource.this[0]",
 	}
 	for _, r := range resources {
 		check.InPlan(test.PlanStruct).That(r).Exists().ErrorIsNil(t)
@@ -199,6 +214,16 @@ func TestDeployVirtualNetworkValidVnetPeering(t *testing.T) {
 	// defer terraform destroy with retry
 	defer test.DestroyRetry(setuptest.DefaultRetry) //nolint:errcheck
 	test.ApplyIdempotent().ErrorIsNil(t)
+
+	// check that both sides of each peering are connected with the configured options
+	ctx, cancel := azureutils.NewTestContext(t)
+	defer cancel()
+	for k, vnet := range v["virtual_networks"].(map[string]map[string]any) {
+		diff, err := azureutils.VerifyHubPeering(ctx, subID, vnet)
+		require.NoErrorf(t, err, "cannot verify hub peering of virtual network %s", k)
+		assert.Emptyf(t, diff, "hub peering of virtual network %s differs from the input:\n%s", k, diff)
+	}
+
 }
 
 // TestDeployVirtualNetworkValidUniDirectionalVnetPeering tests the deployment of a virtual network
@@ -206,14 +231,17 @@ func TestDeployVirtualNetworkValidVnetPeering(t *testing.T) {
 func TestDeployVirtualNetworkValidUniDirectionalVnetPeering(t *testing.T) {
 
 	utils.PreCheckDeployTests(t)
-	testDir := "testdata/" + t.Name()
 	v, err := getValidInputVariables()
 	require.NoErrorf(t, err, "could not generate valid input variables, %s", err)
 
 	SetupResourceGroups(t, v["virtual_networks"].(map[string]map[string]any), v["subscription_id"].(string))
+	subID := uuid.MustParse(v["subscription_id"].(string))
+	hub := hubNetwork(t, subID)
 
 	primaryvnet := v["virtual_networks"].(map[string]map[string]any)["primary"]
 	secondaryvnet := v["virtual_networks"].(map[string]map[string]any)["secondary"]
+	primaryvnet["hub_network_resource_id"] = hub.ID
+	secondaryvnet["hub_network_resource_id"] = hub.ID
 	primaryvnet["hub_peering_enabled"] = true
 	primaryvnet["hub_peering_direction"] = "fromhub"
 	secondaryvnet["hub_peering_enabled"] = true
@@ -225,18 +253,17 @@ func TestDeployVirtualNetworkValidUniDirectionalVnetPeering(t *testing.T) {
 		"use_remote_gateways": false,
 	}
 
-	test, err := setuptest.Dirs(moduleDir, testDir).WithVars(v).InitPlanShowWithPrepFunc(t, utils.AzureRmAndRequiredProviders)
+	test, err := setuptest.Dirs(moduleDir, "").WithVars(v).InitPlanShowWithPrepFunc(t, utils.AzureRmAndRequiredProviders)
 	require.NoError(t, err)
 	defer test.Cleanup()
 
-	// This includes the hub, hub rg as well as the resources below
-	check.InPlan(test.PlanStruct).NumberOfResourcesEquals(6).ErrorIsNil(t)
+	check.InPlan(test.PlanStruct).NumberOfResourcesEquals(4).ErrorIsNil(t)
 
 	resources := []string{
-		"module.virtualnetwork_test.module.virtual_networks[\"primary\"].azapi_resource.vnet",
-		"module.virtualnetwork_test.module.virtual_networks[\"secondary\"].azapi_resource.vnet",
-		"module.virtualnetwork_test.module.peering_hub_inbound[\"primary\"].azapi_resource.this[0]",
-		"module.virtualnetwork_test.module.peering_hub_outbound[\"secondary\"].azapi_resource.this[0]",
+		"module.virtual_networks[\"primary\"].azapi_resource.vnet",
+		"module.virtual_networks[\"secondary\"].azapi_resource.vnet",
+		"module.peering_hub_inbound[\"primary\"].azapi_resource.this[0]",
+		"module.peering_hub_outbound[\"secondary\"].azapi_resource.this[0]",
 	}
 	for _, r := range resources {
 		check.InPlan(test.PlanStruct).That(r).Exists().ErrorIsNil(t)
@@ -245,6 +272,16 @@ func TestDeployVirtualNetworkValidUniDirectionalVnetPeering(t *testing.T) {
 	// defer terraform destroy with retry
 	defer test.DestroyRetry(setuptest.DefaultRetry) //nolint:errcheck
 	test.ApplyIdempotent().ErrorIsNil(t)
+
+	// check that both sides of each peering are connected with the configured options
+	ctx, cancel := azureutils.NewTestContext(t)
+	defer cancel()
+	for k, vnet := range v["virtual_networks"].(map[string]map[string]any) {
+		diff, err := azureutils.VerifyHubPeering(ctx, subID, vnet)
+		require.NoErrorf(t, err, "cannot verify hub peering of virtual network %s", k)
+		assert.Emptyf(t, diff, "hub peering of virtual network %s differs from the input:\n%s", k, diff)
+	}
+
 }
 
 // TestDeployVirtualNetworkValidVhubConnection tests the deployment of a virtual network
@@ -252,29 +289,31 @@ func TestDeployVirtualNetworkValidUniDirectionalVnetPeering(t *testing.T) {
 func TestDeployVirtualNetworkValidVhubConnection(t *testing.T) {
 
 	utils.PreCheckDeployTests(t)
-	testDir := "testdata/" + t.Name()
 	v, err := getValidInputVariables()
 	require.NoErrorf(t, err, "could not generate valid input variables, %s", err)
 
 	SetupResourceGroups(t, v["virtual_networks"].(map[string]map[string]any), v["subscription_id"].(string))
+	subID := uuid.MustParse(v["subscription_id"].(string))
+	hub := virtualHub(t, subID)
 
 	primaryvnet := v["virtual_networks"].(map[string]map[string]any)["primary"]
 	secondaryvnet := v["virtual_networks"].(map[string]map[string]any)["secondary"]
+	primaryvnet["vwan_hub_resource_id"] = hub.ID
+	secondaryvnet["vwan_hub_resource_id"] = hub.ID
 	primaryvnet["vwan_connection_enabled"] = true
 	secondaryvnet["vwan_connection_enabled"] = true
 
-	test, err := setuptest.Dirs(moduleDir, testDir).WithVars(v).InitPlanShowWithPrepFunc(t, utils.AzureRmAndRequiredProviders)
+	test, err := setuptest.Dirs(moduleDir, "").WithVars(v).InitPlanShowWithPrepFunc(t, utils.AzureRmAndRequiredProviders)
 	require.NoError(t, err)
 	defer test.Cleanup()
 
-	// This includes the hub, hub proxy, hub rg as well as the resources below
-	check.InPlan(test.PlanStruct).NumberOfResourcesEquals(7).ErrorIsNil(t)
+	check.InPlan(test.PlanStruct).NumberOfResourcesEquals(4).ErrorIsNil(t)
 
 	resources := []string{
-		"module.virtualnetwork_test.module.virtual_networks[\"primary\"].azapi_resource.vnet",
-		"module.virtualnetwork_test.module.virtual_networks[\"secondary\"].azapi_resource.vnet",
-		"module.virtualnetwork_test.azapi_resource.vhubconnection[\"primary\"]",
-		"module.virtualnetwork_test.azapi_resource.vhubconnection[\"secondary\"]",
+		"module.virtual_networks[\"primary\"].azapi_resource.vnet",
+		"module.virtual_networks[\"secondary\"].azapi_resource.vnet",
+		"azapi_resource.vhubconnection[\"primary\"]",
+		"azapi_resource.vhubconnection[\"secondary\"]",
 	}
 	for _, r := range resources {
 		check.InPlan(test.PlanStruct).That(r).Exists().ErrorIsNil(t)
@@ -287,6 +326,16 @@ func TestDeployVirtualNetworkValidVhubConnection(t *testing.T) {
 	}
 	defer test.DestroyRetry(rty) //nolint:errcheck
 	test.ApplyIdempotent().ErrorIsNil(t)
+
+	// check the hub connection routing of each virtual network
+	ctx, cancel := azureutils.NewTestContext(t)
+	defer cancel()
+	for k, vnet := range v["virtual_networks"].(map[string]map[string]any) {
+		diff, err := azureutils.VerifyVirtualHubConnection(ctx, subID, vnet)
+		require.NoErrorf(t, err, "cannot verify hub connection of virtual network %s", k)
+		assert.Emptyf(t, diff, "hub connection of virtual network %s differs from the input:\n%s", k, diff)
+	}
+
 }
 
 // TestDeployVirtualNetworkValidVhubConnectionAndRoutingIntent tests the deployment of a virtual network
@@ -294,14 +343,17 @@ func TestDeployVirtualNetworkValidVhubConnection(t *testing.T) {
 func TestDeployVirtualNetworkValidVhubConnectionAndRoutingIntent(t *testing.T) {
 
 	utils.PreCheckDeployTests(t)
-	testDir := "testdata/" + t.Name()
 	v, err := getValidInputVariables()
 	require.NoErrorf(t, err, "could not generate valid input variables, %s", err)
 
 	SetupResourceGroups(t, v["virtual_networks"].(map[string]map[string]any), v["subscription_id"].(string))
+	subID := uuid.MustParse(v["subscription_id"].(string))
+	hub := routingIntentVirtualHub(t, subID)
 
 	primaryvnet := v["virtual_networks"].(map[string]map[string]any)["primary"]
 	secondaryvnet := v["virtual_networks"].(map[string]map[string]any)["secondary"]
+	primaryvnet["vwan_hub_resource_id"] = hub.ID
+	secondaryvnet["vwan_hub_resource_id"] = hub.ID
 	primaryvnet["vwan_connection_enabled"] = true
 	secondaryvnet["vwan_connection_enabled"] = true
 	primaryvnet["vwan_security_configuration"] = map[string]any{
@@ -311,9 +363,7 @@ func TestDeployVirtualNetworkValidVhubConnectionAndRoutingIntent(t *testing.T) {
 		"routing_intent_enabled": true,
 	}
 
-	test, err := setuptest.Dirs(moduleDir, testDir).WithVars(v).Init(t)
-	require.NoError(t, utils.AzureRmAndRequiredProviders(test))
-
+	test, err := setuptest.Dirs(moduleDir, "").WithVars(v).InitPlanShowWithPrepFunc(t, utils.AzureRmAndRequiredProviders)
 	require.NoError(t, err)
 	defer test.Cleanup()
 
@@ -328,6 +378,16 @@ func TestDeployVirtualNetworkValidVhubConnectionAndRoutingIntent(t *testing.T) {
 	}
 	defer test.DestroyRetry(rtyDestroy) //nolint:errcheck
 	test.ApplyIdempotentRetry(rtyApply).ErrorIsNil(t)
+
+	// check the hub connection routing of each virtual network
+	ctx, cancel := azureutils.NewTestContext(t)
+	defer cancel()
+	for k, vnet := range v["virtual_networks"].(map[string]map[string]any) {
+		diff, err := azureutils.VerifyVirtualHubConnection(ctx, subID, vnet)
+		require.NoErrorf(t, err, "cannot verify hub connection of virtual network %s", k)
+		assert.Emptyf(t, diff, "hub connection of virtual network %s differs from the input:\n%s", k, diff)
+	}
+
 }
 
 // TestDeployVirtualNetworkSubnetIdempotency tests that we can make changes
@@ -358,7 +418,9 @@ func TestDeployVirtualNetworkSubnetIdempotency(t *testing.T) {
 	_, err = terraform.ApplyAndIdempotentE(t, test.Options)
 	assert.NoError(t, err)
 	name := primaryvnet["name"].(string)
-	subnets, err := azureutils.ListSubnets(name, name, uuid.MustParse(os.Getenv("AZURE_SUBSCRIPTION_ID")))
+	ctx, cancel := azureutils.NewTestContext(t)
+	defer cancel()
+	subnets, err := azureutils.ListSubnets(ctx, name, name, uuid.MustParse(os.Getenv("AZURE_SUBSCRIPTION_ID")))
 	require.NoErrorf(t, err, "failed to list subnets")
 	assert.Lenf(t, subnets, 1, "expected 1 subnet, got %d", len(subnets))
 }
@@ -398,6 +460,14 @@ func TestDeployVirtualNetworkValidMeshPeering(t *testing.T) {
 	// defer terraform destroy with retry
 	defer test.DestroyRetry(setuptest.DefaultRetry) //nolint:errcheck
 	test.ApplyIdempotent().ErrorIsNil(t)
+
+	// check that both sides of each mesh peering are connected
+	ctx, cancel := azureutils.NewTestContext(t)
+	defer cancel()
+	diff, err := azureutils.VerifyMeshPeering(ctx, uuid.MustParse(v["subscription_id"].(string)), v["virtual_networks"].(map[string]map[string]any))
+	require.NoError(t, err, "cannot verify mesh peering")
+	assert.Emptyf(t, diff, "mesh peering differs from the input:\n%s", diff)
+
 }
 
 func SetupResourceGroups(t *testing.T, vnets map[string]map[string]any, subscriptionID string) {
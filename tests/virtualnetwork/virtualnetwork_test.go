@@ -5,6 +5,7 @@ import (
 	"reflect"
 	"testing"
 
+	"github.com/Azure/terraform-azurerm-lz-vending/tests/inputs"
 	"github.com/Azure/terraform-azurerm-lz-vending/tests/utils"
 	"github.com/Azure/terratest-terraform-fluent/check"
 	"github.com/Azure/terratest-terraform-fluent/setuptest"
@@ -96,14 +97,14 @@ func TestVirtualNetworkCreateValidWithTags(t *testing.T) {
 // creates two virtual networks in the specified resource groups with mesh peering.
 func TestVirtualNetworkCreateValidWithMeshPeering(t *testing.T) {
 
-	v := getMockInputVariables()
-	primaryvnet := v["virtual_networks"].(map[string]map[string]any)["primary"]
-	secondaryvnet := v["virtual_networks"].(map[string]map[string]any)["secondary"]
-	primaryvnet["mesh_peering_enabled"] = true
-	secondaryvnet["mesh_peering_enabled"] = true
-	secondaryvnet["mesh_peering_allow_forwarded_traffic"] = true
+	v := getMockInputs()
+	primaryvnet, secondaryvnet := v.VirtualNetworks["primary"], v.VirtualNetworks["secondary"]
+	primaryvnet.MeshPeeringEnabled = inputs.Ptr(true)
+	secondaryvnet.MeshPeeringEnabled = inputs.Ptr(true)
+	secondaryvnet.MeshPeeringAllowForwardedTraffic = inputs.Ptr(true)
+	v.VirtualNetworks["primary"], v.VirtualNetworks["secondary"] = primaryvnet, secondaryvnet
 
-	test, err := setuptest.Dirs(moduleDir, "").WithVars(v).InitPlanShowWithPrepFunc(t, utils.AzureRmAndRequiredProviders)
+	test, err := setuptest.Dirs(moduleDir, "").WithVars(v.Vars()).InitPlanShowWithPrepFunc(t, utils.AzureRmAndRequiredProviders)
 	require.NoError(t, err)
 	defer test.Cleanup()
 
@@ -1206,25 +1207,26 @@ func TestVirtualNetworkCreateValidWithOnlyFromHubPeering(t *testing.T) {
 // with useRemoteGateways disabled.
 func TestVirtualNetworkCreateValidWithPeeringUseCustomOptions(t *testing.T) {
 
-	v := getMockInputVariables()
+	v := getMockInputs()
 	// Enable hub network peering to primary vnet in test mock input variables
-	primaryvnet := v["virtual_networks"].(map[string]map[string]any)["primary"]
-	primaryvnet["hub_network_resource_id"] = "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/testrg/providers/Microsoft.Network/virtualNetworks/testvnet2"
-	primaryvnet["hub_peering_enabled"] = true
-	primaryvnet["hub_peering_options_tohub"] = map[string]any{
-		"allow_forwarded_traffic":      false,
-		"allow_virtual_network_access": false,
-		"allow_gateway_transit":        false,
-		"use_remote_gateways":          false,
-	}
-	primaryvnet["hub_peering_options_fromhub"] = map[string]any{
-		"allow_forwarded_traffic":      true,
-		"allow_virtual_network_access": true,
-		"allow_gateway_transit":        true,
-		"use_remote_gateways":          true,
-	}
-
-	test, err := setuptest.Dirs(moduleDir, "").WithVars(v).InitPlanShowWithPrepFunc(t, utils.AzureRmAndRequiredProviders)
+	primaryvnet := v.VirtualNetworks["primary"]
+	primaryvnet.HubNetworkResourceID = inputs.Ptr("/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/testrg/providers/Microsoft.Network/virtualNetworks/testvnet2")
+	primaryvnet.HubPeeringEnabled = inputs.Ptr(true)
+	primaryvnet.HubPeeringOptionsTohub = &inputs.VirtualNetworkVirtualNetworkHubPeeringOptionsTohub{
+		AllowForwardedTraffic:     inputs.Ptr(false),
+		AllowVirtualNetworkAccess: inputs.Ptr(false),
+		AllowGatewayTransit:       inputs.Ptr(false),
+		UseRemoteGateways:         inputs.Ptr(false),
+	}
+	primaryvnet.HubPeeringOptionsFromhub = &inputs.VirtualNetworkVirtualNetworkHubPeeringOptionsFromhub{
+		AllowForwardedTraffic:     inputs.Ptr(true),
+		AllowVirtualNetworkAccess: inputs.Ptr(true),
+		AllowGatewayTransit:       inputs.Ptr(true),
+		UseRemoteGateways:         inputs.Ptr(true),
+	}
+	v.VirtualNetworks["primary"] = primaryvnet
+
+	test, err := setuptest.Dirs(moduleDir, "").WithVars(v.Vars()).InitPlanShowWithPrepFunc(t, utils.AzureRmAndRequiredProviders)
 	require.NoError(t, err)
 	defer test.Cleanup()
 
@@ -1642,3 +1644,24 @@ func getMockInputVariables() map[string]any {
 		},
 	}
 }
+
+// getMockInputs returns the same variables as getMockInputVariables, typed.
+func getMockInputs() *inputs.VirtualNetworkVariables {
+	return inputs.NewVirtualNetworkVariables().
+		WithSubscriptionID("00000000-0000-0000-0000-000000000000").
+		WithEnableTelemetry(false).
+		WithVirtualNetworks(map[string]inputs.VirtualNetworkVirtualNetwork{
+			"primary": {
+				Name:              "primary-vnet",
+				AddressSpace:      []string{"192.168.0.0/24"},
+				Location:          inputs.Ptr("westeurope"),
+				ResourceGroupName: "primary-rg",
+			},
+			"secondary": {
+				Name:              "secondary-vnet",
+				AddressSpace:      []string{"192.168.1.0/24"},
+				Location:          inputs.Ptr("northeurope"),
+				ResourceGroupName: "secondary-rg",
+			},
+		})
+}